@@ -5,6 +5,9 @@ export const getToken = () => localStorage.getItem('token')
 export const setToken = (token) => localStorage.setItem('token', token)
 export const removeToken = () => localStorage.removeItem('token')
 
+// Extracts the message from an error envelope {error: {code, message}}
+export const errorMessage = (data, fallback) => data?.error?.message || data?.error || fallback
+
 // Helper for API calls with auth
 const apiCall = async (endpoint, options = {}) => {
   const token = getToken()
@@ -24,7 +27,7 @@ const apiCall = async (endpoint, options = {}) => {
 
   if (!response.ok) {
     const error = await response.json().catch(() => ({ error: 'Network error' }))
-    throw new Error(error.error || `HTTP ${response.status}`)
+    throw new Error(errorMessage(error, `HTTP ${response.status}`))
   }
 
   return response.json()
@@ -100,7 +103,7 @@ export const botsAPI = {
 
     if (!response.ok) {
       const error = await response.json().catch(() => ({ error: 'Upload failed' }))
-      throw new Error(error.error || `HTTP ${response.status}`)
+      throw new Error(errorMessage(error, `HTTP ${response.status}`))
     }
 
     return response.json()
@@ -32,7 +32,7 @@ const Auth = ({ onLogin }) => {
       const data = await response.json()
 
       if (!response.ok) {
-        throw new Error(data.error || 'Authentication failed')
+        throw new Error(data.error?.message || data.error || 'Authentication failed')
       }
 
       // Save token and user info
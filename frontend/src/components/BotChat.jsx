@@ -29,11 +29,13 @@ function BotChat({ bot, token, onBack }) {
 
       if (response.ok) {
         const data = await response.json()
-        setUploadStatus(`✓ Uploaded: ${data.file_name}`)
+        setUploadStatus(data.warning
+          ? `✓ Uploaded: ${data.file_name} (⚠ ${data.warning})`
+          : `✓ Uploaded: ${data.file_name}`)
         setTimeout(() => setUploadStatus(''), 3000)
       } else {
         const error = await response.json()
-        setUploadStatus(`✗ Error: ${error.error}`)
+        setUploadStatus(`✗ Error: ${error.error?.message || error.error}`)
       }
     } catch (err) {
       setUploadStatus('✗ Upload failed')
@@ -170,7 +172,7 @@ function BotChat({ bot, token, onBack }) {
           <label className="upload-btn">
             <Upload size={20} />
             Upload Document
-            <input type="file" onChange={handleFileUpload} accept=".pdf,.txt,.docx,.csv,.json,.md,.html" hidden />
+            <input type="file" onChange={handleFileUpload} accept=".pdf,.txt,.docx,.odt,.rtf,.epub,.csv,.json,.md,.html" hidden />
           </label>
           {uploadStatus && <span className="upload-status">{uploadStatus}</span>}
         </div>
@@ -60,8 +60,8 @@ function BotForm({ token, bot, onSave, onCancel }) {
   const uploadDocuments = async (botId) => {
     if (files.length === 0) return true
 
-    const MAX_FILE_SIZE = 100 * 1024 * 1024 // 100MB
-    const allowedExtensions = ['.pdf', '.txt', '.docx', '.doc', '.csv', '.xlsx', '.json', '.md', '.html']
+    const MAX_FILE_SIZE = 50 * 1024 * 1024 // 50MB, matches backend MAX_UPLOAD_BYTES
+    const allowedExtensions = ['.pdf', '.txt', '.docx', '.odt', '.rtf', '.epub', '.csv', '.xlsx', '.json', '.md', '.html', '.htm']
 
     setUploadProgress('Uploading documents...')
     
@@ -71,7 +71,7 @@ function BotForm({ token, bot, onSave, onCancel }) {
 
       // Client-side validation mirrors backend limits for clearer errors
       if (file.size > MAX_FILE_SIZE) {
-        setError(`File ${file.name} is too large (max 100MB)`) 
+        setError(`File ${file.name} is too large (max 50MB)`) 
         return false
       }
 
@@ -100,7 +100,7 @@ function BotForm({ token, bot, onSave, onCancel }) {
             let message = response.statusText
             try {
               const data = await response.json()
-              message = data?.error || data?.message || message
+              message = data?.error?.message || data?.error || data?.message || message
             } catch (jsonErr) {
               console.error('Failed to parse upload error response', jsonErr)
             }
@@ -152,7 +152,7 @@ function BotForm({ token, bot, onSave, onCancel }) {
         onSave()
       } else {
         const data = await response.json()
-        setError(data.error || 'Failed to save bot')
+        setError(data.error?.message || data.error || 'Failed to save bot')
       }
     } catch (err) {
       setError('Network error')
@@ -325,7 +325,7 @@ function BotForm({ token, bot, onSave, onCancel }) {
                 type="file"
                 id="file-upload"
                 multiple
-                accept=".pdf,.txt,.docx,.doc,.csv,.xlsx,.json,.md,.html"
+                accept=".pdf,.txt,.docx,.odt,.rtf,.epub,.csv,.xlsx,.json,.md,.html,.htm"
                 onChange={handleFileChange}
                 disabled={isLoading}
                 style={{ display: 'none' }}
@@ -333,7 +333,7 @@ function BotForm({ token, bot, onSave, onCancel }) {
               <label htmlFor="file-upload" className="upload-label">
                 <Upload size={32} />
                 <span>Click to upload or drag and drop</span>
-                <small>PDF, TXT, DOCX, CSV, XLSX, JSON, MD, HTML (max 100MB each)</small>
+                <small>PDF, TXT, DOCX, ODT, RTF, EPUB, CSV, XLSX, JSON, MD, HTML (max 50MB each)</small>
               </label>
             </div>
 
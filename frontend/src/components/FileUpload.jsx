@@ -56,14 +56,16 @@ function FileUpload({ clientId }) {
 
       if (!response.ok) {
         const error = await response.json()
-        throw new Error(error.error || 'Upload failed')
+        throw new Error(error.error?.message || error.error || 'Upload failed')
       }
 
       const result = await response.json()
       const name = result.file_name || 'файл'
       setStatus({
         type: 'success',
-        message: `✅ Загружено: ${name}`
+        message: result.warning
+          ? `✅ Загружено: ${name} (⚠️ ${result.warning})`
+          : `✅ Загружено: ${name}`
       })
     } catch (error) {
       setStatus({
@@ -96,7 +98,7 @@ function FileUpload({ clientId }) {
           ref={fileInputRef}
           type="file"
           onChange={handleFileSelect}
-          accept=".pdf,.txt,.docx,.xlsx,.csv,.json,.html,.md"
+          accept=".pdf,.txt,.docx,.odt,.rtf,.epub,.xlsx,.csv,.json,.html,.md"
           style={{ display: 'none' }}
         />
         
@@ -109,7 +111,7 @@ function FileUpload({ clientId }) {
           <>
             <File className="upload-icon" size={48} />
             <p>Перетащите файл или кликните</p>
-            <span className="upload-hint">PDF, TXT, DOCX, Excel, CSV, JSON, HTML, MD</span>
+            <span className="upload-hint">PDF, TXT, DOCX, ODT, RTF, EPUB, Excel, CSV, JSON, HTML, MD</span>
           </>
         )}
       </div>
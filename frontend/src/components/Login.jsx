@@ -41,7 +41,7 @@ function Login({ onLoginSuccess }) {
         console.log('Calling onLoginSuccess')
         onLoginSuccess(data.token, data.user)
       } else {
-        setError(data.error || 'Authentication failed')
+        setError(data.error?.message || data.error || 'Authentication failed')
       }
     } catch (err) {
       setError('Network error. Please try again.')
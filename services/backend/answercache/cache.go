@@ -0,0 +1,112 @@
+package answercache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Entry is a generated answer with the documents it was generated from
+type Entry struct {
+	Answer  string
+	Docs    []string
+	Sources []string
+}
+
+type item struct {
+	key     string
+	botID   string
+	entry   Entry
+	expires time.Time
+}
+
+// Cache is an in-memory LRU of answers with a TTL, keyed per bot.
+// A nil *Cache is a disabled cache: Get always misses and Set does nothing.
+type Cache struct {
+	mu    sync.Mutex
+	size  int
+	ttl   time.Duration
+	items map[string]*list.Element
+	order *list.List // Front is the most recently used
+}
+
+// New creates a cache holding up to size answers for ttl; it returns nil (disabled) when size <= 0
+func New(size int, ttl time.Duration) *Cache {
+	if size <= 0 {
+		return nil
+	}
+	return &Cache{
+		size:  size,
+		ttl:   ttl,
+		items: make(map[string]*list.Element, size),
+		order: list.New(),
+	}
+}
+
+// Get returns the bot's cached answer for key if it has not expired
+func (c *Cache) Get(botID, key string) (Entry, bool) {
+	if c == nil {
+		return Entry{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[botID+"\x00"+key]
+	if !ok {
+		return Entry{}, false
+	}
+	it := el.Value.(*item)
+	if time.Now().After(it.expires) {
+		c.remove(el)
+		return Entry{}, false
+	}
+	c.order.MoveToFront(el)
+	return it.entry, true
+}
+
+// Set stores an answer for the bot, evicting the least recently used answers when full
+func (c *Cache) Set(botID, key string, entry Entry) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	fullKey := botID + "\x00" + key
+	if el, ok := c.items[fullKey]; ok {
+		it := el.Value.(*item)
+		it.entry, it.expires = entry, time.Now().Add(c.ttl)
+		c.order.MoveToFront(el)
+		return
+	}
+	c.items[fullKey] = c.order.PushFront(&item{key: fullKey, botID: botID, entry: entry, expires: time.Now().Add(c.ttl)})
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+}
+
+// InvalidateBot drops all cached answers of a bot and returns how many were dropped
+func (c *Cache) InvalidateBot(botID string) int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	dropped := 0
+	for el := c.order.Front(); el != nil; {
+		next := el.Next()
+		if el.Value.(*item).botID == botID {
+			c.remove(el)
+			dropped++
+		}
+		el = next
+	}
+	return dropped
+}
+
+// remove deletes an element; the caller holds the lock
+func (c *Cache) remove(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.items, el.Value.(*item).key)
+}
@@ -0,0 +1,131 @@
+package apierror
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Code is a stable machine-readable error identifier that clients can branch on
+type Code string
+
+const (
+	CodeBadRequest         Code = "BAD_REQUEST"
+	CodeInvalidBody        Code = "INVALID_REQUEST_BODY"
+	CodeValidationFailed   Code = "VALIDATION_FAILED"
+	CodeUnauthorized       Code = "UNAUTHORIZED"
+	CodeInvalidToken       Code = "INVALID_TOKEN"
+	CodeInvalidCredentials Code = "INVALID_CREDENTIALS"
+	CodeAccountLocked      Code = "ACCOUNT_LOCKED"
+	CodeForbidden          Code = "FORBIDDEN"
+	CodeNotFound           Code = "NOT_FOUND"
+	CodeBotNotFound        Code = "BOT_NOT_FOUND"
+	CodeUserNotFound       Code = "USER_NOT_FOUND"
+	CodeConflict           Code = "CONFLICT"
+	CodeEmailTaken         Code = "EMAIL_ALREADY_EXISTS"
+	CodeRateLimited        Code = "RATE_LIMITED"
+	CodeQuotaExceeded      Code = "QUOTA_EXCEEDED"
+	CodePayloadTooLarge    Code = "PAYLOAD_TOO_LARGE"
+	CodeFileTooLarge       Code = "FILE_TOO_LARGE"
+	CodeUnsupportedFile    Code = "UNSUPPORTED_FILE_TYPE"
+	CodeParseFailed        Code = "PARSE_FAILED"
+	CodeEmptyDocument      Code = "EMPTY_DOCUMENT"
+	CodeTooManyChunks      Code = "TOO_MANY_CHUNKS"
+	CodeSensitiveContent   Code = "SENSITIVE_CONTENT"
+	CodeQueryRejected      Code = "QUERY_REJECTED"
+	CodeEmbeddingFailed    Code = "EMBEDDING_FAILED"
+	CodeVectorDBFailed     Code = "VECTOR_DB_FAILED"
+	CodeImportFailed       Code = "IMPORT_FAILED"
+	CodeNotImplemented     Code = "NOT_IMPLEMENTED"
+	CodeUpstreamFailed     Code = "UPSTREAM_FAILED"
+	CodeUnavailable        Code = "SERVICE_UNAVAILABLE"
+	CodeInternal           Code = "INTERNAL_ERROR"
+)
+
+// Error is the body of the error envelope. It also implements error, so helpers can
+// return it from handlers and let ErrorHandler render it with its HTTP status.
+type Error struct {
+	Status  int    `json:"-"`
+	Code    Code   `json:"code"`
+	Message string `json:"message"`
+	Details any    `json:"details,omitempty"`
+}
+
+// New creates an error that renders as an envelope with the given HTTP status
+func New(status int, code Code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+// Error implements the error interface
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Response is the standard error envelope: {"error": {"code", "message", "details"}}
+type Response struct {
+	Error Error `json:"error"`
+}
+
+// Send writes an error envelope with the given HTTP status
+func Send(c *fiber.Ctx, status int, code Code, message string) error {
+	return SendWithDetails(c, status, code, message, nil)
+}
+
+// SendWithDetails writes an error envelope carrying extra structured details (e.g. field errors)
+func SendWithDetails(c *fiber.Ctx, status int, code Code, message string, details any) error {
+	return c.Status(status).JSON(Response{
+		Error: Error{
+			Code:    code,
+			Message: message,
+			Details: details,
+		},
+	})
+}
+
+// CodeForStatus returns the generic code for an HTTP status
+func CodeForStatus(status int) Code {
+	switch status {
+	case fiber.StatusBadRequest:
+		return CodeBadRequest
+	case fiber.StatusUnauthorized:
+		return CodeUnauthorized
+	case fiber.StatusForbidden:
+		return CodeForbidden
+	case fiber.StatusNotFound:
+		return CodeNotFound
+	case fiber.StatusConflict:
+		return CodeConflict
+	case fiber.StatusRequestEntityTooLarge:
+		return CodePayloadTooLarge
+	case fiber.StatusTooManyRequests:
+		return CodeRateLimited
+	case fiber.StatusNotImplemented:
+		return CodeNotImplemented
+	case fiber.StatusBadGateway:
+		return CodeUpstreamFailed
+	case fiber.StatusServiceUnavailable:
+		return CodeUnavailable
+	default:
+		return CodeInternal
+	}
+}
+
+// ErrorHandler is a Fiber error handler that renders errors returned from handlers and
+// framework errors (unknown route, body limit, ...) in the standard envelope
+func ErrorHandler(c *fiber.Ctx, err error) error {
+	status := fiber.StatusInternalServerError
+	message := "internal server error"
+
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return SendWithDetails(c, apiErr.Status, apiErr.Code, apiErr.Message, apiErr.Details)
+	}
+
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		status = fiberErr.Code
+		message = fiberErr.Message
+	}
+
+	return Send(c, status, CodeForStatus(status), message)
+}
@@ -0,0 +1,122 @@
+package audit
+
+import (
+	"backend/database"
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Actions recorded in the audit log
+const (
+	ActionBotCreate      = "bot.create"
+	ActionBotUpdate      = "bot.update"
+	ActionBotDelete      = "bot.delete"
+	ActionDocumentUpload = "document.upload"
+	ActionBotReembed     = "bot.reembed"
+)
+
+// Target types of audit log entries
+const (
+	TargetBot = "bot"
+)
+
+// queueSize bounds entries waiting to be written; entries beyond it are dropped with a log line
+const queueSize = 1024
+
+// Logger writes audit log entries asynchronously, so recording never slows down the request being audited
+type Logger struct {
+	repo     *database.AuditRepository
+	queue    chan database.AuditLog
+	done     chan struct{}
+	wg       sync.WaitGroup
+	stopOnce sync.Once
+}
+
+// NewLogger creates a logger; call Start to launch the writer
+func NewLogger(repo *database.AuditRepository) *Logger {
+	return &Logger{
+		repo:  repo,
+		queue: make(chan database.AuditLog, queueSize),
+		done:  make(chan struct{}),
+	}
+}
+
+// Start launches the writer
+func (l *Logger) Start() {
+	l.wg.Add(1)
+	go l.worker()
+}
+
+// Stop stops accepting entries and waits until the queued ones are written or ctx expires
+func (l *Logger) Stop(ctx context.Context) {
+	l.stopOnce.Do(func() { close(l.done) })
+
+	finished := make(chan struct{})
+	go func() {
+		l.wg.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-ctx.Done():
+		log.Printf("[audit] Stop timed out with %d entries unwritten", len(l.queue))
+	}
+}
+
+// Record queues an entry stamped with the current time. It never blocks the caller;
+// a nil logger ignores entries.
+func (l *Logger) Record(actorID uint, action, targetType, targetID, ip string, details map[string]any) {
+	if l == nil {
+		return
+	}
+	entry := database.AuditLog{
+		ActorID:    actorID,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Details:    details,
+		IP:         ip,
+		CreatedAt:  time.Now().UTC(),
+	}
+
+	select {
+	case <-l.done:
+		log.Printf("[audit] Logger stopped, dropping %s of %s %s by user %d", action, targetType, targetID, actorID)
+		return
+	default:
+	}
+	select {
+	case l.queue <- entry:
+	default:
+		log.Printf("[audit] Queue full, dropping %s of %s %s by user %d", action, targetType, targetID, actorID)
+	}
+}
+
+// worker writes entries until stopped, then drains what is left in the queue
+func (l *Logger) worker() {
+	defer l.wg.Done()
+	for {
+		select {
+		case entry := <-l.queue:
+			l.write(entry)
+		case <-l.done:
+			for {
+				select {
+				case entry := <-l.queue:
+					l.write(entry)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (l *Logger) write(entry database.AuditLog) {
+	if err := l.repo.Create(&entry); err != nil {
+		log.Printf("[audit] Failed to write %s of %s %s by user %d: %v",
+			entry.Action, entry.TargetType, entry.TargetID, entry.ActorID, err)
+	}
+}
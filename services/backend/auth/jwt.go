@@ -10,46 +10,68 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+// TokenVersions looks up the current token version of a user. Tokens issued with another version are revoked.
+type TokenVersions interface {
+	TokenVersion(userID uint) (int, error)
+}
+
 // JWTService handles JWT token operations
 type JWTService struct {
 	secretKey     []byte
 	tokenDuration time.Duration
+	// versions is read on every validation, so revocation holds across restarts and replicas; nil disables it
+	versions TokenVersions
 }
 
+// User roles
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
 // Claims represents JWT claims
 type Claims struct {
-	UserID uint    `json:"user_id"`
+	UserID uint   `json:"user_id"`
 	Email  string `json:"email"`
+	Role   string `json:"role"`
+	// Token version of the user when the token was issued; tokens from before versions existed carry 0
+	TokenVersion int `json:"ver"`
 	jwt.RegisteredClaims
 }
 
 // NewJWTService creates a new JWT service
-func NewJWTService(secretKey string, tokenDuration time.Duration) *JWTService {
+func NewJWTService(secretKey string, tokenDuration time.Duration, versions TokenVersions) *JWTService {
 	return &JWTService{
 		secretKey:     []byte(secretKey),
 		tokenDuration: tokenDuration,
+		versions:      versions,
 	}
 }
 
-// GenerateToken generates a new JWT token for a user
-func (s *JWTService) GenerateToken(userID uint, email string) (string, error) {
+// GenerateToken generates a new JWT token for a user with the user's current token version
+// and returns it with its expiry time
+func (s *JWTService) GenerateToken(userID uint, email, role string, tokenVersion int) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(s.tokenDuration)
 	claims := Claims{
-		UserID: userID,
-		Email:  email,
+		UserID:       userID,
+		Email:        email,
+		Role:         role,
+		TokenVersion: tokenVersion,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(s.tokenDuration)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
 		},
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	tokenString, err := token.SignedString(s.secretKey)
 	if err != nil {
-		return "", fmt.Errorf("failed to sign token: %w", err)
+		return "", time.Time{}, fmt.Errorf("failed to sign token: %w", err)
 	}
 
-	return tokenString, nil
+	return tokenString, expiresAt, nil
 }
 
 // ValidateToken validates a JWT token and returns the claims
@@ -71,6 +93,16 @@ func (s *JWTService) ValidateToken(tokenString string) (*Claims, error) {
 		return nil, fmt.Errorf("invalid token")
 	}
 
+	if s.versions != nil {
+		version, err := s.versions.TokenVersion(claims.UserID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check token version: %w", err)
+		}
+		if claims.TokenVersion != version {
+			return nil, fmt.Errorf("token has been revoked")
+		}
+	}
+
 	return claims, nil
 }
 
@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"backend/apierror"
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
@@ -12,17 +13,13 @@ func Middleware(jwtService *JWTService) fiber.Handler {
 		// Get token from Authorization header
 		authHeader := c.Get("Authorization")
 		if authHeader == "" {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "missing authorization header",
-			})
+			return apierror.Send(c, fiber.StatusUnauthorized, apierror.CodeUnauthorized, "missing authorization header")
 		}
 
 		// Parse Bearer token
 		parts := strings.SplitN(authHeader, " ", 2)
 		if len(parts) != 2 || parts[0] != "Bearer" {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "invalid authorization header format",
-			})
+			return apierror.Send(c, fiber.StatusUnauthorized, apierror.CodeUnauthorized, "invalid authorization header format")
 		}
 
 		tokenString := parts[1]
@@ -30,14 +27,13 @@ func Middleware(jwtService *JWTService) fiber.Handler {
 		// Validate token
 		claims, err := jwtService.ValidateToken(tokenString)
 		if err != nil {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "invalid or expired token",
-			})
+			return apierror.Send(c, fiber.StatusUnauthorized, apierror.CodeInvalidToken, "invalid or expired token")
 		}
 
 		// Store user info in context
 		c.Locals("user_id", claims.UserID)
 		c.Locals("user_email", claims.Email)
+		c.Locals("user_role", claims.Role)
 
 		return c.Next()
 	}
@@ -55,6 +51,7 @@ func OptionalMiddleware(jwtService *JWTService) fiber.Handler {
 				if err == nil {
 					c.Locals("user_id", claims.UserID)
 					c.Locals("user_email", claims.Email)
+					c.Locals("user_role", claims.Role)
 				}
 			}
 		}
@@ -62,6 +59,17 @@ func OptionalMiddleware(jwtService *JWTService) fiber.Handler {
 	}
 }
 
+// AdminMiddleware restricts access to users with the admin role.
+// Must be used after Middleware so the role from the token is available.
+func AdminMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if role, _ := GetUserRole(c); role != RoleAdmin {
+			return apierror.Send(c, fiber.StatusForbidden, apierror.CodeForbidden, "admin access required")
+		}
+		return c.Next()
+	}
+}
+
 // GetUserID extracts user ID from context
 func GetUserID(c *fiber.Ctx) (uint, bool) {
 	userID, ok := c.Locals("user_id").(uint)
@@ -73,3 +81,9 @@ func GetUserEmail(c *fiber.Ctx) (string, bool) {
 	email, ok := c.Locals("user_email").(string)
 	return email, ok
 }
+
+// GetUserRole extracts user role from context
+func GetUserRole(c *fiber.Ctx) (string, bool) {
+	role, ok := c.Locals("user_role").(string)
+	return role, ok
+}
@@ -0,0 +1,46 @@
+package bodylimit
+
+import (
+	"backend/apierror"
+	"backend/utils"
+	"fmt"
+	"io"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// New returns middleware that rejects request bodies larger than limit bytes with 413.
+// The server-wide BodyLimit has to admit uploads; routes taking small JSON bodies use this tighter cap.
+// Bodies are streamed (StreamRequestBody), so a declared Content-Length is checked before anything is read,
+// and a chunked body is buffered only up to the limit. next, when set, exempts requests it returns true for.
+func New(limit int, next func(c *fiber.Ctx) bool) fiber.Handler {
+	message := fmt.Sprintf("request body too large (max %s)", utils.FormatBytes(int64(limit)))
+	tooLarge := func(c *fiber.Ctx) error {
+		// The rest of the body is left unread, so the connection can't carry another request
+		c.Context().SetConnectionClose()
+		return apierror.Send(c, fiber.StatusRequestEntityTooLarge, apierror.CodePayloadTooLarge, message)
+	}
+	return func(c *fiber.Ctx) error {
+		if next != nil && next(c) {
+			return c.Next()
+		}
+
+		req := c.Request()
+		length := req.Header.ContentLength()
+		if length > limit {
+			return tooLarge(c)
+		}
+		// No Content-Length (chunked): read at most one byte past the limit to find out
+		if stream := req.BodyStream(); stream != nil && length < 0 {
+			body, err := io.ReadAll(io.LimitReader(stream, int64(limit)+1))
+			if err != nil {
+				return apierror.Send(c, fiber.StatusBadRequest, apierror.CodeInvalidBody, "failed to read request body")
+			}
+			if len(body) > limit {
+				return tooLarge(c)
+			}
+			req.SetBody(body)
+		}
+		return c.Next()
+	}
+}
@@ -0,0 +1,77 @@
+package clients
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"sync"
+)
+
+// embeddingKey identifies a text embedded by a model in query or passage mode (the AI service prefixes them differently)
+// with the prefixing forced on, off or left to the AI service
+type embeddingKey [sha256.Size]byte
+
+func newEmbeddingKey(aiURL, model string, usePrefix *bool, text string, isQuery bool) embeddingKey {
+	mode := "passage"
+	if isQuery {
+		mode = "query"
+	}
+	if usePrefix != nil {
+		if *usePrefix {
+			mode += "+prefix"
+		} else {
+			mode += "+plain"
+		}
+	}
+	return sha256.Sum256([]byte(aiURL + "\x00" + model + "\x00" + mode + "\x00" + text))
+}
+
+type embeddingEntry struct {
+	key    embeddingKey
+	vector []float32
+}
+
+// embeddingCache is a bounded LRU of embeddings keyed by content hash. Cached vectors are shared,
+// so callers must not modify them.
+type embeddingCache struct {
+	mu    sync.Mutex
+	size  int
+	items map[embeddingKey]*list.Element
+	order *list.List // Front is the most recently used
+}
+
+func newEmbeddingCache(size int) *embeddingCache {
+	return &embeddingCache{
+		size:  size,
+		items: make(map[embeddingKey]*list.Element, size),
+		order: list.New(),
+	}
+}
+
+func (c *embeddingCache) get(key embeddingKey) ([]float32, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*embeddingEntry).vector, true
+}
+
+func (c *embeddingCache) set(key embeddingKey, vector []float32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		el.Value.(*embeddingEntry).vector = vector
+		c.order.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.order.PushFront(&embeddingEntry{key: key, vector: vector})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*embeddingEntry).key)
+	}
+}
@@ -3,51 +3,92 @@ package clients
 import (
 	"backend/models"
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // Client handles external service communication
 type Client struct {
-	httpClient *http.Client
+	httpClient  *http.Client
+	callTimeout time.Duration   // Bounds each short call; 0 = no limit
+	embeddings  *embeddingCache // nil when embedding caching is disabled
 }
 
-// NewClient creates a new service client
-func NewClient(httpClient *http.Client) *Client {
-	return &Client{
-		httpClient: httpClient,
+// NewClient creates a new service client. callTimeout bounds parse, embedding, vector DB and messenger
+// calls; streaming generation is bounded only by its caller's context, so httpClient should have no Timeout.
+// With embeddingCacheSize > 0, up to that many embeddings are cached by text so that repeated queries
+// and re-uploaded documents are not embedded again.
+func NewClient(httpClient *http.Client, callTimeout time.Duration, embeddingCacheSize int) *Client {
+	c := &Client{
+		httpClient:  httpClient,
+		callTimeout: callTimeout,
 	}
-}
-
-// ParseDocument calls the document parser service
-func (c *Client) ParseDocument(url, filename string, reader io.Reader) (*models.ParseResponse, error) {
-	body := &bytes.Buffer{}
-	writer := multipart.NewWriter(body)
-
-	part, err := writer.CreateFormFile("file", filename)
-	if err != nil {
-		return nil, fmt.Errorf("create form file: %w", err)
+	if embeddingCacheSize > 0 {
+		c.embeddings = newEmbeddingCache(embeddingCacheSize)
 	}
+	return c
+}
 
-	if _, err := io.Copy(part, reader); err != nil {
-		return nil, fmt.Errorf("copy file content: %w", err)
+// callContext derives the context of a short service call from ctx, cancelled after callTimeout
+func (c *Client) callContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.callTimeout <= 0 {
+		return context.WithCancel(ctx)
 	}
+	return context.WithTimeout(ctx, c.callTimeout)
+}
 
-	if err := writer.Close(); err != nil {
-		return nil, fmt.Errorf("close multipart writer: %w", err)
+// post sends a POST request bound to ctx
+func (c *Client) post(ctx context.Context, url, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, body)
+	if err != nil {
+		return nil, err
 	}
+	req.Header.Set("Content-Type", contentType)
+	return c.httpClient.Do(req)
+}
 
-	req, err := http.NewRequest(http.MethodPost, strings.TrimRight(url, "/")+"/parse", body)
+// get sends a GET request bound to ctx
+func (c *Client) get(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return nil, fmt.Errorf("create request: %w", err)
+		return nil, err
 	}
-	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return c.httpClient.Do(req)
+}
 
-	resp, err := c.httpClient.Do(req)
+// ParseDocument calls the document parser service. The multipart body is written through a pipe
+// while the request is sent, so the file streams from reader to the parser without an in-memory copy.
+func (c *Client) ParseDocument(ctx context.Context, url, filename string, reader io.Reader, opts models.ParseOptions) (*models.ParseResponse, error) {
+	bodyReader, bodyWriter := io.Pipe()
+	writer := multipart.NewWriter(bodyWriter)
+	writeErr := make(chan error, 1)
+	go func() {
+		err := writeParseForm(writer, filename, reader, opts)
+		bodyWriter.CloseWithError(err)
+		writeErr <- err
+	}()
+
+	ctx, cancel := c.callContext(ctx)
+	defer cancel()
+	resp, err := c.post(ctx, strings.TrimRight(url, "/")+"/parse", writer.FormDataContentType(), bodyReader)
+	// The parser may answer (e.g. 413) before reading the whole body: unblock the writer and wait for it,
+	// so reader is no longer used once ParseDocument returns
+	bodyReader.Close()
+	if werr := <-writeErr; werr != nil && !errors.Is(werr, io.ErrClosedPipe) {
+		if err == nil {
+			resp.Body.Close()
+		}
+		return nil, werr
+	}
 	if err != nil {
 		return nil, fmt.Errorf("execute request: %w", err)
 	}
@@ -66,27 +107,113 @@ func (c *Client) ParseDocument(url, filename string, reader io.Reader) (*models.
 	return &parsed, nil
 }
 
-// CreateEmbeddings calls the AI service to create passage/document embeddings.
-func (c *Client) CreateEmbeddings(aiURL string, texts []string) ([][]float32, error) {
-	return c.createEmbeddings(aiURL, texts, false)
+// writeParseForm writes the parse options and the file to the multipart body of a /parse request
+func writeParseForm(writer *multipart.Writer, filename string, reader io.Reader, opts models.ParseOptions) error {
+	if opts.JSONMode != "" {
+		if err := writer.WriteField("json_mode", opts.JSONMode); err != nil {
+			return fmt.Errorf("write form field: %w", err)
+		}
+	}
+	if opts.CSVHeader {
+		if err := writer.WriteField("csv_header", "true"); err != nil {
+			return fmt.Errorf("write form field: %w", err)
+		}
+	}
+	if opts.MarkdownSections {
+		if err := writer.WriteField("markdown_sections", "true"); err != nil {
+			return fmt.Errorf("write form field: %w", err)
+		}
+	}
+	if opts.DetectLanguage {
+		if err := writer.WriteField("detect_language", "true"); err != nil {
+			return fmt.Errorf("write form field: %w", err)
+		}
+	}
+
+	part, err := writer.CreateFormFile("file", filename)
+	if err != nil {
+		return fmt.Errorf("create form file: %w", err)
+	}
+
+	if _, err := io.Copy(part, reader); err != nil {
+		return fmt.Errorf("copy file content: %w", err)
+	}
+
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("close multipart writer: %w", err)
+	}
+	return nil
+}
+
+// CreateEmbeddings calls the AI service to create passage/document embeddings with the given model ("" = default).
+// usePrefix controls the "passage: " instruction prefix; nil lets the AI service decide by model name (e5 models).
+func (c *Client) CreateEmbeddings(ctx context.Context, aiURL, model string, usePrefix *bool, texts []string) ([][]float32, error) {
+	return c.createEmbeddings(ctx, aiURL, model, usePrefix, texts, false)
 }
 
 // CreateQueryEmbeddings calls the AI service with query mode enabled (adds query prefix for e5 models).
-func (c *Client) CreateQueryEmbeddings(aiURL string, texts []string) ([][]float32, error) {
-	return c.createEmbeddings(aiURL, texts, true)
+// usePrefix overrides the prefixing as in CreateEmbeddings.
+func (c *Client) CreateQueryEmbeddings(ctx context.Context, aiURL, model string, usePrefix *bool, texts []string) ([][]float32, error) {
+	return c.createEmbeddings(ctx, aiURL, model, usePrefix, texts, true)
 }
 
-func (c *Client) createEmbeddings(aiURL string, texts []string, isQuery bool) ([][]float32, error) {
+// createEmbeddings returns cached embeddings where available and requests only the missing texts
+func (c *Client) createEmbeddings(ctx context.Context, aiURL, model string, usePrefix *bool, texts []string, isQuery bool) ([][]float32, error) {
 	if len(texts) == 0 {
 		return nil, fmt.Errorf("texts array is empty")
 	}
+	if c.embeddings == nil {
+		return c.requestEmbeddings(ctx, aiURL, model, usePrefix, texts, isQuery)
+	}
+
+	result := make([][]float32, len(texts))
+	keys := make([]embeddingKey, len(texts))
+	missing := map[embeddingKey][]int{} // Positions of each missing text, so duplicates are embedded once
+	var missingTexts []string
+	var missingKeys []embeddingKey
+	for i, text := range texts {
+		keys[i] = newEmbeddingKey(aiURL, model, usePrefix, text, isQuery)
+		if vector, ok := c.embeddings.get(keys[i]); ok {
+			result[i] = vector
+			continue
+		}
+		if _, seen := missing[keys[i]]; !seen {
+			missingTexts = append(missingTexts, text)
+			missingKeys = append(missingKeys, keys[i])
+		}
+		missing[keys[i]] = append(missing[keys[i]], i)
+	}
+	if len(missingTexts) == 0 {
+		return result, nil
+	}
+
+	embeddings, err := c.requestEmbeddings(ctx, aiURL, model, usePrefix, missingTexts, isQuery)
+	if err != nil {
+		return nil, err
+	}
+	if len(embeddings) != len(missingTexts) {
+		return nil, fmt.Errorf("embedding count mismatch: requested %d, received %d", len(missingTexts), len(embeddings))
+	}
+	for j, vector := range embeddings {
+		c.embeddings.set(missingKeys[j], vector)
+		for _, i := range missing[missingKeys[j]] {
+			result[i] = vector
+		}
+	}
+	return result, nil
+}
 
-	reqBody, err := json.Marshal(models.EmbeddingsRequest{Texts: texts, IsQuery: isQuery})
+// requestEmbeddings calls the AI service /embeddings endpoint
+func (c *Client) requestEmbeddings(ctx context.Context, aiURL, model string, usePrefix *bool, texts []string, isQuery bool) ([][]float32, error) {
+	reqBody, err := json.Marshal(models.EmbeddingsRequest{Texts: texts, IsQuery: isQuery, Model: model, UsePrefix: usePrefix})
 	if err != nil {
 		return nil, fmt.Errorf("marshal request: %w", err)
 	}
 
-	resp, err := c.httpClient.Post(
+	ctx, cancel := c.callContext(ctx)
+	defer cancel()
+	resp, err := c.post(
+		ctx,
 		strings.TrimRight(aiURL, "/")+"/embeddings",
 		"application/json",
 		bytes.NewReader(reqBody),
@@ -113,8 +240,8 @@ func (c *Client) createEmbeddings(aiURL string, texts []string, isQuery bool) ([
 	return out.Embeddings, nil
 }
 
-// SplitDocument calls the AI service for semantic chunking
-func (c *Client) SplitDocument(aiURL string, text string, chunkSize, overlap int) ([]string, error) {
+// SplitDocument calls the AI service for semantic chunking; the response carries the chunks and their stats
+func (c *Client) SplitDocument(ctx context.Context, aiURL string, text string, chunkSize, overlap int) (*models.SplitDocumentResponse, error) {
 	if strings.TrimSpace(text) == "" {
 		return nil, fmt.Errorf("text is empty")
 	}
@@ -128,7 +255,10 @@ func (c *Client) SplitDocument(aiURL string, text string, chunkSize, overlap int
 		return nil, fmt.Errorf("marshal request: %w", err)
 	}
 
-	resp, err := c.httpClient.Post(
+	ctx, cancel := c.callContext(ctx)
+	defer cancel()
+	resp, err := c.post(
+		ctx,
 		strings.TrimRight(aiURL, "/")+"/split-document",
 		"application/json",
 		bytes.NewReader(reqBody),
@@ -152,26 +282,83 @@ func (c *Client) SplitDocument(aiURL string, text string, chunkSize, overlap int
 		return nil, fmt.Errorf("split-document returned no chunks")
 	}
 
-	return out.Chunks, nil
+	return &out, nil
 }
 
-// AddVectorDocuments adds documents to the vector database
-func (c *Client) AddVectorDocuments(vectorURL, clientID string, texts []string, embeddings [][]float32, metadata []map[string]string) error {
+// RewriteQuery calls the AI service to spell-correct and expand a search query before it is embedded
+func (c *Client) RewriteQuery(ctx context.Context, aiURL, query string) (string, error) {
+	if strings.TrimSpace(query) == "" {
+		return "", fmt.Errorf("query is empty")
+	}
+
+	reqBody, err := json.Marshal(models.RewriteQueryRequest{Query: query})
+	if err != nil {
+		return "", fmt.Errorf("marshal request: %w", err)
+	}
+
+	ctx, cancel := c.callContext(ctx)
+	defer cancel()
+	resp, err := c.post(
+		ctx,
+		strings.TrimRight(aiURL, "/")+"/rewrite-query",
+		"application/json",
+		bytes.NewReader(reqBody),
+	)
+	if err != nil {
+		return "", fmt.Errorf("execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("AI service error (status %d): %s", resp.StatusCode, string(respBody))
+	}
+
+	var out models.RewriteQueryResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", fmt.Errorf("decode response: %w", err)
+	}
+
+	if strings.TrimSpace(out.Query) == "" {
+		return "", fmt.Errorf("rewrite-query returned an empty query")
+	}
+
+	return out.Query, nil
+}
+
+// ErrEmbeddingModelMismatch is returned when a bot's vector collection was indexed with another embedding model
+var ErrEmbeddingModelMismatch = errors.New("embedding model mismatch")
+
+// vectorError describes a failed vector service response; a 409 Conflict wraps ErrEmbeddingModelMismatch
+func vectorError(resp *http.Response) error {
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode == http.StatusConflict {
+		return fmt.Errorf("%w: %s", ErrEmbeddingModelMismatch, string(respBody))
+	}
+	return fmt.Errorf("vector service error (status %d): %s", resp.StatusCode, string(respBody))
+}
+
+// AddVectorDocuments adds documents embedded with model ("" = default) to the vector database
+func (c *Client) AddVectorDocuments(ctx context.Context, vectorURL, clientID, model string, texts []string, embeddings [][]float32, metadata []map[string]string) error {
 	if len(texts) != len(embeddings) {
 		return fmt.Errorf("texts and embeddings length mismatch: %d vs %d", len(texts), len(embeddings))
 	}
 
 	reqBody, err := json.Marshal(models.VectorAddRequest{
-		BotID:      clientID,
-		Texts:      texts,
-		Embeddings: embeddings,
-		Metadata:   metadata,
+		BotID:          clientID,
+		Texts:          texts,
+		Embeddings:     embeddings,
+		Metadata:       metadata,
+		EmbeddingModel: model,
 	})
 	if err != nil {
 		return fmt.Errorf("marshal request: %w", err)
 	}
 
-	resp, err := c.httpClient.Post(
+	ctx, cancel := c.callContext(ctx)
+	defer cancel()
+	resp, err := c.post(
+		ctx,
 		strings.TrimRight(vectorURL, "/")+"/documents/add",
 		"application/json",
 		bytes.NewReader(reqBody),
@@ -182,15 +369,16 @@ func (c *Client) AddVectorDocuments(vectorURL, clientID string, texts []string,
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 300 {
-		respBody, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("vector service error (status %d): %s", resp.StatusCode, string(respBody))
+		return vectorError(resp)
 	}
 
 	return nil
 }
 
-// SearchVectorDocuments searches for similar documents in the vector database
-func (c *Client) SearchVectorDocuments(vectorURL, clientID string, queryEmbedding []float32, limit int) ([]map[string]any, error) {
+// SearchVectorDocuments searches for similar documents in the vector database; fields limits the returned payload keys (nil = all)
+// and uploaded the upload dates searched. model is the embedding model of queryEmbedding, which must match the one
+// the documents were indexed with.
+func (c *Client) SearchVectorDocuments(ctx context.Context, vectorURL, clientID, model string, queryEmbedding []float32, limit int, fields []string, uploaded models.UploadRange) ([]models.Document, error) {
 	if len(queryEmbedding) == 0 {
 		return nil, fmt.Errorf("query embedding is empty")
 	}
@@ -199,12 +387,19 @@ func (c *Client) SearchVectorDocuments(vectorURL, clientID string, queryEmbeddin
 		BotID:          clientID,
 		QueryEmbedding: queryEmbedding,
 		Limit:          limit,
+		Fields:         fields,
+		EmbeddingModel: model,
+		UploadedAfter:  uploaded.After,
+		UploadedBefore: uploaded.Before,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("marshal request: %w", err)
 	}
 
-	resp, err := c.httpClient.Post(
+	ctx, cancel := c.callContext(ctx)
+	defer cancel()
+	resp, err := c.post(
+		ctx,
 		strings.TrimRight(vectorURL, "/")+"/documents/search",
 		"application/json",
 		bytes.NewReader(reqBody),
@@ -215,8 +410,7 @@ func (c *Client) SearchVectorDocuments(vectorURL, clientID string, queryEmbeddin
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 300 {
-		respBody, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("vector service error (status %d): %s", resp.StatusCode, string(respBody))
+		return nil, vectorError(resp)
 	}
 
 	var out models.VectorSearchResponse
@@ -228,28 +422,90 @@ func (c *Client) SearchVectorDocuments(vectorURL, clientID string, queryEmbeddin
 		return nil, fmt.Errorf("vector search failed: %s", out.Error)
 	}
 
-	rawDocs, ok := out.Data["documents"].([]any)
-	if !ok {
-		return []map[string]any{}, nil
-	}
-
-	docs := make([]map[string]any, 0, len(rawDocs))
-	for _, d := range rawDocs {
-		if m, ok := d.(map[string]any); ok {
-			docs = append(docs, m)
-		}
+	if out.Data.Documents == nil {
+		return []models.Document{}, nil
 	}
-
-	return docs, nil
+	return out.Data.Documents, nil
 }
 
 // ListVectorDocuments fetches documents without similarity filtering (fallback)
-func (c *Client) ListVectorDocuments(vectorURL, clientID string, limit int) ([]map[string]any, error) {
+func (c *Client) ListVectorDocuments(ctx context.Context, vectorURL, clientID string, limit int) ([]models.Document, error) {
+	docs, _, err := c.ListVectorDocumentsPage(ctx, vectorURL, clientID, limit, "")
+	return docs, err
+}
+
+// ListVectorDocumentsPage fetches a page of documents starting at cursor ("" = the first page) and returns
+// the cursor of the next page, which is empty after the last one
+func (c *Client) ListVectorDocumentsPage(ctx context.Context, vectorURL, clientID string, limit int, cursor string) ([]models.Document, string, error) {
 	if limit <= 0 {
 		limit = 100
 	}
-	url := fmt.Sprintf("%s/documents/list/%s?limit=%d", strings.TrimRight(vectorURL, "/"), clientID, limit)
-	resp, err := c.httpClient.Get(url)
+	query := url.Values{"limit": {strconv.Itoa(limit)}}
+	if cursor != "" {
+		query.Set("cursor", cursor)
+	}
+	endpoint := fmt.Sprintf("%s/documents/list/%s?%s", strings.TrimRight(vectorURL, "/"), clientID, query.Encode())
+	ctx, cancel := c.callContext(ctx)
+	defer cancel()
+	resp, err := c.get(ctx, endpoint)
+	if err != nil {
+		return nil, "", fmt.Errorf("execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, "", fmt.Errorf("vector service error (status %d): %s", resp.StatusCode, string(respBody))
+	}
+
+	var out models.VectorListResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, "", fmt.Errorf("decode response: %w", err)
+	}
+
+	if !out.Success {
+		return nil, "", fmt.Errorf("vector list failed: %s", out.Error)
+	}
+
+	if out.Data.Documents == nil {
+		return []models.Document{}, out.Data.NextCursor, nil
+	}
+	return out.Data.Documents, out.Data.NextCursor, nil
+}
+
+// GetVectorStats returns the number of indexed chunks for a bot
+func (c *Client) GetVectorStats(ctx context.Context, vectorURL, clientID string) (int, error) {
+	url := fmt.Sprintf("%s/documents/stats/%s", strings.TrimRight(vectorURL, "/"), clientID)
+	ctx, cancel := c.callContext(ctx)
+	defer cancel()
+	resp, err := c.get(ctx, url)
+	if err != nil {
+		return 0, fmt.Errorf("execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return 0, fmt.Errorf("vector service error (status %d): %s", resp.StatusCode, string(respBody))
+	}
+
+	var out models.VectorStatsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return 0, fmt.Errorf("decode response: %w", err)
+	}
+
+	if !out.Success {
+		return 0, fmt.Errorf("vector stats failed: %s", out.Error)
+	}
+
+	return out.TotalDocuments, nil
+}
+
+// GetSupportedFormats returns the file extensions the document parser can handle
+func (c *Client) GetSupportedFormats(ctx context.Context, docParserURL string) ([]string, error) {
+	ctx, cancel := c.callContext(ctx)
+	defer cancel()
+	resp, err := c.get(ctx, strings.TrimRight(docParserURL, "/")+"/formats")
 	if err != nil {
 		return nil, fmt.Errorf("execute request: %w", err)
 	}
@@ -257,45 +513,218 @@ func (c *Client) ListVectorDocuments(vectorURL, clientID string, limit int) ([]m
 
 	if resp.StatusCode >= 300 {
 		respBody, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("vector service error (status %d): %s", resp.StatusCode, string(respBody))
+		return nil, fmt.Errorf("parser service error (status %d): %s", resp.StatusCode, string(respBody))
 	}
 
-	var out models.VectorSearchResponse
+	var out models.FormatsResponse
 	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
 		return nil, fmt.Errorf("decode response: %w", err)
 	}
 
-	if !out.Success {
-		return nil, fmt.Errorf("vector list failed: %s", out.Error)
+	return out.Formats, nil
+}
+
+// EnsureVectorCollection creates the bot's vector collection if it does not exist yet
+func (c *Client) EnsureVectorCollection(ctx context.Context, vectorURL, botID string) error {
+	body, err := json.Marshal(map[string]string{"bot_id": botID})
+	if err != nil {
+		return fmt.Errorf("marshal request: %w", err)
 	}
 
-	documentsRaw, ok := out.Data["documents"].([]any)
-	if !ok {
-		return []map[string]any{}, nil
+	ctx, cancel := c.callContext(ctx)
+	defer cancel()
+	resp, err := c.post(ctx, strings.TrimRight(vectorURL, "/")+"/collections/ensure", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("execute request: %w", err)
 	}
+	defer resp.Body.Close()
 
-	docs := make([]map[string]any, 0, len(documentsRaw))
-	for _, d := range documentsRaw {
-		if m, ok := d.(map[string]any); ok {
-			docs = append(docs, m)
-		}
+	if resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("vector service error (status %d): %s", resp.StatusCode, string(respBody))
+	}
+
+	return nil
+}
+
+// DeleteVectorDocuments removes all indexed chunks of a bot
+func (c *Client) DeleteVectorDocuments(ctx context.Context, vectorURL, clientID string) error {
+	url := fmt.Sprintf("%s/documents/delete/%s", strings.TrimRight(vectorURL, "/"), clientID)
+	ctx, cancel := c.callContext(ctx)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, url, nil)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("vector service error (status %d): %s", resp.StatusCode, string(respBody))
+	}
+
+	return nil
+}
+
+// DeleteVectorFileDocuments removes the indexed chunks of one file of a bot, except those of keepVersion (0 = all of them)
+func (c *Client) DeleteVectorFileDocuments(ctx context.Context, vectorURL, clientID, fileName string, keepVersion int) error {
+	query := url.Values{"file_name": {fileName}}
+	if keepVersion > 0 {
+		query.Set("keep_version", strconv.Itoa(keepVersion))
+	}
+	endpoint := fmt.Sprintf("%s/documents/delete/%s?%s", strings.TrimRight(vectorURL, "/"), clientID, query.Encode())
+	ctx, cancel := c.callContext(ctx)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("vector service error (status %d): %s", resp.StatusCode, string(respBody))
+	}
+
+	return nil
+}
+
+// CreateStagingCollection creates an empty staging collection of the given vector size for re-embedding
+// a bot's documents and returns its name
+func (c *Client) CreateStagingCollection(ctx context.Context, vectorURL, botID string, dimension int) (string, error) {
+	body, err := json.Marshal(models.VectorStagingRequest{Dimension: dimension})
+	if err != nil {
+		return "", fmt.Errorf("marshal request: %w", err)
+	}
+
+	ctx, cancel := c.callContext(ctx)
+	defer cancel()
+	resp, err := c.post(ctx, fmt.Sprintf("%s/collections/staging/%s", strings.TrimRight(vectorURL, "/"), botID), "application/json", bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("vector service error (status %d): %s", resp.StatusCode, string(respBody))
+	}
+
+	var out models.VectorStagingResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", fmt.Errorf("decode response: %w", err)
+	}
+	if !out.Success || out.Data.Collection == "" {
+		return "", fmt.Errorf("staging collection creation failed: %s", out.Error)
+	}
+	return out.Data.Collection, nil
+}
+
+// AddStagingDocuments writes re-embedded documents to a staging collection under their ids in the bot's
+// current collection, so adding the same batch again overwrites it
+func (c *Client) AddStagingDocuments(ctx context.Context, vectorURL, botID, collection, model string, ids, texts []string, embeddings [][]float32, metadata []map[string]string) error {
+	body, err := json.Marshal(models.VectorStagingDocumentsRequest{
+		IDs:            ids,
+		Texts:          texts,
+		Embeddings:     embeddings,
+		Metadata:       metadata,
+		EmbeddingModel: model,
+	})
+	if err != nil {
+		return fmt.Errorf("marshal request: %w", err)
+	}
+
+	ctx, cancel := c.callContext(ctx)
+	defer cancel()
+	endpoint := fmt.Sprintf("%s/collections/staging/%s/%s/documents", strings.TrimRight(vectorURL, "/"), botID, url.PathEscape(collection))
+	resp, err := c.post(ctx, endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("vector service error (status %d): %s", resp.StatusCode, string(respBody))
+	}
+
+	return nil
+}
+
+// PromoteStagingCollection swaps a filled staging collection in as the bot's collection; model is the
+// embedding model its documents were embedded with
+func (c *Client) PromoteStagingCollection(ctx context.Context, vectorURL, botID, collection, model string) error {
+	body, err := json.Marshal(models.VectorPromoteRequest{EmbeddingModel: model})
+	if err != nil {
+		return fmt.Errorf("marshal request: %w", err)
+	}
+
+	ctx, cancel := c.callContext(ctx)
+	defer cancel()
+	endpoint := fmt.Sprintf("%s/collections/staging/%s/%s/promote", strings.TrimRight(vectorURL, "/"), botID, url.PathEscape(collection))
+	resp, err := c.post(ctx, endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("vector service error (status %d): %s", resp.StatusCode, string(respBody))
 	}
 
-	return docs, nil
+	return nil
 }
 
-// StreamGeneration creates a streaming HTTP request to the AI service
-func (c *Client) StreamGeneration(aiURL string, req models.GenerateRequest) (*http.Response, error) {
+// DropStagingCollection deletes a staging collection that won't be promoted
+func (c *Client) DropStagingCollection(ctx context.Context, vectorURL, botID, collection string) error {
+	endpoint := fmt.Sprintf("%s/collections/staging/%s/%s", strings.TrimRight(vectorURL, "/"), botID, url.PathEscape(collection))
+	ctx, cancel := c.callContext(ctx)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("vector service error (status %d): %s", resp.StatusCode, string(respBody))
+	}
+
+	return nil
+}
+
+// StreamGeneration creates a streaming HTTP request to the AI service.
+// Cancelling ctx aborts the request, including reads of the streamed body.
+func (c *Client) StreamGeneration(ctx context.Context, aiURL string, req models.GenerateRequest) (*http.Response, error) {
 	reqBody, err := json.Marshal(req)
 	if err != nil {
 		return nil, fmt.Errorf("marshal request: %w", err)
 	}
 
-	resp, err := c.httpClient.Post(
-		strings.TrimRight(aiURL, "/")+"/ask",
-		"application/json",
-		bytes.NewReader(reqBody),
-	)
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(aiURL, "/")+"/ask", bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("execute request: %w", err)
 	}
@@ -309,8 +738,10 @@ func (c *Client) StreamGeneration(aiURL string, req models.GenerateRequest) (*ht
 	return resp, nil
 }
 
-// AdvancedSearch calls the AI service for advanced RAG search with reranking
-func (c *Client) AdvancedSearch(aiURL, botID, query string, vectorResults []map[string]any, topK int, maxContextChars int) (map[string]any, error) {
+// AdvancedSearch calls the AI service for advanced RAG search with reranking.
+// The AI service scores the query against vectorResults with its cross-encoder and never embeds
+// the query itself, so the query embedding used for the vector search is not sent.
+func (c *Client) AdvancedSearch(ctx context.Context, aiURL, botID, query string, vectorResults []models.Document, topK int, maxContextChars int) (*models.AdvancedSearchResponse, error) {
 	reqBody, err := json.Marshal(map[string]any{
 		"bot_id":            botID,
 		"query":             query,
@@ -322,7 +753,10 @@ func (c *Client) AdvancedSearch(aiURL, botID, query string, vectorResults []map[
 		return nil, fmt.Errorf("marshal request: %w", err)
 	}
 
-	resp, err := c.httpClient.Post(
+	ctx, cancel := c.callContext(ctx)
+	defer cancel()
+	resp, err := c.post(
+		ctx,
 		strings.TrimRight(aiURL, "/")+"/advanced-search",
 		"application/json",
 		bytes.NewReader(reqBody),
@@ -337,38 +771,10 @@ func (c *Client) AdvancedSearch(aiURL, botID, query string, vectorResults []map[
 		return nil, fmt.Errorf("AI service error (status %d): %s", resp.StatusCode, string(respBody))
 	}
 
-	var result map[string]any
+	var result models.AdvancedSearchResponse
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
 		return nil, fmt.Errorf("decode response: %w", err)
 	}
 
-	return result, nil
-}
-
-// BuildBM25Index calls the AI service to build BM25 index for a bot
-func (c *Client) BuildBM25Index(aiURL, botID string, documents []map[string]any) error {
-	reqBody, err := json.Marshal(map[string]any{
-		"bot_id":    botID,
-		"documents": documents,
-	})
-	if err != nil {
-		return fmt.Errorf("marshal request: %w", err)
-	}
-
-	resp, err := c.httpClient.Post(
-		strings.TrimRight(aiURL, "/")+"/build-bm25-index",
-		"application/json",
-		bytes.NewReader(reqBody),
-	)
-	if err != nil {
-		return fmt.Errorf("execute request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode >= 300 {
-		respBody, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("AI service error (status %d): %s", resp.StatusCode, string(respBody))
-	}
-
-	return nil
+	return &result, nil
 }
@@ -0,0 +1,83 @@
+package clients
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// slackResponse is the common envelope of Slack Web API responses
+type slackResponse struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error"`
+}
+
+// PostSlackMessage posts a message to a Slack channel via chat.postMessage, in a thread when threadTS is set
+func (c *Client) PostSlackMessage(ctx context.Context, apiURL, token, channel, threadTS, text string) error {
+	payload := map[string]any{
+		"channel": channel,
+		"text":    text,
+	}
+	if threadTS != "" {
+		payload["thread_ts"] = threadTS
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("marshal request: %w", err)
+	}
+
+	ctx, cancel := c.callContext(ctx)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(apiURL, "/")+"/chat.postMessage", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var out slackResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return fmt.Errorf("decode response (status %d): %w", resp.StatusCode, err)
+	}
+	if !out.OK {
+		return fmt.Errorf("slack chat.postMessage failed: %s", out.Error)
+	}
+
+	return nil
+}
+
+// PostSlackResponse answers a slash command through its response_url
+func (c *Client) PostSlackResponse(ctx context.Context, responseURL, text string) error {
+	body, err := json.Marshal(map[string]string{
+		"response_type": "in_channel",
+		"text":          text,
+	})
+	if err != nil {
+		return fmt.Errorf("marshal request: %w", err)
+	}
+
+	ctx, cancel := c.callContext(ctx)
+	defer cancel()
+	resp, err := c.post(ctx, responseURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("slack response_url error (status %d): %s", resp.StatusCode, string(respBody))
+	}
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package clients
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// telegramResponse is the common envelope of Telegram Bot API responses
+type telegramResponse struct {
+	OK          bool   `json:"ok"`
+	Description string `json:"description"`
+}
+
+// SendTelegramMessage sends a plain-text message to a Telegram chat
+func (c *Client) SendTelegramMessage(ctx context.Context, apiURL, token string, chatID int64, text string) error {
+	return c.callTelegram(ctx, apiURL, token, "sendMessage", map[string]any{
+		"chat_id": chatID,
+		"text":    text,
+	})
+}
+
+// SetTelegramWebhook points the Telegram bot at webhookURL; updates carry secretToken in a header
+func (c *Client) SetTelegramWebhook(ctx context.Context, apiURL, token, webhookURL, secretToken string) error {
+	return c.callTelegram(ctx, apiURL, token, "setWebhook", map[string]any{
+		"url":             webhookURL,
+		"secret_token":    secretToken,
+		"allowed_updates": []string{"message"},
+	})
+}
+
+// DeleteTelegramWebhook stops update delivery to the webhook
+func (c *Client) DeleteTelegramWebhook(ctx context.Context, apiURL, token string) error {
+	return c.callTelegram(ctx, apiURL, token, "deleteWebhook", map[string]any{})
+}
+
+// callTelegram invokes a Bot API method; errors never include the bot token
+func (c *Client) callTelegram(ctx context.Context, apiURL, token, method string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("marshal request: %w", err)
+	}
+
+	endpoint := fmt.Sprintf("%s/bot%s/%s", strings.TrimRight(apiURL, "/"), token, method)
+	ctx, cancel := c.callContext(ctx)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("telegram %s: create request failed", method)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		// *url.Error includes the URL (and thus the token), so only the cause is reported
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("telegram %s: request failed: %w", method, err)
+	}
+	defer resp.Body.Close()
+
+	var out telegramResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return fmt.Errorf("telegram %s: decode response (status %d): %w", method, resp.StatusCode, err)
+	}
+	if !out.OK {
+		return fmt.Errorf("telegram %s failed: %s", method, out.Description)
+	}
+
+	return nil
+}
@@ -2,22 +2,45 @@ package config
 
 import (
 	"backend/models"
+	"backend/utils"
 	"fmt"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
 type Config struct {
-	Server     ServerConfig
-	Services   ServicesConfig
-	RAG        RAGConfig
-	HTTPClient HTTPClientConfig
-	Generation models.GenerationDefaults
+	Server       ServerConfig
+	Database     DatabaseConfig
+	Services     ServicesConfig
+	RAG          RAGConfig
+	HTTPClient   HTTPClientConfig
+	CORS         CORSConfig
+	Auth         AuthConfig
+	Upload       UploadConfig
+	Quota        QuotaConfig
+	Trash        TrashConfig
+	Crawl        CrawlConfig
+	Webhooks     WebhookConfig
+	Stream       StreamConfig
+	Integrations IntegrationsConfig
+	Generation   models.GenerationDefaults
 }
 
 type ServerConfig struct {
-	Port string
+	Port          string
+	JSONBodyLimit int // Max request body of routes other than uploads and bot import (bytes)
+}
+
+type LogConfig struct {
+	Level  string // debug, info, warn or error
+	Format string // text or json
+}
+
+type DatabaseConfig struct {
+	RunMigrations bool // Migrate the schema on startup; off when migrations run as a separate step ("backend migrate")
 }
 
 type ServicesConfig struct {
@@ -27,23 +50,129 @@ type ServicesConfig struct {
 }
 
 type RAGConfig struct {
-	ChunkSize       int
-	ChunkOverlap    int
-	MaxDocChars     int
-	MaxContextChars int
-	MaxResults      int
-	ScoreThreshold  float64
+	ChunkSize          int
+	ChunkOverlap       int
+	SplitTimeout       time.Duration // Wait for AI service splitting before chunking locally (0 = SERVICE_CALL_TIMEOUT only)
+	MaxDocChars        int
+	MaxContextChars    int
+	ModelContextTokens int // Model window used for context budgeting; 0 disables it
+	MaxResults         int // Default vector search limit: candidates passed to reranking, results of legacy chat
+	RerankTopK         int // Documents kept after reranking
+	FallbackLimit      int // Indexed chunks used when search finds nothing (0 = answer without context)
+	RerankFallbackDocs int // Top vector results used when reranking fails or keeps nothing (0 = answer without context)
+	ScoreThreshold     float64
+	AnswerCacheSize    int           // Answers cached for repeated public chat queries (0 = cache disabled)
+	AnswerCacheTTL     time.Duration // How long a cached answer is served
+	EmbeddingCacheSize int           // Embeddings cached by text hash (0 = cache disabled)
+	FilterArtifacts    bool          // Strip think blocks and ArtifactTokens from generated answers
+	ArtifactTokens     []string      // Model control tokens removed from answers
+	EmbeddingModels    []string      // Embedding models bots may choose besides the AI service default
 }
 
 type HTTPClientConfig struct {
-	Timeout time.Duration
+	Timeout        time.Duration // Server read/write timeout
+	ServiceTimeout time.Duration // Per-call timeout of parse, embedding and vector DB calls (not streaming generation)
+}
+
+type AuthConfig struct {
+	JWTExpiration    time.Duration
+	LoginMaxFailures int           // Failed logins in a row that lock an account (0 = no lockout)
+	LoginLockout     time.Duration // How long a locked account rejects logins
+}
+
+type TrashConfig struct {
+	Retention time.Duration // How long deleted bots can be restored
+}
+
+type CrawlConfig struct {
+	Delay        time.Duration // Politeness delay between page requests
+	AllowPrivate bool          // Allow crawling loopback/private network addresses
+}
+
+type QuotaConfig struct {
+	MaxChunks int   // Default max indexed chunks per user (0 = unlimited)
+	MaxBytes  int64 // Default max total uploaded bytes per user (0 = unlimited)
+}
+
+type UploadConfig struct {
+	MaxBytes         int64  // Max uploaded file size; also bounds the request body limit
+	MaxChunksPerDoc  int    // Max chunks one document or crawled page may produce (0 = unlimited)
+	ChunkLimitAction string // What happens above MaxChunksPerDoc: ChunkLimitReject or ChunkLimitTruncate
+	DuplicateAction  string // What happens when a bot already has an identical file: DuplicateSkip or DuplicateAllow
+	DetectLanguage   bool   // Ask the parser for the language of uploaded documents and crawled pages
+}
+
+// MAX_CHUNKS_PER_DOC actions
+const (
+	ChunkLimitReject   = "reject"   // Refuse the document
+	ChunkLimitTruncate = "truncate" // Index the first MaxChunksPerDoc chunks and warn
+)
+
+// DUPLICATE_UPLOAD_ACTION values
+const (
+	DuplicateSkip  = "skip"  // Answer with the existing document instead of indexing the file again
+	DuplicateAllow = "allow" // Index identical files again
+)
+
+// MaxSearchLimit is the largest number of results one vector search may ask for. The vector service
+// rejects larger limits instead of truncating them, so every layer validates against the same bound.
+const MaxSearchLimit = 500
+
+// multipartOverheadBytes leaves room for multipart boundaries and form fields around the file
+const multipartOverheadBytes = 1 << 20
+
+// BodyLimit returns the HTTP request body limit that admits a maximum-size upload
+func (u UploadConfig) BodyLimit() int {
+	return int(u.MaxBytes) + multipartOverheadBytes
+}
+
+type WebhookConfig struct {
+	Workers      int
+	MaxAttempts  int
+	Timeout      time.Duration
+	RetryBackoff time.Duration // Delay before the first retry; doubles on each attempt
+	AllowPrivate bool          // Allow webhook URLs on loopback/private network addresses
+}
+
+// StreamConfig controls how chat stream frames are flushed to the client
+type StreamConfig struct {
+	FlushInterval time.Duration // Coalesce frames for up to this long before flushing (0 = flush every frame)
+	FlushBytes    int           // Flush early once this many bytes are buffered (0 = interval only)
+	DrainTimeout  time.Duration // On shutdown, how long open chat streams may keep generating before they are ended
+}
+
+type IntegrationsConfig struct {
+	EncryptionKey  string // Encrypts stored messenger credentials; integrations are disabled when empty
+	PublicBaseURL  string // Externally reachable backend URL used to register messenger webhooks
+	TelegramAPIURL string
+	SlackAPIURL    string
+}
+
+type CORSConfig struct {
+	AllowOrigins []string
+	AllowMethods string
+	AllowHeaders string
+}
+
+// AllowsOrigin reports whether the request origin is in the allow-list
+func (c CORSConfig) AllowsOrigin(origin string) bool {
+	for _, allowed := range c.AllowOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
 }
 
 // Load loads configuration from environment variables with validation
 func Load() (*Config, error) {
 	cfg := &Config{
 		Server: ServerConfig{
-			Port: getEnv("PORT", ""),
+			Port:          getEnv("PORT", ""),
+			JSONBodyLimit: getOptionalEnvInt("JSON_BODY_LIMIT", 256*1024),
+		},
+		Database: DatabaseConfig{
+			RunMigrations: getEnvBool("RUN_MIGRATIONS", true),
 		},
 		Services: ServicesConfig{
 			DocParserURL: getEnv("DOC_PARSER_URL", ""),
@@ -51,15 +180,73 @@ func Load() (*Config, error) {
 			AIURL:        getEnv("AI_URL", ""),
 		},
 		RAG: RAGConfig{
-			ChunkSize:       getEnvInt("CHUNK_SIZE", 0),
-			ChunkOverlap:    getEnvInt("CHUNK_OVERLAP", 0),
-			MaxDocChars:     getEnvInt("RAG_MAX_DOC_CHARS", 0),
-			MaxContextChars: getEnvInt("RAG_MAX_CONTEXT_CHARS", 16000),
-			MaxResults:      getEnvInt("RAG_MAX_RESULTS", 100),
-			ScoreThreshold:  getEnvFloat("RAG_SCORE_THRESHOLD", 0.5),
+			ChunkSize:          getEnvInt("CHUNK_SIZE", 0),
+			ChunkOverlap:       getEnvInt("CHUNK_OVERLAP", 0),
+			SplitTimeout:       getEnvDuration("SPLIT_DOCUMENT_TIMEOUT", 20*time.Second),
+			MaxDocChars:        getEnvInt("RAG_MAX_DOC_CHARS", 0),
+			MaxContextChars:    getEnvInt("RAG_MAX_CONTEXT_CHARS", 16000),
+			ModelContextTokens: getOptionalEnvInt("RAG_MODEL_CONTEXT_TOKENS", 0),
+			MaxResults:         getEnvInt("RAG_MAX_RESULTS", 100),
+			RerankTopK:         getOptionalEnvInt("RAG_RERANK_TOP_K", 35),
+			FallbackLimit:      getOptionalEnvInt("RAG_FALLBACK_LIMIT", 20),
+			RerankFallbackDocs: getOptionalEnvInt("RAG_RERANK_FALLBACK_DOCS", 10),
+			ScoreThreshold:     getEnvFloat("RAG_SCORE_THRESHOLD", 0.5),
+			AnswerCacheSize:    getOptionalEnvInt("ANSWER_CACHE_SIZE", 0),
+			AnswerCacheTTL:     getEnvDuration("ANSWER_CACHE_TTL", 10*time.Minute),
+			EmbeddingCacheSize: getOptionalEnvInt("EMBEDDING_CACHE_SIZE", 2000),
+			FilterArtifacts:    getEnvBool("RESPONSE_FILTER_ARTIFACTS", true),
+			ArtifactTokens:     getEnvList("RESPONSE_ARTIFACT_TOKENS", utils.DefaultModelArtifacts),
+			EmbeddingModels:    getEnvList("EMBEDDING_MODELS_ALLOWED", nil),
 		},
 		HTTPClient: HTTPClientConfig{
-			Timeout: time.Duration(getEnvInt("HTTP_TIMEOUT_SEC", 0)) * time.Second,
+			Timeout:        time.Duration(getEnvInt("HTTP_TIMEOUT_SEC", 0)) * time.Second,
+			ServiceTimeout: getEnvDuration("SERVICE_CALL_TIMEOUT", 2*time.Minute),
+		},
+		CORS: CORSConfig{
+			AllowOrigins: getEnvList("CORS_ALLOW_ORIGINS", []string{"*"}),
+			AllowMethods: getEnv("CORS_ALLOW_METHODS", "GET,POST,PUT,DELETE,OPTIONS"),
+			AllowHeaders: getEnv("CORS_ALLOW_HEADERS", "Origin,Content-Type,Accept,Authorization"),
+		},
+		Auth: AuthConfig{
+			JWTExpiration:    getEnvDuration("JWT_EXPIRATION", 24*time.Hour),
+			LoginMaxFailures: getOptionalEnvInt("LOGIN_MAX_FAILURES", 5),
+			LoginLockout:     getEnvDuration("LOGIN_LOCKOUT", 15*time.Minute),
+		},
+		Upload: UploadConfig{
+			MaxBytes:         int64(getOptionalEnvInt("MAX_UPLOAD_BYTES", 50*1024*1024)),
+			MaxChunksPerDoc:  getOptionalEnvInt("MAX_CHUNKS_PER_DOC", 10000),
+			ChunkLimitAction: getEnv("CHUNK_LIMIT_ACTION", ChunkLimitReject),
+			DuplicateAction:  getEnv("DUPLICATE_UPLOAD_ACTION", DuplicateSkip),
+			DetectLanguage:   getEnvBool("DETECT_DOCUMENT_LANGUAGE", true),
+		},
+		Trash: TrashConfig{
+			Retention: getEnvDuration("TRASH_RETENTION", 30*24*time.Hour),
+		},
+		Crawl: CrawlConfig{
+			Delay:        getEnvDuration("CRAWL_DELAY", time.Second),
+			AllowPrivate: getEnvBool("CRAWL_ALLOW_PRIVATE", false),
+		},
+		Quota: QuotaConfig{
+			MaxChunks: getOptionalEnvInt("QUOTA_MAX_CHUNKS", 0),
+			MaxBytes:  int64(getOptionalEnvInt("QUOTA_MAX_BYTES", 0)),
+		},
+		Webhooks: WebhookConfig{
+			Workers:      getOptionalEnvInt("WEBHOOK_WORKERS", 4),
+			MaxAttempts:  getOptionalEnvInt("WEBHOOK_MAX_ATTEMPTS", 5),
+			Timeout:      getEnvDuration("WEBHOOK_TIMEOUT", 10*time.Second),
+			RetryBackoff: getEnvDuration("WEBHOOK_RETRY_BACKOFF", 2*time.Second),
+			AllowPrivate: getEnvBool("WEBHOOK_ALLOW_PRIVATE", false),
+		},
+		Stream: StreamConfig{
+			FlushInterval: getEnvDuration("STREAM_FLUSH_INTERVAL", 0),
+			FlushBytes:    getOptionalEnvInt("STREAM_FLUSH_BYTES", 0),
+			DrainTimeout:  getEnvDuration("STREAM_DRAIN_TIMEOUT", 20*time.Second),
+		},
+		Integrations: IntegrationsConfig{
+			EncryptionKey:  os.Getenv("INTEGRATIONS_ENCRYPTION_KEY"),
+			PublicBaseURL:  strings.TrimRight(os.Getenv("PUBLIC_BASE_URL"), "/"),
+			TelegramAPIURL: getEnv("TELEGRAM_API_URL", "https://api.telegram.org"),
+			SlackAPIURL:    getEnv("SLACK_API_URL", "https://slack.com/api"),
 		},
 		Generation: models.GenerationDefaults{
 			MaxNewTokens: getEnvInt("GEN_MAX_NEW_TOKENS", 0),
@@ -79,6 +266,15 @@ func Load() (*Config, error) {
 	return cfg, nil
 }
 
+// maxUploadBytesLimit caps MAX_UPLOAD_BYTES: uploads are buffered in memory by the gateway
+const maxUploadBytesLimit = 1 << 30
+
+// maxStreamFlushInterval caps STREAM_FLUSH_INTERVAL: tokens held longer would no longer look streamed
+const maxStreamFlushInterval = time.Second
+
+// minEncryptionKeyLength is the minimum INTEGRATIONS_ENCRYPTION_KEY length
+const minEncryptionKeyLength = 32
+
 // Validate validates the configuration
 func (c *Config) Validate() error {
 	if c.Server.Port == "" {
@@ -99,18 +295,133 @@ func (c *Config) Validate() error {
 	if c.RAG.ChunkOverlap < 0 {
 		return fmt.Errorf("CHUNK_OVERLAP cannot be negative")
 	}
-	if c.RAG.MaxResults <= 0 {
-		return fmt.Errorf("RAG_MAX_RESULTS must be positive")
+	if c.RAG.SplitTimeout < 0 {
+		return fmt.Errorf("SPLIT_DOCUMENT_TIMEOUT cannot be negative")
+	}
+	if c.RAG.MaxResults <= 0 || c.RAG.MaxResults > MaxSearchLimit {
+		return fmt.Errorf("RAG_MAX_RESULTS must be between 1 and %d", MaxSearchLimit)
 	}
 	if c.RAG.MaxContextChars <= 0 {
 		return fmt.Errorf("RAG_MAX_CONTEXT_CHARS must be positive")
 	}
+	if c.RAG.RerankTopK <= 0 {
+		return fmt.Errorf("RAG_RERANK_TOP_K must be positive")
+	}
+	if c.RAG.RerankTopK > c.RAG.MaxResults {
+		return fmt.Errorf("RAG_RERANK_TOP_K (%d) cannot exceed RAG_MAX_RESULTS (%d)", c.RAG.RerankTopK, c.RAG.MaxResults)
+	}
+	if c.RAG.FallbackLimit < 0 || c.RAG.FallbackLimit > MaxSearchLimit {
+		return fmt.Errorf("RAG_FALLBACK_LIMIT must be between 0 and %d", MaxSearchLimit)
+	}
+	if c.RAG.RerankFallbackDocs < 0 || c.RAG.RerankFallbackDocs > MaxSearchLimit {
+		return fmt.Errorf("RAG_RERANK_FALLBACK_DOCS must be between 0 and %d", MaxSearchLimit)
+	}
+	if c.RAG.ModelContextTokens < 0 {
+		return fmt.Errorf("RAG_MODEL_CONTEXT_TOKENS cannot be negative")
+	}
 	if c.HTTPClient.Timeout <= 0 {
 		return fmt.Errorf("HTTP_TIMEOUT_SEC must be positive")
 	}
+	if c.Auth.JWTExpiration <= 0 {
+		return fmt.Errorf("JWT_EXPIRATION must be positive")
+	}
+	if c.Auth.LoginMaxFailures < 0 {
+		return fmt.Errorf("LOGIN_MAX_FAILURES cannot be negative")
+	}
+	if c.Auth.LoginMaxFailures > 0 && c.Auth.LoginLockout <= 0 {
+		return fmt.Errorf("LOGIN_LOCKOUT must be positive")
+	}
+	if c.Upload.MaxBytes <= 0 {
+		return fmt.Errorf("MAX_UPLOAD_BYTES must be positive")
+	}
+	if c.Upload.MaxBytes > maxUploadBytesLimit {
+		return fmt.Errorf("MAX_UPLOAD_BYTES cannot exceed %d", maxUploadBytesLimit)
+	}
+	if c.Server.JSONBodyLimit <= 0 || c.Server.JSONBodyLimit > c.Upload.BodyLimit() {
+		return fmt.Errorf("JSON_BODY_LIMIT must be positive and cannot exceed the upload body limit (%d)", c.Upload.BodyLimit())
+	}
+	if c.Upload.MaxChunksPerDoc < 0 {
+		return fmt.Errorf("MAX_CHUNKS_PER_DOC cannot be negative")
+	}
+	if c.Upload.ChunkLimitAction != ChunkLimitReject && c.Upload.ChunkLimitAction != ChunkLimitTruncate {
+		return fmt.Errorf("CHUNK_LIMIT_ACTION must be %q or %q", ChunkLimitReject, ChunkLimitTruncate)
+	}
+	if c.Upload.DuplicateAction != DuplicateSkip && c.Upload.DuplicateAction != DuplicateAllow {
+		return fmt.Errorf("DUPLICATE_UPLOAD_ACTION must be %q or %q", DuplicateSkip, DuplicateAllow)
+	}
+	if c.Trash.Retention <= 0 {
+		return fmt.Errorf("TRASH_RETENTION must be positive")
+	}
+	if c.Quota.MaxChunks < 0 || c.Quota.MaxBytes < 0 {
+		return fmt.Errorf("QUOTA_MAX_CHUNKS and QUOTA_MAX_BYTES cannot be negative")
+	}
+	if c.Webhooks.Workers <= 0 {
+		return fmt.Errorf("WEBHOOK_WORKERS must be positive")
+	}
+	if c.Webhooks.MaxAttempts <= 0 {
+		return fmt.Errorf("WEBHOOK_MAX_ATTEMPTS must be positive")
+	}
+	if c.Webhooks.Timeout <= 0 {
+		return fmt.Errorf("WEBHOOK_TIMEOUT must be positive")
+	}
+	if c.Webhooks.RetryBackoff <= 0 {
+		return fmt.Errorf("WEBHOOK_RETRY_BACKOFF must be positive")
+	}
+	if c.Stream.FlushInterval < 0 || c.Stream.FlushInterval > maxStreamFlushInterval {
+		return fmt.Errorf("STREAM_FLUSH_INTERVAL must be between 0 and %s", maxStreamFlushInterval)
+	}
+	if c.Stream.FlushBytes < 0 {
+		return fmt.Errorf("STREAM_FLUSH_BYTES cannot be negative")
+	}
+	if c.Stream.DrainTimeout < 0 {
+		return fmt.Errorf("STREAM_DRAIN_TIMEOUT cannot be negative")
+	}
+	if key := c.Integrations.EncryptionKey; key != "" && len(key) < minEncryptionKeyLength {
+		return fmt.Errorf("INTEGRATIONS_ENCRYPTION_KEY must be at least %d characters", minEncryptionKeyLength)
+	}
+	if c.Integrations.PublicBaseURL != "" {
+		if u, err := url.Parse(c.Integrations.PublicBaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("PUBLIC_BASE_URL must be an absolute http(s) URL")
+		}
+	}
+	if len(c.CORS.AllowOrigins) == 0 {
+		return fmt.Errorf("CORS_ALLOW_ORIGINS cannot be empty")
+	}
+	for _, origin := range c.CORS.AllowOrigins {
+		if err := validateOrigin(origin); err != nil {
+			return fmt.Errorf("CORS_ALLOW_ORIGINS: %w", err)
+		}
+	}
 	return nil
 }
 
+// validateOrigin accepts "*" or a bare scheme://host[:port] origin
+func validateOrigin(origin string) error {
+	if origin == "*" {
+		return nil
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return fmt.Errorf("invalid origin %q: %w", origin, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid origin %q: expected scheme://host[:port]", origin)
+	}
+	if (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" {
+		return fmt.Errorf("invalid origin %q: must not contain a path or query", origin)
+	}
+	return nil
+}
+
+// LoadLogging reads the logging settings. It is separate from Load so that logging is set up
+// before anything else runs, including the migrate command.
+func LoadLogging() LogConfig {
+	return LogConfig{
+		Level:  getEnv("LOG_LEVEL", "info"),
+		Format: getEnv("LOG_FORMAT", "text"),
+	}
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -131,6 +442,17 @@ func getEnvInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+// getOptionalEnvInt reads an integer that may legitimately be left unset
+func getOptionalEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if intVal, err := strconv.Atoi(value); err == nil {
+			return intVal
+		}
+		fmt.Fprintf(os.Stderr, "WARNING: Invalid integer value for %s: %s, using default: %d\n", key, value, defaultValue)
+	}
+	return defaultValue
+}
+
 func getEnvFloat(key string, defaultValue float64) float64 {
 	if value := os.Getenv(key); value != "" {
 		if floatVal, err := strconv.ParseFloat(value, 64); err == nil {
@@ -140,6 +462,32 @@ func getEnvFloat(key string, defaultValue float64) float64 {
 	return defaultValue
 }
 
+// getEnvDuration reads a Go duration string such as "12h" or "90m"
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+		fmt.Fprintf(os.Stderr, "WARNING: Invalid duration value for %s: %s, using default: %s\n", key, value, defaultValue)
+	}
+	return defaultValue
+}
+
+// getEnvList reads a comma-separated list, dropping empty items
+func getEnvList(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimRight(strings.TrimSpace(item), "/"); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 func getEnvBool(key string, defaultValue bool) bool {
 	if value := os.Getenv(key); value != "" {
 		return value == "true" || value == "1" || value == "yes"
@@ -0,0 +1,377 @@
+package crawler
+
+import (
+	"backend/netguard"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// Options configures a crawl
+type Options struct {
+	MaxPages     int           // Pages passed to visit before the crawl stops
+	SameDomain   bool          // Follow only links on the root URL's host
+	Delay        time.Duration // Politeness delay between requests; a longer robots.txt Crawl-delay wins
+	Timeout      time.Duration // Per-request timeout
+	MaxPageBytes int64         // Larger pages are skipped
+	UserAgent    string
+	AllowPrivate bool // Allow fetching loopback/private addresses (off by default to prevent SSRF)
+}
+
+// Page is a fetched HTML page
+type Page struct {
+	URL  string // Final URL after redirects
+	Body []byte
+}
+
+// PageError is a page that could not be fetched or processed
+type PageError struct {
+	URL   string `json:"url"`
+	Error string `json:"error"`
+}
+
+// Summary describes a finished crawl
+type Summary struct {
+	Pages           int         `json:"pages"`             // Pages visited successfully
+	Skipped         int         `json:"skipped"`           // Non-HTML responses and non-page links
+	BlockedByRobots int         `json:"blocked_by_robots"` // URLs disallowed by robots.txt
+	Failed          []PageError `json:"failed,omitempty"`
+	Stopped         string      `json:"stopped,omitempty"` // Why the crawl ended early, if it did
+}
+
+// stopError makes Crawl stop after recording the error
+type stopError struct{ err error }
+
+func (e *stopError) Error() string { return e.err.Error() }
+func (e *stopError) Unwrap() error { return e.err }
+
+// Stop wraps an error returned by visit so that the crawl ends instead of moving on to the next page
+func Stop(err error) error {
+	return &stopError{err: err}
+}
+
+// skippedExtensions are link targets that are never HTML pages
+var skippedExtensions = map[string]bool{
+	".pdf": true, ".zip": true, ".gz": true, ".tar": true, ".rar": true, ".7z": true,
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".svg": true, ".webp": true, ".ico": true,
+	".css": true, ".js": true, ".json": true, ".xml": true, ".txt": true,
+	".mp3": true, ".mp4": true, ".avi": true, ".mov": true, ".webm": true,
+	".doc": true, ".docx": true, ".xls": true, ".xlsx": true, ".ppt": true, ".pptx": true,
+}
+
+// maxSitemaps bounds how many sitemaps one crawl reads, child sitemaps of an index included
+const maxSitemaps = 20
+
+// maxSitemapDepth bounds how deep sitemap indexes may nest below the root sitemap
+const maxSitemapDepth = 3
+
+// Crawler fetches pages breadth-first from a root URL or the URLs listed in a sitemap
+type Crawler struct {
+	client    *http.Client
+	opts      Options
+	robots    map[string]*robotsRules
+	lastFetch time.Time
+}
+
+// New creates a crawler with its own HTTP client
+func New(opts Options) *Crawler {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	if !opts.AllowPrivate {
+		dialer.Control = netguard.PublicAddressesOnly
+	}
+	return &Crawler{
+		client: &http.Client{
+			Timeout: opts.Timeout,
+			Transport: &http.Transport{
+				Proxy:                 http.ProxyFromEnvironment,
+				DialContext:           dialer.DialContext,
+				TLSHandshakeTimeout:   10 * time.Second,
+				ResponseHeaderTimeout: opts.Timeout,
+			},
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= 5 {
+					return errors.New("too many redirects")
+				}
+				return nil
+			},
+		},
+		opts:   opts,
+		robots: map[string]*robotsRules{},
+	}
+}
+
+// Crawl visits pages breadth-first starting at root, calling visit for every HTML page.
+// A root ending in ".xml" is read as a sitemap: its URLs are visited and links are not followed.
+// Errors of visit are recorded in the summary; wrap them with Stop to end the crawl.
+func (c *Crawler) Crawl(ctx context.Context, root string, visit func(Page) error) (*Summary, error) {
+	rootURL, err := url.Parse(root)
+	if err != nil || (rootURL.Scheme != "http" && rootURL.Scheme != "https") || rootURL.Host == "" {
+		return nil, fmt.Errorf("invalid root URL %q: must be an absolute http(s) URL", root)
+	}
+	rootURL.Fragment = ""
+
+	summary := &Summary{}
+	queue := []*url.URL{rootURL}
+	followLinks := true
+	if strings.HasSuffix(strings.ToLower(rootURL.Path), ".xml") {
+		queue, err = c.readSitemap(ctx, rootURL, map[string]bool{}, 0)
+		if err != nil {
+			return nil, err
+		}
+		followLinks = false
+	}
+
+	seen := map[string]bool{}
+	for _, u := range queue {
+		seen[u.String()] = true
+	}
+
+	// Non-HTML responses also cost requests, so the number of fetches is bounded too
+	maxFetches := c.opts.MaxPages * 3
+	fetches := 0
+	for len(queue) > 0 && summary.Pages < c.opts.MaxPages {
+		if err := ctx.Err(); err != nil {
+			summary.Stopped = err.Error()
+			break
+		}
+		if fetches >= maxFetches {
+			summary.Stopped = "too many non-page responses"
+			break
+		}
+
+		current := queue[0]
+		queue = queue[1:]
+
+		if !c.allowedByRobots(ctx, current) {
+			summary.BlockedByRobots++
+			continue
+		}
+
+		fetches++
+		page, isHTML, err := c.fetch(ctx, current.String(), "text/html", "application/xhtml+xml")
+		if err != nil {
+			summary.Failed = append(summary.Failed, PageError{URL: current.String(), Error: err.Error()})
+			continue
+		}
+		if !isHTML {
+			summary.Skipped++
+			continue
+		}
+
+		if followLinks {
+			base, _ := url.Parse(page.URL)
+			for _, link := range extractLinks(base, page.Body) {
+				key := link.String()
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+				if c.opts.SameDomain && !strings.EqualFold(link.Hostname(), rootURL.Hostname()) {
+					continue
+				}
+				if skippedExtensions[strings.ToLower(path.Ext(link.Path))] {
+					summary.Skipped++
+					continue
+				}
+				queue = append(queue, link)
+			}
+		}
+
+		if err := visit(page); err != nil {
+			summary.Failed = append(summary.Failed, PageError{URL: page.URL, Error: err.Error()})
+			var stop *stopError
+			if errors.As(err, &stop) {
+				summary.Stopped = stop.Error()
+				break
+			}
+			continue
+		}
+		summary.Pages++
+	}
+
+	return summary, nil
+}
+
+// readSitemap returns the page URLs of a sitemap, reading the child sitemaps of a sitemap index.
+// Sitemaps in visited are not read again, so indexes that list each other do not loop.
+func (c *Crawler) readSitemap(ctx context.Context, sitemapURL *url.URL, visited map[string]bool, depth int) ([]*url.URL, error) {
+	visited[sitemapURL.String()] = true
+	var doc struct {
+		URLs []struct {
+			Loc string `xml:"loc"`
+		} `xml:"url"`
+		Sitemaps []struct {
+			Loc string `xml:"loc"`
+		} `xml:"sitemap"`
+	}
+	page, _, err := c.fetch(ctx, sitemapURL.String())
+	if err != nil {
+		return nil, fmt.Errorf("fetch sitemap: %w", err)
+	}
+	if err := xml.Unmarshal(page.Body, &doc); err != nil {
+		return nil, fmt.Errorf("parse sitemap: %w", err)
+	}
+
+	var urls []*url.URL
+	for _, entry := range doc.URLs {
+		if u, err := url.Parse(strings.TrimSpace(entry.Loc)); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+			u.Fragment = ""
+			urls = append(urls, u)
+		}
+	}
+	if depth >= maxSitemapDepth {
+		if len(doc.Sitemaps) > 0 {
+			log.Printf("[crawler] Skipping child sitemaps of %s: nested deeper than %d levels", sitemapURL, maxSitemapDepth)
+		}
+		return urls, nil
+	}
+	for _, entry := range doc.Sitemaps {
+		if len(visited) >= maxSitemaps || len(urls) >= c.opts.MaxPages*3 {
+			break
+		}
+		child, err := url.Parse(strings.TrimSpace(entry.Loc))
+		if err != nil || (child.Scheme != "http" && child.Scheme != "https") {
+			continue
+		}
+		child.Fragment = ""
+		if visited[child.String()] {
+			continue
+		}
+		childURLs, err := c.readSitemap(ctx, child, visited, depth+1)
+		if err != nil {
+			log.Printf("[crawler] Skipping sitemap %s: %v", child, err)
+			continue
+		}
+		urls = append(urls, childURLs...)
+	}
+	return urls, nil
+}
+
+// allowedByRobots checks the URL against the robots.txt of its host, fetched once per host.
+// A missing or unreadable robots.txt allows everything.
+func (c *Crawler) allowedByRobots(ctx context.Context, u *url.URL) bool {
+	hostKey := u.Scheme + "://" + u.Host
+	rules, ok := c.robots[hostKey]
+	if !ok {
+		rules = &robotsRules{}
+		if page, _, err := c.fetch(ctx, hostKey+"/robots.txt"); err == nil {
+			rules = parseRobots(bytes.NewReader(page.Body), c.opts.UserAgent)
+		}
+		c.robots[hostKey] = rules
+	}
+	return rules.allowed(u.EscapedPath())
+}
+
+// fetch GETs a URL after the politeness delay. With accepted content types, isHTML reports
+// whether the response matched them; the body is not read otherwise.
+func (c *Crawler) fetch(ctx context.Context, target string, accepted ...string) (Page, bool, error) {
+	if err := c.wait(ctx, target); err != nil {
+		return Page{}, false, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return Page{}, false, err
+	}
+	req.Header.Set("User-Agent", c.opts.UserAgent)
+
+	resp, err := c.client.Do(req)
+	c.lastFetch = time.Now()
+	if err != nil {
+		return Page{}, false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Page{}, false, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	if len(accepted) > 0 {
+		mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+		matched := false
+		for _, t := range accepted {
+			if mediaType == t {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return Page{}, false, nil
+		}
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, c.opts.MaxPageBytes+1))
+	if err != nil {
+		return Page{}, false, fmt.Errorf("read body: %w", err)
+	}
+	if int64(len(body)) > c.opts.MaxPageBytes {
+		return Page{}, false, fmt.Errorf("page is larger than %d bytes", c.opts.MaxPageBytes)
+	}
+	return Page{URL: resp.Request.URL.String(), Body: body}, true, nil
+}
+
+// wait sleeps until the politeness delay (or the host's robots.txt Crawl-delay, if longer) has passed
+func (c *Crawler) wait(ctx context.Context, target string) error {
+	delay := c.opts.Delay
+	if u, err := url.Parse(target); err == nil {
+		if rules, ok := c.robots[u.Scheme+"://"+u.Host]; ok && rules.crawlDelay > delay {
+			delay = rules.crawlDelay
+		}
+	}
+	remaining := time.Until(c.lastFetch.Add(delay))
+	if c.lastFetch.IsZero() || remaining <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(remaining)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// extractLinks returns the absolute http(s) links of <a href> tags, without fragments
+func extractLinks(base *url.URL, body []byte) []*url.URL {
+	var links []*url.URL
+	tokenizer := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return links
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := tokenizer.TagName()
+			if string(name) != "a" || !hasAttr {
+				continue
+			}
+			for {
+				key, value, more := tokenizer.TagAttr()
+				if string(key) == "href" {
+					if link, err := base.Parse(strings.TrimSpace(string(value))); err == nil &&
+						(link.Scheme == "http" || link.Scheme == "https") {
+						link.Fragment = ""
+						links = append(links, link)
+					}
+					break
+				}
+				if !more {
+					break
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package crawler
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// robotsRules are the Allow/Disallow rules of robots.txt that apply to the crawler
+type robotsRules struct {
+	allow      []string
+	disallow   []string
+	crawlDelay time.Duration
+}
+
+// parseRobots reads the rules of the group matching userAgent, or of the "*" group when there is none
+func parseRobots(r io.Reader, userAgent string) *robotsRules {
+	agent := strings.ToLower(userAgent)
+	groups := map[string]*robotsRules{}
+	var current []*robotsRules
+	lastWasAgent := false
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		if key == "user-agent" {
+			// Consecutive User-agent lines share one group
+			if !lastWasAgent {
+				current = nil
+			}
+			name := strings.ToLower(value)
+			rules, exists := groups[name]
+			if !exists {
+				rules = &robotsRules{}
+				groups[name] = rules
+			}
+			current = append(current, rules)
+			lastWasAgent = true
+			continue
+		}
+		lastWasAgent = false
+
+		for _, rules := range current {
+			switch key {
+			case "allow":
+				if value != "" {
+					rules.allow = append(rules.allow, value)
+				}
+			case "disallow":
+				if value != "" {
+					rules.disallow = append(rules.disallow, value)
+				}
+			case "crawl-delay":
+				if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+					rules.crawlDelay = time.Duration(seconds * float64(time.Second))
+				}
+			}
+		}
+	}
+
+	for name, rules := range groups {
+		if name != "*" && strings.Contains(agent, name) {
+			return rules
+		}
+	}
+	if rules, ok := groups["*"]; ok {
+		return rules
+	}
+	return &robotsRules{}
+}
+
+// allowed reports whether path may be fetched: the longest matching rule wins, Allow wins ties
+func (r *robotsRules) allowed(path string) bool {
+	if r == nil {
+		return true
+	}
+	best, allow := -1, true
+	for _, rule := range r.disallow {
+		if matchRobotsRule(rule, path) && len(rule) > best {
+			best, allow = len(rule), false
+		}
+	}
+	for _, rule := range r.allow {
+		if matchRobotsRule(rule, path) && len(rule) >= best {
+			best, allow = len(rule), true
+		}
+	}
+	return allow
+}
+
+// matchRobotsRule matches a robots.txt path rule with "*" wildcards and a "$" end anchor
+func matchRobotsRule(rule, path string) bool {
+	anchored := strings.HasSuffix(rule, "$")
+	rule = strings.TrimSuffix(rule, "$")
+	parts := strings.Split(rule, "*")
+
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+	if len(parts) == 1 {
+		return !anchored || rest == ""
+	}
+
+	middle, last := parts[1:len(parts)-1], parts[len(parts)-1]
+	for _, part := range middle {
+		i := strings.Index(rest, part)
+		if i < 0 {
+			return false
+		}
+		rest = rest[i+len(part):]
+	}
+	if anchored {
+		return strings.HasSuffix(rest, last)
+	}
+	return strings.Contains(rest, last)
+}
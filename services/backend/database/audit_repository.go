@@ -0,0 +1,69 @@
+package database
+
+import (
+	"fmt"
+	"time"
+)
+
+// AuditRepository handles audit log database operations using GORM
+type AuditRepository struct {
+	db *DB
+}
+
+// NewAuditRepository creates a new AuditRepository
+func NewAuditRepository(db *DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+// AuditFilter narrows an audit log listing; zero fields match everything
+type AuditFilter struct {
+	ActorID    uint
+	Action     string
+	TargetType string
+	TargetID   string
+	Since      time.Time
+	Until      time.Time
+}
+
+// Create stores an audit log entry
+func (r *AuditRepository) Create(entry *AuditLog) error {
+	if err := r.db.Conn.Create(entry).Error; err != nil {
+		return fmt.Errorf("failed to create audit log entry: %w", err)
+	}
+	return nil
+}
+
+// List retrieves audit log entries matching the filter, newest first
+func (r *AuditRepository) List(filter AuditFilter, limit, offset int) ([]AuditLog, error) {
+	query := r.db.Conn.Model(&AuditLog{})
+	if filter.ActorID != 0 {
+		query = query.Where("actor_id = ?", filter.ActorID)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.TargetType != "" {
+		query = query.Where("target_type = ?", filter.TargetType)
+	}
+	if filter.TargetID != "" {
+		query = query.Where("target_id = ?", filter.TargetID)
+	}
+	if !filter.Since.IsZero() {
+		query = query.Where("created_at >= ?", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		query = query.Where("created_at < ?", filter.Until)
+	}
+
+	var entries []AuditLog
+	err := query.Order("created_at DESC, id DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&entries).Error
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get audit log: %w", err)
+	}
+
+	return entries, nil
+}
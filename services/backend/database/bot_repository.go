@@ -1,11 +1,16 @@
 package database
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 
 	"gorm.io/gorm"
 )
 
+// ErrBotNotOwned is returned by Delete for a bot that doesn't exist, is already deleted or belongs to another user
+var ErrBotNotOwned = errors.New("bot not found or not owned by user")
+
 // BotRepository handles bot database operations using GORM
 type BotRepository struct {
 	db *DB
@@ -16,9 +21,10 @@ func NewBotRepository(db *DB) *BotRepository {
 	return &BotRepository{db: db}
 }
 
-// Create creates a new bot (UUID generated automatically by BeforeCreate hook)
+// Create creates a new bot (UUID generated automatically by BeforeCreate hook).
+// All columns are written explicitly so false booleans are not replaced by column defaults.
 func (r *BotRepository) Create(bot *Bot) (*Bot, error) {
-	if err := r.db.Conn.Create(bot).Error; err != nil {
+	if err := r.db.Conn.Select("*").Create(bot).Error; err != nil {
 		return nil, fmt.Errorf("failed to create bot: %w", err)
 	}
 	return bot, nil
@@ -53,10 +59,28 @@ func (r *BotRepository) GetByOwnerID(ownerID uint) ([]*Bot, error) {
 	return bots, nil
 }
 
-// Update updates an existing bot
+// ListAll retrieves bots of all owners, including inactive and deleted ones (admin use)
+func (r *BotRepository) ListAll(limit, offset int) ([]*Bot, error) {
+	var bots []*Bot
+	err := r.db.Conn.Unscoped().Order("created_at DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&bots).Error
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to list bots: %w", err)
+	}
+
+	return bots, nil
+}
+
+// Update updates an existing bot.
+// All mutable columns are written so zero values (e.g. is_public = false) are persisted.
 func (r *BotRepository) Update(bot *Bot) error {
 	result := r.db.Conn.Model(bot).
 		Where("id = ? AND is_active = ?", bot.ID, true).
+		Select("*").
+		Omit("id", "owner_id", "created_at", "deleted_at").
 		Updates(bot)
 
 	if result.Error != nil {
@@ -69,17 +93,80 @@ func (r *BotRepository) Update(bot *Bot) error {
 	return nil
 }
 
-// Delete soft deletes a bot by setting is_active to false
+// Delete soft deletes a bot (sets deleted_at), moving it to the owner's trash
 func (r *BotRepository) Delete(id string, ownerID uint) error {
-	result := r.db.Conn.Model(&Bot{}).
-		Where("id = ? AND owner_id = ? AND is_active = ?", id, ownerID, true).
-		Update("is_active", false)
+	result := r.db.Conn.
+		Where("id = ? AND owner_id = ?", id, ownerID).
+		Delete(&Bot{})
 
 	if result.Error != nil {
 		return fmt.Errorf("failed to delete bot: %w", result.Error)
 	}
 	if result.RowsAffected == 0 {
-		return fmt.Errorf("bot not found or not owned by user")
+		return ErrBotNotOwned
+	}
+
+	return nil
+}
+
+// ListDeleted retrieves the owner's bots in the trash
+func (r *BotRepository) ListDeleted(ownerID uint) ([]*Bot, error) {
+	var bots []*Bot
+	err := r.db.Conn.Unscoped().Where("owner_id = ? AND deleted_at IS NOT NULL", ownerID).
+		Order("deleted_at DESC").
+		Find(&bots).Error
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deleted bots: %w", err)
+	}
+
+	return bots, nil
+}
+
+// GetDeleted retrieves a bot from the owner's trash
+func (r *BotRepository) GetDeleted(id string, ownerID uint) (*Bot, error) {
+	var bot Bot
+	err := r.db.Conn.Unscoped().Where("id = ? AND owner_id = ? AND deleted_at IS NOT NULL", id, ownerID).
+		First(&bot).Error
+
+	if err == gorm.ErrRecordNotFound {
+		return nil, fmt.Errorf("bot not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bot: %w", err)
+	}
+
+	return &bot, nil
+}
+
+// Restore takes a bot out of the owner's trash
+func (r *BotRepository) Restore(id string, ownerID uint) error {
+	result := r.db.Conn.Unscoped().Model(&Bot{}).
+		Where("id = ? AND owner_id = ? AND deleted_at IS NOT NULL", id, ownerID).
+		Update("deleted_at", nil)
+
+	if result.Error != nil {
+		return fmt.Errorf("failed to restore bot: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("bot not found")
+	}
+
+	return nil
+}
+
+// SetActive enables or disables a bot regardless of owner (admin moderation), including bots in the trash.
+// A disabled bot stays disabled when its owner restores it.
+func (r *BotRepository) SetActive(id string, active bool) error {
+	result := r.db.Conn.Unscoped().Model(&Bot{}).
+		Where("id = ?", id).
+		Update("is_active", active)
+
+	if result.Error != nil {
+		return fmt.Errorf("failed to update bot status: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("bot not found")
 	}
 
 	return nil
@@ -93,10 +180,53 @@ func (r *BotRepository) AddDocument(doc *BotDocument) error {
 	return nil
 }
 
-// GetDocuments retrieves all documents for a bot
+// LatestDocumentVersion returns the highest version of a bot's document with this filename, or 0 if there is none
+func (r *BotRepository) LatestDocumentVersion(botID, filename string) (int, error) {
+	var version int
+	err := r.db.Conn.Model(&BotDocument{}).
+		Select("COALESCE(MAX(version), 0)").
+		Where("bot_id = ? AND filename = ?", botID, filename).
+		Scan(&version).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to get document version: %w", err)
+	}
+	return version, nil
+}
+
+// FindDocumentByHash returns the bot's document uploaded from a file with this SHA-256, or nil if there is none
+func (r *BotRepository) FindDocumentByHash(botID, hash string) (*BotDocument, error) {
+	var doc BotDocument
+	err := r.db.Conn.Omit("text").
+		Where("bot_id = ? AND content_hash = ?", botID, hash).
+		Order("uploaded_at DESC").
+		First(&doc).Error
+
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find document by hash: %w", err)
+	}
+
+	return &doc, nil
+}
+
+// DeleteOtherVersions removes the records of a bot's document versions other than keepID and returns how many were removed
+func (r *BotRepository) DeleteOtherVersions(botID, filename string, keepID uint) (int64, error) {
+	result := r.db.Conn.
+		Where("bot_id = ? AND filename = ? AND id <> ?", botID, filename, keepID).
+		Delete(&BotDocument{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to delete document versions: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// GetDocuments retrieves all documents for a bot (without the stored text to keep listings small)
 func (r *BotRepository) GetDocuments(botID string) ([]BotDocument, error) {
 	var docs []BotDocument
-	err := r.db.Conn.Where("bot_id = ?", botID).
+	err := r.db.Conn.Omit("text").
+		Where("bot_id = ?", botID).
 		Order("uploaded_at DESC").
 		Find(&docs).Error
 
@@ -107,6 +237,71 @@ func (r *BotRepository) GetDocuments(botID string) ([]BotDocument, error) {
 	return docs, nil
 }
 
+// likeEscaper escapes the LIKE wildcards of a search query so they match literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// SearchDocuments finds a bot's documents whose file name or type contains query (case-insensitive),
+// newest first, and returns one page of them with the total number of matches
+func (r *BotRepository) SearchDocuments(botID, query string, limit, offset int) ([]BotDocument, int64, error) {
+	pattern := "%" + likeEscaper.Replace(query) + "%"
+	scope := r.db.Conn.Model(&BotDocument{}).
+		Where("bot_id = ?", botID).
+		Where("(filename ILIKE ? OR file_type ILIKE ?)", pattern, pattern).
+		Session(&gorm.Session{}) // Shared by the count and the page query
+
+	var total int64
+	if err := scope.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count documents: %w", err)
+	}
+
+	var docs []BotDocument
+	err := scope.Omit("text").
+		Order("uploaded_at DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&docs).Error
+
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to search documents: %w", err)
+	}
+
+	return docs, total, nil
+}
+
+// GetUsage sums the chunks and bytes of documents indexed for the owner's active bots
+func (r *BotRepository) GetUsage(ownerID uint) (*StorageUsage, error) {
+	var usage StorageUsage
+	// A subquery on the Bot model, unlike a join, leaves out deleted bots automatically
+	bots := r.db.Conn.Model(&Bot{}).Select("id").Where("owner_id = ? AND is_active = ?", ownerID, true)
+	err := r.db.Conn.Model(&BotDocument{}).
+		Select("COALESCE(SUM(chunks_count), 0) AS chunks, COALESCE(SUM(file_size), 0) AS bytes").
+		Where("bot_id IN (?)", bots).
+		Scan(&usage).Error
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get storage usage: %w", err)
+	}
+
+	return &usage, nil
+}
+
+// GetAccountSummary counts the owner's active bots and totals their documents in a single aggregate query
+func (r *BotRepository) GetAccountSummary(ownerID uint) (*AccountSummary, error) {
+	var summary AccountSummary
+	err := r.db.Conn.Model(&Bot{}).
+		Select("COUNT(DISTINCT bots.id) AS bots, COUNT(bot_documents.id) AS documents, "+
+			"COALESCE(SUM(bot_documents.chunks_count), 0) AS chunks, COALESCE(SUM(bot_documents.file_size), 0) AS bytes").
+		Joins("LEFT JOIN bot_documents ON bot_documents.bot_id = bots.id").
+		Where("bots.owner_id = ? AND bots.is_active = ?", ownerID, true).
+		Scan(&summary).Error
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account summary: %w", err)
+	}
+
+	return &summary, nil
+}
+
 // CheckOwnership verifies if a user owns a specific bot
 func (r *BotRepository) CheckOwnership(botID string, ownerID uint) (bool, error) {
 	var count int64
@@ -62,28 +62,3 @@ func (db *DB) Close() error {
 	}
 	return nil
 }
-
-// AutoMigrate runs database migrations for all models
-func (db *DB) AutoMigrate() error {
-	// Handle constraint migration issues by checking if old constraint exists
-	if db.Conn.Migrator().HasTable(&User{}) {
-		// Check if old constraint exists before GORM tries to drop it
-		var count int64
-		db.Conn.Raw(`
-			SELECT COUNT(*) FROM information_schema.table_constraints 
-			WHERE constraint_name = 'uni_users_email' 
-			AND table_name = 'users'
-		`).Scan(&count)
-
-		// If old constraint exists, drop it manually
-		if count > 0 {
-			db.Conn.Exec(`ALTER TABLE users DROP CONSTRAINT IF EXISTS uni_users_email`)
-		}
-	}
-
-	return db.Conn.AutoMigrate(
-		&User{},
-		&Bot{},
-		&BotDocument{},
-	)
-}
@@ -0,0 +1,61 @@
+package database
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// IntegrationRepository handles bot integration database operations using GORM
+type IntegrationRepository struct {
+	db *DB
+}
+
+// NewIntegrationRepository creates a new IntegrationRepository
+func NewIntegrationRepository(db *DB) *IntegrationRepository {
+	return &IntegrationRepository{db: db}
+}
+
+// Upsert creates the integration of a bot or replaces its credentials
+func (r *IntegrationRepository) Upsert(integration *BotIntegration) error {
+	err := r.db.Conn.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "bot_id"}, {Name: "provider"}},
+		DoUpdates: clause.AssignmentColumns([]string{"credentials", "is_active", "updated_at"}),
+	}).Select("*").Omit("id").Create(integration).Error
+
+	if err != nil {
+		return fmt.Errorf("failed to save integration: %w", err)
+	}
+	return nil
+}
+
+// Get retrieves the active integration of a bot for a provider
+func (r *IntegrationRepository) Get(botID, provider string) (*BotIntegration, error) {
+	var integration BotIntegration
+	err := r.db.Conn.Where("bot_id = ? AND provider = ? AND is_active = ?", botID, provider, true).
+		First(&integration).Error
+
+	if err == gorm.ErrRecordNotFound {
+		return nil, fmt.Errorf("integration not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get integration: %w", err)
+	}
+
+	return &integration, nil
+}
+
+// Delete removes the integration of a bot for a provider
+func (r *IntegrationRepository) Delete(botID, provider string) error {
+	result := r.db.Conn.Where("bot_id = ? AND provider = ?", botID, provider).Delete(&BotIntegration{})
+
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete integration: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("integration not found")
+	}
+
+	return nil
+}
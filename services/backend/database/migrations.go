@@ -0,0 +1,143 @@
+package database
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// migrationLockID is the Postgres advisory lock held while migrating, so that replicas starting together
+// migrate one at a time instead of running DDL concurrently (which can deadlock)
+const migrationLockID = 4210738001
+
+// SchemaMigration records a one-off schema change applied to the database
+type SchemaMigration struct {
+	Version   int       `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"size:255;not null"`
+	AppliedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// schemaChange is DDL that AutoMigrate can't express. Changes are applied once, in version order,
+// and recorded in schema_migrations; run always comes before the model migration.
+type schemaChange struct {
+	version int
+	name    string
+	run     func(tx *gorm.DB) error
+}
+
+// schemaChanges must only be appended to: versions of applied changes are never reused
+var schemaChanges = []schemaChange{
+	{
+		version: 1,
+		name:    "drop the uni_users_email constraint left by older GORM versions",
+		// GORM would try to drop the old constraint itself and fail; the unique index on email replaces it
+		run: func(tx *gorm.DB) error {
+			if !tx.Migrator().HasTable(&User{}) {
+				return nil
+			}
+			return tx.Exec(`ALTER TABLE users DROP CONSTRAINT IF EXISTS uni_users_email`).Error
+		},
+	},
+	{
+		version: 2,
+		name:    "drop single-column indexes covered by idx_bots_owner_active and idx_bot_documents_bot_uploaded",
+		// The composite indexes that replace them are created by the model migration right after
+		run: func(tx *gorm.DB) error {
+			if err := tx.Exec(`DROP INDEX IF EXISTS idx_bots_owner_id`).Error; err != nil {
+				return err
+			}
+			return tx.Exec(`DROP INDEX IF EXISTS idx_bot_documents_bot_id`).Error
+		},
+	},
+	{
+		version: 3,
+		name:    "re-enable bots in the trash: deleted_at alone marks them deleted now",
+		// Deleting a bot used to clear is_active as well; left as is, restored bots would come back disabled.
+		// Only active bots could be deleted, and a trashed bot could only be changed afterwards by an admin
+		// deactivating it, which also bumped updated_at: bots updated after they were deleted stay disabled,
+		// so restoring them doesn't undo moderation.
+		run: func(tx *gorm.DB) error {
+			if !tx.Migrator().HasColumn(&Bot{}, "deleted_at") {
+				return nil
+			}
+			return tx.Exec(`UPDATE bots SET is_active = true
+				WHERE deleted_at IS NOT NULL AND is_active = false
+				AND (updated_at IS NULL OR updated_at <= deleted_at + interval '1 second')`).Error
+		},
+	},
+}
+
+// AutoMigrate runs database migrations for all models. It holds an advisory lock for the whole run,
+// so it is safe to call from several instances at once; they migrate one after another.
+func (db *DB) AutoMigrate() error {
+	return db.Conn.Connection(func(conn *gorm.DB) error {
+		// Session-level lock: it must be taken and released on the same pinned connection
+		if err := conn.Exec(`SELECT pg_advisory_lock(?)`, migrationLockID).Error; err != nil {
+			return fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+		defer conn.Exec(`SELECT pg_advisory_unlock(?)`, migrationLockID)
+		return migrate(conn)
+	})
+}
+
+func migrate(conn *gorm.DB) error {
+	if err := applySchemaChanges(conn); err != nil {
+		return err
+	}
+
+	if err := conn.AutoMigrate(
+		&User{},
+		&Bot{},
+		&BotDocument{},
+		&Webhook{},
+		&BotIntegration{},
+		&AuditLog{},
+		&ReembedJob{},
+	); err != nil {
+		return err
+	}
+
+	// Trigram indexes make the substring search of BotRepository.SearchDocuments use an index.
+	// pg_trgm needs a privileged role to install; without it the search still works, just by scanning the bot's rows.
+	if err := conn.Exec(`CREATE EXTENSION IF NOT EXISTS pg_trgm`).Error; err != nil {
+		log.Printf("⚠️ pg_trgm is not available, document search will not be indexed: %v", err)
+		return nil
+	}
+	for _, column := range []string{"filename", "file_type"} {
+		err := conn.Exec(fmt.Sprintf(`CREATE INDEX IF NOT EXISTS idx_bot_documents_%[1]s_trgm ON bot_documents USING gin (%[1]s gin_trgm_ops)`, column)).Error
+		if err != nil {
+			return fmt.Errorf("failed to create %s search index: %w", column, err)
+		}
+	}
+	return nil
+}
+
+// applySchemaChanges runs the schema changes newer than the database's latest recorded version
+func applySchemaChanges(conn *gorm.DB) error {
+	if err := conn.AutoMigrate(&SchemaMigration{}); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	var current int
+	if err := conn.Model(&SchemaMigration{}).Select("COALESCE(MAX(version), 0)").Scan(&current).Error; err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	for _, change := range schemaChanges {
+		if change.version <= current {
+			continue
+		}
+		err := conn.Transaction(func(tx *gorm.DB) error {
+			if err := change.run(tx); err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{Version: change.version, Name: change.name}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("schema change %d (%s) failed: %w", change.version, change.name, err)
+		}
+		log.Printf("✓ Applied schema change %d: %s", change.version, change.name)
+	}
+	return nil
+}
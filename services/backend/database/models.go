@@ -1,6 +1,11 @@
 package database
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -9,12 +14,23 @@ import (
 
 // User represents a registered user
 type User struct {
-	ID           uint      `gorm:"primaryKey" json:"id"`
-	Email        string    `gorm:"unique;not null;size:255" json:"email"`
-	PasswordHash string    `gorm:"not null;size:255" json:"-"` // Never expose in JSON
-	Name         string    `gorm:"size:255" json:"name"`
-	CreatedAt    time.Time `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt    time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+	ID           uint   `gorm:"primaryKey" json:"id"`
+	Email        string `gorm:"unique;not null;size:255" json:"email"`
+	PasswordHash string `gorm:"not null;size:255" json:"-"` // Never expose in JSON
+	Name         string `gorm:"size:255" json:"name"`
+	Role         string `gorm:"size:20;not null;default:'user'" json:"role"`
+	// Storage quota overrides; nil = global QUOTA_MAX_* default, 0 = unlimited
+	QuotaMaxChunks *int      `json:"quota_max_chunks,omitempty"`
+	QuotaMaxBytes  *int64    `json:"quota_max_bytes,omitempty"`
+	CreatedAt      time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt      time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+
+	// Login activity and lockout after repeated failed logins
+	LastLoginAt      *time.Time `json:"last_login_at,omitempty"`
+	FailedLoginCount int        `gorm:"not null;default:0" json:"-"` // Failures since the last successful login or lockout
+	LockedUntil      *time.Time `json:"locked_until,omitempty"`
+	// Tokens carry the version they were issued with; increasing it revokes every token issued before
+	TokenVersion int `gorm:"not null;default:0" json:"-"`
 
 	// Relationships
 	Bots []Bot `gorm:"foreignKey:OwnerID" json:"bots,omitempty"`
@@ -22,11 +38,11 @@ type User struct {
 
 // Bot represents a configured chatbot
 type Bot struct {
-	ID          string `gorm:"type:uuid;primaryKey" json:"id"`
-	OwnerID     uint   `gorm:"not null;index" json:"owner_id"`
-	Name        string `gorm:"not null;size:255" json:"name"`
-	Description string `gorm:"type:text" json:"description"`
-	Config      string `gorm:"type:jsonb;default:'{}'" json:"config"`
+	ID          string    `gorm:"type:uuid;primaryKey" json:"id"`
+	OwnerID     uint      `gorm:"not null;index:idx_bots_owner_active,priority:1" json:"owner_id"`
+	Name        string    `gorm:"not null;size:255" json:"name"`
+	Description string    `gorm:"type:text" json:"description"`
+	Config      BotConfig `gorm:"type:jsonb;default:'{}'" json:"config"`
 
 	// Generation parameters
 	Temperature  float64 `gorm:"default:0.75" json:"temperature"`
@@ -37,13 +53,23 @@ type Bot struct {
 	SystemPrompt string  `gorm:"type:text" json:"system_prompt"`
 
 	// RAG settings
-	ChunkSize    int `gorm:"default:800" json:"chunk_size"`
-	ChunkOverlap int `gorm:"default:200" json:"chunk_overlap"`
+	ChunkSize          int `gorm:"default:800" json:"chunk_size"`
+	ChunkOverlap       int `gorm:"default:200" json:"chunk_overlap"`
+	ModelContextTokens int `gorm:"default:0" json:"model_context_tokens"` // 0 = use the global RAG_MODEL_CONTEXT_TOKENS
+	RerankCandidates   int `gorm:"default:0" json:"rerank_candidates"`    // 0 = use the global RAG_MAX_RESULTS
+	RerankTopK         int `gorm:"default:0" json:"rerank_top_k"`         // 0 = use the global RAG_RERANK_TOP_K
+	// Embedding model of the bot's vectors; "" = the AI service default (EMBEDDING_MODEL_NAME)
+	EmbeddingModel string `gorm:"size:255;default:''" json:"embedding_model"`
+	// Whether texts get the "query: "/"passage: " instruction prefix; nil = decided by model name (e5 models)
+	UsesInstructionPrefix *bool `json:"uses_instruction_prefix"`
 
-	// Status
-	IsActive  bool      `gorm:"default:true;index" json:"is_active"`
-	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+	// Status. IsActive = false means disabled by an admin; DeletedAt is set when the owner deletes the bot
+	// (moves it to the trash), and GORM leaves such bots out of queries unless Unscoped
+	IsActive  bool           `gorm:"default:true;index;index:idx_bots_owner_active,priority:2" json:"is_active"`
+	IsPublic  bool           `gorm:"not null;default:true" json:"is_public"` // Private bots are visible to the owner only
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at"`
+	CreatedAt time.Time      `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
 
 	// Relationships
 	Owner     User          `gorm:"foreignKey:OwnerID" json:"owner,omitempty"`
@@ -58,20 +84,238 @@ func (b *Bot) BeforeCreate(tx *gorm.DB) error {
 	return nil
 }
 
-// BotDocument represents metadata about documents uploaded for a bot
+// StorageUsage is the total size of the documents indexed for a user's bots
+type StorageUsage struct {
+	Chunks int64 `json:"chunks"`
+	Bytes  int64 `json:"bytes"`
+}
+
+// AccountSummary counts a user's active bots and the documents indexed for them
+type AccountSummary struct {
+	Bots      int64 `json:"bots"`
+	Documents int64 `json:"documents"`
+	Chunks    int64 `json:"chunks"`
+	Bytes     int64 `json:"bytes"`
+}
+
+// BotDocument represents metadata about documents uploaded for a bot.
+// Listings filter by bot and sort by upload time, which idx_bot_documents_bot_uploaded serves.
 type BotDocument struct {
 	ID          uint      `gorm:"primaryKey" json:"id"`
-	BotID       string    `gorm:"type:uuid;not null;index" json:"bot_id"`
+	BotID       string    `gorm:"type:uuid;not null;index:idx_bot_documents_bot_uploaded,priority:1" json:"bot_id"`
 	Filename    string    `gorm:"not null;size:255" json:"filename"`
 	FileType    string    `gorm:"size:50" json:"file_type"`
 	FileSize    int64     `json:"file_size"`
 	ChunksCount int       `gorm:"default:0" json:"chunks_count"`
-	UploadedAt  time.Time `gorm:"autoCreateTime;column:uploaded_at" json:"uploaded_at"`
+	Version     int       `gorm:"not null;default:1" json:"version"`           // Increases with each upload of the same filename
+	Text        string    `gorm:"type:text" json:"text,omitempty"`             // Original parsed text, canonical copy for reindexing
+	ContentHash string    `gorm:"size:64;index" json:"content_hash,omitempty"` // SHA-256 of the uploaded file (empty for crawled pages)
+	UploadedAt  time.Time `gorm:"autoCreateTime;column:uploaded_at;index:idx_bot_documents_bot_uploaded,priority:2" json:"uploaded_at"`
 
 	// Relationships
 	Bot Bot `gorm:"foreignKey:BotID" json:"bot,omitempty"`
 }
 
+// Webhook is an owner-configured endpoint notified about bot events
+type Webhook struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	BotID     string    `gorm:"type:uuid;not null;index" json:"bot_id"`
+	URL       string    `gorm:"not null;size:2048" json:"url"`
+	Secret    string    `gorm:"not null;size:255" json:"-"` // HMAC signing key, returned only on creation
+	Events    []string  `gorm:"serializer:json;type:jsonb;not null" json:"events"`
+	IsActive  bool      `gorm:"not null;default:true" json:"is_active"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// Integration providers
+const (
+	ProviderTelegram = "telegram"
+	ProviderSlack    = "slack"
+)
+
+// BotIntegration stores the credentials of a messenger integration of a bot (one per provider)
+type BotIntegration struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	BotID       string    `gorm:"type:uuid;not null;uniqueIndex:idx_bot_integrations_bot_provider" json:"bot_id"`
+	Provider    string    `gorm:"size:20;not null;uniqueIndex:idx_bot_integrations_bot_provider" json:"provider"`
+	Credentials string    `gorm:"type:text;not null" json:"-"` // Encrypted JSON, see secrets.Box
+	IsActive    bool      `gorm:"not null;default:true" json:"is_active"`
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// AuditLog records a sensitive operation: who did what to which target, and when.
+// There are no foreign keys, so entries outlive the users and bots they mention.
+type AuditLog struct {
+	ID         uint           `gorm:"primaryKey" json:"id"`
+	ActorID    uint           `gorm:"not null;index" json:"actor_id"`
+	Action     string         `gorm:"size:64;not null;index" json:"action"`
+	TargetType string         `gorm:"size:32;not null;index:idx_audit_logs_target" json:"target_type"`
+	TargetID   string         `gorm:"size:255;not null;index:idx_audit_logs_target" json:"target_id"`
+	Details    map[string]any `gorm:"serializer:json;type:jsonb" json:"details,omitempty"`
+	IP         string         `gorm:"size:64" json:"ip,omitempty"`
+	CreatedAt  time.Time      `gorm:"not null;index" json:"created_at"`
+}
+
+// Re-embedding job statuses
+const (
+	ReembedRunning   = "running"
+	ReembedCompleted = "completed"
+	ReembedFailed    = "failed"
+)
+
+// ReembedStaleAfter is how long a running job may go without saving its progress before it is taken to be dead
+// (its backend replica stopped). Jobs save after every batch, so a live one is never this quiet.
+const ReembedStaleAfter = 10 * time.Minute
+
+// ReembedJob tracks re-embedding the chunks of a bot into a new vector collection (POST /admin/reembed/:bot_id).
+// Collection and Cursor record how far it got, so a failed or interrupted job resumes instead of starting over.
+type ReembedJob struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	BotID          string     `gorm:"type:uuid;not null;index;uniqueIndex:idx_reembed_jobs_running,where:status = 'running'" json:"bot_id"`
+	Status         string     `gorm:"size:16;not null" json:"status"`                      // At most one running job per bot, across replicas
+	EmbeddingModel string     `gorm:"size:255;not null;default:''" json:"embedding_model"` // Target model; "" = the AI service default
+	Collection     string     `gorm:"size:255;not null;default:''" json:"collection"`      // Staging collection; "" until the first batch
+	Cursor         string     `gorm:"size:64;not null;default:''" json:"-"`                // Next page of the old collection; "" = the start
+	Total          int        `gorm:"not null;default:0" json:"total"`                     // Chunks in the old collection when the job started
+	Processed      int        `gorm:"not null;default:0" json:"processed"`
+	Error          string     `gorm:"type:text" json:"error,omitempty"`
+	StartedBy      uint       `gorm:"not null" json:"started_by"`
+	CreatedAt      time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt      time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+	FinishedAt     *time.Time `json:"finished_at,omitempty"`
+}
+
+// Widget defaults used when the owner has not customized the chat widget
+const (
+	DefaultWidgetColor       = "#2563eb"
+	DefaultWidgetPlaceholder = "Type your message..."
+)
+
+// WidgetConfig holds the public theming and behavior settings of the embeddable chat widget
+type WidgetConfig struct {
+	WelcomeMessage string `json:"welcome_message" validate:"max=500"`
+	PrimaryColor   string `json:"primary_color" validate:"omitempty,hexcolor"`
+	Placeholder    string `json:"placeholder" validate:"max=100"`
+	ShowSources    bool   `json:"show_sources"`
+}
+
+// BotConfig holds the owner-defined bot settings stored in the bots.config jsonb column
+type BotConfig struct {
+	Widget          WidgetConfig `json:"widget"`
+	AllowedDomains  []string     `json:"allowed_domains,omitempty" validate:"max=50,dive,hostname"` // Empty = embeddable anywhere
+	FallbackAnswer  string       `json:"fallback_answer,omitempty" validate:"max=1000"`             // Sent instead of calling the model when context is insufficient
+	MinContextChars int          `json:"min_context_chars,omitempty" validate:"gte=0,lte=100000"`   // Retrieved context below this size counts as "nothing found"
+	MinTopScore     float64      `json:"min_top_score,omitempty" validate:"gte=0,lte=1"`            // A best vector score below this counts as "nothing found" (0 = off)
+	RewriteQuery    bool         `json:"rewrite_query,omitempty"`                                   // Spell-correct and expand the query with the AI service before retrieval
+	PII             PIIConfig    `json:"pii"`
+
+	// First message of a chat, served by the greeting endpoint
+	Greeting           string   `json:"greeting,omitempty" validate:"max=500"` // Empty = the widget's welcome message
+	SuggestedQuestions []string `json:"suggested_questions,omitempty" validate:"max=10,dive,min=1,max=200"`
+
+	// Guardrails for public chat and messenger queries, checked before retrieval
+	MaxQueryChars  int      `json:"max_query_chars,omitempty" validate:"gte=0,lte=10000"`            // 0 = global limit only
+	BlockedPhrases []string `json:"blocked_phrases,omitempty" validate:"max=200,dive,min=1,max=200"` // Case-insensitive; queries containing one are rejected
+}
+
+// BlockedPhrase returns the first blocked phrase contained in query (case and spacing are ignored), or "" if none is
+func (c BotConfig) BlockedPhrase(query string) string {
+	normalized := strings.Join(strings.Fields(strings.ToLower(query)), " ")
+	for _, phrase := range c.BlockedPhrases {
+		phrase = strings.Join(strings.Fields(strings.ToLower(phrase)), " ")
+		if phrase != "" && strings.Contains(normalized, phrase) {
+			return phrase
+		}
+	}
+	return ""
+}
+
+// PII query actions
+const (
+	PIIActionFlag   = "flag"   // Log and redact the query before it reaches the model
+	PIIActionReject = "reject" // Reject the query
+)
+
+// PIIConfig controls redaction of sensitive data (emails, phone and card numbers) in documents and queries
+type PIIConfig struct {
+	Enabled        bool     `json:"enabled"`
+	Rules          []string `json:"rules,omitempty" validate:"dive,oneof=email phone credit_card"` // Empty = all built-in rules
+	CustomPatterns []string `json:"custom_patterns,omitempty" validate:"max=20,dive,max=500,regexp"`
+	QueryAction    string   `json:"query_action,omitempty" validate:"omitempty,oneof=flag reject"` // Defaults to flag
+}
+
+// Value implements driver.Valuer so BotConfig is stored as jsonb
+func (c BotConfig) Value() (driver.Value, error) {
+	encoded, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	return string(encoded), nil
+}
+
+// Scan implements sql.Scanner so BotConfig is read from jsonb
+func (c *BotConfig) Scan(value any) error {
+	var raw []byte
+	switch v := value.(type) {
+	case nil:
+		*c = BotConfig{}
+		return nil
+	case []byte:
+		raw = v
+	case string:
+		raw = []byte(v)
+	default:
+		return fmt.Errorf("unsupported bot config type %T", value)
+	}
+	*c = BotConfig{}
+	return json.Unmarshal(raw, c)
+}
+
+// AllowsOrigin reports whether the widget may be used from the page origin (e.g. https://shop.example.com).
+// Subdomains of an allowed domain are allowed too; requests without an Origin header are not restricted.
+func (c BotConfig) AllowsOrigin(origin string) bool {
+	if len(c.AllowedDomains) == 0 || origin == "" {
+		return true
+	}
+	parsed, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(parsed.Hostname())
+	for _, domain := range c.AllowedDomains {
+		domain = strings.ToLower(domain)
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// Widget returns the widget settings of the bot, filling defaults for unset fields
+func (b *Bot) Widget() WidgetConfig {
+	widget := b.Config.Widget
+	if widget.WelcomeMessage == "" {
+		widget.WelcomeMessage = fmt.Sprintf("👋 Hi! I'm %s. How can I help?", b.Name)
+	}
+	if widget.PrimaryColor == "" {
+		widget.PrimaryColor = DefaultWidgetColor
+	}
+	if widget.Placeholder == "" {
+		widget.Placeholder = DefaultWidgetPlaceholder
+	}
+	return widget
+}
+
+// Greeting returns the message a chat opens with: the configured greeting, else the widget's welcome message
+func (b *Bot) Greeting() string {
+	if b.Config.Greeting != "" {
+		return b.Config.Greeting
+	}
+	return b.Widget().WelcomeMessage
+}
+
 // PublicBot represents a bot with only public information (no config details)
 type PublicBot struct {
 	ID          string    `json:"id"`
@@ -89,3 +333,17 @@ func (b *Bot) ToPublic() PublicBot {
 		CreatedAt:   b.CreatedAt,
 	}
 }
+
+// PublicDocument represents a document with only its name and upload date (safe for external access)
+type PublicDocument struct {
+	Filename   string    `json:"filename"`
+	UploadedAt time.Time `json:"uploaded_at"`
+}
+
+// ToPublic converts a BotDocument to PublicDocument
+func (d *BotDocument) ToPublic() PublicDocument {
+	return PublicDocument{
+		Filename:   d.Filename,
+		UploadedAt: d.UploadedAt,
+	}
+}
@@ -0,0 +1,101 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrReembedRunning is returned by Start when the bot already has a running re-embedding job
+var ErrReembedRunning = errors.New("the bot is already being re-embedded")
+
+// ReembedRepository handles re-embedding job database operations using GORM
+type ReembedRepository struct {
+	db *DB
+}
+
+// NewReembedRepository creates a new ReembedRepository
+func NewReembedRepository(db *DB) *ReembedRepository {
+	return &ReembedRepository{db: db}
+}
+
+// Start marks a job running: a new job (ID 0) is created, an existing one resumed. An existing job is only
+// taken over while it is still as it was read (same status and updated_at), so two replicas can't both resume it;
+// the unique index on running jobs keeps a second job of the bot from starting. Either way ErrReembedRunning
+// is returned.
+func (r *ReembedRepository) Start(job *ReembedJob, userID uint) error {
+	job.StartedBy = userID
+	if job.ID == 0 {
+		job.Status = ReembedRunning
+		if err := r.db.Conn.Create(job).Error; err != nil {
+			if isUniqueViolation(err) {
+				return ErrReembedRunning
+			}
+			return fmt.Errorf("failed to create re-embedding job: %w", err)
+		}
+		return nil
+	}
+
+	now := time.Now()
+	result := r.db.Conn.Model(&ReembedJob{}).
+		Where("id = ? AND status = ? AND updated_at = ?", job.ID, job.Status, job.UpdatedAt).
+		Updates(map[string]any{"status": ReembedRunning, "error": "", "started_by": userID, "finished_at": nil, "updated_at": now})
+	if result.Error != nil {
+		if isUniqueViolation(result.Error) {
+			return ErrReembedRunning
+		}
+		return fmt.Errorf("failed to resume re-embedding job: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrReembedRunning
+	}
+	job.Status, job.Error, job.FinishedAt, job.UpdatedAt = ReembedRunning, "", nil, now
+	return nil
+}
+
+// Save updates a re-embedding job
+func (r *ReembedRepository) Save(job *ReembedJob) error {
+	if err := r.db.Conn.Save(job).Error; err != nil {
+		return fmt.Errorf("failed to save re-embedding job: %w", err)
+	}
+	return nil
+}
+
+// Latest returns the most recent re-embedding job of a bot, or nil if it has none
+func (r *ReembedRepository) Latest(botID string) (*ReembedJob, error) {
+	var job ReembedJob
+	err := r.db.Conn.Where("bot_id = ?", botID).Order("id DESC").First(&job).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get re-embedding job: %w", err)
+	}
+	return &job, nil
+}
+
+// Running reports whether the bot has a running job that is still saving progress
+func (r *ReembedRepository) Running(botID string) (bool, error) {
+	var count int64
+	err := r.db.Conn.Model(&ReembedJob{}).
+		Where("bot_id = ? AND status = ? AND updated_at > ?", botID, ReembedRunning, time.Now().Add(-ReembedStaleAfter)).
+		Count(&count).Error
+	if err != nil {
+		return false, fmt.Errorf("failed to check re-embedding jobs: %w", err)
+	}
+	return count > 0, nil
+}
+
+// MarkInterrupted fails the running jobs that stopped saving progress (their replica stopped), so they can be
+// resumed. Jobs of other replicas that are still running are left alone. It returns how many jobs were marked.
+func (r *ReembedRepository) MarkInterrupted() (int64, error) {
+	result := r.db.Conn.Model(&ReembedJob{}).
+		Where("status = ? AND updated_at <= ?", ReembedRunning, time.Now().Add(-ReembedStaleAfter)).
+		Updates(map[string]any{"status": ReembedFailed, "error": "interrupted: the backend running it stopped"})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to mark interrupted re-embedding jobs: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
@@ -4,6 +4,16 @@ CREATE TABLE IF NOT EXISTS users (
     email VARCHAR(255) UNIQUE NOT NULL,
     password_hash VARCHAR(255) NOT NULL,
     name VARCHAR(255),
+    role VARCHAR(20) NOT NULL DEFAULT 'user',
+    -- Storage quota overrides (NULL = global default, 0 = unlimited)
+    quota_max_chunks INTEGER,
+    quota_max_bytes BIGINT,
+    -- Login activity and lockout after repeated failed logins
+    last_login_at TIMESTAMP WITH TIME ZONE,
+    failed_login_count INTEGER NOT NULL DEFAULT 0,
+    locked_until TIMESTAMP WITH TIME ZONE,
+    -- Tokens issued with an older version are rejected (bumped on password change)
+    token_version INTEGER NOT NULL DEFAULT 0,
     created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
 );
@@ -29,14 +39,21 @@ CREATE TABLE IF NOT EXISTS bots (
     -- RAG settings (chunk configuration)
     chunk_size INTEGER DEFAULT 800,
     chunk_overlap INTEGER DEFAULT 200,
+    model_context_tokens INTEGER DEFAULT 0,
+    rerank_candidates INTEGER DEFAULT 0,
+    rerank_top_k INTEGER DEFAULT 0,
+    embedding_model VARCHAR(255) DEFAULT '',
+    uses_instruction_prefix BOOLEAN, -- NULL = query/passage prefixes decided by model name (e5)
     -- Status
     is_active BOOLEAN DEFAULT true,
+    is_public BOOLEAN NOT NULL DEFAULT true,
+    deleted_at TIMESTAMP WITH TIME ZONE, -- Set when the owner moves the bot to the trash (is_active is the admin's switch)
     created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
 );
 
 -- Indexes for bots
-CREATE INDEX IF NOT EXISTS idx_bots_owner_id ON bots(owner_id);
+CREATE INDEX IF NOT EXISTS idx_bots_owner_active ON bots(owner_id, is_active);
 CREATE INDEX IF NOT EXISTS idx_bots_is_active ON bots(is_active);
 
 -- Bot documents tracking (metadata only, actual vectors in Qdrant)
@@ -47,10 +64,92 @@ CREATE TABLE IF NOT EXISTS bot_documents (
     file_type VARCHAR(50),
     file_size BIGINT,
     chunks_count INTEGER DEFAULT 0,
+    version INTEGER NOT NULL DEFAULT 1,
+    text TEXT,
+    content_hash VARCHAR(64),
     uploaded_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
 );
 
-CREATE INDEX IF NOT EXISTS idx_bot_documents_bot_id ON bot_documents(bot_id);
+-- Serves the per-bot listings, which are ordered by upload time
+CREATE INDEX IF NOT EXISTS idx_bot_documents_bot_uploaded ON bot_documents(bot_id, uploaded_at);
+CREATE INDEX IF NOT EXISTS idx_bot_documents_content_hash ON bot_documents(content_hash);
+
+-- Trigram indexes for the case-insensitive substring search over document names
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+CREATE INDEX IF NOT EXISTS idx_bot_documents_filename_trgm ON bot_documents USING gin (filename gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_bot_documents_file_type_trgm ON bot_documents USING gin (file_type gin_trgm_ops);
+
+-- Webhooks notified about bot events (payloads signed with HMAC-SHA256 using secret)
+CREATE TABLE IF NOT EXISTS webhooks (
+    id SERIAL PRIMARY KEY,
+    bot_id UUID NOT NULL REFERENCES bots(id) ON DELETE CASCADE,
+    url VARCHAR(2048) NOT NULL,
+    secret VARCHAR(255) NOT NULL,
+    events JSONB NOT NULL,
+    is_active BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhooks_bot_id ON webhooks(bot_id);
+
+-- Messenger integrations (credentials are encrypted with INTEGRATIONS_ENCRYPTION_KEY)
+CREATE TABLE IF NOT EXISTS bot_integrations (
+    id SERIAL PRIMARY KEY,
+    bot_id UUID NOT NULL REFERENCES bots(id) ON DELETE CASCADE,
+    provider VARCHAR(20) NOT NULL,
+    credentials TEXT NOT NULL,
+    is_active BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_bot_integrations_bot_provider ON bot_integrations(bot_id, provider);
+
+-- Audit log of sensitive operations; no foreign keys, so entries outlive users and bots
+CREATE TABLE IF NOT EXISTS audit_logs (
+    id SERIAL PRIMARY KEY,
+    actor_id BIGINT NOT NULL,
+    action VARCHAR(64) NOT NULL,
+    target_type VARCHAR(32) NOT NULL,
+    target_id VARCHAR(255) NOT NULL,
+    details JSONB,
+    ip VARCHAR(64),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_logs_actor_id ON audit_logs(actor_id);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_action ON audit_logs(action);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_target ON audit_logs(target_type, target_id);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_created_at ON audit_logs(created_at);
+
+-- Re-embedding jobs (POST /api/v1/admin/reembed/:bot_id)
+CREATE TABLE IF NOT EXISTS reembed_jobs (
+    id SERIAL PRIMARY KEY,
+    bot_id UUID NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    embedding_model VARCHAR(255) NOT NULL DEFAULT '',
+    collection VARCHAR(255) NOT NULL DEFAULT '',
+    cursor VARCHAR(64) NOT NULL DEFAULT '',
+    total BIGINT NOT NULL DEFAULT 0,
+    processed BIGINT NOT NULL DEFAULT 0,
+    error TEXT,
+    started_by BIGINT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE,
+    finished_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_reembed_jobs_bot_id ON reembed_jobs(bot_id);
+-- One running job per bot, whichever replica started it
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reembed_jobs_running ON reembed_jobs(bot_id) WHERE status = 'running';
+
+-- One-off schema changes applied by the backend migration (see database/migrations.go)
+CREATE TABLE IF NOT EXISTS schema_migrations (
+    version BIGINT PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    applied_at TIMESTAMP WITH TIME ZONE
+);
 
 -- Trigger to update updated_at timestamp
 CREATE OR REPLACE FUNCTION update_updated_at_column()
@@ -66,3 +165,9 @@ CREATE TRIGGER update_users_updated_at BEFORE UPDATE ON users
 
 CREATE TRIGGER update_bots_updated_at BEFORE UPDATE ON bots
     FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TRIGGER update_webhooks_updated_at BEFORE UPDATE ON webhooks
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TRIGGER update_bot_integrations_updated_at BEFORE UPDATE ON bot_integrations
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -1,12 +1,28 @@
 package database
 
 import (
+	"errors"
 	"fmt"
+	"time"
 
+	"github.com/jackc/pgx/v5/pgconn"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// ErrDuplicateEmail is returned by Create when a user with the email already exists
+var ErrDuplicateEmail = errors.New("user with this email already exists")
+
+// pgUniqueViolation is the PostgreSQL error code of a unique constraint violation
+const pgUniqueViolation = "23505"
+
+// isUniqueViolation reports whether err is a unique constraint violation
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation
+}
+
 // UserRepository handles user database operations using GORM
 type UserRepository struct {
 	db *DB
@@ -31,8 +47,11 @@ func (r *UserRepository) Create(email, password, name string) (*User, error) {
 		Name:         name,
 	}
 
-	// GORM automatically handles validation and returns duplicate key errors
+	// The unique constraint on email settles concurrent signups that both passed the handler's GetByEmail check
 	if err := r.db.Conn.Create(user).Error; err != nil {
+		if isUniqueViolation(err) {
+			return nil, ErrDuplicateEmail
+		}
 		return nil, fmt.Errorf("failed to create user: %w", err)
 	}
 
@@ -69,6 +88,127 @@ func (r *UserRepository) GetByID(id uint) (*User, error) {
 	return &user, nil
 }
 
+// List retrieves users ordered by creation date (newest first)
+func (r *UserRepository) List(limit, offset int) ([]*User, error) {
+	var users []*User
+	err := r.db.Conn.Order("created_at DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&users).Error
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+
+	return users, nil
+}
+
+// UpdatePassword hashes and stores a new password for the user and increments the token version, which
+// revokes the tokens issued so far. It returns the new token version.
+func (r *UserRepository) UpdatePassword(userID uint, password string) (int, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return 0, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	var user User
+	result := r.db.Conn.Model(&user).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "token_version"}}}).
+		Where("id = ?", userID).
+		Updates(map[string]any{
+			"password_hash": string(hashedPassword),
+			"token_version": gorm.Expr("token_version + 1"),
+		})
+
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to update password: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return 0, fmt.Errorf("user not found")
+	}
+
+	return user.TokenVersion, nil
+}
+
+// TokenVersion returns the user's current token version (auth.TokenVersions)
+func (r *UserRepository) TokenVersion(userID uint) (int, error) {
+	var version int
+	err := r.db.Conn.Model(&User{}).Select("token_version").Where("id = ?", userID).Take(&version).Error
+	if err == gorm.ErrRecordNotFound {
+		return 0, fmt.Errorf("user not found")
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get token version: %w", err)
+	}
+	return version, nil
+}
+
+// RecordLogin stores a successful login and clears the failed login count and any lockout
+func (r *UserRepository) RecordLogin(user *User) error {
+	now := time.Now()
+	result := r.db.Conn.Model(&User{}).
+		Where("id = ?", user.ID).
+		UpdateColumns(map[string]any{
+			"last_login_at":      now,
+			"failed_login_count": 0,
+			"locked_until":       nil,
+		})
+
+	if result.Error != nil {
+		return fmt.Errorf("failed to record login: %w", result.Error)
+	}
+	user.LastLoginAt = &now
+	user.FailedLoginCount = 0
+	user.LockedUntil = nil
+
+	return nil
+}
+
+// RecordLoginFailure counts a failed login. The maxFailures-th failure in a row locks the account
+// for lockout and starts a new count; the returned time is when the lock ends (nil while not locked).
+// The count is incremented in the database, so concurrent failures are all counted.
+func (r *UserRepository) RecordLoginFailure(userID uint, maxFailures int, lockout time.Duration) (*time.Time, error) {
+	var user User
+	result := r.db.Conn.Model(&user).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "locked_until"}}}).
+		Where("id = ?", userID).
+		UpdateColumns(map[string]any{
+			"failed_login_count": gorm.Expr("CASE WHEN failed_login_count + 1 >= ? THEN 0 ELSE failed_login_count + 1 END", maxFailures),
+			"locked_until":       gorm.Expr("CASE WHEN failed_login_count + 1 >= ? THEN ?::timestamptz ELSE locked_until END", maxFailures, time.Now().Add(lockout)),
+		})
+
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to record login failure: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, fmt.Errorf("user not found")
+	}
+	if user.LockedUntil != nil && user.LockedUntil.After(time.Now()) {
+		return user.LockedUntil, nil
+	}
+
+	return nil, nil
+}
+
+// SetQuota stores the user's storage quota overrides (nil resets a limit to the global default)
+func (r *UserRepository) SetQuota(userID uint, maxChunks *int, maxBytes *int64) error {
+	result := r.db.Conn.Model(&User{}).
+		Where("id = ?", userID).
+		Updates(map[string]any{
+			"quota_max_chunks": maxChunks,
+			"quota_max_bytes":  maxBytes,
+		})
+
+	if result.Error != nil {
+		return fmt.Errorf("failed to update quota: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("user not found")
+	}
+
+	return nil
+}
+
 // VerifyPassword checks if the provided password matches the user's hashed password
 func (r *UserRepository) VerifyPassword(user *User, password string) error {
 	return bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password))
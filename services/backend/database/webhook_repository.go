@@ -0,0 +1,70 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// WebhookRepository handles webhook database operations using GORM
+type WebhookRepository struct {
+	db *DB
+}
+
+// NewWebhookRepository creates a new WebhookRepository
+func NewWebhookRepository(db *DB) *WebhookRepository {
+	return &WebhookRepository{db: db}
+}
+
+// Create stores a new webhook
+func (r *WebhookRepository) Create(hook *Webhook) error {
+	if err := r.db.Conn.Select("*").Create(hook).Error; err != nil {
+		return fmt.Errorf("failed to create webhook: %w", err)
+	}
+	return nil
+}
+
+// ListByBot retrieves all webhooks of a bot
+func (r *WebhookRepository) ListByBot(botID string) ([]Webhook, error) {
+	var hooks []Webhook
+	err := r.db.Conn.Where("bot_id = ?", botID).
+		Order("created_at ASC").
+		Find(&hooks).Error
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhooks: %w", err)
+	}
+
+	return hooks, nil
+}
+
+// ListForEvent retrieves active webhooks of a bot subscribed to the event type
+func (r *WebhookRepository) ListForEvent(botID, event string) ([]Webhook, error) {
+	eventJSON, err := json.Marshal([]string{event})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	var hooks []Webhook
+	err = r.db.Conn.Where("bot_id = ? AND is_active = ? AND events @> ?::jsonb", botID, true, string(eventJSON)).
+		Find(&hooks).Error
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhooks: %w", err)
+	}
+
+	return hooks, nil
+}
+
+// Delete removes a webhook of a bot
+func (r *WebhookRepository) Delete(id uint, botID string) error {
+	result := r.db.Conn.Where("id = ? AND bot_id = ?", id, botID).Delete(&Webhook{})
+
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete webhook: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("webhook not found")
+	}
+
+	return nil
+}
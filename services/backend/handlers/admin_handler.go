@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"backend/apierror"
+	"backend/database"
+	"backend/validation"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type AdminHandler struct {
+	userRepo  *database.UserRepository
+	botRepo   *database.BotRepository
+	auditRepo *database.AuditRepository
+}
+
+func NewAdminHandler(userRepo *database.UserRepository, botRepo *database.BotRepository, auditRepo *database.AuditRepository) *AdminHandler {
+	return &AdminHandler{
+		userRepo:  userRepo,
+		botRepo:   botRepo,
+		auditRepo: auditRepo,
+	}
+}
+
+// paginationParams reads limit/offset query params with sane bounds
+func paginationParams(c *fiber.Ctx) (int, int) {
+	limit := c.QueryInt("limit", 100)
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	offset := c.QueryInt("offset", 0)
+	if offset < 0 {
+		offset = 0
+	}
+	return limit, offset
+}
+
+// ListBots returns bots of all users, including deactivated ones
+func (h *AdminHandler) ListBots(c *fiber.Ctx) error {
+	limit, offset := paginationParams(c)
+
+	bots, err := h.botRepo.ListAll(limit, offset)
+	if err != nil {
+		return apierror.Send(c, fiber.StatusInternalServerError, apierror.CodeInternal, "failed to get bots")
+	}
+
+	return c.JSON(fiber.Map{
+		"bots":   bots,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// ListUsers returns all registered users
+func (h *AdminHandler) ListUsers(c *fiber.Ctx) error {
+	limit, offset := paginationParams(c)
+
+	users, err := h.userRepo.List(limit, offset)
+	if err != nil {
+		return apierror.Send(c, fiber.StatusInternalServerError, apierror.CodeInternal, "failed to get users")
+	}
+
+	return c.JSON(fiber.Map{
+		"users":  users,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// SetUserQuotaRequest sets a user's storage quota overrides; omitted limits fall back to the global default
+type SetUserQuotaRequest struct {
+	MaxChunks *int   `json:"max_chunks" validate:"omitempty,gte=0"` // 0 = unlimited
+	MaxBytes  *int64 `json:"max_bytes" validate:"omitempty,gte=0"`  // 0 = unlimited
+}
+
+// SetUserQuota overrides the storage quota of a user
+func (h *AdminHandler) SetUserQuota(c *fiber.Ctx) error {
+	userID, err := c.ParamsInt("id")
+	if err != nil || userID <= 0 {
+		return apierror.Send(c, fiber.StatusBadRequest, apierror.CodeBadRequest, "invalid user id")
+	}
+
+	req := new(SetUserQuotaRequest)
+	if err := validation.ParseBody(c, req); err != nil {
+		return err
+	}
+
+	if err := h.userRepo.SetQuota(uint(userID), req.MaxChunks, req.MaxBytes); err != nil {
+		return apierror.Send(c, fiber.StatusNotFound, apierror.CodeUserNotFound, "user not found")
+	}
+
+	return c.JSON(fiber.Map{
+		"success":    true,
+		"max_chunks": req.MaxChunks,
+		"max_bytes":  req.MaxBytes,
+	})
+}
+
+// DeactivateBot disables a bot of any owner (moderation)
+func (h *AdminHandler) DeactivateBot(c *fiber.Ctx) error {
+	botID := c.Params("id")
+	if botID == "" {
+		return apierror.Send(c, fiber.StatusBadRequest, apierror.CodeBadRequest, "bot_id is required")
+	}
+
+	if err := h.botRepo.SetActive(botID, false); err != nil {
+		return apierror.Send(c, fiber.StatusNotFound, apierror.CodeBotNotFound, "bot not found")
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "bot deactivated",
+	})
+}
+
+// ListAudit returns the audit log, newest first. It can be filtered by actor_id, action, target_type,
+// target_id and a since/until time range (RFC 3339).
+func (h *AdminHandler) ListAudit(c *fiber.Ctx) error {
+	limit, offset := paginationParams(c)
+
+	filter := database.AuditFilter{
+		Action:     c.Query("action"),
+		TargetType: c.Query("target_type"),
+		TargetID:   c.Query("target_id"),
+	}
+	if actor := c.Query("actor_id"); actor != "" {
+		actorID, err := strconv.ParseUint(actor, 10, 64)
+		if err != nil || actorID == 0 {
+			return apierror.Send(c, fiber.StatusBadRequest, apierror.CodeBadRequest, "invalid actor_id")
+		}
+		filter.ActorID = uint(actorID)
+	}
+	for param, bound := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return apierror.Send(c, fiber.StatusBadRequest, apierror.CodeBadRequest, "invalid "+param+": expected an RFC 3339 time")
+		}
+		*bound = t
+	}
+
+	entries, err := h.auditRepo.List(filter, limit, offset)
+	if err != nil {
+		return apierror.Send(c, fiber.StatusInternalServerError, apierror.CodeInternal, "failed to get audit log")
+	}
+
+	return c.JSON(fiber.Map{
+		"entries": entries,
+		"limit":   limit,
+		"offset":  offset,
+	})
+}
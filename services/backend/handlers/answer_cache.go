@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"backend/database"
+	"backend/models"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// answerCacheKey identifies answers to the same question with the same generation settings.
+// The bot's UpdatedAt is part of the key, so changing bot settings invalidates its answers.
+func answerCacheKey(req models.RAGChatRequest, bot *database.Bot) string {
+	key, _ := json.Marshal(struct {
+		Query        string
+		Limit        int
+		Temperature  float64
+		TopP         float64
+		TopK         int
+		MaxNewTokens int
+		DoSample     bool
+		SystemPrompt string
+		PromptVars   map[string]string
+		Citations    bool
+		Highlight    bool
+		Uploaded     models.UploadRange
+		BotUpdatedAt time.Time
+	}{
+		Query:        strings.Join(strings.Fields(strings.ToLower(req.Query)), " "),
+		Limit:        req.Limit,
+		Temperature:  req.Temperature,
+		TopP:         req.TopP,
+		TopK:         req.TopK,
+		MaxNewTokens: req.MaxNewTokens,
+		DoSample:     req.DoSample,
+		SystemPrompt: req.SystemPrompt,
+		PromptVars:   req.PromptVars,
+		Citations:    req.Citations,
+		Highlight:    req.Highlight,
+		Uploaded:     req.UploadRange(),
+		BotUpdatedAt: bot.UpdatedAt,
+	})
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:])
+}
+
+// bypassAnswerCache reports whether the client asked for a fresh answer with "Cache-Control: no-cache" (or no-store)
+func bypassAnswerCache(c *fiber.Ctx) bool {
+	cacheControl := strings.ToLower(c.Get(fiber.HeaderCacheControl))
+	return strings.Contains(cacheControl, "no-cache") || strings.Contains(cacheControl, "no-store")
+}
@@ -1,22 +1,35 @@
 package handlers
 
 import (
+	"backend/apierror"
 	"backend/auth"
 	"backend/database"
+	"backend/validation"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 type AuthHandler struct {
-	userRepo   *database.UserRepository
-	jwtService *auth.JWTService
+	userRepo         *database.UserRepository
+	jwtService       *auth.JWTService
+	loginMaxFailures int           // LOGIN_MAX_FAILURES; 0 = no lockout
+	loginLockout     time.Duration // LOGIN_LOCKOUT
 }
 
-func NewAuthHandler(userRepo *database.UserRepository, jwtService *auth.JWTService) *AuthHandler {
+func NewAuthHandler(userRepo *database.UserRepository, jwtService *auth.JWTService, loginMaxFailures int, loginLockout time.Duration) *AuthHandler {
 	return &AuthHandler{
-		userRepo:   userRepo,
-		jwtService: jwtService,
+		userRepo:         userRepo,
+		jwtService:       jwtService,
+		loginMaxFailures: loginMaxFailures,
+		loginLockout:     loginLockout,
 	}
 }
 
@@ -33,51 +46,58 @@ type LoginRequest struct {
 	Password string `json:"password" validate:"required"`
 }
 
+// ChangePasswordRequest represents a password change request
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required,min=8"`
+}
+
 // AuthResponse represents an authentication response
 type AuthResponse struct {
-	Token string         `json:"token"`
-	User  *database.User `json:"user"`
+	Token     string         `json:"token"`
+	ExpiresAt time.Time      `json:"expires_at"`
+	User      *database.User `json:"user"`
 }
 
 // Register handles user registration
 func (h *AuthHandler) Register(c *fiber.Ctx) error {
 	req := new(RegisterRequest)
 	if err := c.BodyParser(req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "invalid request body",
-		})
+		return apierror.Send(c, fiber.StatusBadRequest, apierror.CodeInvalidBody, "invalid request body")
 	}
 
 	// Normalize email
 	req.Email = strings.ToLower(strings.TrimSpace(req.Email))
+	if err := validation.Struct(req); err != nil {
+		return err
+	}
 
 	// Check if user already exists
 	existingUser, _ := h.userRepo.GetByEmail(req.Email)
 	if existingUser != nil {
-		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
-			"error": "user with this email already exists",
-		})
+		return apierror.Send(c, fiber.StatusConflict, apierror.CodeEmailTaken, "user with this email already exists")
 	}
 
 	// Create user (password hashing handled in repository)
 	user, err := h.userRepo.Create(req.Email, req.Password, req.Name)
+	if errors.Is(err, database.ErrDuplicateEmail) {
+		// Lost a race with a concurrent signup for the same email
+		return apierror.Send(c, fiber.StatusConflict, apierror.CodeEmailTaken, "user with this email already exists")
+	}
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "failed to create user",
-		})
+		return apierror.Send(c, fiber.StatusInternalServerError, apierror.CodeInternal, "failed to create user")
 	}
 
 	// Generate JWT token
-	token, err := h.jwtService.GenerateToken(user.ID, user.Email)
+	token, expiresAt, err := h.jwtService.GenerateToken(user.ID, user.Email, user.Role, user.TokenVersion)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "failed to generate token",
-		})
+		return apierror.Send(c, fiber.StatusInternalServerError, apierror.CodeInternal, "failed to generate token")
 	}
 
 	return c.Status(fiber.StatusCreated).JSON(AuthResponse{
-		Token: token,
-		User:  user,
+		Token:     token,
+		ExpiresAt: expiresAt,
+		User:      user,
 	})
 }
 
@@ -85,58 +105,132 @@ func (h *AuthHandler) Register(c *fiber.Ctx) error {
 func (h *AuthHandler) Login(c *fiber.Ctx) error {
 	req := new(LoginRequest)
 	if err := c.BodyParser(req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "invalid request body",
-		})
+		return apierror.Send(c, fiber.StatusBadRequest, apierror.CodeInvalidBody, "invalid request body")
 	}
 
 	// Normalize email
 	req.Email = strings.ToLower(strings.TrimSpace(req.Email))
+	if err := validation.Struct(req); err != nil {
+		return err
+	}
 
-	// Get user
+	// Unknown emails and wrong passwords get the same 401, and an unknown email still costs a bcrypt
+	// comparison, so responses do not tell which emails are registered
 	user, err := h.userRepo.GetByEmail(req.Email)
 	if err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "invalid email or password",
-		})
+		_ = auth.CheckPassword(req.Password, dummyPasswordHash())
+		return apierror.Send(c, fiber.StatusUnauthorized, apierror.CodeInvalidCredentials, "invalid email or password")
 	}
 
 	// Check password
+	locked := user.LockedUntil != nil && time.Now().Before(*user.LockedUntil)
 	if err := auth.CheckPassword(req.Password, user.PasswordHash); err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "invalid email or password",
-		})
+		// Guesses against a locked account are not counted, so they cannot extend the lock
+		if h.loginMaxFailures > 0 && !locked {
+			lockedUntil, err := h.userRepo.RecordLoginFailure(user.ID, h.loginMaxFailures, h.loginLockout)
+			if err != nil {
+				log.Printf("[Login] Failed to record login failure of user %d: %v", user.ID, err)
+			} else if lockedUntil != nil {
+				log.Printf("[Login] User %d locked until %s after %d failed logins", user.ID, lockedUntil.Format(time.RFC3339), h.loginMaxFailures)
+			}
+		}
+		return apierror.Send(c, fiber.StatusUnauthorized, apierror.CodeInvalidCredentials, "invalid email or password")
+	}
+
+	// Only the right password learns that the account is locked; it is still refused until the lock ends
+	if locked {
+		return accountLocked(c, *user.LockedUntil)
 	}
 
 	// Generate JWT token
-	token, err := h.jwtService.GenerateToken(user.ID, user.Email)
+	token, expiresAt, err := h.jwtService.GenerateToken(user.ID, user.Email, user.Role, user.TokenVersion)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "failed to generate token",
-		})
+		return apierror.Send(c, fiber.StatusInternalServerError, apierror.CodeInternal, "failed to generate token")
+	}
+	if err := h.userRepo.RecordLogin(user); err != nil {
+		log.Printf("[Login] Failed to record login of user %d: %v", user.ID, err)
 	}
 
 	return c.JSON(AuthResponse{
-		Token: token,
-		User:  user,
+		Token:     token,
+		ExpiresAt: expiresAt,
+		User:      user,
 	})
 }
 
+// dummyPasswordHash is compared against for unknown emails so they take as long as known ones
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, err := auth.HashPassword("unknown-email-placeholder")
+	if err != nil {
+		log.Printf("[Login] Failed to hash placeholder password: %v", err)
+	}
+	return hash
+})
+
+// accountLocked rejects a login to an account locked after repeated failures; Retry-After tells when to try again
+func accountLocked(c *fiber.Ctx, until time.Time) error {
+	retryAfter := int(math.Ceil(time.Until(until).Seconds()))
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(retryAfter))
+	return apierror.Send(c, fiber.StatusTooManyRequests, apierror.CodeAccountLocked,
+		fmt.Sprintf("account temporarily locked after too many failed logins; try again in %s", time.Until(until).Round(time.Second)))
+}
+
 // Me returns the current user's information
 func (h *AuthHandler) Me(c *fiber.Ctx) error {
 	userID, ok := auth.GetUserID(c)
 	if !ok {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "unauthorized",
-		})
+		return apierror.Send(c, fiber.StatusUnauthorized, apierror.CodeUnauthorized, "unauthorized")
 	}
 
 	user, err := h.userRepo.GetByID(userID)
 	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": "user not found",
-		})
+		return apierror.Send(c, fiber.StatusNotFound, apierror.CodeUserNotFound, "user not found")
 	}
 
 	return c.JSON(user)
 }
+
+// ChangePassword verifies the current password and replaces it with a new one.
+// All previously issued tokens are revoked; a fresh token is returned for the current session.
+func (h *AuthHandler) ChangePassword(c *fiber.Ctx) error {
+	userID, ok := auth.GetUserID(c)
+	if !ok {
+		return apierror.Send(c, fiber.StatusUnauthorized, apierror.CodeUnauthorized, "unauthorized")
+	}
+
+	req := new(ChangePasswordRequest)
+	if err := validation.ParseBody(c, req); err != nil {
+		return err
+	}
+
+	if req.NewPassword == req.CurrentPassword {
+		return apierror.Send(c, fiber.StatusBadRequest, apierror.CodeBadRequest, "new password must differ from the current one")
+	}
+
+	user, err := h.userRepo.GetByID(userID)
+	if err != nil {
+		return apierror.Send(c, fiber.StatusNotFound, apierror.CodeUserNotFound, "user not found")
+	}
+
+	// Verify current password
+	if err := auth.CheckPassword(req.CurrentPassword, user.PasswordHash); err != nil {
+		return apierror.Send(c, fiber.StatusUnauthorized, apierror.CodeInvalidCredentials, "current password is incorrect")
+	}
+
+	// The new token version logs out other sessions, on every replica
+	user.TokenVersion, err = h.userRepo.UpdatePassword(user.ID, req.NewPassword)
+	if err != nil {
+		return apierror.Send(c, fiber.StatusInternalServerError, apierror.CodeInternal, "failed to update password")
+	}
+
+	token, expiresAt, err := h.jwtService.GenerateToken(user.ID, user.Email, user.Role, user.TokenVersion)
+	if err != nil {
+		return apierror.Send(c, fiber.StatusInternalServerError, apierror.CodeInternal, "failed to generate token")
+	}
+
+	return c.JSON(AuthResponse{
+		Token:     token,
+		ExpiresAt: expiresAt,
+		User:      user,
+	})
+}
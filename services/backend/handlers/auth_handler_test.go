@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"backend/apierror"
+	"backend/auth"
+	"backend/database"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// testDB connects to the Postgres database in TEST_DATABASE_URL and migrates it; the test is skipped without one
+func testDB(t *testing.T) *database.DB {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	db, err := database.NewDB(url)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.AutoMigrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+// Two signups for the same email that both pass the handler's existence check are settled by the unique index:
+// one creates the user, the other gets 409 instead of a 500
+func TestRegisterConcurrentSameEmail(t *testing.T) {
+	db := testDB(t)
+	email := fmt.Sprintf("race-%d@example.com", time.Now().UnixNano())
+	t.Cleanup(func() { db.Conn.Unscoped().Where("email = ?", email).Delete(&database.User{}) })
+
+	handler := NewAuthHandler(database.NewUserRepository(db), auth.NewJWTService("test-secret", time.Hour, nil), 0, 0)
+	app := fiber.New(fiber.Config{ErrorHandler: apierror.ErrorHandler})
+	app.Post("/register", handler.Register)
+
+	// Hashing the password takes far longer than the existence check, so both requests get past it
+	// before either inserts
+	body := fmt.Sprintf(`{"email":%q,"password":"correct-horse","name":"Race"}`, email)
+	statuses := make([]int, 2)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := range statuses {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := httptest.NewRequest(fiber.MethodPost, "/register", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			<-start
+			resp, err := app.Test(req, -1)
+			if err != nil {
+				t.Errorf("request %d: %v", i, err)
+				return
+			}
+			resp.Body.Close()
+			statuses[i] = resp.StatusCode
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	sort.Ints(statuses)
+	if want := []int{fiber.StatusCreated, fiber.StatusConflict}; statuses[0] != want[0] || statuses[1] != want[1] {
+		t.Errorf("statuses = %v, want %v", statuses, want)
+	}
+
+	var users int64
+	if err := db.Conn.Model(&database.User{}).Where("email = ?", email).Count(&users).Error; err != nil {
+		t.Fatalf("count users: %v", err)
+	}
+	if users != 1 {
+		t.Errorf("%d users with the email, want 1", users)
+	}
+}
+
+// Login answers unknown emails and wrong passwords alike, locked account or not, so it cannot be used to
+// find registered emails; only the right password is told about the lock
+func TestLoginHidesWhetherEmailIsRegistered(t *testing.T) {
+	db := testDB(t)
+	email := fmt.Sprintf("lockout-%d@example.com", time.Now().UnixNano())
+	t.Cleanup(func() { db.Conn.Unscoped().Where("email = ?", email).Delete(&database.User{}) })
+
+	handler := NewAuthHandler(database.NewUserRepository(db), auth.NewJWTService("test-secret", time.Hour, nil), 2, time.Hour)
+	app := fiber.New(fiber.Config{ErrorHandler: apierror.ErrorHandler})
+	app.Post("/register", handler.Register)
+	app.Post("/login", handler.Login)
+
+	post := func(path, body string) int {
+		t.Helper()
+		req := httptest.NewRequest(fiber.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatalf("POST %s: %v", path, err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	login := func(email, password string) int {
+		t.Helper()
+		return post("/login", fmt.Sprintf(`{"email":%q,"password":%q}`, email, password))
+	}
+
+	if status := post("/register", fmt.Sprintf(`{"email":%q,"password":"correct-horse","name":"Lockout"}`, email)); status != fiber.StatusCreated {
+		t.Fatalf("register: status %d", status)
+	}
+
+	steps := []struct {
+		name     string
+		email    string
+		password string
+		want     int
+	}{
+		{"unknown email", "nobody-" + email, "wrong-horse", fiber.StatusUnauthorized},
+		{"first wrong password", email, "wrong-horse", fiber.StatusUnauthorized},
+		{"wrong password that locks", email, "wrong-horse", fiber.StatusUnauthorized},
+		{"wrong password while locked", email, "wrong-horse", fiber.StatusUnauthorized},
+		{"right password while locked", email, "correct-horse", fiber.StatusTooManyRequests},
+	}
+	for _, step := range steps {
+		if status := login(step.email, step.password); status != step.want {
+			t.Errorf("%s: status %d, want %d", step.name, status, step.want)
+		}
+	}
+}
@@ -1,8 +1,16 @@
 package handlers
 
 import (
+	"backend/apierror"
+	"backend/audit"
 	"backend/auth"
 	"backend/database"
+	"backend/validation"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
@@ -10,12 +18,16 @@ import (
 )
 
 type BotHandler struct {
-	botRepo *database.BotRepository
+	botRepo         *database.BotRepository
+	embeddingModels []string // EMBEDDING_MODELS_ALLOWED
+	audit           *audit.Logger
 }
 
-func NewBotHandler(botRepo *database.BotRepository) *BotHandler {
+func NewBotHandler(botRepo *database.BotRepository, embeddingModels []string, auditLog *audit.Logger) *BotHandler {
 	return &BotHandler{
-		botRepo: botRepo,
+		botRepo:         botRepo,
+		embeddingModels: embeddingModels,
+		audit:           auditLog,
 	}
 }
 
@@ -32,6 +44,14 @@ type CreateBotRequest struct {
 	RAGTopK      int     `json:"rag_top_k" validate:"omitempty,gte=1,lte=10"`
 	ChunkSize    int     `json:"chunk_size" validate:"omitempty,gte=100,lte=5000"`
 	ChunkOverlap int     `json:"chunk_overlap" validate:"omitempty,gte=0,lte=1000"`
+	IsPublic     *bool   `json:"is_public"` // Defaults to true
+
+	ModelContextTokens    int                 `json:"model_context_tokens" validate:"omitempty,gte=512,lte=1048576"`
+	RerankCandidates      int                 `json:"rerank_candidates" validate:"omitempty,gte=1,lte=500"`
+	RerankTopK            int                 `json:"rerank_top_k" validate:"omitempty,gte=1,lte=100"`
+	EmbeddingModel        string              `json:"embedding_model" validate:"max=255"` // "" = AI service default
+	UsesInstructionPrefix *bool               `json:"uses_instruction_prefix"`            // Query/passage embedding prefix; nil = by model name (e5)
+	Config                *database.BotConfig `json:"config"`
 }
 
 // UpdateBotRequest represents a request to update an existing bot
@@ -47,25 +67,63 @@ type UpdateBotRequest struct {
 	RAGTopK      int     `json:"rag_top_k" validate:"omitempty,gte=1,lte=10"`
 	ChunkSize    int     `json:"chunk_size" validate:"omitempty,gte=100,lte=5000"`
 	ChunkOverlap int     `json:"chunk_overlap" validate:"omitempty,gte=0,lte=1000"`
+	IsPublic     *bool   `json:"is_public"`
+
+	ModelContextTokens    *int                `json:"model_context_tokens" validate:"omitempty,eq=0|gte=512,lte=1048576"` // 0 switches back to the global window
+	RerankCandidates      int                 `json:"rerank_candidates" validate:"omitempty,gte=1,lte=500"`
+	RerankTopK            int                 `json:"rerank_top_k" validate:"omitempty,gte=1,lte=100"`
+	EmbeddingModel        *string             `json:"embedding_model" validate:"omitempty,max=255"` // "" switches back to the default
+	UsesInstructionPrefix *bool               `json:"uses_instruction_prefix"`                      // Like embedding_model, fixed while the bot has documents
+	Config                *database.BotConfig `json:"config"`                                       // Replaces the whole config when set
 }
 
-// CreateBot creates a new bot
-func (h *BotHandler) CreateBot(c *fiber.Ctx) error {
-	userID, ok := auth.GetUserID(c)
-	if !ok {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "unauthorized",
-		})
+// BatchDeleteRequest lists the bots to move to the trash
+type BatchDeleteRequest struct {
+	BotIDs []string `json:"bot_ids" validate:"required,min=1,max=100,dive,required"`
+}
+
+// BatchDeleteResult is the outcome for one bot of a batch delete
+type BatchDeleteResult struct {
+	BotID   string `json:"bot_id"`
+	Deleted bool   `json:"deleted"`
+	Error   string `json:"error,omitempty"`
+}
+
+// WidgetConfigResponse is the public widget configuration fetched by the embed script
+type WidgetConfigResponse struct {
+	BotID string `json:"bot_id"`
+	Name  string `json:"name"`
+	database.WidgetConfig
+}
+
+// GreetingResponse is the opening message of a chat and the starter questions a widget may offer
+type GreetingResponse struct {
+	BotID              string   `json:"bot_id"`
+	Greeting           string   `json:"greeting"`
+	SuggestedQuestions []string `json:"suggested_questions"`
+}
+
+// canAccessBot reports whether the requester may see the bot: public bots are open to everyone,
+// private bots only to their owner
+func canAccessBot(c *fiber.Ctx, bot *database.Bot) bool {
+	if bot.IsPublic {
+		return true
 	}
+	userID, ok := auth.GetUserID(c)
+	return ok && userID == bot.OwnerID
+}
 
-	req := new(CreateBotRequest)
-	if err := c.BodyParser(req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "invalid request body",
-		})
+// checkEmbeddingModel rejects embedding models outside the allowlist; "" (the AI service default) is always allowed
+func checkEmbeddingModel(model string, allowed []string) error {
+	if model == "" || slices.Contains(allowed, model) {
+		return nil
 	}
+	return apierror.New(fiber.StatusBadRequest, apierror.CodeValidationFailed,
+		fmt.Sprintf("embedding model %q is not allowed (EMBEDDING_MODELS_ALLOWED)", model))
+}
 
-	// Set defaults
+// newBotFromRequest builds a bot from a create request, applying defaults for unset fields
+func newBotFromRequest(ownerID uint, req *CreateBotRequest) *database.Bot {
 	if req.Temperature == 0 {
 		req.Temperature = 0.75
 	}
@@ -87,13 +145,16 @@ func (h *BotHandler) CreateBot(c *fiber.Ctx) error {
 	if req.SystemPrompt == "" {
 		req.SystemPrompt = "You are a helpful assistant. /no_think"
 	}
+	isPublic := true
+	if req.IsPublic != nil {
+		isPublic = *req.IsPublic
+	}
 
 	bot := &database.Bot{
 		ID:           uuid.New().String(),
-		OwnerID:      userID,
+		OwnerID:      ownerID,
 		Name:         strings.TrimSpace(req.Name),
 		Description:  strings.TrimSpace(req.Description),
-		Config:       "{}",
 		Temperature:  req.Temperature,
 		TopP:         req.TopP,
 		TopK:         req.TopK,
@@ -103,14 +164,43 @@ func (h *BotHandler) CreateBot(c *fiber.Ctx) error {
 		ChunkSize:    req.ChunkSize,
 		ChunkOverlap: req.ChunkOverlap,
 		IsActive:     true,
+		IsPublic:     isPublic,
+
+		ModelContextTokens: req.ModelContextTokens,
+		RerankCandidates:   req.RerankCandidates,
+		RerankTopK:         req.RerankTopK,
+		EmbeddingModel:     strings.TrimSpace(req.EmbeddingModel),
+
+		UsesInstructionPrefix: req.UsesInstructionPrefix,
+	}
+	if req.Config != nil {
+		bot.Config = *req.Config
+	}
+	return bot
+}
+
+// CreateBot creates a new bot
+func (h *BotHandler) CreateBot(c *fiber.Ctx) error {
+	userID, ok := auth.GetUserID(c)
+	if !ok {
+		return apierror.Send(c, fiber.StatusUnauthorized, apierror.CodeUnauthorized, "unauthorized")
+	}
+
+	req := new(CreateBotRequest)
+	if err := validation.ParseBody(c, req); err != nil {
+		return err
+	}
+
+	bot := newBotFromRequest(userID, req)
+	if err := checkEmbeddingModel(bot.EmbeddingModel, h.embeddingModels); err != nil {
+		return err
 	}
 
 	createdBot, err := h.botRepo.Create(bot)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "failed to create bot",
-		})
+		return apierror.Send(c, fiber.StatusInternalServerError, apierror.CodeInternal, "failed to create bot")
 	}
+	h.audit.Record(userID, audit.ActionBotCreate, audit.TargetBot, createdBot.ID, c.IP(), map[string]any{"name": createdBot.Name})
 
 	return c.Status(fiber.StatusCreated).JSON(createdBot)
 }
@@ -119,16 +209,12 @@ func (h *BotHandler) CreateBot(c *fiber.Ctx) error {
 func (h *BotHandler) GetMyBots(c *fiber.Ctx) error {
 	userID, ok := auth.GetUserID(c)
 	if !ok {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "unauthorized",
-		})
+		return apierror.Send(c, fiber.StatusUnauthorized, apierror.CodeUnauthorized, "unauthorized")
 	}
 
 	bots, err := h.botRepo.GetByOwnerID(userID)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "failed to get bots",
-		})
+		return apierror.Send(c, fiber.StatusInternalServerError, apierror.CodeInternal, "failed to get bots")
 	}
 
 	return c.JSON(fiber.Map{
@@ -140,16 +226,13 @@ func (h *BotHandler) GetMyBots(c *fiber.Ctx) error {
 func (h *BotHandler) GetBot(c *fiber.Ctx) error {
 	botID := c.Params("id")
 	if botID == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "bot_id is required",
-		})
+		return apierror.Send(c, fiber.StatusBadRequest, apierror.CodeBadRequest, "bot_id is required")
 	}
 
 	bot, err := h.botRepo.GetByID(botID)
-	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": "bot not found",
-		})
+	if err != nil || !canAccessBot(c, bot) {
+		// Private bots are reported as missing so their existence isn't leaked
+		return apierror.Send(c, fiber.StatusNotFound, apierror.CodeBotNotFound, "bot not found")
 	}
 
 	// Check if user is the owner
@@ -163,49 +246,112 @@ func (h *BotHandler) GetBot(c *fiber.Ctx) error {
 	return c.JSON(bot.ToPublic())
 }
 
+// GetWidgetConfig returns the public theming and behavior settings of the bot's chat widget
+func (h *BotHandler) GetWidgetConfig(c *fiber.Ctx) error {
+	bot, err := h.botRepo.GetByID(c.Params("id"))
+	if err != nil || !canAccessBot(c, bot) {
+		return apierror.Send(c, fiber.StatusNotFound, apierror.CodeBotNotFound, "bot not found")
+	}
+	if !bot.Config.AllowsOrigin(c.Get(fiber.HeaderOrigin)) {
+		return apierror.Send(c, fiber.StatusForbidden, apierror.CodeForbidden, "this bot can't be embedded on this site")
+	}
+
+	if bot.IsPublic {
+		c.Set(fiber.HeaderCacheControl, "public, max-age=60")
+	}
+	return c.JSON(WidgetConfigResponse{
+		BotID:        bot.ID,
+		Name:         bot.Name,
+		WidgetConfig: bot.Widget(),
+	})
+}
+
+// GetGreeting returns the bot's greeting and suggested starter questions for chat widgets
+func (h *BotHandler) GetGreeting(c *fiber.Ctx) error {
+	bot, err := h.botRepo.GetByID(c.Params("id"))
+	if err != nil || !canAccessBot(c, bot) {
+		return apierror.Send(c, fiber.StatusNotFound, apierror.CodeBotNotFound, "bot not found")
+	}
+	if !bot.Config.AllowsOrigin(c.Get(fiber.HeaderOrigin)) {
+		return apierror.Send(c, fiber.StatusForbidden, apierror.CodeForbidden, "this bot can't be embedded on this site")
+	}
+
+	questions := bot.Config.SuggestedQuestions
+	if questions == nil {
+		questions = []string{}
+	}
+	if bot.IsPublic {
+		c.Set(fiber.HeaderCacheControl, "public, max-age=60")
+	}
+	return c.JSON(GreetingResponse{
+		BotID:              bot.ID,
+		Greeting:           bot.Greeting(),
+		SuggestedQuestions: questions,
+	})
+}
+
+// GetPublicDocuments lists the names and upload dates of a bot's documents for chat widgets.
+// The owner opts in with the widget's show_sources setting; private bots are visible to their owner only.
+func (h *BotHandler) GetPublicDocuments(c *fiber.Ctx) error {
+	bot, err := h.botRepo.GetByID(c.Params("id"))
+	if err != nil || !canAccessBot(c, bot) {
+		return apierror.Send(c, fiber.StatusNotFound, apierror.CodeBotNotFound, "bot not found")
+	}
+	if !bot.Config.AllowsOrigin(c.Get(fiber.HeaderOrigin)) {
+		return apierror.Send(c, fiber.StatusForbidden, apierror.CodeForbidden, "this bot can't be embedded on this site")
+	}
+	if !bot.Config.Widget.ShowSources {
+		return apierror.Send(c, fiber.StatusForbidden, apierror.CodeForbidden, "this bot doesn't share its document list")
+	}
+
+	documents, err := h.botRepo.GetDocuments(bot.ID)
+	if err != nil {
+		return apierror.Send(c, fiber.StatusInternalServerError, apierror.CodeInternal, "failed to get documents")
+	}
+	public := make([]database.PublicDocument, len(documents))
+	for i := range documents {
+		public[i] = documents[i].ToPublic()
+	}
+
+	if bot.IsPublic {
+		c.Set(fiber.HeaderCacheControl, "public, max-age=60")
+	}
+	return c.JSON(fiber.Map{
+		"documents": public,
+	})
+}
+
 // UpdateBot updates an existing bot
 func (h *BotHandler) UpdateBot(c *fiber.Ctx) error {
 	userID, ok := auth.GetUserID(c)
 	if !ok {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "unauthorized",
-		})
+		return apierror.Send(c, fiber.StatusUnauthorized, apierror.CodeUnauthorized, "unauthorized")
 	}
 
 	botID := c.Params("id")
 	if botID == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "bot_id is required",
-		})
+		return apierror.Send(c, fiber.StatusBadRequest, apierror.CodeBadRequest, "bot_id is required")
 	}
 
 	// Check ownership
 	isOwner, err := h.botRepo.CheckOwnership(botID, userID)
 	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": "bot not found",
-		})
+		return apierror.Send(c, fiber.StatusNotFound, apierror.CodeBotNotFound, "bot not found")
 	}
 	if !isOwner {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-			"error": "you don't have permission to update this bot",
-		})
+		return apierror.Send(c, fiber.StatusForbidden, apierror.CodeForbidden, "you don't have permission to update this bot")
 	}
 
 	// Get existing bot
 	bot, err := h.botRepo.GetByID(botID)
 	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": "bot not found",
-		})
+		return apierror.Send(c, fiber.StatusNotFound, apierror.CodeBotNotFound, "bot not found")
 	}
 
 	// Parse update request
 	req := new(UpdateBotRequest)
-	if err := c.BodyParser(req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "invalid request body",
-		})
+	if err := validation.ParseBody(c, req); err != nil {
+		return err
 	}
 
 	// Update fields if provided
@@ -239,37 +385,94 @@ func (h *BotHandler) UpdateBot(c *fiber.Ctx) error {
 	if req.ChunkOverlap >= 0 {
 		bot.ChunkOverlap = req.ChunkOverlap
 	}
+	if req.IsPublic != nil {
+		bot.IsPublic = *req.IsPublic
+	}
+	if req.ModelContextTokens != nil {
+		bot.ModelContextTokens = *req.ModelContextTokens
+	}
+	if req.RerankCandidates > 0 {
+		bot.RerankCandidates = req.RerankCandidates
+	}
+	if req.RerankTopK > 0 {
+		bot.RerankTopK = req.RerankTopK
+	}
+	if req.EmbeddingModel != nil {
+		model := strings.TrimSpace(*req.EmbeddingModel)
+		if err := checkEmbeddingModel(model, h.embeddingModels); err != nil {
+			return err
+		}
+		if model != bot.EmbeddingModel {
+			// Indexed vectors can't be compared with vectors of another model
+			if err := h.requireNoDocuments(botID, "the embedding model"); err != nil {
+				return err
+			}
+			bot.EmbeddingModel = model
+		}
+	}
+	if req.UsesInstructionPrefix != nil &&
+		(bot.UsesInstructionPrefix == nil || *bot.UsesInstructionPrefix != *req.UsesInstructionPrefix) {
+		// Prefixed queries match unprefixed passages poorly, so indexed vectors would have to be rebuilt
+		if err := h.requireNoDocuments(botID, "the instruction prefix setting"); err != nil {
+			return err
+		}
+		bot.UsesInstructionPrefix = req.UsesInstructionPrefix
+	}
+	if req.Config != nil {
+		bot.Config = *req.Config
+	}
 
 	if err := h.botRepo.Update(bot); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "failed to update bot",
-		})
+		return apierror.Send(c, fiber.StatusInternalServerError, apierror.CodeInternal, "failed to update bot")
 	}
+	h.audit.Record(userID, audit.ActionBotUpdate, audit.TargetBot, botID, c.IP(), map[string]any{"fields": bodyFields(c)})
 
 	return c.JSON(bot)
 }
 
+// bodyFields lists the top-level fields of a JSON request body, sorted
+func bodyFields(c *fiber.Ctx) []string {
+	var body map[string]json.RawMessage
+	if err := json.Unmarshal(c.Body(), &body); err != nil {
+		return nil
+	}
+	fields := make([]string, 0, len(body))
+	for field := range body {
+		fields = append(fields, field)
+	}
+	slices.Sort(fields)
+	return fields
+}
+
+// requireNoDocuments rejects a change of setting with 409 Conflict while the bot has documents
+// indexed under the old value
+func (h *BotHandler) requireNoDocuments(botID, setting string) error {
+	documents, err := h.botRepo.GetDocuments(botID)
+	if err != nil {
+		return apierror.New(fiber.StatusInternalServerError, apierror.CodeInternal, "failed to load bot documents")
+	}
+	if len(documents) > 0 {
+		return apierror.New(fiber.StatusConflict, apierror.CodeConflict, setting+" can't be changed while the bot has documents; delete them first")
+	}
+	return nil
+}
+
 // DeleteBot deletes a bot
 func (h *BotHandler) DeleteBot(c *fiber.Ctx) error {
 	userID, ok := auth.GetUserID(c)
 	if !ok {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "unauthorized",
-		})
+		return apierror.Send(c, fiber.StatusUnauthorized, apierror.CodeUnauthorized, "unauthorized")
 	}
 
 	botID := c.Params("id")
 	if botID == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "bot_id is required",
-		})
+		return apierror.Send(c, fiber.StatusBadRequest, apierror.CodeBadRequest, "bot_id is required")
 	}
 
 	if err := h.botRepo.Delete(botID, userID); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "failed to delete bot",
-		})
+		return apierror.Send(c, fiber.StatusInternalServerError, apierror.CodeInternal, "failed to delete bot")
 	}
+	h.audit.Record(userID, audit.ActionBotDelete, audit.TargetBot, botID, c.IP(), nil)
 
 	return c.JSON(fiber.Map{
 		"success": true,
@@ -277,40 +480,114 @@ func (h *BotHandler) DeleteBot(c *fiber.Ctx) error {
 	})
 }
 
+// SearchBotDocuments finds the bot's documents by file name or type (?q=, case-insensitive substring),
+// paginated with limit/offset (owner only)
+func (h *BotHandler) SearchBotDocuments(c *fiber.Ctx) error {
+	userID, ok := auth.GetUserID(c)
+	if !ok {
+		return apierror.Send(c, fiber.StatusUnauthorized, apierror.CodeUnauthorized, "unauthorized")
+	}
+
+	botID := c.Params("id")
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		return apierror.Send(c, fiber.StatusBadRequest, apierror.CodeValidationFailed, "q is required")
+	}
+	if len(query) > 255 {
+		return apierror.Send(c, fiber.StatusBadRequest, apierror.CodeValidationFailed, "q must be at most 255 characters")
+	}
+
+	isOwner, err := h.botRepo.CheckOwnership(botID, userID)
+	if err != nil {
+		return apierror.Send(c, fiber.StatusNotFound, apierror.CodeBotNotFound, "bot not found")
+	}
+	if !isOwner {
+		return apierror.Send(c, fiber.StatusForbidden, apierror.CodeForbidden, "you don't have permission to view this bot's documents")
+	}
+
+	limit, offset := paginationParams(c)
+	documents, total, err := h.botRepo.SearchDocuments(botID, query, limit, offset)
+	if err != nil {
+		return apierror.Send(c, fiber.StatusInternalServerError, apierror.CodeInternal, "failed to search documents")
+	}
+
+	return c.JSON(fiber.Map{
+		"documents": documents,
+		"total":     total,
+		"limit":     limit,
+		"offset":    offset,
+	})
+}
+
+// BatchDeleteBots moves several bots to the trash. Each bot is deleted on its own: one that is missing
+// or owned by someone else is reported in its result and doesn't stop the others.
+func (h *BotHandler) BatchDeleteBots(c *fiber.Ctx) error {
+	userID, ok := auth.GetUserID(c)
+	if !ok {
+		return apierror.Send(c, fiber.StatusUnauthorized, apierror.CodeUnauthorized, "unauthorized")
+	}
+
+	req := new(BatchDeleteRequest)
+	if err := validation.ParseBody(c, req); err != nil {
+		return err
+	}
+
+	results := make([]BatchDeleteResult, 0, len(req.BotIDs))
+	seen := make(map[string]bool, len(req.BotIDs))
+	deleted := 0
+	for _, botID := range req.BotIDs {
+		if seen[botID] {
+			continue
+		}
+		seen[botID] = true
+
+		result := BatchDeleteResult{BotID: botID}
+		err := h.botRepo.Delete(botID, userID)
+		switch {
+		case err == nil:
+			result.Deleted = true
+			deleted++
+			h.audit.Record(userID, audit.ActionBotDelete, audit.TargetBot, botID, c.IP(), map[string]any{"batch": true})
+		case errors.Is(err, database.ErrBotNotOwned):
+			result.Error = "bot not found"
+		default:
+			log.Printf("[BatchDeleteBots] Failed to delete bot %s: %v", botID, err)
+			result.Error = "failed to delete bot"
+		}
+		results = append(results, result)
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"deleted": deleted,
+		"results": results,
+	})
+}
+
 // GetBotDocuments returns all documents for a bot
 func (h *BotHandler) GetBotDocuments(c *fiber.Ctx) error {
 	userID, ok := auth.GetUserID(c)
 	if !ok {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "unauthorized",
-		})
+		return apierror.Send(c, fiber.StatusUnauthorized, apierror.CodeUnauthorized, "unauthorized")
 	}
 
 	botID := c.Params("id")
 	if botID == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "bot_id is required",
-		})
+		return apierror.Send(c, fiber.StatusBadRequest, apierror.CodeBadRequest, "bot_id is required")
 	}
 
 	// Check ownership
 	isOwner, err := h.botRepo.CheckOwnership(botID, userID)
 	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": "bot not found",
-		})
+		return apierror.Send(c, fiber.StatusNotFound, apierror.CodeBotNotFound, "bot not found")
 	}
 	if !isOwner {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-			"error": "you don't have permission to view this bot's documents",
-		})
+		return apierror.Send(c, fiber.StatusForbidden, apierror.CodeForbidden, "you don't have permission to view this bot's documents")
 	}
 
 	documents, err := h.botRepo.GetDocuments(botID)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "failed to get documents",
-		})
+		return apierror.Send(c, fiber.StatusInternalServerError, apierror.CodeInternal, "failed to get documents")
 	}
 
 	return c.JSON(fiber.Map{
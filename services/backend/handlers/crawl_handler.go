@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"backend/apierror"
+	"backend/crawler"
+	"backend/models"
+	"backend/validation"
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// crawlUserAgent identifies the crawler to site owners and robots.txt rules
+const crawlUserAgent = "ChatBotPlatformCrawler/1.0"
+
+// CrawlRequest starts a website crawl into a bot's knowledge base
+type CrawlRequest struct {
+	URL        string `json:"url" validate:"required,http_url"` // Root page, or a sitemap ending in .xml
+	MaxPages   int    `json:"max_pages" validate:"omitempty,min=1,max=100"`
+	SameDomain *bool  `json:"same_domain"` // Defaults to true
+}
+
+// CrawlDocuments crawls a website breadth-first (or its sitemap) and indexes every HTML page
+// as a separate document with its URL in the chunk metadata (owner only)
+func (h *Handler) CrawlDocuments(c *fiber.Ctx) error {
+	bot, err := h.ownedBot(c)
+	if err != nil {
+		return err
+	}
+
+	var req CrawlRequest
+	if err := validation.ParseBody(c, &req); err != nil {
+		return err
+	}
+	if req.MaxPages == 0 {
+		req.MaxPages = 20
+	}
+	sameDomain := req.SameDomain == nil || *req.SameDomain
+
+	// The crawl runs within the request, so it has to finish before the server write timeout
+	ctx, cancel := context.WithTimeout(c.UserContext(), h.cfg.HTTPClient.Timeout)
+	defer cancel()
+
+	crawl := crawler.New(crawler.Options{
+		MaxPages:     req.MaxPages,
+		SameDomain:   sameDomain,
+		Delay:        h.cfg.Crawl.Delay,
+		Timeout:      h.cfg.HTTPClient.Timeout,
+		MaxPageBytes: h.cfg.Upload.MaxBytes,
+		UserAgent:    crawlUserAgent,
+		AllowPrivate: h.cfg.Crawl.AllowPrivate,
+	})
+
+	indexedChunks := 0
+	summary, err := crawl.Crawl(ctx, req.URL, func(page crawler.Page) error {
+		textResp, err := h.client.ParseDocument(ctx, h.cfg.Services.DocParserURL, "page.html", bytes.NewReader(page.Body), models.ParseOptions{DetectLanguage: h.cfg.Upload.DetectLanguage})
+		if err != nil {
+			return fmt.Errorf("parse error: %w", err)
+		}
+		// Crawled URLs are percent-encoded ASCII, so a byte cut is safe for the filename column
+		textResp.FileName = page.URL
+		if len(textResp.FileName) > 255 {
+			textResp.FileName = textResp.FileName[:255]
+		}
+
+		prepared, err := h.chunkParsed(ctx, bot, textResp, int64(len(page.Body)))
+		if err != nil {
+			return err
+		}
+		prepared.SourceURL = page.URL
+		if err := h.checkQuota(bot.OwnerID, len(prepared.Chunks), prepared.Size); err != nil {
+			return crawler.Stop(err)
+		}
+		if _, err := h.indexDocument(ctx, bot, prepared); err != nil {
+			return err
+		}
+		indexedChunks += len(prepared.Chunks)
+		return nil
+	})
+	if err != nil {
+		return apierror.Send(c, fiber.StatusBadRequest, apierror.CodeBadRequest, err.Error())
+	}
+
+	log.Printf("🕷️ [CrawlDocuments] Crawled %s for bot %s: %d pages, %d chunks, %d failed, %d blocked by robots.txt",
+		req.URL, bot.ID, summary.Pages, indexedChunks, len(summary.Failed), summary.BlockedByRobots)
+
+	return c.JSON(fiber.Map{
+		"success": summary.Pages > 0,
+		"bot_id":  bot.ID,
+		"url":     req.URL,
+		"chunks":  indexedChunks,
+		"summary": summary,
+	})
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"backend/apierror"
+	"backend/auth"
+	"backend/models"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// retrievalStage is the outcome of one step of the retrieval pipeline
+type retrievalStage struct {
+	Name       string    `json:"name"`
+	OK         bool      `json:"ok"`
+	DurationMS int64     `json:"duration_ms"`
+	Error      string    `json:"error,omitempty"`
+	Detail     fiber.Map `json:"detail,omitempty"`
+}
+
+// retrievalTrace records what a RAGPipeline did; all methods are no-ops on a nil trace
+type retrievalTrace struct {
+	Stages          []retrievalStage `json:"stages"`
+	FallbackUsed    bool             `json:"fallback_used"`
+	FallbackReasons []string         `json:"fallback_reasons,omitempty"`
+	ContextSource   string           `json:"context_source"` // "ai_service", "local" or "none"
+	ContextDocs     int              `json:"context_docs"`
+	ContextChars    int              `json:"context_chars"`
+	Documents       []tracedDocument `json:"documents"` // Documents of the final context, in order
+}
+
+// tracedDocument is a document of the final context with its retrieval scores
+type tracedDocument struct {
+	Source      string   `json:"source"`
+	Score       float64  `json:"score"`                  // Vector similarity; 0 for listed fallback chunks
+	RerankScore *float64 `json:"rerank_score,omitempty"` // Cross-encoder score; absent without reranking
+}
+
+// record adds a finished stage started at start
+func (t *retrievalTrace) record(name string, start time.Time, err error, detail fiber.Map) {
+	if t == nil {
+		return
+	}
+	stage := retrievalStage{
+		Name:       name,
+		OK:         err == nil,
+		DurationMS: time.Since(start).Milliseconds(),
+		Detail:     detail,
+	}
+	if err != nil {
+		stage.Error = err.Error()
+	}
+	t.Stages = append(t.Stages, stage)
+}
+
+// fallback notes that the pipeline degraded to a simpler path
+func (t *retrievalTrace) fallback(reason string) {
+	if t == nil {
+		return
+	}
+	t.FallbackUsed = true
+	t.FallbackReasons = append(t.FallbackReasons, reason)
+}
+
+// context records where the final context came from and its size
+func (t *retrievalTrace) context(source string, docs, chars int) {
+	if t == nil {
+		return
+	}
+	t.ContextSource = source
+	t.ContextDocs = docs
+	t.ContextChars = chars
+}
+
+// documents records the scores of the retrieved chunks that made it into the context
+func (t *retrievalTrace) documents(chunks []models.Document) {
+	if t == nil {
+		return
+	}
+	t.Documents = make([]tracedDocument, len(chunks))
+	for i, chunk := range chunks {
+		t.Documents[i] = tracedDocument{Source: chunk.Source, Score: chunk.Score, RerankScore: chunk.RerankScore}
+	}
+}
+
+// DiagAdvancedSearch runs the retrieval pipeline of PublicRAGChat for a test query (?q=) and reports
+// each stage with timings and whether a fallback was used, so silent degradation becomes visible (owner only)
+func (h *Handler) DiagAdvancedSearch(c *fiber.Ctx) error {
+	userID, ok := auth.GetUserID(c)
+	if !ok {
+		return apierror.Send(c, fiber.StatusUnauthorized, apierror.CodeUnauthorized, "unauthorized")
+	}
+
+	botID := normalizeBotID(c.Params("bot_id"))
+	bot, err := h.botRepo.GetByID(botID)
+	if err != nil || bot.OwnerID != userID {
+		return apierror.Send(c, fiber.StatusNotFound, apierror.CodeBotNotFound, "bot not found")
+	}
+
+	query := c.Query("q")
+	if query == "" {
+		return apierror.Send(c, fiber.StatusBadRequest, apierror.CodeBadRequest, "q is required")
+	}
+
+	req := models.RAGChatRequest{ClientID: bot.ID, Query: query}
+	req.SetDefaults(h.cfg.RAG.MaxResults, h.cfg.Generation)
+
+	trace := &retrievalTrace{Stages: []retrievalStage{}}
+	start := time.Now()
+	_, err = h.botPipeline(bot).Retrieve(c.UserContext(), &req, trace)
+
+	resp := fiber.Map{
+		"bot_id":   bot.ID,
+		"query":    query,
+		"ok":       err == nil && !trace.FallbackUsed,
+		"total_ms": time.Since(start).Milliseconds(),
+		"trace":    trace,
+	}
+	if err != nil {
+		resp["error"] = err.Error()
+	}
+	return c.JSON(resp)
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"backend/database"
+	"backend/validation"
+	"context"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/sync/errgroup"
+)
+
+// evaluationWorkers bounds how many questions of an evaluation are answered at once;
+// each one runs a full generation in the AI service
+const evaluationWorkers = 4
+
+// EvaluationCase is a question and the keywords a correct answer must contain
+type EvaluationCase struct {
+	Question         string   `json:"question" validate:"required,max=10000"`
+	ExpectedKeywords []string `json:"expected_keywords" validate:"required,min=1,max=20,dive,min=1,max=200"`
+}
+
+// EvaluateRequest is the body of POST /bots/:id/evaluate
+type EvaluateRequest struct {
+	Cases []EvaluationCase `json:"cases" validate:"required,min=1,max=50,dive"`
+}
+
+// EvaluationResult is the outcome of one question. It passes when the answer contains every expected keyword.
+type EvaluationResult struct {
+	Question        string           `json:"question"`
+	Passed          bool             `json:"passed"`
+	Answer          string           `json:"answer"`
+	FoundKeywords   []string         `json:"found_keywords"`
+	MissingKeywords []string         `json:"missing_keywords"`
+	Fallback        bool             `json:"fallback"`  // Answered with the bot's fallback answer
+	Documents       []tracedDocument `json:"documents"` // Retrieved documents with their scores
+	DurationMS      int64            `json:"duration_ms"`
+	Error           string           `json:"error,omitempty"`
+}
+
+// EvaluateBot answers known questions with the bot's chat pipeline and checks each answer for the expected
+// keywords (case-insensitive): a regression check after re-uploading documents or changing settings (owner only).
+// Evaluation answers don't trigger chat.completed webhooks.
+func (h *Handler) EvaluateBot(c *fiber.Ctx) error {
+	bot, err := h.ownedBot(c)
+	if err != nil {
+		return err
+	}
+
+	var req EvaluateRequest
+	if err := validation.ParseBody(c, &req); err != nil {
+		return err
+	}
+
+	start := time.Now()
+	ctx := c.UserContext()
+	results := make([]EvaluationResult, len(req.Cases))
+	var g errgroup.Group
+	g.SetLimit(evaluationWorkers)
+	for i, evalCase := range req.Cases {
+		g.Go(func() error {
+			results[i] = h.evaluateCase(ctx, bot, evalCase)
+			return nil
+		})
+	}
+	g.Wait()
+
+	passed := 0
+	for _, result := range results {
+		if result.Passed {
+			passed++
+		}
+	}
+	return c.JSON(fiber.Map{
+		"bot_id":      bot.ID,
+		"total":       len(results),
+		"passed":      passed,
+		"pass_rate":   float64(passed) / float64(len(results)),
+		"duration_ms": time.Since(start).Milliseconds(),
+		"results":     results,
+	})
+}
+
+// evaluateCase answers one question and matches the expected keywords against the answer
+func (h *Handler) evaluateCase(ctx context.Context, bot *database.Bot, evalCase EvaluationCase) EvaluationResult {
+	start := time.Now()
+	trace := &retrievalTrace{Stages: []retrievalStage{}, Documents: []tracedDocument{}}
+	answer, err := h.runBotPipeline(ctx, bot, evalCase.Question, trace)
+
+	result := EvaluationResult{
+		Question:        evalCase.Question,
+		FoundKeywords:   []string{},
+		MissingKeywords: []string{},
+		Documents:       trace.Documents,
+		DurationMS:      time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		result.Error = err.Error()
+		result.MissingKeywords = evalCase.ExpectedKeywords
+		return result
+	}
+
+	result.Answer = answer.Answer
+	result.Fallback = answer.Fallback
+	text := strings.ToLower(answer.Answer)
+	for _, keyword := range evalCase.ExpectedKeywords {
+		if strings.Contains(text, strings.ToLower(keyword)) {
+			result.FoundKeywords = append(result.FoundKeywords, keyword)
+		} else {
+			result.MissingKeywords = append(result.MissingKeywords, keyword)
+		}
+	}
+	result.Passed = len(result.MissingKeywords) == 0
+	return result
+}
@@ -0,0 +1,352 @@
+package handlers
+
+import (
+	"backend/apierror"
+	"backend/auth"
+	"backend/database"
+	"backend/models"
+	"backend/validation"
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// botBundleVersion is the current export bundle format version
+const botBundleVersion = 1
+
+// exportBatchSize is how many chunks are listed from the vector service at a time during export
+const exportBatchSize = 500
+
+// importBatchSize limits how many chunks are re-embedded per AI service call during import
+const importBatchSize = 64
+
+// BotBundle is the export/import format of a bot with its documents and indexed chunks
+type BotBundle struct {
+	Version    int                  `json:"version"`
+	ExportedAt time.Time            `json:"exported_at"`
+	Bot        CreateBotRequest     `json:"bot"`
+	Documents  []BundleDocument     `json:"documents" validate:"dive"`
+	Chunks     []models.ExportChunk `json:"chunks"`
+}
+
+// BundleDocument is the uploaded document metadata stored in a bundle
+type BundleDocument struct {
+	Filename    string    `json:"filename"`
+	FileType    string    `json:"file_type" validate:"max=50"`
+	FileSize    int64     `json:"file_size"`
+	ChunksCount int       `json:"chunks_count"`
+	UploadedAt  time.Time `json:"uploaded_at"`
+}
+
+// chunkPayloadKeys are vector payload keys managed by the vector service itself
+var chunkPayloadKeys = map[string]bool{"bot_id": true, "upload_date": true, "embedding_model": true}
+
+// ExportBot streams a JSON bundle with the bot settings, document list and all indexed chunks (owner only).
+// Chunks are listed page by page while the bundle is written, so a large bot is never held in memory.
+func (h *Handler) ExportBot(c *fiber.Ctx) error {
+	userID, ok := auth.GetUserID(c)
+	if !ok {
+		return apierror.Send(c, fiber.StatusUnauthorized, apierror.CodeUnauthorized, "unauthorized")
+	}
+
+	botID := normalizeBotID(c.Params("id"))
+	bot, err := h.botRepo.GetByID(botID)
+	if err != nil {
+		return apierror.Send(c, fiber.StatusNotFound, apierror.CodeBotNotFound, "bot not found")
+	}
+	if bot.OwnerID != userID {
+		return apierror.Send(c, fiber.StatusForbidden, apierror.CodeForbidden, "you don't have permission to export this bot")
+	}
+
+	documents, err := h.botRepo.GetDocuments(botID)
+	if err != nil {
+		return apierror.Send(c, fiber.StatusInternalServerError, apierror.CodeInternal, "failed to get documents")
+	}
+
+	// The first page is listed before anything is sent, so an unavailable vector service still gets an error response
+	vectorURL := h.cfg.Services.VectorURL
+	page, cursor, err := h.client.ListVectorDocumentsPage(c.UserContext(), vectorURL, botID, exportBatchSize, "")
+	if err != nil {
+		return apierror.Send(c, fiber.StatusInternalServerError, apierror.CodeVectorDBFailed, fmt.Sprintf("vector list error: %v", err))
+	}
+
+	header := BotBundle{
+		Version:    botBundleVersion,
+		ExportedAt: time.Now().UTC(),
+		Bot:        botToRequest(bot),
+		Documents:  make([]BundleDocument, 0, len(documents)),
+	}
+	for _, d := range documents {
+		header.Documents = append(header.Documents, BundleDocument{
+			Filename:    d.Filename,
+			FileType:    d.FileType,
+			FileSize:    d.FileSize,
+			ChunksCount: d.ChunksCount,
+			UploadedAt:  d.UploadedAt,
+		})
+	}
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return apierror.Send(c, fiber.StatusInternalServerError, apierror.CodeInternal, "failed to encode export")
+	}
+	// Re-open the object so chunks can be streamed one by one instead of marshaling the whole bundle
+	headerJSON = headerJSON[:len(headerJSON)-1]
+
+	c.Set("Content-Type", "application/json")
+	c.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="bot-%s.json"`, botID))
+
+	// The stream writer runs after the handler returns, when the request context can no longer be used
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		w.Write(headerJSON)
+		w.WriteString(`,"chunks":[`)
+		written := 0
+		for {
+			for _, doc := range page {
+				chunk, ok := exportChunk(doc)
+				if !ok {
+					continue
+				}
+				chunkJSON, err := json.Marshal(chunk)
+				if err != nil {
+					continue
+				}
+				if written > 0 {
+					w.WriteByte(',')
+				}
+				w.Write(chunkJSON)
+				written++
+			}
+			if err := w.Flush(); err != nil {
+				log.Printf("[ExportBot] Export of bot %s aborted after %d chunks: %v", botID, written, err)
+				return
+			}
+			if cursor == "" {
+				break
+			}
+			page, cursor, err = h.client.ListVectorDocumentsPage(context.Background(), vectorURL, botID, exportBatchSize, cursor)
+			if err != nil {
+				// The status is already sent: the bundle is left unterminated, so it fails to import instead of
+				// passing for a complete one
+				log.Printf("[ExportBot] Export of bot %s failed after %d chunks: %v", botID, written, err)
+				return
+			}
+		}
+		w.WriteString("]}")
+		w.Flush()
+		log.Printf("[ExportBot] Exported bot %s: %d documents, %d chunks", botID, len(header.Documents), written)
+	})
+
+	return nil
+}
+
+// ImportBot recreates a bot from an export bundle and re-indexes its chunks with fresh embeddings
+func (h *Handler) ImportBot(c *fiber.Ctx) error {
+	userID, ok := auth.GetUserID(c)
+	if !ok {
+		return apierror.Send(c, fiber.StatusUnauthorized, apierror.CodeUnauthorized, "unauthorized")
+	}
+
+	var bundle BotBundle
+	if err := validation.ParseBody(c, &bundle); err != nil {
+		return err
+	}
+	if bundle.Version != botBundleVersion {
+		return apierror.Send(c, fiber.StatusBadRequest, apierror.CodeBadRequest, fmt.Sprintf("unsupported bundle version %d", bundle.Version))
+	}
+	// The sizes a bundle declares are up to the client, so the quota is checked against what is actually imported
+	imports := measureChunks(bundle.Chunks)
+	if err := h.checkQuota(userID, imports.total.chunks, imports.total.bytes); err != nil {
+		return err
+	}
+	if err := checkEmbeddingModel(strings.TrimSpace(bundle.Bot.EmbeddingModel), h.cfg.RAG.EmbeddingModels); err != nil {
+		return err
+	}
+
+	bot, err := h.botRepo.Create(newBotFromRequest(userID, &bundle.Bot))
+	if err != nil {
+		return apierror.Send(c, fiber.StatusInternalServerError, apierror.CodeInternal, "failed to create bot")
+	}
+
+	imported, err := h.importChunks(c.UserContext(), bot, bundle.Chunks)
+	if err != nil {
+		log.Printf("[ImportBot] Import into bot %s failed after %d chunks: %v", bot.ID, imported, err)
+		if delErr := h.client.DeleteVectorDocuments(c.UserContext(), h.cfg.Services.VectorURL, bot.ID); delErr != nil {
+			log.Printf("[ImportBot] Failed to clean up vectors for bot %s: %v", bot.ID, delErr)
+		}
+		if delErr := h.botRepo.Delete(bot.ID, userID); delErr != nil {
+			log.Printf("[ImportBot] Failed to remove bot %s: %v", bot.ID, delErr)
+		}
+		return apierror.Send(c, fiber.StatusInternalServerError, apierror.CodeImportFailed, fmt.Sprintf("import error: %v", err))
+	}
+
+	// Usage is summed from the document rows, so every imported chunk has to be covered by one: declared
+	// documents are never recorded smaller than their chunks, and chunks of undeclared files get rows of their own
+	declared := make(map[string]bool, len(bundle.Documents))
+	documents := make([]*database.BotDocument, 0, len(bundle.Documents))
+	for _, d := range bundle.Documents {
+		measured := imports.byFile[d.Filename]
+		declared[d.Filename] = true
+		documents = append(documents, &database.BotDocument{
+			BotID:       bot.ID,
+			Filename:    importedFilename(d.Filename),
+			FileType:    d.FileType,
+			FileSize:    max(d.FileSize, measured.bytes),
+			ChunksCount: max(d.ChunksCount, measured.chunks),
+		})
+	}
+	for _, name := range slices.Sorted(maps.Keys(imports.byFile)) {
+		if declared[name] {
+			continue
+		}
+		measured := imports.byFile[name]
+		documents = append(documents, &database.BotDocument{
+			BotID:       bot.ID,
+			Filename:    importedFilename(name),
+			FileSize:    measured.bytes,
+			ChunksCount: measured.chunks,
+		})
+	}
+	for _, doc := range documents {
+		if err := h.botRepo.AddDocument(doc); err != nil {
+			log.Printf("[ImportBot] Failed to record document %q for bot %s: %v", doc.Filename, bot.ID, err)
+		}
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success":   true,
+		"bot":       bot,
+		"chunks":    imported,
+		"documents": len(documents),
+	})
+}
+
+// chunkUsage is the number and text size of imported chunks
+type chunkUsage struct {
+	chunks int
+	bytes  int64
+}
+
+// bundleUsage is what importing a bundle's chunks adds to the owner's storage, in total and per file
+type bundleUsage struct {
+	total  chunkUsage
+	byFile map[string]chunkUsage // By the "file_name" metadata of the chunks
+}
+
+// measureChunks sums the chunks that importChunks indexes (those with text)
+func measureChunks(chunks []models.ExportChunk) bundleUsage {
+	usage := bundleUsage{byFile: make(map[string]chunkUsage)}
+	for _, chunk := range chunks {
+		if chunk.Text == "" {
+			continue
+		}
+		size := int64(len(chunk.Text))
+		usage.total.chunks++
+		usage.total.bytes += size
+		file := usage.byFile[chunk.Metadata["file_name"]]
+		file.chunks++
+		file.bytes += size
+		usage.byFile[chunk.Metadata["file_name"]] = file
+	}
+	return usage
+}
+
+// importedFilename fits a file name from a bundle to the filename column, so recording its row can't fail
+func importedFilename(name string) string {
+	if name == "" {
+		return "imported chunks"
+	}
+	// The filename column holds 255 bytes; the name is cut on a character boundary
+	for len(name) > 255 {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	return name
+}
+
+// importChunks embeds and indexes chunks in batches, returning how many were indexed
+func (h *Handler) importChunks(ctx context.Context, bot *database.Bot, chunks []models.ExportChunk) (int, error) {
+	imported := 0
+	for start := 0; start < len(chunks); start += importBatchSize {
+		end := start + importBatchSize
+		if end > len(chunks) {
+			end = len(chunks)
+		}
+
+		texts := make([]string, 0, end-start)
+		metadata := make([]map[string]string, 0, end-start)
+		for _, chunk := range chunks[start:end] {
+			if chunk.Text == "" {
+				continue
+			}
+			meta := chunk.Metadata
+			if meta == nil {
+				meta = map[string]string{}
+			}
+			texts = append(texts, chunk.Text)
+			metadata = append(metadata, meta)
+		}
+		if len(texts) == 0 {
+			continue
+		}
+
+		embeddings, err := h.client.CreateEmbeddings(ctx, h.cfg.Services.AIURL, bot.EmbeddingModel, bot.UsesInstructionPrefix, texts)
+		if err != nil {
+			return imported, fmt.Errorf("embedding error: %w", err)
+		}
+		if err := h.client.AddVectorDocuments(ctx, h.cfg.Services.VectorURL, bot.ID, bot.EmbeddingModel, texts, embeddings, metadata); err != nil {
+			return imported, fmt.Errorf("vector DB error: %w", err)
+		}
+		imported += len(texts)
+	}
+	return imported, nil
+}
+
+// exportChunk converts a vector document into a bundle chunk
+func exportChunk(doc models.Document) (models.ExportChunk, bool) {
+	if doc.Text == "" {
+		return models.ExportChunk{}, false
+	}
+	meta := make(map[string]string, len(doc.Metadata))
+	for key, value := range doc.Metadata {
+		if !chunkPayloadKeys[key] {
+			meta[key] = value
+		}
+	}
+	return models.ExportChunk{Text: doc.Text, Metadata: meta}, true
+}
+
+// botToRequest captures the bot settings in the same shape used to create a bot
+func botToRequest(bot *database.Bot) CreateBotRequest {
+	isPublic := bot.IsPublic
+	config := bot.Config
+	return CreateBotRequest{
+		Name:         bot.Name,
+		Description:  bot.Description,
+		Temperature:  bot.Temperature,
+		TopP:         bot.TopP,
+		TopK:         bot.TopK,
+		MaxNewTokens: bot.MaxNewTokens,
+		DoSample:     bot.DoSample,
+		SystemPrompt: bot.SystemPrompt,
+		ChunkSize:    bot.ChunkSize,
+		ChunkOverlap: bot.ChunkOverlap,
+		IsPublic:     &isPublic,
+
+		ModelContextTokens: bot.ModelContextTokens,
+		RerankCandidates:   bot.RerankCandidates,
+		RerankTopK:         bot.RerankTopK,
+		EmbeddingModel:     bot.EmbeddingModel,
+		Config:             &config,
+
+		UsesInstructionPrefix: bot.UsesInstructionPrefix,
+	}
+}
@@ -1,25 +1,48 @@
 package handlers
 
 import (
+	"backend/answercache"
+	"backend/apierror"
+	"backend/audit"
+	"backend/auth"
 	"backend/clients"
 	"backend/config"
+	"backend/database"
 	"backend/models"
+	"backend/secrets"
 	"backend/utils"
+	"backend/validation"
+	"backend/webhooks"
 	"bufio"
 	"context"
-	"encoding/json"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/gofiber/fiber/v2"
-	"golang.org/x/sync/errgroup"
+	"github.com/google/uuid"
 )
 
 type Handler struct {
-	cfg    *config.Config
-	client *clients.Client
+	cfg             *config.Config
+	client          ServiceClient
+	botRepo         *database.BotRepository
+	userRepo        *database.UserRepository
+	webhooks        *webhooks.Dispatcher
+	integrationRepo *database.IntegrationRepository
+	secrets         *secrets.Box       // nil when integrations are disabled
+	answers         *answercache.Cache // nil when answer caching is disabled
+	audit           *audit.Logger
+	streams         *streamTracker
+	reembedRepo     *database.ReembedRepository
 }
 
 // clampContext limits context size to avoid exceeding model window
@@ -34,16 +57,85 @@ func clampContext(contextStr string, maxChars int) string {
 	return contextStr
 }
 
+// ragPayloadFields are the payload keys the RAG path uses besides the chunk text:
+// sources for the documents event and file/chunk ids for reranking logs
+var ragPayloadFields = []string{"source", "file_name", "chunk_index"}
+
+// contextReserveTokens covers chat template markup and the "Context:" framing around the prompt
+const contextReserveTokens = 128
+
+// modelContextTokens returns the model window for a bot, falling back to the global setting
+func (h *Handler) modelContextTokens(bot *database.Bot) int {
+	if bot != nil && bot.ModelContextTokens > 0 {
+		return bot.ModelContextTokens
+	}
+	return h.cfg.RAG.ModelContextTokens
+}
+
+// rerankLimits returns how many vector candidates to fetch and how many documents to keep after reranking,
+// preferring the bot's settings over the global ones
+func (h *Handler) rerankLimits(bot *database.Bot) (int, int) {
+	candidates, topK := h.cfg.RAG.MaxResults, h.cfg.RAG.RerankTopK
+	if bot != nil && bot.RerankCandidates > 0 {
+		candidates = bot.RerankCandidates
+	}
+	if bot != nil && bot.RerankTopK > 0 {
+		topK = bot.RerankTopK
+	}
+	if topK > candidates {
+		topK = candidates
+	}
+	return candidates, topK
+}
+
+// fitContext trims retrieved documents so that prompt, query, context and the answer fit the model window,
+// then builds the context string (or trims the prebuilt one). modelTokens <= 0 disables token budgeting;
+// the RAG_MAX_CONTEXT_CHARS cap always applies.
+func (h *Handler) fitContext(req models.RAGChatRequest, docs []string, prebuilt string, modelTokens int) ([]string, string) {
+	budget := 0
+	if modelTokens > 0 {
+		budget = modelTokens - req.MaxNewTokens - contextReserveTokens -
+			utils.EstimateTokens(req.SystemPrompt) - utils.EstimateTokens(req.Query)
+		if budget < contextReserveTokens {
+			budget = contextReserveTokens
+		}
+		before := len(docs)
+		docs = utils.FitToTokenBudget(docs, budget)
+		if len(docs) < before {
+			slog.Info("Context trimmed to the token budget", "budget_tokens", budget, "window_tokens", modelTokens, "kept", len(docs), "documents", before)
+		}
+	}
+
+	contextStr := prebuilt
+	if contextStr == "" {
+		contextStr = utils.BuildContext(docs)
+	} else if budget > 0 {
+		contextStr = utils.TruncateToTokens(contextStr, budget)
+	}
+	return docs, clampContext(contextStr, h.cfg.RAG.MaxContextChars)
+}
+
 // normalizeBotID strips a leading "bot_" prefix if callers provide the collection-style ID.
 // This keeps the bot UUID consistent across services and avoids double-prefix collection names.
 func normalizeBotID(botID string) string {
 	return strings.TrimPrefix(botID, "bot_")
 }
 
-func NewHandler(cfg *config.Config, client *clients.Client) *Handler {
+func NewHandler(cfg *config.Config, client ServiceClient, botRepo *database.BotRepository, userRepo *database.UserRepository,
+	dispatcher *webhooks.Dispatcher, integrationRepo *database.IntegrationRepository, box *secrets.Box, auditLog *audit.Logger,
+	reembedRepo *database.ReembedRepository) *Handler {
 	return &Handler{
-		cfg:    cfg,
-		client: client,
+		cfg:             cfg,
+		client:          client,
+		botRepo:         botRepo,
+		userRepo:        userRepo,
+		webhooks:        dispatcher,
+		integrationRepo: integrationRepo,
+		secrets:         box,
+		answers:         answercache.New(cfg.RAG.AnswerCacheSize, cfg.RAG.AnswerCacheTTL),
+		audit:           auditLog,
+		streams:         newStreamTracker(),
+		reembedRepo:     reembedRepo,
 	}
 }
 
@@ -58,351 +150,674 @@ func (h *Handler) Health(c *fiber.Ctx) error {
 	})
 }
 
-// GetDefaults returns default generation parameters
+// GetDefaults returns default generation parameters. With ?bot_id= (owner only) it returns the settings
+// the bot's chats run with: its stored values over the global defaults, plus its RAG settings.
 func (h *Handler) GetDefaults(c *fiber.Ctx) error {
-	return c.JSON(fiber.Map{
+	defaults := fiber.Map{
 		"temperature":    h.cfg.Generation.Temperature,
 		"top_p":          h.cfg.Generation.TopP,
 		"top_k":          h.cfg.Generation.TopK,
 		"max_new_tokens": h.cfg.Generation.MaxNewTokens,
 		"do_sample":      h.cfg.Generation.DoSample,
 		"user_prompt":    h.cfg.Generation.UserPrompt,
-	})
-}
+	}
+	botID := c.Query("bot_id")
+	if botID == "" {
+		return c.JSON(defaults)
+	}
 
-// UploadDocument handles document upload and processing
-func (h *Handler) UploadDocument(c *fiber.Ctx) error {
-	// Get and validate client ID
-	clientID := utils.SanitizeInput(c.FormValue("client_id"))
-	if err := utils.ValidateClientID(clientID); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	bot, err := h.ownedBotByID(c, botID)
+	if err != nil {
+		return err
+	}
+	// Zero values aren't stored settings: chats fall back to the global defaults for them (see SetDefaults)
+	if bot.Temperature > 0 {
+		defaults["temperature"] = bot.Temperature
+	}
+	if bot.TopP > 0 {
+		defaults["top_p"] = bot.TopP
+	}
+	if bot.TopK > 0 {
+		defaults["top_k"] = bot.TopK
+	}
+	if bot.MaxNewTokens > 0 {
+		defaults["max_new_tokens"] = bot.MaxNewTokens
 	}
+	defaults["do_sample"] = bot.DoSample
+	candidates, topK := h.rerankLimits(bot)
+	defaults["bot_id"] = bot.ID
+	defaults["system_prompt"] = bot.SystemPrompt
+	defaults["chunk_size"] = bot.ChunkSize
+	defaults["chunk_overlap"] = bot.ChunkOverlap
+	defaults["model_context_tokens"] = h.modelContextTokens(bot)
+	defaults["rerank_candidates"] = candidates
+	defaults["rerank_top_k"] = topK
+	defaults["embedding_model"] = bot.EmbeddingModel
+	return c.JSON(defaults)
+}
 
-	// Get file
+// openUpload reads the "file" form field and checks its size, extension and sniffed content type.
+// The returned file is rewound to the start; errors are *apierror.Error values ready to return.
+func (h *Handler) openUpload(c *fiber.Ctx) (*multipart.FileHeader, multipart.File, error) {
 	fileHeader, err := c.FormFile("file")
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "file is required"})
+		return nil, nil, apierror.New(fiber.StatusBadRequest, apierror.CodeBadRequest, "file is required")
 	}
 
-	// Validate file size (max 100MB)
-	const maxFileSize = 100 * 1024 * 1024
-	if fileHeader.Size > maxFileSize {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "file too large (max 10MB)"})
+	if fileHeader.Size > h.cfg.Upload.MaxBytes {
+		return nil, nil, apierror.New(fiber.StatusRequestEntityTooLarge, apierror.CodeFileTooLarge, fmt.Sprintf("file too large (max %s)", utils.FormatBytes(h.cfg.Upload.MaxBytes)))
 	}
 
-	// Validate file extension
-	allowedExtensions := map[string]bool{
-		".pdf": true, ".txt": true, ".docx": true, ".doc": true,
-		".csv": true, ".xlsx": true, ".json": true, ".md": true, ".html": true,
+	ext, ok := utils.UploadExtension(fileHeader.Filename)
+	if !ok {
+		return nil, nil, apierror.New(fiber.StatusBadRequest, apierror.CodeUnsupportedFile, fmt.Sprintf("unsupported file type (allowed: %s)", strings.Join(utils.AllowedUploadExtensions(), ", ")))
 	}
-	filename := strings.ToLower(fileHeader.Filename)
-	isAllowed := false
-	for ext := range allowedExtensions {
-		if strings.HasSuffix(filename, ext) {
-			isAllowed = true
-			break
-		}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, nil, apierror.New(fiber.StatusInternalServerError, apierror.CodeInternal, "cannot open file")
 	}
-	if !isAllowed {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "unsupported file type (allowed: pdf, txt, docx, csv, xlsx, json, md, html)",
-		})
+
+	// Sniff the real content type instead of trusting the extension
+	head := make([]byte, utils.SniffLength)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		file.Close()
+		return nil, nil, apierror.New(fiber.StatusInternalServerError, apierror.CodeInternal, "cannot read file")
+	}
+	if err := utils.CheckUploadContent(ext, head[:n]); err != nil {
+		file.Close()
+		return nil, nil, apierror.New(fiber.StatusBadRequest, apierror.CodeUnsupportedFile, err.Error())
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		file.Close()
+		return nil, nil, apierror.New(fiber.StatusInternalServerError, apierror.CodeInternal, "cannot read file")
 	}
 
-	// Open file
-	file, err := fileHeader.Open()
+	return fileHeader, file, nil
+}
+
+// UploadDocument handles document upload and processing
+func (h *Handler) UploadDocument(c *fiber.Ctx) error {
+	// Get and validate client ID
+	clientID := utils.SanitizeInput(c.FormValue("client_id"))
+	if err := utils.ValidateClientID(clientID); err != nil {
+		return apierror.Send(c, fiber.StatusBadRequest, apierror.CodeValidationFailed, err.Error())
+	}
+
+	// Get and validate file
+	fileHeader, file, err := h.openUpload(c)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot open file"})
+		return err
 	}
 	defer file.Close()
 
+	opts, err := parseOptions(c)
+	if err != nil {
+		return err
+	}
+	opts.DetectLanguage = h.cfg.Upload.DetectLanguage
+
 	// Parse document
-	textResp, err := h.client.ParseDocument(h.cfg.Services.DocParserURL, fileHeader.Filename, file)
+	textResp, err := h.client.ParseDocument(c.UserContext(), h.cfg.Services.DocParserURL, fileHeader.Filename, file, opts)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("parse error: %v", err)})
+		return apierror.Send(c, fiber.StatusBadRequest, apierror.CodeParseFailed, fmt.Sprintf("parse error: %v", err))
 	}
 
 	// Не разбиваем на чанки, сохраняем весь текст как один документ
 	if len(strings.TrimSpace(textResp.Text)) == 0 {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "no text extracted from document"})
+		return apierror.Send(c, fiber.StatusBadRequest, apierror.CodeEmptyDocument, emptyDocumentMessage(textResp))
 	}
 
-	embeddings, err := h.client.CreateEmbeddings(h.cfg.Services.AIURL, []string{textResp.Text})
+	embeddings, err := h.client.CreateEmbeddings(c.UserContext(), h.cfg.Services.AIURL, "", nil, []string{textResp.Text})
 	if err != nil || len(embeddings) == 0 {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("embedding error: %v", err)})
+		return apierror.Send(c, fiber.StatusInternalServerError, apierror.CodeEmbeddingFailed, fmt.Sprintf("embedding error: %v", err))
 	}
 
 	metadata := []map[string]string{{
 		"file_name": textResp.FileName,
 		"file_type": textResp.FileType,
 	}}
+	if textResp.Language != "" {
+		metadata[0]["language"] = textResp.Language
+	}
 
-	if err := h.client.AddVectorDocuments(h.cfg.Services.VectorURL, clientID, []string{textResp.Text}, embeddings, metadata); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("vector DB error: %v", err)})
+	if err := h.client.AddVectorDocuments(c.UserContext(), h.cfg.Services.VectorURL, clientID, "", []string{textResp.Text}, embeddings, metadata); err != nil {
+		return apierror.Send(c, fiber.StatusInternalServerError, apierror.CodeVectorDBFailed, fmt.Sprintf("vector DB error: %v", err))
 	}
 
-	return c.JSON(fiber.Map{
+	return c.JSON(withExtractionInfo(fiber.Map{
 		"success":   true,
 		"client_id": clientID,
 		"chunks":    1,
 		"file_name": textResp.FileName,
-	})
+	}, textResp))
 }
 
-// UploadDocumentForBot handles document upload for a specific bot (requires auth and ownership)
-func (h *Handler) UploadDocumentForBot(c *fiber.Ctx) error {
-	botID := normalizeBotID(c.Params("id"))
-	log.Printf("[UploadDocumentForBot] Received bot_id from URL: %q", botID)
+// preparedDocument is a parsed document chunked with the bot's settings, ready for indexing
+type preparedDocument struct {
+	Parsed       *models.ParseResponse
+	Size         int64  // Original file or page size in bytes
+	SourceURL    string // Set for crawled pages
+	Chunks       []string
+	ChunkSize    int
+	ChunkOverlap int
+	Splitter     string         // "ai_service", "local" (fallback) or "sections" (chunk_strategy=sections)
+	Redactions   map[string]int // PII matches redacted per rule
+	Truncated    int            // Chunks dropped above MAX_CHUNKS_PER_DOC
+	TotalChars   int            // Characters of the split text
+	AvgChunkLen  int            // TotalChars per chunk, as reported by the splitter
+	KeepVersions bool           // Keep earlier uploads of the same file name searchable instead of replacing them
+	ContentHash  string         // SHA-256 of the uploaded file, recorded for duplicate detection
+	Replaced     int64          // Set by indexDocument: earlier versions removed
+}
 
-	if botID == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "bot_id is required"})
+// chunkSettings returns the bot's chunk size and overlap, falling back to the global settings
+func (h *Handler) chunkSettings(bot *database.Bot) (int, int) {
+	size, overlap := h.cfg.RAG.ChunkSize, h.cfg.RAG.ChunkOverlap
+	if bot.ChunkSize > 0 {
+		size = bot.ChunkSize
+		overlap = bot.ChunkOverlap
 	}
+	return size, overlap
+}
 
-	// Get file
+// prepareDocument validates and parses the uploaded file, then chunks it with chunkParsed.
+// Errors are *apierror.Error values.
+func (h *Handler) prepareDocument(c *fiber.Ctx, bot *database.Bot) (*preparedDocument, error) {
+	opts, err := parseOptions(c)
+	if err != nil {
+		return nil, err
+	}
+	opts.DetectLanguage = h.cfg.Upload.DetectLanguage
+	fileHeader, file, err := h.openUpload(c)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	textResp, err := h.client.ParseDocument(c.UserContext(), h.cfg.Services.DocParserURL, fileHeader.Filename, file, opts)
+	if err != nil {
+		return nil, apierror.New(fiber.StatusBadRequest, apierror.CodeParseFailed, fmt.Sprintf("parse error: %v", err))
+	}
+	return h.chunkParsed(c.UserContext(), bot, textResp, fileHeader.Size)
+}
+
+// uploadHash returns the hex SHA-256 of the uploaded file. Errors are *apierror.Error values.
+func uploadHash(c *fiber.Ctx) (string, error) {
 	fileHeader, err := c.FormFile("file")
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "file is required"})
+		return "", apierror.New(fiber.StatusBadRequest, apierror.CodeBadRequest, "file is required")
 	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		return "", apierror.New(fiber.StatusInternalServerError, apierror.CodeInternal, "cannot open file")
+	}
+	defer file.Close()
 
-	// Validate file size (max 100MB)
-	const maxFileSize = 100 * 1024 * 1024
-	if fileHeader.Size > maxFileSize {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "file too large (max 10MB)"})
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", apierror.New(fiber.StatusInternalServerError, apierror.CodeInternal, "cannot read file")
 	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
 
-	// Validate file extension
-	allowedExtensions := map[string]bool{
-		".pdf": true, ".txt": true, ".docx": true, ".doc": true,
-		".csv": true, ".xlsx": true, ".json": true, ".md": true, ".html": true,
+// parseOptions reads the parser settings of an upload form (json_mode, csv_header, chunk_strategy).
+// Errors are *apierror.Error values.
+func parseOptions(c *fiber.Ctx) (models.ParseOptions, error) {
+	var opts models.ParseOptions
+	switch mode := c.FormValue("json_mode"); mode {
+	case "", models.JSONModePretty, models.JSONModeFlatten:
+		opts.JSONMode = mode
+	default:
+		return models.ParseOptions{}, apierror.New(fiber.StatusBadRequest, apierror.CodeValidationFailed,
+			fmt.Sprintf("unsupported json_mode %q (use %q or %q)", mode, models.JSONModePretty, models.JSONModeFlatten))
 	}
-	filename := strings.ToLower(fileHeader.Filename)
-	isAllowed := false
-	for ext := range allowedExtensions {
-		if strings.HasSuffix(filename, ext) {
-			isAllowed = true
-			break
+	if value := c.FormValue("csv_header"); value != "" {
+		header, err := strconv.ParseBool(value)
+		if err != nil {
+			return models.ParseOptions{}, apierror.New(fiber.StatusBadRequest, apierror.CodeValidationFailed,
+				fmt.Sprintf("invalid csv_header %q (use true or false)", value))
 		}
+		opts.CSVHeader = header
 	}
-	if !isAllowed {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "unsupported file type (allowed: pdf, txt, docx, csv, xlsx, json, md, html)",
-		})
+	switch strategy := c.FormValue("chunk_strategy"); strategy {
+	case "", models.ChunkStrategySemantic:
+	case models.ChunkStrategySections:
+		// The parser only returns sections for markdown; other formats keep semantic chunking
+		opts.MarkdownSections = true
+	default:
+		return models.ParseOptions{}, apierror.New(fiber.StatusBadRequest, apierror.CodeValidationFailed,
+			fmt.Sprintf("unsupported chunk_strategy %q (use %q or %q)", strategy, models.ChunkStrategySemantic, models.ChunkStrategySections))
 	}
+	return opts, nil
+}
 
-	// Open file
-	file, err := fileHeader.Open()
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot open file"})
+// chunkParsed applies the bot's PII redaction to parsed text and splits it into chunks via the AI service
+// (falling back to local chunking). Errors are *apierror.Error values.
+func (h *Handler) chunkParsed(ctx context.Context, bot *database.Bot, textResp *models.ParseResponse, size int64) (*preparedDocument, error) {
+	if len(strings.TrimSpace(textResp.Text)) == 0 {
+		return nil, apierror.New(fiber.StatusBadRequest, apierror.CodeEmptyDocument, emptyDocumentMessage(textResp))
+	}
+	if warning := textResp.ExtractionWarning(); warning != "" {
+		slog.Warn("Partial extraction", "file", textResp.FileName, "warning", warning)
 	}
-	defer file.Close()
 
-	// Parse document
-	textResp, err := h.client.ParseDocument(h.cfg.Services.DocParserURL, fileHeader.Filename, file)
-	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("parse error: %v", err)})
+	doc := &preparedDocument{Parsed: textResp, Size: size, Splitter: "ai_service"}
+	if bot.Config.PII.Enabled {
+		rules, err := utils.RedactionRules(bot.Config.PII.Rules, bot.Config.PII.CustomPatterns)
+		if err != nil {
+			return nil, apierror.New(fiber.StatusBadRequest, apierror.CodeBadRequest, err.Error())
+		}
+		textResp.Text, doc.Redactions = utils.Redact(textResp.Text, rules)
+		for i := range textResp.Sections {
+			textResp.Sections[i].Heading, _ = utils.Redact(textResp.Sections[i].Heading, rules)
+			textResp.Sections[i].Text, _ = utils.Redact(textResp.Sections[i].Text, rules)
+		}
+		if len(doc.Redactions) > 0 {
+			slog.Info("Redacted sensitive data in document", "file", textResp.FileName, "redactions", utils.FormatRedactionCounts(doc.Redactions))
+		}
 	}
 
-	if len(strings.TrimSpace(textResp.Text)) == 0 {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "no text extracted from document"})
+	// Split into semantic chunks via AI service (fallback to local chunking on error);
+	// markdown parsed with chunk_strategy=sections is chunked along its headings instead
+	doc.ChunkSize, doc.ChunkOverlap = h.chunkSettings(bot)
+	if len(textResp.Sections) > 0 {
+		doc.Chunks = utils.ChunkSections(textResp.Sections, doc.ChunkSize, doc.ChunkOverlap)
+		doc.Splitter = "sections"
+	} else if split, err := h.splitDocument(ctx, textResp.Text, doc.ChunkSize, doc.ChunkOverlap); err != nil {
+		slog.Warn("split-document failed, falling back to simple chunking", "error", err)
+		doc.Chunks = utils.ChunkText(textResp.Text, doc.ChunkSize, doc.ChunkOverlap)
+		doc.Splitter = "local"
+	} else {
+		doc.Chunks, doc.TotalChars, doc.AvgChunkLen = split.Chunks, split.TotalChars, split.AvgChunkLen
+	}
+	if doc.Splitter != "ai_service" {
+		doc.TotalChars = utf8.RuneCountInString(textResp.Text)
+		if len(doc.Chunks) > 0 {
+			doc.AvgChunkLen = doc.TotalChars / len(doc.Chunks)
+		}
+	}
+	if len(doc.Chunks) == 0 {
+		return nil, apierror.New(fiber.StatusBadRequest, apierror.CodeEmptyDocument, "no chunks created from document")
+	}
+	slog.Info("Document split", "file", textResp.FileName, "splitter", doc.Splitter, "chars", doc.TotalChars,
+		"chunks", len(doc.Chunks), "avg_chunk_chars", doc.AvgChunkLen, "chunk_size", doc.ChunkSize, "chunk_overlap", doc.ChunkOverlap)
+	if err := h.limitChunks(doc); err != nil {
+		return nil, err
 	}
+	return doc, nil
+}
 
-	// Split into semantic chunks via AI service (fallback to local chunking on error)
-	var chunks []string
-	chunks, err = h.client.SplitDocument(h.cfg.Services.AIURL, textResp.Text, h.cfg.RAG.ChunkSize, h.cfg.RAG.ChunkOverlap)
-	if err != nil || len(chunks) == 0 {
-		log.Printf("[UploadDocumentForBot] split-document failed: %v; falling back to simple chunking", err)
-		chunks = utils.ChunkText(textResp.Text, h.cfg.RAG.ChunkSize, h.cfg.RAG.ChunkOverlap)
+// splitDocument asks the AI service for semantic chunks, giving up after SPLIT_DOCUMENT_TIMEOUT
+// so a slow AI service delays uploads by at most that much before local chunking takes over
+func (h *Handler) splitDocument(ctx context.Context, text string, chunkSize, overlap int) (*models.SplitDocumentResponse, error) {
+	if h.cfg.RAG.SplitTimeout <= 0 {
+		return h.client.SplitDocument(ctx, h.cfg.Services.AIURL, text, chunkSize, overlap)
+	}
+	splitCtx, cancel := context.WithTimeout(ctx, h.cfg.RAG.SplitTimeout)
+	defer cancel()
+	split, err := h.client.SplitDocument(splitCtx, h.cfg.Services.AIURL, text, chunkSize, overlap)
+	if err != nil && ctx.Err() == nil && errors.Is(splitCtx.Err(), context.DeadlineExceeded) {
+		return nil, fmt.Errorf("no answer within SPLIT_DOCUMENT_TIMEOUT (%s)", h.cfg.RAG.SplitTimeout)
 	}
-	if len(chunks) == 0 {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "no chunks created from document"})
+	return split, err
+}
+
+// limitChunks enforces MAX_CHUNKS_PER_DOC: the document is rejected or its extra chunks are dropped,
+// depending on CHUNK_LIMIT_ACTION
+func (h *Handler) limitChunks(doc *preparedDocument) error {
+	limit := h.cfg.Upload.MaxChunksPerDoc
+	if limit <= 0 || len(doc.Chunks) <= limit {
+		return nil
+	}
+	if h.cfg.Upload.ChunkLimitAction == config.ChunkLimitTruncate {
+		slog.Warn("Document exceeds the chunk limit, truncating", "file", doc.Parsed.FileName, "chunks", len(doc.Chunks), "limit", limit)
+		doc.Truncated = len(doc.Chunks) - limit
+		doc.Chunks = doc.Chunks[:limit]
+		return nil
+	}
+	slog.Warn("Document exceeds the chunk limit, rejected", "file", doc.Parsed.FileName, "chunks", len(doc.Chunks), "limit", limit)
+	return apierror.New(fiber.StatusRequestEntityTooLarge, apierror.CodeTooManyChunks,
+		fmt.Sprintf("document produced %d chunks, more than the limit of %d per document; split it or increase the chunk size", len(doc.Chunks), limit))
+}
+
+// withTruncationInfo reports chunks dropped by limitChunks in an upload or preview response
+func withTruncationInfo(resp fiber.Map, doc *preparedDocument) fiber.Map {
+	if doc.Truncated > 0 {
+		resp["truncated_chunks"] = doc.Truncated
+		resp["truncation_warning"] = fmt.Sprintf("only the first %d chunks were kept; %d were dropped by the per-document limit", len(doc.Chunks), doc.Truncated)
+	}
+	return resp
+}
+
+// indexDocument embeds the chunks of a prepared document, stores them in the bot's vector collection,
+// records the document and emits document.indexed. Errors are *apierror.Error values.
+func (h *Handler) indexDocument(ctx context.Context, bot *database.Bot, prepared *preparedDocument) (*database.BotDocument, error) {
+	botID := bot.ID
+	textResp, chunks := prepared.Parsed, prepared.Chunks
+	// Chunks added now would miss the collection that replaces the current one
+	if err := h.checkNotReembedding(botID); err != nil {
+		return nil, err
 	}
 
-	log.Printf("[UploadDocumentForBot] Creating embeddings for %d chunks from %s", len(chunks), textResp.FileName)
-	embeddings, err := h.client.CreateEmbeddings(h.cfg.Services.AIURL, chunks)
+	slog.Debug("Creating document embeddings", "bot_id", botID, "file", textResp.FileName, "chunks", len(chunks))
+	embeddings, err := h.client.CreateEmbeddings(ctx, h.cfg.Services.AIURL, bot.EmbeddingModel, bot.UsesInstructionPrefix, chunks)
 	if err != nil || len(embeddings) == 0 {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("embedding error: %v", err)})
+		return nil, apierror.New(fiber.StatusInternalServerError, apierror.CodeEmbeddingFailed, fmt.Sprintf("embedding error: %v", err))
 	}
 
 	if len(embeddings) != len(chunks) {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "embedding count mismatch"})
+		return nil, apierror.New(fiber.StatusInternalServerError, apierror.CodeEmbeddingFailed, "embedding count mismatch")
 	}
 
+	// Uploading a file name again creates a new version; the earlier ones are removed once it is indexed
+	latest, err := h.botRepo.LatestDocumentVersion(botID, textResp.FileName)
+	if err != nil {
+		return nil, apierror.New(fiber.StatusInternalServerError, apierror.CodeInternal, "failed to check document versions")
+	}
+	version := latest + 1
+	replace := latest > 0 && !prepared.KeepVersions
+
+	// "source" is what answers link to: the page URL for crawled pages, the file name otherwise
+	source := textResp.FileName
+	if prepared.SourceURL != "" {
+		source = prepared.SourceURL
+	}
 	metadata := make([]map[string]string, len(chunks))
 	for i := range chunks {
 		metadata[i] = map[string]string{
 			"file_name":   textResp.FileName,
 			"file_type":   textResp.FileType,
 			"chunk_index": fmt.Sprintf("%d", i),
+			"source":      source,
+			"doc_version": fmt.Sprintf("%d", version),
+		}
+		if textResp.Language != "" {
+			metadata[i]["language"] = textResp.Language
 		}
 	}
 
+	// Checked again now that embedding is done: a re-embedding may have started meanwhile
+	if err := h.checkNotReembedding(botID); err != nil {
+		return nil, err
+	}
+
 	// Add to vector DB using bot_id
-	log.Printf("[UploadDocumentForBot] Adding to vector DB with bot_id: %q, chunks: %d", botID, len(chunks))
-	if err := h.client.AddVectorDocuments(h.cfg.Services.VectorURL, botID, chunks, embeddings, metadata); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("vector DB error: %v", err)})
+	slog.Debug("Adding document to the vector DB", "bot_id", botID, "file", textResp.FileName, "chunks", len(chunks))
+	if err := h.client.AddVectorDocuments(ctx, h.cfg.Services.VectorURL, botID, bot.EmbeddingModel, chunks, embeddings, metadata); err != nil {
+		if errors.Is(err, clients.ErrEmbeddingModelMismatch) {
+			return nil, apierror.New(fiber.StatusConflict, apierror.CodeConflict, err.Error())
+		}
+		return nil, apierror.New(fiber.StatusInternalServerError, apierror.CodeVectorDBFailed, fmt.Sprintf("vector DB error: %v", err))
+	}
+	if replace {
+		if err := h.client.DeleteVectorFileDocuments(ctx, h.cfg.Services.VectorURL, botID, textResp.FileName, version); err != nil {
+			// Both versions stay searchable and recorded; uploading again retries the replacement
+			slog.Warn("Failed to remove earlier document versions", "bot_id", botID, "file", textResp.FileName, "error", err)
+			replace = false
+		}
+	}
+	if dropped := h.answers.InvalidateBot(botID); dropped > 0 {
+		slog.Debug("Dropped cached answers", "bot_id", botID, "answers", dropped)
 	}
 
-	return c.JSON(fiber.Map{
-		"success":   true,
-		"bot_id":    botID,
-		"chunks":    len(chunks),
-		"file_name": textResp.FileName,
-	})
-}
+	// Keep the original text so the document can be re-chunked or re-embedded later
+	doc := &database.BotDocument{
+		BotID:       botID,
+		Filename:    textResp.FileName,
+		FileType:    textResp.FileType,
+		FileSize:    prepared.Size,
+		ChunksCount: len(chunks),
+		Version:     version,
+		Text:        textResp.Text,
+		ContentHash: prepared.ContentHash,
+	}
+	if err := h.botRepo.AddDocument(doc); err != nil {
+		slog.Error("Failed to record document", "bot_id", botID, "file", textResp.FileName, "error", err)
+	} else if replace {
+		removed, err := h.botRepo.DeleteOtherVersions(botID, textResp.FileName, doc.ID)
+		if err != nil {
+			slog.Error("Failed to delete earlier document version records", "bot_id", botID, "file", textResp.FileName, "error", err)
+		}
+		prepared.Replaced = removed
+		slog.Info("Document version replaced earlier versions", "bot_id", botID, "file", textResp.FileName, "version", version, "replaced", removed)
+	}
 
-// SearchDocuments handles document search requests
-func (h *Handler) SearchDocuments(c *fiber.Ctx) error {
-	var req models.SearchRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request body"})
+	event := fiber.Map{
+		"document_id":  doc.ID,
+		"file_name":    textResp.FileName,
+		"file_type":    textResp.FileType,
+		"file_size":    prepared.Size,
+		"chunks":       len(chunks),
+		"version":      version,
+		"pages_parsed": textResp.PagesParsed,
+		"pages_total":  textResp.PagesTotal,
+	}
+	if prepared.SourceURL != "" {
+		event["source_url"] = prepared.SourceURL
 	}
+	h.webhooks.Emit(botID, webhooks.EventDocumentIndexed, event)
 
-	// Для совместимости: просто возвращаем 501 Not Implemented
-	return c.Status(fiber.StatusNotImplemented).JSON(fiber.Map{"error": "SearchDocuments endpoint is not implemented. Use RAGChat instead."})
+	return doc, nil
 }
 
-// RAGChat handles RAG-based chat requests with streaming
-func (h *Handler) RAGChat(c *fiber.Ctx) error {
-	var req models.RAGChatRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request body"})
+// PreviewDocumentChunks parses and chunks an uploaded file like UploadDocumentForBot would,
+// without embedding or indexing it, so owners can tune chunk settings (owner only)
+func (h *Handler) PreviewDocumentChunks(c *fiber.Ctx) error {
+	bot, err := h.ownedBot(c)
+	if err != nil {
+		return err
 	}
 
-	// Validate and sanitize inputs
-	req.ClientID = utils.SanitizeInput(req.ClientID)
-	req.Query = utils.SanitizeInput(req.Query)
-	req.SystemPrompt = utils.SanitizeInput(req.SystemPrompt)
+	doc, err := h.prepareDocument(c, bot)
+	if err != nil {
+		return err
+	}
 
-	if err := utils.ValidateClientID(req.ClientID); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	chunks := make([]fiber.Map, len(doc.Chunks))
+	totalChars := 0
+	for i, chunk := range doc.Chunks {
+		chars := utf8.RuneCountInString(chunk)
+		totalChars += chars
+		chunks[i] = fiber.Map{"index": i, "chars": chars, "text": chunk}
 	}
-	if err := utils.ValidateQuery(req.Query); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+
+	resp := fiber.Map{
+		"file_name":      doc.Parsed.FileName,
+		"file_type":      doc.Parsed.FileType,
+		"file_size":      doc.Size,
+		"chunk_size":     doc.ChunkSize,
+		"chunk_overlap":  doc.ChunkOverlap,
+		"splitter":       doc.Splitter,
+		"count":          len(doc.Chunks),
+		"total_chars":    totalChars,
+		"avg_chunk_size": doc.AvgChunkLen,
+		"chunks":         chunks,
+	}
+	if len(doc.Redactions) > 0 {
+		resp["redactions"] = doc.Redactions
 	}
+	return c.JSON(withTruncationInfo(withExtractionInfo(resp, doc.Parsed), doc))
+}
 
-	// Set defaults and validate parameters
-	req.SetDefaults(h.cfg.RAG.MaxResults, h.cfg.Generation)
+// UploadDocumentForBot handles document upload for a specific bot (requires auth and ownership)
+func (h *Handler) UploadDocumentForBot(c *fiber.Ctx) error {
+	botID := normalizeBotID(c.Params("id"))
+	slog.Debug("Document upload", "bot_id", botID)
 
-	// Additional validation
-	if req.Limit > 100 {
-		req.Limit = 100
+	if botID == "" {
+		return apierror.Send(c, fiber.StatusBadRequest, apierror.CodeBadRequest, "bot_id is required")
 	}
-	if req.Temperature > 2 {
-		req.Temperature = 2
+
+	userID, ok := auth.GetUserID(c)
+	if !ok {
+		return apierror.Send(c, fiber.StatusUnauthorized, apierror.CodeUnauthorized, "unauthorized")
 	}
-	if req.TopP > 1 {
-		req.TopP = 1
+	isOwner, err := h.botRepo.CheckOwnership(botID, userID)
+	if err != nil || !isOwner {
+		return apierror.Send(c, fiber.StatusForbidden, apierror.CodeForbidden, "you don't have permission to upload documents to this bot")
 	}
-	if req.TopK > 200 {
-		req.TopK = 200
+	bot, err := h.botRepo.GetByID(botID)
+	if err != nil {
+		return apierror.Send(c, fiber.StatusNotFound, apierror.CodeBotNotFound, "bot not found")
 	}
-	if req.MaxNewTokens > 8192 {
-		req.MaxNewTokens = 8192
+
+	// An identical file is answered with the document already indexed from it, before any parsing
+	hash, err := uploadHash(c)
+	if err != nil {
+		return err
 	}
-	if len(req.SystemPrompt) > 2000 {
-		req.SystemPrompt = req.SystemPrompt[:2000]
+	if h.cfg.Upload.DuplicateAction == config.DuplicateSkip {
+		existing, err := h.botRepo.FindDocumentByHash(botID, hash)
+		if err != nil {
+			return apierror.Send(c, fiber.StatusInternalServerError, apierror.CodeInternal, "failed to check for duplicate documents")
+		}
+		if existing != nil {
+			slog.Info("Skipped duplicate upload", "bot_id", botID, "document_id", existing.ID, "file", existing.Filename)
+			return c.JSON(fiber.Map{
+				"success":          true,
+				"bot_id":           botID,
+				"already_uploaded": true,
+				"message":          fmt.Sprintf("this file is already uploaded as %q (version %d); it was not indexed again", existing.Filename, existing.Version),
+				"document_id":      existing.ID,
+				"file_name":        existing.Filename,
+				"version":          existing.Version,
+				"chunks":           existing.ChunksCount,
+			})
+		}
 	}
 
-	// Create context with timeout for async operations
-	ctx, cancel := context.WithTimeout(context.Background(), 45*time.Second)
-	defer cancel()
+	prepared, err := h.prepareDocument(c, bot)
+	if err != nil {
+		return err
+	}
+	prepared.KeepVersions = c.FormValue("keep_versions") == "true"
+	prepared.ContentHash = hash
+	if err := h.checkQuota(userID, len(prepared.Chunks), prepared.Size); err != nil {
+		return err
+	}
+	doc, err := h.indexDocument(c.UserContext(), bot, prepared)
+	if err != nil {
+		return err
+	}
+	textResp, chunks := prepared.Parsed, prepared.Chunks
+	h.audit.Record(userID, audit.ActionDocumentUpload, audit.TargetBot, botID, c.IP(), map[string]any{
+		"document_id": doc.ID,
+		"file_name":   textResp.FileName,
+		"file_size":   prepared.Size,
+		"version":     doc.Version,
+	})
 
-	// Execute embedding creation
-	var embedding [][]float32
-	g, gctx := errgroup.WithContext(ctx)
+	return c.JSON(withTruncationInfo(withExtractionInfo(fiber.Map{
+		"success":           true,
+		"bot_id":            botID,
+		"chunks":            len(chunks),
+		"file_name":         textResp.FileName,
+		"version":           doc.Version,
+		"replaced_versions": prepared.Replaced,
+		"chunking": fiber.Map{
+			"splitter":       prepared.Splitter,
+			"chunk_size":     prepared.ChunkSize,
+			"chunk_overlap":  prepared.ChunkOverlap,
+			"total_chars":    prepared.TotalChars,
+			"avg_chunk_size": prepared.AvgChunkLen,
+		},
+	}, textResp), prepared))
+}
 
-	g.Go(func() error {
-		select {
-		case <-gctx.Done():
-			return gctx.Err()
-		default:
-		}
+// emptyDocumentMessage explains why nothing was extracted, mentioning image-only pages when known
+func emptyDocumentMessage(textResp *models.ParseResponse) string {
+	if textResp.PagesTotal > 0 {
+		return fmt.Sprintf("no text extracted from document (none of %d pages had extractable text, scanned pages are not supported)", textResp.PagesExamined())
+	}
+	return "no text extracted from document"
+}
 
-		emb, err := h.client.CreateQueryEmbeddings(h.cfg.Services.AIURL, []string{req.Query})
-		if err != nil || len(emb) == 0 {
-			return fmt.Errorf("failed to create query embedding: %w", err)
+// withExtractionInfo adds page statistics, the detected language, the damaged-file report
+// and a partial-extraction warning to an upload response
+func withExtractionInfo(resp fiber.Map, textResp *models.ParseResponse) fiber.Map {
+	if textResp.PagesTotal > 0 {
+		resp["pages_parsed"] = textResp.PagesParsed
+		resp["pages_total"] = textResp.PagesTotal
+		if textResp.PagesTruncated > 0 {
+			resp["pages_truncated"] = textResp.PagesTruncated
 		}
-		embedding = emb
-		return nil
-	})
-
-	if err := g.Wait(); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
 	}
-
-	// Search for relevant documents; fallback to full list if empty
-	searchResults, err := h.client.SearchVectorDocuments(h.cfg.Services.VectorURL, req.ClientID, embedding[0], req.Limit)
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("search error: %v", err)})
+	if textResp.Language != "" {
+		resp["language"] = textResp.Language
 	}
-	if len(searchResults) == 0 {
-		fallback, listErr := h.client.ListVectorDocuments(h.cfg.Services.VectorURL, req.ClientID, 500)
-		if listErr == nil {
-			searchResults = fallback
-		}
+	if textResp.Recovery != nil {
+		resp["recovery"] = textResp.Recovery
+	}
+	if warning := textResp.ExtractionWarning(); warning != "" {
+		resp["warning"] = warning
 	}
+	return resp
+}
 
-	// Extract and build context
-	snippetWindow := h.cfg.RAG.MaxDocChars / 2
-	if snippetWindow < 800 {
-		snippetWindow = 800
+// SearchDocuments handles document search requests
+func (h *Handler) SearchDocuments(c *fiber.Ctx) error {
+	var req models.SearchRequest
+	if err := validation.ParseBody(c, &req); err != nil {
+		return err
 	}
-	docs := utils.ExtractRelevantTexts(searchResults, req.Query, h.cfg.RAG.MaxDocChars, snippetWindow)
-	contextStr := clampContext(utils.BuildContext(docs), h.cfg.RAG.MaxContextChars)
 
-	// Setup SSE headers
-	c.Set("Content-Type", "text/event-stream")
-	c.Set("Cache-Control", "no-cache")
-	c.Set("Connection", "keep-alive")
-	c.Set("Access-Control-Allow-Origin", "*")
-	c.Set("X-Accel-Buffering", "no") // Disable nginx buffering
+	// Для совместимости: просто возвращаем 501 Not Implemented
+	return apierror.Send(c, fiber.StatusNotImplemented, apierror.CodeNotImplemented, "SearchDocuments endpoint is not implemented. Use RAGChat instead.")
+}
 
-	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
-		// Send documents info first
-		docsJSON, _ := json.Marshal(map[string]interface{}{
-			"documents": docs,
-		})
-		fmt.Fprintf(w, "data: %s\n\n", docsJSON)
-		w.Flush()
+// RAGChat handles RAG-based chat requests with streaming
+func (h *Handler) RAGChat(c *fiber.Ctx) error {
+	var req models.RAGChatRequest
+	if err := c.BodyParser(&req); err != nil {
+		return apierror.Send(c, fiber.StatusBadRequest, apierror.CodeInvalidBody, "invalid request body")
+	}
 
-		// Prepare generation request
-		systemPromptWithContext := fmt.Sprintf("%s\n\nContext:\n%s", req.SystemPrompt, contextStr)
-		genReq := models.GenerateRequest{
-			Messages:     []map[string]string{{"role": "user", "content": req.Query}},
-			MaxNewTokens: req.MaxNewTokens,
-			Temperature:  req.Temperature,
-			TopP:         req.TopP,
-			TopK:         req.TopK,
-			DoSample:     req.DoSample,
-			SystemPrompt: systemPromptWithContext,
-		}
+	// Sanitize and validate inputs
+	req.ClientID = utils.SanitizeInput(req.ClientID)
+	req.Query = utils.SanitizeInput(req.Query)
+	req.SystemPrompt = utils.SanitizeInput(req.SystemPrompt)
 
-		// Call streaming generation
-		resp, err := h.client.StreamGeneration(h.cfg.Services.AIURL, genReq)
-		if err != nil {
-			errJSON, _ := json.Marshal(map[string]string{"error": err.Error()})
-			fmt.Fprintf(w, "data: %s\n\n", errJSON)
-			w.Flush()
-			return
-		}
-		defer resp.Body.Close()
+	if err := validation.Struct(&req); err != nil {
+		return err
+	}
+	if err := utils.ValidateClientID(req.ClientID); err != nil {
+		return apierror.Send(c, fiber.StatusBadRequest, apierror.CodeValidationFailed, err.Error())
+	}
+	if err := checkUploadRange(&req); err != nil {
+		return err
+	}
 
-		// Stream response
-		scanner := bufio.NewScanner(resp.Body)
-		for scanner.Scan() {
-			line := scanner.Text()
-			if strings.HasPrefix(line, "data: ") {
-				fmt.Fprintf(w, "%s\n\n", line)
-				w.Flush()
-			}
+	// A client_id that is a bot id reads that bot's documents, so private bots are only open to their owner.
+	// Other client_ids are collections of the legacy upload API, which have no bot to check.
+	if _, err := uuid.Parse(normalizeBotID(req.ClientID)); err == nil {
+		req.ClientID = normalizeBotID(req.ClientID)
+		bot, err := h.botRepo.GetByID(req.ClientID)
+		if err != nil || !canAccessBot(c, bot) {
+			return apierror.Send(c, fiber.StatusNotFound, apierror.CodeBotNotFound, "bot not found")
 		}
+	}
 
-		// Send completion marker
-		fmt.Fprintf(w, "data: [DONE]\n\n")
-		w.Flush()
-	})
+	req.SetDefaults(h.cfg.RAG.MaxResults, h.cfg.Generation)
 
-	return nil
+	// Legacy chat searches the client_id collection with the default embedding setup and answers from
+	// keyword snippets of the candidates, without reranking; the timeout bounds every service call
+	ctx, cancel := context.WithTimeout(c.UserContext(), 45*time.Second)
+	defer cancel()
+	pipeline := &RAGPipeline{
+		h:             h,
+		Collection:    req.ClientID,
+		SearchLimit:   req.Limit,
+		Fallback:      true,
+		ContextTokens: h.cfg.RAG.ModelContextTokens,
+	}
+	result, err := pipeline.Retrieve(ctx, &req, nil)
+	if err != nil {
+		return err
+	}
+
+	return h.streamRAGResponse(c, req, result.Docs, result.Sources, result.Context, "")
 }
 
 // PublicRAGChat handles public chat requests using ADVANCED SEARCH (90%+ accuracy)
@@ -410,25 +825,165 @@ func (h *Handler) PublicRAGChat(c *fiber.Ctx) error {
 	botID := normalizeBotID(c.Params("bot_id"))
 	var req models.RAGChatRequest
 	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request body"})
+		return apierror.Send(c, fiber.StatusBadRequest, apierror.CodeInvalidBody, "invalid request body")
 	}
 
 	// Поддержка передачи query/message через body
 	if req.Query == "" && req.Message != "" {
 		req.Query = req.Message
 	}
-	if req.Query == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "query is required"})
+	// Public chat has no client_id: the bot id from the path identifies the collection
+	if err := validation.Struct(&req, "ClientID"); err != nil {
+		return err
+	}
+	if err := checkUploadRange(&req); err != nil {
+		return err
+	}
+
+	bot, err := h.botRepo.GetByID(botID)
+	if err != nil || !canAccessBot(c, bot) {
+		return apierror.Send(c, fiber.StatusNotFound, apierror.CodeBotNotFound, "bot not found")
+	}
+	if !bot.Config.AllowsOrigin(c.Get(fiber.HeaderOrigin)) {
+		return apierror.Send(c, fiber.StatusForbidden, apierror.CodeForbidden, "this bot can't be used on this site")
+	}
+	if err := checkGuardrails(&req, bot); err != nil {
+		return err
+	}
+	if err := filterQuery(&req, bot); err != nil {
+		return err
 	}
 
 	// Подставляем bot_id
 	req.ClientID = botID
 	req.SetDefaults(h.cfg.RAG.MaxResults, h.cfg.Generation)
+	req.PromptVars = botPromptVars(bot, utils.PreferredLocale(c.Get(fiber.HeaderAcceptLanguage)))
+
+	// Repeated questions are answered from the cache without retrieval or generation
+	cacheKey := ""
+	if h.answers != nil && !bypassAnswerCache(c) {
+		cacheKey = answerCacheKey(req, bot)
+		if cached, ok := h.answers.Get(bot.ID, cacheKey); ok {
+			c.Set("X-Answer-Cache", "HIT")
+			return h.streamStoredAnswer(c, req, cached.Answer, cached.Docs, cached.Sources, fiber.Map{"cached": true})
+		}
+		c.Set("X-Answer-Cache", "MISS")
+	}
+
+	result, err := h.botPipeline(bot).Retrieve(c.UserContext(), &req, nil)
+	if err != nil {
+		return err
+	}
+	if fallback, ok := applyFallback(&req, bot, result.Context); ok {
+		return h.streamStoredAnswer(c, req, fallback, []string{}, []string{}, fiber.Map{"fallback": true})
+	}
+
+	return h.streamRAGResponse(c, req, result.Docs, result.Sources, result.Context, cacheKey)
+}
+
+// checkUploadRange rejects an upload date range that doesn't end after it starts
+func checkUploadRange(req *models.RAGChatRequest) error {
+	if req.UploadedAfter != nil && req.UploadedBefore != nil && !req.UploadedAfter.Before(*req.UploadedBefore) {
+		return apierror.New(fiber.StatusBadRequest, apierror.CodeValidationFailed, "uploaded_after must be before uploaded_before")
+	}
+	return nil
+}
+
+// checkGuardrails rejects queries longer than the bot's MaxQueryChars or containing one of its blocked phrases
+func checkGuardrails(req *models.RAGChatRequest, bot *database.Bot) error {
+	if limit := bot.Config.MaxQueryChars; limit > 0 && utf8.RuneCountInString(req.Query) > limit {
+		return apierror.New(fiber.StatusBadRequest, apierror.CodeValidationFailed, fmt.Sprintf("query is too long (max %d characters for this bot)", limit))
+	}
+	if phrase := bot.Config.BlockedPhrase(req.Query); phrase != "" {
+		slog.Info("Query rejected by a blocked phrase", "bot_id", bot.ID, "phrase", phrase)
+		return apierror.New(fiber.StatusBadRequest, apierror.CodeQueryRejected, "this question can't be answered by this bot")
+	}
+	return nil
+}
+
+// filterQuery applies the bot's PII rules to the query: matches are redacted (and logged by count)
+// or, with the reject action, the query is refused
+func filterQuery(req *models.RAGChatRequest, bot *database.Bot) error {
+	pii := bot.Config.PII
+	if !pii.Enabled {
+		return nil
+	}
+	rules, err := utils.RedactionRules(pii.Rules, pii.CustomPatterns)
+	if err != nil {
+		slog.Warn("Invalid PII redaction rules", "bot_id", bot.ID, "error", err)
+		return apierror.New(fiber.StatusInternalServerError, apierror.CodeInternal, "invalid bot redaction rules")
+	}
+
+	redacted, counts := utils.Redact(req.Query, rules)
+	if len(counts) == 0 {
+		return nil
+	}
+	slog.Info("Query contains sensitive data", "bot_id", bot.ID, "redactions", utils.FormatRedactionCounts(counts))
+	if pii.QueryAction == database.PIIActionReject {
+		return apierror.New(fiber.StatusBadRequest, apierror.CodeSensitiveContent, "query contains sensitive data (emails, phone or card numbers); please remove it and try again")
+	}
+	req.Query = redacted
+	return nil
+}
+
+// applyFallback handles a retrieved context too thin to answer from (empty or below the bot's MinContextChars).
+// It returns the bot's fallback answer to send without calling the model, or, when none is configured,
+// instructs the model to say it doesn't know.
+func applyFallback(req *models.RAGChatRequest, bot *database.Bot, contextStr string) (string, bool) {
+	size := len(strings.TrimSpace(contextStr))
+	if size > 0 && size >= bot.Config.MinContextChars {
+		return "", false
+	}
+
+	slog.Info("Insufficient RAG context", "bot_id", bot.ID, "context_chars", size)
+	if bot.Config.FallbackAnswer != "" {
+		return bot.Config.FallbackAnswer, true
+	}
+	req.SystemPrompt += "\n\n" + utils.NoContextInstruction
+	return "", false
+}
+
+// streamStoredAnswer sends an answer that needs no generation (the bot's fallback answer or a cached one)
+// in the same SSE format as a generated answer. extra is added to the chat.completed event.
+func (h *Handler) streamStoredAnswer(c *fiber.Ctx, req models.RAGChatRequest, answer string, docs, sources []string, extra fiber.Map) error {
+	legacy, err := legacyStream(c)
+	if err != nil {
+		return err
+	}
+	if err := h.beginStream(); err != nil {
+		return err
+	}
+	h.setSSEHeaders(c)
 
-	// Валидация параметров
-	if req.Limit > 100 {
-		req.Limit = 100
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer h.streams.end()
+		stream := chatStream{w: w, legacy: legacy}
+		stream.sources(documentsEvent(req, docs, sources))
+		stream.token(answer)
+		if legacy {
+			// Legacy streams mirror the AI service, whose own done frame precedes "[DONE]"
+			fmt.Fprintf(w, "data: {\"type\":\"done\"}\n\n")
+		}
+		stream.done(answer)
+		w.Flush()
+	})
+
+	event := fiber.Map{
+		"query":     req.Query,
+		"answer":    answer,
+		"complete":  true,
+		"documents": len(docs),
+		"source":    "web",
+	}
+	for key, value := range extra {
+		event[key] = value
 	}
+	h.webhooks.Emit(req.ClientID, webhooks.EventChatCompleted, event)
+	return nil
+}
+
+// clampGenerationParams caps generation parameters at the limits the AI service accepts
+func clampGenerationParams(req *models.RAGChatRequest) {
 	if req.Temperature > 2 {
 		req.Temperature = 2
 	}
@@ -438,147 +993,308 @@ func (h *Handler) PublicRAGChat(c *fiber.Ctx) error {
 	if req.TopK > 200 {
 		req.TopK = 200
 	}
-	if req.MaxNewTokens > 8192 {
-		req.MaxNewTokens = 8192
+	if req.MaxNewTokens > models.MaxNewTokensLimit {
+		req.MaxNewTokens = models.MaxNewTokensLimit
 	}
 	if len(req.SystemPrompt) > 2000 {
 		req.SystemPrompt = req.SystemPrompt[:2000]
 	}
+}
 
-	log.Printf("🔍 [Advanced RAG] Bot: %s, Query: %s", botID, req.Query)
+// fallbackDocuments lists the first RAG_FALLBACK_LIMIT chunks of a collection for a search that found nothing.
+// The result goes through the same reranking and context clamping as search results; none with the fallback disabled.
+func (h *Handler) fallbackDocuments(ctx context.Context, clientID string) ([]models.Document, error) {
+	if h.cfg.RAG.FallbackLimit == 0 {
+		return nil, nil
+	}
+	docs, err := h.client.ListVectorDocuments(ctx, h.cfg.Services.VectorURL, clientID, h.cfg.RAG.FallbackLimit)
+	if err != nil {
+		return nil, err
+	}
+	return markFallback(docs), nil
+}
 
-	// ШАГ 1: Создаём embedding для запроса
-	embeddings, err := h.client.CreateQueryEmbeddings(h.cfg.Services.AIURL, []string{req.Query})
-	if err != nil || len(embeddings) == 0 {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "embedding error: " + err.Error()})
+// topScore returns the best similarity score of the retrieved chunks
+func topScore(docs []models.Document) float64 {
+	best := 0.0
+	for _, doc := range docs {
+		best = max(best, doc.Score)
 	}
+	return best
+}
 
-	// ШАГ 2: Векторный поиск (initial candidates) - МАКСИМАЛЬНЫЙ охват
-	searchLimit := h.cfg.RAG.MaxResults
-	if searchLimit <= 0 {
-		searchLimit = 60 // Увеличено до 60 для максимального покрытия
+// markFallback flags listed documents used in place of search results: they carry score 0
+// and FromFallback, like the vector service's own search fallback
+func markFallback(docs []models.Document) []models.Document {
+	for i := range docs {
+		docs[i].Score = 0
+		docs[i].FromFallback = true
 	}
-	log.Printf("🔍 [Advanced RAG] Requesting %d vector candidates", searchLimit)
+	return docs
+}
 
-	vectorResults, err := h.client.SearchVectorDocuments(h.cfg.Services.VectorURL, botID, embeddings[0], searchLimit)
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "vector search error: " + err.Error()})
+// buildSystemPrompt renders the system prompt template (see utils.RenderPrompt) with the citation instruction,
+// if requested. The retrieved context goes where the prompt has {{context}}, or is appended at the end.
+func buildSystemPrompt(req models.RAGChatRequest, contextStr string) string {
+	prompt := req.SystemPrompt
+	if req.Citations {
+		prompt += "\n\n" + utils.CitationInstruction
 	}
 
-	// Fallback если векторный поиск не дал результатов
-	if len(vectorResults) == 0 {
-		log.Printf("⚠️ [Advanced RAG] No vector results, using fallback")
-		fallback, listErr := h.client.ListVectorDocuments(h.cfg.Services.VectorURL, botID, 100)
-		if listErr == nil {
-			vectorResults = fallback
-		}
+	vars := map[string]string{utils.PromptVarDate: time.Now().UTC().Format("2006-01-02")}
+	for name, value := range req.PromptVars {
+		vars[name] = value
+	}
+	if utils.PromptUsesVariable(prompt, utils.PromptVarContext) {
+		vars[utils.PromptVarContext] = contextStr
+		return utils.RenderPrompt(prompt, vars)
 	}
+	return utils.RenderPrompt(prompt, vars) + "\n\nContext:\n" + contextStr
+}
 
-	log.Printf("📊 [Advanced RAG] Vector search: %d initial candidates", len(vectorResults))
+// botPromptVars returns the system prompt template variables describing a bot and the user's locale
+func botPromptVars(bot *database.Bot, locale string) map[string]string {
+	return map[string]string{
+		utils.PromptVarBotName: bot.Name,
+		utils.PromptVarLocale:  locale,
+	}
+}
 
-	// ШАГ 3: ADVANCED SEARCH - Query Expansion + Hybrid Search + Reranking
-	advancedResult, err := h.client.AdvancedSearch(
-		h.cfg.Services.AIURL,
-		botID,
-		req.Query,
-		vectorResults,
-		35, // top_k после reranking (увеличено до 35 для полноты контекста)
-		h.cfg.RAG.MaxContextChars,
-	)
-	if err != nil {
-		log.Printf("⚠️ [Advanced RAG] Advanced search failed: %v, using fallback", err)
-		// Fallback к простому подходу
-		docs := make([]string, 0, len(vectorResults))
-		for _, doc := range vectorResults {
-			if text, ok := doc["text"].(string); ok && text != "" {
-				docs = append(docs, text)
-				if len(docs) >= 10 {
-					break
-				}
-			}
+// documentsEvent builds the SSE payload describing the documents used for the answer.
+// "sources" holds the origin of each document, by index, when known.
+// With highlighting requested, "highlights" holds keyword matches for each document, by index.
+func documentsEvent(req models.RAGChatRequest, docs, sources []string) map[string]any {
+	event := map[string]any{"documents": docs}
+	if sources != nil {
+		event["sources"] = sources
+	}
+	if req.Citations {
+		// Ids match the "[n]" tags assigned by utils.BuildContext
+		ids := make([]int, len(docs))
+		for i := range docs {
+			ids[i] = i + 1
 		}
-		contextStr := clampContext(utils.BuildContext(docs), h.cfg.RAG.MaxContextChars)
-
-		// SSE stream с fallback контекстом
-		return h.streamRAGResponse(c, req, docs, contextStr)
+		event["ids"] = ids
 	}
-
-	// Извлекаем результаты
-	results, _ := advancedResult["results"].([]any)
-	compressedContext, _ := advancedResult["compressed_context"].(string)
-
-	// Конвертируем results в нужный формат
-	docs := make([]string, 0, len(results))
-	for _, r := range results {
-		if resMap, ok := r.(map[string]any); ok {
-			if text, ok := resMap["text"].(string); ok && text != "" {
-				docs = append(docs, text)
-			}
+	if req.Highlight {
+		highlights := make([][]utils.KeywordMatch, len(docs))
+		for i, d := range docs {
+			highlights[i] = utils.FindKeywordMatches(d, req.Query)
 		}
+		event["highlights"] = highlights
 	}
+	return event
+}
 
-	log.Printf("🎯 [Advanced RAG] Final: %d docs, context: %d chars", len(docs), len(compressedContext))
+// setSSEHeaders prepares the response for Server-Sent Events.
+// The request origin is echoed back only if it is in the configured CORS allow-list.
+func (h *Handler) setSSEHeaders(c *fiber.Ctx) {
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("X-Accel-Buffering", "no") // Disable nginx buffering
 
-	// Используем compressed context или fallback к простому
-	contextStr := compressedContext
-	if contextStr == "" || len(contextStr) < 100 {
-		contextStr = utils.BuildContext(docs)
+	if origin := c.Get("Origin"); origin != "" && h.cfg.CORS.AllowsOrigin(origin) {
+		c.Set("Access-Control-Allow-Origin", origin)
+		c.Vary("Origin")
 	}
-	contextStr = clampContext(contextStr, h.cfg.RAG.MaxContextChars)
-
-	log.Printf("📝 [Advanced RAG] Final context: %d chars", len(contextStr))
+}
 
-	return h.streamRAGResponse(c, req, docs, contextStr)
+// beginStream registers a chat stream about to be written; during shutdown it refuses new ones with 503
+func (h *Handler) beginStream() error {
+	if !h.streams.begin() {
+		return apierror.New(fiber.StatusServiceUnavailable, apierror.CodeUnavailable, "server is restarting, please retry shortly")
+	}
+	return nil
 }
 
-// streamRAGResponse handles SSE streaming for RAG responses
-func (h *Handler) streamRAGResponse(c *fiber.Ctx, req models.RAGChatRequest, docs []string, contextStr string) error {
-	c.Set("Content-Type", "text/event-stream")
-	c.Set("Cache-Control", "no-cache")
-	c.Set("Connection", "keep-alive")
-	c.Set("Access-Control-Allow-Origin", "*")
-	c.Set("X-Accel-Buffering", "no")
+// streamRAGResponse handles SSE streaming for RAG responses.
+// If the client disconnects (a write fails), the upstream generation request is cancelled right away so
+// the model stops working on an answer nobody reads. On shutdown the stream may finish within the grace
+// period of DrainStreams; past it, generation is cancelled and the stream ends with a "server restarting" error.
+// A complete answer is stored in the answer cache under cacheKey, unless it is empty.
+// Frames are written in the format selected by stream_format (see chatStream) and flushed as
+// STREAM_FLUSH_INTERVAL/STREAM_FLUSH_BYTES allow (see frameFlusher).
+func (h *Handler) streamRAGResponse(c *fiber.Ctx, req models.RAGChatRequest, docs, sources []string, contextStr, cacheKey string) error {
+	legacy, err := legacyStream(c)
+	if err != nil {
+		return err
+	}
+	if err := h.beginStream(); err != nil {
+		return err
+	}
+	h.setSSEHeaders(c)
+
+	// Captured before returning: the fiber.Ctx must not be used inside the stream writer
+	serverDone := c.Context().Done()
 
 	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
-		// Отправляем документы
-		docsJSON, _ := json.Marshal(map[string]interface{}{"documents": docs})
-		fmt.Fprintf(w, "data: %s\n\n", docsJSON)
-		w.Flush()
+		defer h.streams.end()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		// Cancelled when the drain grace runs out, or when the server stops without draining
+		go func() {
+			select {
+			case <-h.streams.stopping():
+				cancel()
+			case <-serverDone:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
 
-		// Формируем system prompt с контекстом
-		systemPromptWithContext := req.SystemPrompt + "\n\nContext:\n" + contextStr
-
-		genReq := models.GenerateRequest{
-			Messages:     []map[string]string{{"role": "user", "content": req.Query}},
-			MaxNewTokens: req.MaxNewTokens,
-			Temperature:  req.Temperature,
-			TopP:         req.TopP,
-			TopK:         req.TopK,
-			DoSample:     req.DoSample,
-			SystemPrompt: systemPromptWithContext,
+		// Отправляем документы
+		stream := chatStream{w: w, legacy: legacy, filter: h.newArtifactFilter()}
+		stream.sources(documentsEvent(req, docs, sources))
+		if err := w.Flush(); err != nil {
+			slog.Info("Chat client disconnected before generation", "bot_id", req.ClientID, "error", err)
+			return
 		}
 
-		resp, err := h.client.StreamGeneration(h.cfg.Services.AIURL, genReq)
+		resp, err := h.client.StreamGeneration(ctx, h.cfg.Services.AIURL, buildGenerateRequest(req, contextStr))
 		if err != nil {
-			errJSON, _ := json.Marshal(map[string]string{"error": err.Error()})
-			fmt.Fprintf(w, "data: %s\n\n", errJSON)
+			shutdown := ctx.Err() != nil
+			if shutdown {
+				stream.error(serverRestartingMessage)
+			} else {
+				stream.error(err.Error())
+			}
+			if !legacy || shutdown {
+				stream.done("")
+			}
 			w.Flush()
 			return
 		}
 		defer resp.Body.Close()
 
-		scanner := bufio.NewScanner(resp.Body)
-		for scanner.Scan() {
-			line := scanner.Text()
-			if strings.HasPrefix(line, "data: ") {
-				fmt.Fprintf(w, "%s\n\n", line)
-				w.Flush()
+		// The answer is collected from token frames for the done frame, the chat.completed webhook and the answer cache
+		var answer strings.Builder
+		failed := false
+		scanner := newFrameScanner(resp.Body)
+		// Lines are read in their own goroutine so a coalesced flush can fire while waiting for the next token
+		lines := make(chan string)
+		go func() {
+			defer close(lines)
+			for scanner.Scan() {
+				select {
+				case lines <- scanner.Text():
+				case <-ctx.Done():
+					return
+				}
 			}
+		}()
+		flusher := &frameFlusher{w: w, interval: h.cfg.Stream.FlushInterval, maxBytes: h.cfg.Stream.FlushBytes}
+	relay:
+		for {
+			var err error
+			select {
+			case line, ok := <-lines:
+				if !ok {
+					break relay
+				}
+				if !strings.HasPrefix(line, "data: ") {
+					continue
+				}
+				frame, _ := parseStreamFrame(line)
+				if frame.Type == frameError {
+					failed = true
+				}
+				answer.WriteString(stream.relay(line, frame))
+				err = flusher.wrote()
+			case <-flusher.due():
+				err = flusher.flush()
+			}
+			if err != nil {
+				// Deferred cancel and Body.Close abort the upstream request
+				slog.Info("Chat client disconnected, aborting generation", "bot_id", req.ClientID, "error", err)
+				return
+			}
+		}
+		if ctx.Err() != nil {
+			// Shutdown: end the stream properly, with what was generated so far, so the client knows to retry
+			slog.Info("Generation aborted by shutdown", "bot_id", req.ClientID)
+			stream.error(serverRestartingMessage)
+			failed = true
+		} else if err := scanner.Err(); err != nil {
+			slog.Error("Reading generation stream failed", "bot_id", req.ClientID, "error", err)
+			stream.error("generation stream interrupted")
+			failed = true
 		}
 
-		fmt.Fprintf(w, "data: [DONE]\n\n")
-		w.Flush()
+		answer.WriteString(stream.flush())
+		text := strings.TrimSpace(answer.String())
+		stream.done(text)
+		flusher.flush()
+
+		if cacheKey != "" && !failed && text != "" {
+			h.answers.Set(req.ClientID, cacheKey, answercache.Entry{Answer: text, Docs: docs, Sources: sources})
+		}
+		h.webhooks.Emit(req.ClientID, webhooks.EventChatCompleted, fiber.Map{
+			"query":     req.Query,
+			"answer":    text,
+			"complete":  !failed,
+			"documents": len(docs),
+			"source":    "web",
+		})
 	})
 
 	return nil
 }
+
+// buildGenerateRequest builds the AI service request with the retrieved context in the system prompt
+func buildGenerateRequest(req models.RAGChatRequest, contextStr string) models.GenerateRequest {
+	return models.GenerateRequest{
+		Messages:     []map[string]string{{"role": "user", "content": req.Query}},
+		MaxNewTokens: req.MaxNewTokens,
+		Temperature:  req.Temperature,
+		TopP:         req.TopP,
+		TopK:         req.TopK,
+		DoSample:     req.DoSample,
+		SystemPrompt: buildSystemPrompt(req, contextStr),
+	}
+}
+
+// generateAnswer runs generation to completion and returns the full answer (non-streaming callers)
+func (h *Handler) generateAnswer(ctx context.Context, req models.RAGChatRequest, contextStr string) (string, error) {
+	resp, err := h.client.StreamGeneration(ctx, h.cfg.Services.AIURL, buildGenerateRequest(req, contextStr))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var answer strings.Builder
+	filter := h.newArtifactFilter()
+	scanner := newFrameScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+		frame, _ := parseStreamFrame(line)
+		switch frame.Type {
+		case frameToken:
+			if filter != nil {
+				frame.Token = filter.Push(frame.Token)
+			}
+			answer.WriteString(frame.Token)
+		case frameError:
+			return "", fmt.Errorf("generation error: %s", frame.Error)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("read generation stream: %w", err)
+	}
+	if filter != nil {
+		answer.WriteString(filter.Flush())
+	}
+
+	return strings.TrimSpace(answer.String()), nil
+}
+
+// newArtifactFilter returns the post-processing filter for one generated answer, or nil when it is disabled
+func (h *Handler) newArtifactFilter() *utils.ArtifactFilter {
+	if !h.cfg.RAG.FilterArtifacts {
+		return nil
+	}
+	return utils.NewArtifactFilter(h.cfg.RAG.ArtifactTokens)
+}
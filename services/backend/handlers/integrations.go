@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"backend/apierror"
+	"backend/auth"
+	"backend/database"
+	"backend/models"
+	"backend/utils"
+	"backend/webhooks"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// requireIntegrations fails when no encryption key is configured for messenger credentials
+func (h *Handler) requireIntegrations() error {
+	if h.secrets == nil || h.integrationRepo == nil {
+		return apierror.New(fiber.StatusServiceUnavailable, apierror.CodeUnavailable, "integrations are disabled: INTEGRATIONS_ENCRYPTION_KEY is not set")
+	}
+	return nil
+}
+
+// ownedBot loads the bot from the :id path param and checks that the current user owns it
+func (h *Handler) ownedBot(c *fiber.Ctx) (*database.Bot, error) {
+	return h.ownedBotByID(c, c.Params("id"))
+}
+
+// ownedBotByID loads bot id and checks that the current user owns it; other owners' bots are reported as not found
+func (h *Handler) ownedBotByID(c *fiber.Ctx, id string) (*database.Bot, error) {
+	userID, ok := auth.GetUserID(c)
+	if !ok {
+		return nil, apierror.New(fiber.StatusUnauthorized, apierror.CodeUnauthorized, "unauthorized")
+	}
+	bot, err := h.botRepo.GetByID(normalizeBotID(id))
+	if err != nil || bot.OwnerID != userID {
+		return nil, apierror.New(fiber.StatusNotFound, apierror.CodeBotNotFound, "bot not found")
+	}
+	return bot, nil
+}
+
+// saveIntegration encrypts credentials and stores them as the bot's integration for provider
+func (h *Handler) saveIntegration(botID, provider string, credentials any) error {
+	plain, err := json.Marshal(credentials)
+	if err != nil {
+		return fmt.Errorf("encode credentials: %w", err)
+	}
+	sealed, err := h.secrets.Encrypt(string(plain))
+	if err != nil {
+		return err
+	}
+	return h.integrationRepo.Upsert(&database.BotIntegration{
+		BotID:       botID,
+		Provider:    provider,
+		Credentials: sealed,
+		IsActive:    true,
+	})
+}
+
+// loadIntegration decrypts the stored credentials of the bot's integration into dst
+func (h *Handler) loadIntegration(botID, provider string, dst any) error {
+	integration, err := h.integrationRepo.Get(botID, provider)
+	if err != nil {
+		return err
+	}
+	plain, err := h.secrets.Decrypt(integration.Credentials)
+	if err != nil {
+		return fmt.Errorf("decrypt %s credentials: %w", provider, err)
+	}
+	return json.Unmarshal([]byte(plain), dst)
+}
+
+// answerForBot runs the public RAG pipeline for a messenger message and returns the full answer.
+// Generation uses the bot's own settings; source is reported in the chat.completed webhook.
+func (h *Handler) answerForBot(ctx context.Context, bot *database.Bot, query, source string) (string, error) {
+	result, err := h.runBotPipeline(ctx, bot, query, nil)
+	if err != nil {
+		return "", err
+	}
+
+	log.Printf("[answerForBot] Bot %s answered a %s message (%d docs, %d chars)", bot.ID, source, result.Docs, len(result.Answer))
+	h.webhooks.Emit(bot.ID, webhooks.EventChatCompleted, fiber.Map{
+		"query":     result.Query,
+		"answer":    result.Answer,
+		"complete":  true,
+		"documents": result.Docs,
+		"source":    source,
+		"fallback":  result.Fallback,
+	})
+	return result.Answer, nil
+}
+
+// botAnswer is the outcome of runBotPipeline
+type botAnswer struct {
+	Query    string // The query as the pipeline saw it: sanitized, PII redacted
+	Answer   string
+	Docs     int  // Documents in the context
+	Fallback bool // The bot's fallback answer was used instead of generation
+}
+
+// runBotPipeline answers a query like the public chat does (guardrails, retrieval, generation with the
+// bot's own settings) without streaming. A non-nil trace records the retrieval stages.
+func (h *Handler) runBotPipeline(ctx context.Context, bot *database.Bot, query string, trace *retrievalTrace) (*botAnswer, error) {
+	query = utils.SanitizeInput(query)
+	if err := utils.ValidateQuery(query); err != nil {
+		return nil, err
+	}
+
+	req := models.RAGChatRequest{
+		ClientID:     bot.ID,
+		Query:        query,
+		Temperature:  bot.Temperature,
+		TopP:         bot.TopP,
+		TopK:         bot.TopK,
+		MaxNewTokens: bot.MaxNewTokens,
+		DoSample:     bot.DoSample,
+		SystemPrompt: bot.SystemPrompt,
+		PromptVars:   botPromptVars(bot, ""),
+	}
+	req.SetDefaults(h.cfg.RAG.MaxResults, h.cfg.Generation)
+	if err := checkGuardrails(&req, bot); err != nil {
+		return nil, err
+	}
+	if err := filterQuery(&req, bot); err != nil {
+		return nil, err
+	}
+
+	result, err := h.botPipeline(bot).Retrieve(ctx, &req, trace)
+	if err != nil {
+		return nil, err
+	}
+	answer, fallback := applyFallback(&req, bot, result.Context)
+	if !fallback {
+		answer, err = h.generateAnswer(ctx, req, result.Context)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &botAnswer{Query: req.Query, Answer: answer, Docs: len(result.Docs), Fallback: fallback}, nil
+}
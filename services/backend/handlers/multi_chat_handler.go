@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"backend/apierror"
+	"backend/auth"
+	"backend/database"
+	"backend/models"
+	"backend/utils"
+	"backend/validation"
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/sync/errgroup"
+)
+
+// AllBots in bot_ids selects every active bot of the owner
+const AllBots = "all"
+
+// maxMultiChatBots bounds how many bots one multi-bot chat searches
+const maxMultiChatBots = 20
+
+// multiChatWorkers bounds how many bot collections are searched at once
+const multiChatWorkers = 8
+
+// rrfK is the reciprocal rank fusion constant: higher values flatten the advantage of the top ranks
+const rrfK = 60
+
+// MultiChatRequest is the body of POST /chat/multi: a chat request answered from several bots of the owner
+type MultiChatRequest struct {
+	models.RAGChatRequest
+	BotIDs []string `json:"bot_ids" validate:"required,min=1,max=20,dive,required,max=255"` // ["all"] = every bot of the owner
+}
+
+// multiChatSearch is the outcome of the vector search in one bot's collection
+type multiChatSearch struct {
+	bot     *database.Bot
+	results []models.Document
+}
+
+// MultiBotChat answers one query from several bots of the owner: their collections are searched concurrently,
+// the candidates merged and reranked together, and one answer generated from the combined context (owner only).
+// Each bot's guardrails and PII rules apply to the query; generation uses the request parameters and defaults.
+func (h *Handler) MultiBotChat(c *fiber.Ctx) error {
+	userID, ok := auth.GetUserID(c)
+	if !ok {
+		return apierror.Send(c, fiber.StatusUnauthorized, apierror.CodeUnauthorized, "unauthorized")
+	}
+
+	var req MultiChatRequest
+	if err := c.BodyParser(&req); err != nil {
+		return apierror.Send(c, fiber.StatusBadRequest, apierror.CodeInvalidBody, "invalid request body")
+	}
+	if req.Query == "" && req.Message != "" {
+		req.Query = req.Message
+	}
+	req.Query = utils.SanitizeInput(req.Query)
+	req.SystemPrompt = utils.SanitizeInput(req.SystemPrompt)
+	// The bots are the collections searched, so there is no client_id
+	if err := validation.Struct(&req, "RAGChatRequest.ClientID"); err != nil {
+		return err
+	}
+	if err := checkUploadRange(&req.RAGChatRequest); err != nil {
+		return err
+	}
+
+	bots, err := h.multiChatBots(userID, req.BotIDs)
+	if err != nil {
+		return err
+	}
+	chatReq := req.RAGChatRequest
+	for _, bot := range bots {
+		if err := checkGuardrails(&chatReq, bot); err != nil {
+			return err
+		}
+		if err := filterQuery(&chatReq, bot); err != nil {
+			return err
+		}
+	}
+	chatReq.ClientID = ""
+	chatReq.SetDefaults(h.cfg.RAG.MaxResults, h.cfg.Generation)
+	clampGenerationParams(&chatReq)
+
+	docs, sources, contextStr, err := h.retrieveMultiContext(c.UserContext(), &chatReq, bots)
+	if err != nil {
+		return err
+	}
+	return h.streamRAGResponse(c, chatReq, docs, sources, contextStr, "")
+}
+
+// multiChatBots resolves bot_ids to the owner's active bots. Bots of other owners are reported as not found,
+// like missing ones.
+func (h *Handler) multiChatBots(userID uint, botIDs []string) ([]*database.Bot, error) {
+	if len(botIDs) == 1 && botIDs[0] == AllBots {
+		bots, err := h.botRepo.GetByOwnerID(userID)
+		if err != nil {
+			return nil, apierror.New(fiber.StatusInternalServerError, apierror.CodeInternal, "failed to load bots")
+		}
+		if len(bots) == 0 {
+			return nil, apierror.New(fiber.StatusNotFound, apierror.CodeBotNotFound, "you have no bots to search")
+		}
+		if len(bots) > maxMultiChatBots {
+			return nil, apierror.New(fiber.StatusBadRequest, apierror.CodeValidationFailed,
+				fmt.Sprintf("you have %d bots; list up to %d of them in bot_ids", len(bots), maxMultiChatBots))
+		}
+		return bots, nil
+	}
+
+	bots := make([]*database.Bot, 0, len(botIDs))
+	seen := make(map[string]bool, len(botIDs))
+	for _, id := range botIDs {
+		id = normalizeBotID(id)
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		bot, err := h.botRepo.GetByID(id)
+		if err != nil || bot.OwnerID != userID {
+			return nil, apierror.New(fiber.StatusNotFound, apierror.CodeBotNotFound, fmt.Sprintf("bot %s not found", id))
+		}
+		bots = append(bots, bot)
+	}
+	return bots, nil
+}
+
+// retrieveMultiContext runs retrieval over several bots. The query is embedded once per embedding setup
+// (model and instruction prefix) and each collection searched with its bot's candidate limit.
+// Vector scores of different collections and models aren't comparable, so the result lists are merged by
+// reciprocal rank fusion; the cross-encoder then reranks the merged candidates against the query, which
+// scores them on one scale. A bot whose search fails is left out; the request fails only if all of them do.
+func (h *Handler) retrieveMultiContext(ctx context.Context, req *models.RAGChatRequest, bots []*database.Bot) ([]string, []string, string, error) {
+	slog.Debug("Multi-bot RAG retrieval", "bots", len(bots), "query", req.Query)
+
+	embeddings, err := h.multiChatEmbeddings(ctx, req.Query, bots)
+	if err != nil {
+		return nil, nil, "", apierror.New(fiber.StatusInternalServerError, apierror.CodeEmbeddingFailed, fmt.Sprintf("embedding error: %v", err))
+	}
+
+	searches := make([]multiChatSearch, len(bots))
+	var failed int
+	var mu sync.Mutex
+	var g errgroup.Group
+	g.SetLimit(multiChatWorkers)
+	maxCandidates, maxTopK := 0, 0
+	for i, bot := range bots {
+		limit, topK := h.rerankLimits(bot)
+		maxCandidates, maxTopK = max(maxCandidates, limit), max(maxTopK, topK)
+		g.Go(func() error {
+			results, err := h.client.SearchVectorDocuments(ctx, h.cfg.Services.VectorURL, bot.ID, bot.EmbeddingModel,
+				embeddings[embeddingSetup(bot)], limit, ragPayloadFields, req.UploadRange())
+			if err != nil {
+				slog.Warn("Multi-bot RAG vector search failed", "bot_id", bot.ID, "error", err)
+				mu.Lock()
+				failed++
+				mu.Unlock()
+				return nil
+			}
+			// Like the single-bot path, a weak best match means the bot has nothing on the topic
+			if minScore := bot.Config.MinTopScore; minScore > 0 && len(results) > 0 && topScore(results) < minScore {
+				results = nil
+			}
+			searches[i] = multiChatSearch{bot: bot, results: results}
+			return nil
+		})
+	}
+	g.Wait()
+	if failed == len(bots) {
+		return nil, nil, "", apierror.New(fiber.StatusInternalServerError, apierror.CodeVectorDBFailed, "vector search failed for every bot")
+	}
+
+	// The reranker gets as many candidates as the most demanding bot would send it alone
+	candidates := fuseRankings(searches)
+	if len(candidates) > maxCandidates {
+		candidates = candidates[:maxCandidates]
+	}
+	slog.Debug("Multi-bot RAG candidates merged", "candidates", len(candidates), "failed_bots", failed)
+	if len(candidates) == 0 {
+		req.SystemPrompt += "\n\n" + utils.NoContextInstruction
+		return []string{}, []string{}, "", nil
+	}
+
+	ranked := candidates
+	advancedResult, err := h.client.AdvancedSearch(ctx, h.cfg.Services.AIURL, "multi", req.Query, candidates, maxTopK, h.cfg.RAG.MaxContextChars)
+	if err != nil {
+		slog.Warn("Multi-bot RAG reranking failed, using the fused ranking", "error", err)
+		if len(ranked) > maxTopK {
+			ranked = ranked[:maxTopK]
+		}
+	} else {
+		ranked = advancedResult.Results
+	}
+
+	docs := make([]string, 0, len(ranked))
+	sources := make([]string, 0, len(ranked))
+	for _, doc := range ranked {
+		if doc.Text != "" {
+			docs = append(docs, doc.Text)
+			sources = append(sources, multiChatSource(doc))
+		}
+	}
+	// The AI service's prebuilt context doesn't name the bots, so the context is always built locally
+	docs, contextStr := h.fitContext(*req, docs, "", h.modelContextTokens(nil))
+	slog.Debug("Multi-bot RAG context ready", "documents", len(docs), "context_chars", len(contextStr))
+	return docs, sources[:len(docs)], contextStr, nil
+}
+
+// multiChatEmbeddings embeds the query once for every embedding setup among the bots, keyed by embeddingSetup
+func (h *Handler) multiChatEmbeddings(ctx context.Context, query string, bots []*database.Bot) (map[string][]float32, error) {
+	embeddings := make(map[string][]float32)
+	for _, bot := range bots {
+		setup := embeddingSetup(bot)
+		if _, ok := embeddings[setup]; ok {
+			continue
+		}
+		vectors, err := h.client.CreateQueryEmbeddings(ctx, h.cfg.Services.AIURL, bot.EmbeddingModel, bot.UsesInstructionPrefix, []string{query})
+		if err != nil {
+			return nil, err
+		}
+		if len(vectors) == 0 {
+			return nil, fmt.Errorf("no embedding returned")
+		}
+		embeddings[setup] = vectors[0]
+	}
+	return embeddings, nil
+}
+
+// embeddingSetup identifies the query embedding a bot's collection is searched with
+func embeddingSetup(bot *database.Bot) string {
+	prefix := "auto"
+	if bot.UsesInstructionPrefix != nil {
+		prefix = strconv.FormatBool(*bot.UsesInstructionPrefix)
+	}
+	return bot.EmbeddingModel + "\x00" + prefix
+}
+
+// fuseRankings merges the per-bot result lists by reciprocal rank fusion: a chunk scores 1/(rrfK+rank)
+// by its rank in its own bot's list, so every bot's best match ranks alike whatever its raw similarity.
+// Each chunk is tagged with its bot (metadata "bot_id", "bot_name") and keeps its vector score; its id gets
+// the bot id as a prefix, since the reranker drops candidates with repeated ids and collections may share point ids.
+func fuseRankings(searches []multiChatSearch) []models.Document {
+	type fusedDocument struct {
+		doc   models.Document
+		score float64
+	}
+	var merged []fusedDocument
+	for _, search := range searches {
+		ranked := append([]models.Document(nil), search.results...)
+		sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].Score > ranked[j].Score })
+		for rank, doc := range ranked {
+			doc.ID = search.bot.ID + ":" + doc.ID
+			if doc.Metadata == nil {
+				doc.Metadata = make(map[string]string, 2)
+			}
+			doc.Metadata["bot_id"] = search.bot.ID
+			doc.Metadata["bot_name"] = search.bot.Name
+			merged = append(merged, fusedDocument{doc: doc, score: 1 / float64(rrfK+rank+1)})
+		}
+	}
+	sort.SliceStable(merged, func(i, j int) bool { return merged[i].score > merged[j].score })
+	docs := make([]models.Document, len(merged))
+	for i, fused := range merged {
+		docs[i] = fused.doc
+	}
+	return docs
+}
+
+// multiChatSource names the bot a chunk came from along with its source
+func multiChatSource(doc models.Document) string {
+	botName := doc.Metadata["bot_name"]
+	if doc.Source != "" {
+		return botName + ": " + doc.Source
+	}
+	return botName
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"backend/apierror"
+	"backend/auth"
+	"backend/config"
+	"backend/database"
+	"backend/utils"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// userQuota returns the effective storage quota of a user: per-user overrides or the global defaults
+func (h *Handler) userQuota(userID uint) (config.QuotaConfig, error) {
+	user, err := h.userRepo.GetByID(userID)
+	if err != nil {
+		return h.cfg.Quota, err
+	}
+	return h.quotaOf(user), nil
+}
+
+// quotaOf applies the per-user overrides of a loaded user to the global quota
+func (h *Handler) quotaOf(user *database.User) config.QuotaConfig {
+	quota := h.cfg.Quota
+	if user.QuotaMaxChunks != nil {
+		quota.MaxChunks = *user.QuotaMaxChunks
+	}
+	if user.QuotaMaxBytes != nil {
+		quota.MaxBytes = *user.QuotaMaxBytes
+	}
+	return quota
+}
+
+// checkQuota fails with 402 QUOTA_EXCEEDED when adding chunks/bytes would exceed the user's storage quota
+func (h *Handler) checkQuota(userID uint, chunks int, bytes int64) error {
+	quota, err := h.userQuota(userID)
+	if err != nil {
+		return apierror.New(fiber.StatusInternalServerError, apierror.CodeInternal, "failed to get quota")
+	}
+	if quota.MaxChunks == 0 && quota.MaxBytes == 0 {
+		return nil
+	}
+
+	usage, err := h.botRepo.GetUsage(userID)
+	if err != nil {
+		return apierror.New(fiber.StatusInternalServerError, apierror.CodeInternal, "failed to get storage usage")
+	}
+
+	if quota.MaxChunks > 0 && usage.Chunks+int64(chunks) > int64(quota.MaxChunks) {
+		apiErr := apierror.New(fiber.StatusPaymentRequired, apierror.CodeQuotaExceeded,
+			fmt.Sprintf("chunk quota exceeded: %d of %d chunks used, this upload needs %d", usage.Chunks, quota.MaxChunks, chunks))
+		apiErr.Details = fiber.Map{"resource": "chunks", "limit": quota.MaxChunks, "used": usage.Chunks, "requested": chunks}
+		return apiErr
+	}
+	if quota.MaxBytes > 0 && usage.Bytes+bytes > quota.MaxBytes {
+		apiErr := apierror.New(fiber.StatusPaymentRequired, apierror.CodeQuotaExceeded,
+			fmt.Sprintf("storage quota exceeded: %s of %s used, this upload needs %s",
+				utils.FormatBytes(usage.Bytes), utils.FormatBytes(quota.MaxBytes), utils.FormatBytes(bytes)))
+		apiErr.Details = fiber.Map{"resource": "bytes", "limit": quota.MaxBytes, "used": usage.Bytes, "requested": bytes}
+		return apiErr
+	}
+	return nil
+}
+
+// GetQuota returns the storage quota and current usage of the current user (0 limits = unlimited)
+func (h *Handler) GetQuota(c *fiber.Ctx) error {
+	userID, ok := auth.GetUserID(c)
+	if !ok {
+		return apierror.Send(c, fiber.StatusUnauthorized, apierror.CodeUnauthorized, "unauthorized")
+	}
+
+	quota, err := h.userQuota(userID)
+	if err != nil {
+		return apierror.Send(c, fiber.StatusNotFound, apierror.CodeUserNotFound, "user not found")
+	}
+	usage, err := h.botRepo.GetUsage(userID)
+	if err != nil {
+		return apierror.Send(c, fiber.StatusInternalServerError, apierror.CodeInternal, "failed to get storage usage")
+	}
+
+	return c.JSON(fiber.Map{
+		"max_chunks": quota.MaxChunks,
+		"max_bytes":  quota.MaxBytes,
+		"usage":      usage,
+	})
+}
+
+// GetAccountSummary returns the current user with their bot and document counts and quota usage,
+// everything a dashboard needs in one call
+func (h *Handler) GetAccountSummary(c *fiber.Ctx) error {
+	userID, ok := auth.GetUserID(c)
+	if !ok {
+		return apierror.Send(c, fiber.StatusUnauthorized, apierror.CodeUnauthorized, "unauthorized")
+	}
+
+	user, err := h.userRepo.GetByID(userID)
+	if err != nil {
+		return apierror.Send(c, fiber.StatusNotFound, apierror.CodeUserNotFound, "user not found")
+	}
+	summary, err := h.botRepo.GetAccountSummary(userID)
+	if err != nil {
+		return apierror.Send(c, fiber.StatusInternalServerError, apierror.CodeInternal, "failed to get account summary")
+	}
+	quota := h.quotaOf(user)
+
+	return c.JSON(fiber.Map{
+		"user":      user,
+		"bots":      summary.Bots,
+		"documents": summary.Documents,
+		"chunks":    summary.Chunks,
+		"quota": fiber.Map{
+			"max_chunks": quota.MaxChunks,
+			"max_bytes":  quota.MaxBytes,
+			"usage":      database.StorageUsage{Chunks: summary.Chunks, Bytes: summary.Bytes},
+		},
+	})
+}
@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"backend/apierror"
+	"backend/clients"
+	"backend/database"
+	"backend/models"
+	"backend/utils"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RAGPipeline retrieves the context of a chat answer from one collection: optionally rewrite the query, embed it,
+// search it, list chunks if the search found nothing, then either rerank the candidates in the AI service or cut
+// keyword snippets out of them, and fit the result to the model window. Its fields select the stages,
+// so every chat path runs the same code with its own options.
+type RAGPipeline struct {
+	h *Handler
+
+	Collection        string  // Bot id of the collection searched
+	RewriteQuery      bool    // Spell-correct and expand the query with the AI service before embedding
+	EmbeddingModel    string  // "" = the AI service default
+	InstructionPrefix *bool   // nil = the AI service decides
+	SearchLimit       int     // Vector candidates
+	Fallback          bool    // List chunks when the search finds nothing (up to RAG_FALLBACK_LIMIT)
+	MinTopScore       float64 // A best candidate below it means nothing relevant was found; 0 = off
+	Rerank            bool    // Cross-encoder reranking in the AI service; otherwise keyword snippets
+	RerankTopK        int     // Documents kept after reranking
+	RerankFallback    int     // Top candidates used in vector order when reranking fails or keeps nothing; 0 = none
+	ContextTokens     int     // Model window the context is fitted to; <= 0 disables token budgeting
+}
+
+// RAGResult is the retrieved context of an answer
+type RAGResult struct {
+	Docs    []string // Documents of the context, in order
+	Sources []string // Source of each document, by index; nil when snippets don't map to chunks
+	Context string
+}
+
+// botPipeline returns the pipeline of a bot's chats: its embedding setup, candidate limits and score gate,
+// with reranking
+func (h *Handler) botPipeline(bot *database.Bot) *RAGPipeline {
+	searchLimit, rerankTopK := h.rerankLimits(bot)
+	return &RAGPipeline{
+		h:                 h,
+		Collection:        bot.ID,
+		EmbeddingModel:    bot.EmbeddingModel,
+		InstructionPrefix: bot.UsesInstructionPrefix,
+		SearchLimit:       searchLimit,
+		Fallback:          true,
+		MinTopScore:       bot.Config.MinTopScore,
+		RewriteQuery:      bot.Config.RewriteQuery,
+		Rerank:            true,
+		RerankTopK:        rerankTopK,
+		RerankFallback:    h.cfg.RAG.RerankFallbackDocs,
+		ContextTokens:     h.modelContextTokens(bot),
+	}
+}
+
+// Retrieve clamps the generation parameters of req (its prompt and answer size budget the context) and runs
+// the pipeline. A rewritten query is only searched with: the answer is generated for the query as asked.
+// An empty context means nothing relevant was found. Errors are *apierror.Error values ready to return
+// from a handler. A non-nil trace records each stage.
+func (p *RAGPipeline) Retrieve(ctx context.Context, req *models.RAGChatRequest, trace *retrievalTrace) (*RAGResult, error) {
+	clampGenerationParams(req)
+	slog.Debug("RAG retrieval", "bot_id", p.Collection, "query", req.Query)
+
+	query := req.Query
+	if p.RewriteQuery {
+		query = p.rewrite(ctx, query, trace)
+	}
+	embedding, err := p.embed(ctx, query, trace)
+	if err != nil {
+		return nil, err
+	}
+	candidates, err := p.search(ctx, req, embedding, trace)
+	if err != nil {
+		return nil, err
+	}
+
+	// Off-topic questions only match weakly: below min_top_score nothing relevant was found, and the empty
+	// context makes applyFallback answer instead of the model guessing from unrelated chunks.
+	// Listed fallback chunks score 0, so they never pass an enabled gate.
+	if p.MinTopScore > 0 && len(candidates) > 0 {
+		if best := topScore(candidates); best < p.MinTopScore {
+			slog.Info("RAG best score below min_top_score", "bot_id", p.Collection, "score", best, "min_top_score", p.MinTopScore)
+			trace.fallback(fmt.Sprintf("best vector score %.3f is below min_top_score %.3f; no context used", best, p.MinTopScore))
+			trace.context("none", 0, 0)
+			return &RAGResult{Docs: []string{}, Sources: []string{}}, nil
+		}
+	}
+
+	if !p.Rerank {
+		return p.snippetContext(req, query, candidates, trace), nil
+	}
+	return p.rerankedContext(ctx, req, query, candidates, trace), nil
+}
+
+// rewrite spell-corrects and expands the query with the AI service. Rewriting only helps retrieval,
+// so if it fails the original query is searched with.
+func (p *RAGPipeline) rewrite(ctx context.Context, query string, trace *retrievalTrace) string {
+	start := time.Now()
+	rewritten, err := p.h.client.RewriteQuery(ctx, p.h.cfg.Services.AIURL, query)
+	if err != nil {
+		trace.record("query_rewrite", start, err, nil)
+		trace.fallback("query rewriting failed; searching with the original query")
+		slog.Warn("RAG query rewriting failed, using the original query", "bot_id", p.Collection, "error", err)
+		return query
+	}
+	trace.record("query_rewrite", start, nil, fiber.Map{"query": rewritten})
+	slog.Debug("RAG query rewritten", "bot_id", p.Collection, "query", query, "rewritten", rewritten)
+	return rewritten
+}
+
+// embed creates the query embedding
+func (p *RAGPipeline) embed(ctx context.Context, query string, trace *retrievalTrace) ([]float32, error) {
+	start := time.Now()
+	embeddings, err := p.h.client.CreateQueryEmbeddings(ctx, p.h.cfg.Services.AIURL, p.EmbeddingModel, p.InstructionPrefix, []string{query})
+	if err == nil && len(embeddings) == 0 {
+		err = fmt.Errorf("no embedding returned")
+	}
+	trace.record("embedding", start, err, nil)
+	if err != nil {
+		return nil, apierror.New(fiber.StatusInternalServerError, apierror.CodeEmbeddingFailed, fmt.Sprintf("embedding error: %v", err))
+	}
+	return embeddings[0], nil
+}
+
+// search runs the vector search and, if it found nothing, the listing fallback
+func (p *RAGPipeline) search(ctx context.Context, req *models.RAGChatRequest, embedding []float32, trace *retrievalTrace) ([]models.Document, error) {
+	slog.Debug("RAG vector search", "bot_id", p.Collection, "candidates", p.SearchLimit, "rerank_top_k", p.RerankTopK)
+
+	start := time.Now()
+	results, err := p.h.client.SearchVectorDocuments(ctx, p.h.cfg.Services.VectorURL, p.Collection, p.EmbeddingModel, embedding,
+		p.SearchLimit, ragPayloadFields, req.UploadRange())
+	trace.record("vector_search", start, err, fiber.Map{"limit": p.SearchLimit, "candidates": len(results)})
+	if errors.Is(err, clients.ErrEmbeddingModelMismatch) {
+		return nil, apierror.New(fiber.StatusConflict, apierror.CodeConflict, err.Error())
+	}
+	if err != nil {
+		return nil, apierror.New(fiber.StatusInternalServerError, apierror.CodeVectorDBFailed, fmt.Sprintf("vector search error: %v", err))
+	}
+
+	// Listed chunks ignore the upload date range, so a date-restricted search gets no fallback
+	if len(results) == 0 && p.Fallback && p.h.cfg.RAG.FallbackLimit > 0 && req.UploadRange().IsZero() {
+		slog.Info("RAG vector search found nothing, using fallback", "bot_id", p.Collection)
+		trace.fallback("vector search returned no results; using the first indexed chunks")
+		start = time.Now()
+		fallback, listErr := p.h.fallbackDocuments(ctx, p.Collection)
+		trace.record("list_fallback", start, listErr, fiber.Map{"limit": p.h.cfg.RAG.FallbackLimit, "candidates": len(fallback)})
+		if listErr == nil {
+			results = fallback
+		}
+	}
+
+	slog.Debug("RAG vector search results", "bot_id", p.Collection, "candidates", len(results))
+	return results, nil
+}
+
+// hasText reports whether any of the documents has text to put in a context
+func hasText(docs []models.Document) bool {
+	for _, doc := range docs {
+		if doc.Text != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// snippetContext builds the context from the passages of each candidate around the keywords of the searched query.
+// Snippets don't map one-to-one to chunks, so the result has no sources.
+func (p *RAGPipeline) snippetContext(req *models.RAGChatRequest, query string, candidates []models.Document, trace *retrievalTrace) *RAGResult {
+	maxDocChars := p.h.cfg.RAG.MaxDocChars
+	docs := utils.ExtractRelevantTexts(candidates, query, maxDocChars, max(maxDocChars/2, 800))
+	docs, contextStr := p.h.fitContext(*req, docs, "", p.ContextTokens)
+	trace.context("local", len(docs), len(contextStr))
+	return &RAGResult{Docs: docs, Context: contextStr}
+}
+
+// rerankedContext reranks the candidates with the cross-encoder of the AI service and uses its compressed
+// context when it is usable. If reranking fails, or returns no documents although there were candidates,
+// the top RerankFallback candidates are used in vector order rather than answering without context.
+func (p *RAGPipeline) rerankedContext(ctx context.Context, req *models.RAGChatRequest, query string, candidates []models.Document, trace *retrievalTrace) *RAGResult {
+	// The query is not embedded again: the cross-encoder works on the texts of the query and the candidates
+	start := time.Now()
+	advancedResult, err := p.h.client.AdvancedSearch(ctx, p.h.cfg.Services.AIURL, p.Collection, query, candidates,
+		p.RerankTopK, p.h.cfg.RAG.MaxContextChars)
+
+	ranked, limit, prebuilt := candidates, p.RerankFallback, ""
+	switch {
+	case err != nil:
+		trace.record("advanced_search", start, err, nil)
+		trace.fallback("advanced search failed; using the top vector results without reranking")
+		slog.Warn("RAG reranking failed, using fallback", "bot_id", p.Collection, "error", err)
+	case !hasText(advancedResult.Results) && hasText(candidates):
+		trace.record("advanced_search", start, nil, fiber.Map{"top_k": p.RerankTopK, "results": 0, "context_chars": 0})
+		trace.fallback("advanced search returned no documents; using the top vector results without reranking")
+		slog.Warn("RAG reranking returned no documents, using fallback", "bot_id", p.Collection, "candidates", len(candidates))
+	default:
+		results := advancedResult.Results
+		ranked, limit = results, len(results)
+		// Citations need ids that match the documents event, so the compressed context is not used then
+		if compressed := advancedResult.CompressedContext; len(compressed) >= 100 && !req.Citations {
+			prebuilt = compressed
+		}
+		slog.Debug("RAG reranking results", "bot_id", p.Collection, "documents", len(results), "context_chars", len(advancedResult.CompressedContext))
+		trace.record("advanced_search", start, nil, fiber.Map{"top_k": p.RerankTopK, "results": len(results), "context_chars": len(advancedResult.CompressedContext)})
+	}
+
+	docs := make([]string, 0, len(ranked))
+	sources := make([]string, 0, len(ranked))
+	kept := make([]models.Document, 0, len(ranked))
+	for _, doc := range ranked {
+		if len(docs) >= limit {
+			break
+		}
+		if doc.Text == "" {
+			continue
+		}
+		docs = append(docs, doc.Text)
+		sources = append(sources, doc.Source)
+		kept = append(kept, doc)
+	}
+
+	docs, contextStr := p.h.fitContext(*req, docs, prebuilt, p.ContextTokens)
+	if prebuilt != "" {
+		trace.context("ai_service", len(docs), len(contextStr))
+	} else {
+		trace.context("local", len(docs), len(contextStr))
+	}
+	trace.documents(kept[:len(docs)])
+
+	slog.Debug("RAG context ready", "bot_id", p.Collection, "documents", len(docs), "context_chars", len(contextStr))
+	// fitContext keeps a prefix of the documents, so sources stay aligned by index
+	return &RAGResult{Docs: docs, Sources: sources[:len(docs)], Context: contextStr}
+}
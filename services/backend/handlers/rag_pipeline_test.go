@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"backend/config"
+	"backend/models"
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// fakeRAGClient serves the calls of the RAG pipeline; the other ServiceClient methods are not implemented
+type fakeRAGClient struct {
+	ServiceClient
+	candidates []models.Document
+	reranked   *models.AdvancedSearchResponse
+}
+
+func (f *fakeRAGClient) CreateQueryEmbeddings(ctx context.Context, aiURL, model string, usePrefix *bool, texts []string) ([][]float32, error) {
+	return [][]float32{{0.1, 0.2, 0.3}}, nil
+}
+
+func (f *fakeRAGClient) SearchVectorDocuments(ctx context.Context, vectorURL, clientID, model string, queryEmbedding []float32, limit int, fields []string, uploaded models.UploadRange) ([]models.Document, error) {
+	return f.candidates, nil
+}
+
+func (f *fakeRAGClient) AdvancedSearch(ctx context.Context, aiURL, botID, query string, vectorResults []models.Document, topK int, maxContextChars int) (*models.AdvancedSearchResponse, error) {
+	return f.reranked, nil
+}
+
+// An AI service that answers 200 with no reranked documents must not leave the answer without context:
+// the top RerankFallback vector candidates are used instead
+func TestRerankedContextFallsBackWhenRerankingReturnsNothing(t *testing.T) {
+	candidates := make([]models.Document, 5)
+	for i := range candidates {
+		candidates[i] = models.Document{
+			ID:     fmt.Sprint(i),
+			Text:   fmt.Sprintf("chunk %d", i),
+			Score:  0.9 - float64(i)/10,
+			Source: fmt.Sprintf("doc-%d.txt", i),
+		}
+	}
+	client := &fakeRAGClient{
+		candidates: candidates,
+		reranked:   &models.AdvancedSearchResponse{Results: []models.Document{}},
+	}
+	h := &Handler{cfg: &config.Config{}, client: client}
+	pipeline := &RAGPipeline{
+		h:              h,
+		Collection:     "bot",
+		SearchLimit:    len(candidates),
+		Rerank:         true,
+		RerankTopK:     2,
+		RerankFallback: 3,
+	}
+
+	trace := &retrievalTrace{}
+	result, err := pipeline.Retrieve(context.Background(), &models.RAGChatRequest{Query: "question", MaxNewTokens: 256}, trace)
+	if err != nil {
+		t.Fatalf("Retrieve: %v", err)
+	}
+
+	wantDocs := []string{"chunk 0", "chunk 1", "chunk 2"}
+	if !reflect.DeepEqual(result.Docs, wantDocs) {
+		t.Errorf("docs = %q, want %q", result.Docs, wantDocs)
+	}
+	wantSources := []string{"doc-0.txt", "doc-1.txt", "doc-2.txt"}
+	if !reflect.DeepEqual(result.Sources, wantSources) {
+		t.Errorf("sources = %q, want %q", result.Sources, wantSources)
+	}
+	if result.Context == "" {
+		t.Error("context is empty")
+	}
+	if !trace.FallbackUsed {
+		t.Error("trace.FallbackUsed = false, want true")
+	}
+	if trace.ContextSource != "local" || trace.ContextDocs != len(wantDocs) {
+		t.Errorf("trace context = %s with %d docs, want local with %d", trace.ContextSource, trace.ContextDocs, len(wantDocs))
+	}
+}
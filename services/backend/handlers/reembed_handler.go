@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"backend/apierror"
+	"backend/audit"
+	"backend/auth"
+	"backend/database"
+	"backend/models"
+	"backend/validation"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// reembedBatchSize is how many chunks are listed, embedded and written to the staging collection at a time;
+// the job's progress is saved after each batch
+const reembedBatchSize = 64
+
+// ReembedRequest is the body of POST /admin/reembed/:bot_id
+type ReembedRequest struct {
+	EmbeddingModel *string `json:"embedding_model" validate:"omitempty,max=255"` // Omitted = the bot's current model
+	Restart        bool    `json:"restart"`                                      // Start over instead of resuming a failed job
+}
+
+// checkNotReembedding rejects indexing into a bot whose collection is being rebuilt. The running job is looked up
+// in reembed_jobs, so a job started by another backend replica counts too.
+func (h *Handler) checkNotReembedding(botID string) error {
+	running, err := h.reembedRepo.Running(botID)
+	if err != nil {
+		return apierror.New(fiber.StatusInternalServerError, apierror.CodeInternal, "failed to check re-embedding jobs")
+	}
+	if running {
+		return apierror.New(fiber.StatusConflict, apierror.CodeConflict, "the bot's documents are being re-embedded; try again when it finishes")
+	}
+	return nil
+}
+
+// reembedActive reports whether a job is running and still saving progress
+func reembedActive(job *database.ReembedJob) bool {
+	return job.Status == database.ReembedRunning && time.Since(job.UpdatedAt) < database.ReembedStaleAfter
+}
+
+// ReembedBot starts re-embedding every chunk of a bot with the AI service (admin only). The chunks are
+// embedded again in batches and written to a new collection sized for the model's vectors, which then
+// replaces the bot's collection; chats keep using the old one until the swap. The job runs in the background
+// and is answered with 202; GET /admin/reembed/:bot_id reports its progress.
+// A failed or interrupted job is resumed from its last batch, unless restart is set or the bot's chunks
+// changed since it started.
+func (h *Handler) ReembedBot(c *fiber.Ctx) error {
+	userID, ok := auth.GetUserID(c)
+	if !ok {
+		return apierror.Send(c, fiber.StatusUnauthorized, apierror.CodeUnauthorized, "unauthorized")
+	}
+
+	req := new(ReembedRequest)
+	if len(c.Body()) > 0 {
+		if err := validation.ParseBody(c, req); err != nil {
+			return err
+		}
+	}
+
+	bot, err := h.botRepo.GetByID(c.Params("bot_id"))
+	if err != nil {
+		return apierror.Send(c, fiber.StatusNotFound, apierror.CodeBotNotFound, "bot not found")
+	}
+	model := bot.EmbeddingModel
+	if req.EmbeddingModel != nil {
+		model = strings.TrimSpace(*req.EmbeddingModel)
+		if err := checkEmbeddingModel(model, h.cfg.RAG.EmbeddingModels); err != nil {
+			return err
+		}
+	}
+
+	job, err := h.reembedJob(c.UserContext(), bot, model, userID, req.Restart)
+	if err != nil {
+		return err
+	}
+
+	h.audit.Record(userID, audit.ActionBotReembed, audit.TargetBot, bot.ID, c.IP(), map[string]any{
+		"embedding_model": model,
+		"resumed":         job.Processed > 0,
+	})
+	go h.runReembed(job, bot)
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"success": true,
+		"job":     job,
+	})
+}
+
+// reembedJob starts the bot's failed job again, or a new one. A failed job is resumed only for the same
+// model and while the bot still has as many chunks as when it started; otherwise its staging collection is dropped.
+// A running job that stopped saving progress was cut off with its replica and counts as failed.
+func (h *Handler) reembedJob(ctx context.Context, bot *database.Bot, model string, userID uint, restart bool) (*database.ReembedJob, error) {
+	total, err := h.client.GetVectorStats(ctx, h.cfg.Services.VectorURL, bot.ID)
+	if err != nil {
+		return nil, apierror.New(fiber.StatusBadGateway, apierror.CodeVectorDBFailed, fmt.Sprintf("failed to count the bot's chunks: %v", err))
+	}
+
+	latest, err := h.reembedRepo.Latest(bot.ID)
+	if err != nil {
+		return nil, apierror.New(fiber.StatusInternalServerError, apierror.CodeInternal, "failed to load re-embedding jobs")
+	}
+	if latest != nil && reembedActive(latest) {
+		return nil, apierror.New(fiber.StatusConflict, apierror.CodeConflict, database.ErrReembedRunning.Error())
+	}
+	if latest != nil && latest.Status != database.ReembedCompleted {
+		if !restart && latest.EmbeddingModel == model && latest.Total == total {
+			if err := h.reembedRepo.Start(latest, userID); err != nil {
+				return nil, reembedStartError(err)
+			}
+			return latest, nil
+		}
+		if latest.Status == database.ReembedRunning {
+			// The unique index admits one running job per bot, so the dead one is failed before a new one starts
+			latest.Status = database.ReembedFailed
+			latest.Error = "interrupted: the backend running it stopped"
+			if err := h.reembedRepo.Save(latest); err != nil {
+				return nil, apierror.New(fiber.StatusInternalServerError, apierror.CodeInternal, "failed to save the re-embedding job")
+			}
+		}
+		if latest.Collection != "" {
+			if err := h.client.DropStagingCollection(ctx, h.cfg.Services.VectorURL, bot.ID, latest.Collection); err != nil {
+				slog.Warn("Failed to drop the staging collection of a failed re-embedding job",
+					"bot_id", bot.ID, "job_id", latest.ID, "collection", latest.Collection, "error", err)
+			}
+		}
+	}
+
+	job := &database.ReembedJob{
+		BotID:          bot.ID,
+		EmbeddingModel: model,
+		Total:          total,
+	}
+	if err := h.reembedRepo.Start(job, userID); err != nil {
+		return nil, reembedStartError(err)
+	}
+	return job, nil
+}
+
+// reembedStartError converts an error of ReembedRepository.Start: another replica may have started the bot's job first
+func reembedStartError(err error) error {
+	if errors.Is(err, database.ErrReembedRunning) {
+		return apierror.New(fiber.StatusConflict, apierror.CodeConflict, err.Error())
+	}
+	return apierror.New(fiber.StatusInternalServerError, apierror.CodeInternal, "failed to start the re-embedding job")
+}
+
+// runReembed runs a re-embedding job to the end and records how it ended
+func (h *Handler) runReembed(job *database.ReembedJob, bot *database.Bot) {
+	slog.Info("Re-embedding started", "bot_id", bot.ID, "job_id", job.ID, "embedding_model", job.EmbeddingModel,
+		"total", job.Total, "processed", job.Processed)
+	err := h.reembed(context.Background(), job, bot)
+	now := time.Now()
+	job.FinishedAt = &now
+	if err != nil {
+		job.Status = database.ReembedFailed
+		job.Error = err.Error()
+		slog.Error("Re-embedding failed", "bot_id", bot.ID, "job_id", job.ID, "processed", job.Processed, "error", err)
+	} else {
+		job.Status = database.ReembedCompleted
+		slog.Info("Re-embedding completed", "bot_id", bot.ID, "job_id", job.ID, "processed", job.Processed)
+	}
+	if err := h.reembedRepo.Save(job); err != nil {
+		slog.Error("Failed to save the re-embedding job", "bot_id", bot.ID, "job_id", job.ID, "error", err)
+	}
+}
+
+// reembed copies the bot's chunks batch by batch into the staging collection, re-embedded, and swaps it in.
+// Chunks keep their ids and metadata (upload dates included), so a batch written twice after a resume
+// overwrites itself.
+func (h *Handler) reembed(ctx context.Context, job *database.ReembedJob, bot *database.Bot) error {
+	vectorURL := h.cfg.Services.VectorURL
+	// An empty cursor after some progress means every batch was copied and only the swap is left
+	for job.Processed == 0 || job.Cursor != "" {
+		docs, next, err := h.client.ListVectorDocumentsPage(ctx, vectorURL, bot.ID, reembedBatchSize, job.Cursor)
+		if err != nil {
+			return fmt.Errorf("list chunks: %w", err)
+		}
+		if len(docs) > 0 {
+			if err := h.reembedBatch(ctx, job, bot, docs); err != nil {
+				return err
+			}
+		}
+		job.Cursor = next
+		job.Processed += len(docs)
+		if err := h.reembedRepo.Save(job); err != nil {
+			return err
+		}
+		if next == "" {
+			break
+		}
+	}
+
+	// A bot without chunks has nothing to swap; only its model changes
+	if job.Collection != "" {
+		if err := h.client.PromoteStagingCollection(ctx, vectorURL, bot.ID, job.Collection, job.EmbeddingModel); err != nil {
+			return fmt.Errorf("swap collections: %w", err)
+		}
+	}
+	if job.EmbeddingModel != bot.EmbeddingModel {
+		current, err := h.botRepo.GetByID(bot.ID)
+		if err != nil {
+			return fmt.Errorf("update the bot's embedding model: %w", err)
+		}
+		current.EmbeddingModel = job.EmbeddingModel
+		if err := h.botRepo.Update(current); err != nil {
+			return fmt.Errorf("update the bot's embedding model: %w", err)
+		}
+	}
+	// Cached answers were retrieved with the old vectors
+	h.answers.InvalidateBot(bot.ID)
+	return nil
+}
+
+// reembedBatch embeds one batch of chunks and writes it to the staging collection, which is created
+// with the first batch, when the vector size of the model is known
+func (h *Handler) reembedBatch(ctx context.Context, job *database.ReembedJob, bot *database.Bot, docs []models.Document) error {
+	ids := make([]string, len(docs))
+	texts := make([]string, len(docs))
+	metadata := make([]map[string]string, len(docs))
+	for i, doc := range docs {
+		ids[i], texts[i], metadata[i] = doc.ID, doc.Text, doc.Metadata
+	}
+
+	embeddings, err := h.client.CreateEmbeddings(ctx, h.cfg.Services.AIURL, job.EmbeddingModel, bot.UsesInstructionPrefix, texts)
+	if err != nil {
+		return fmt.Errorf("embed chunks: %w", err)
+	}
+	if len(embeddings) != len(texts) || len(embeddings[0]) == 0 {
+		return fmt.Errorf("embed chunks: got %d embeddings for %d chunks", len(embeddings), len(texts))
+	}
+
+	if job.Collection == "" {
+		collection, err := h.client.CreateStagingCollection(ctx, h.cfg.Services.VectorURL, bot.ID, len(embeddings[0]))
+		if err != nil {
+			return fmt.Errorf("create staging collection: %w", err)
+		}
+		job.Collection = collection
+		if err := h.reembedRepo.Save(job); err != nil {
+			return err
+		}
+	}
+	if err := h.client.AddStagingDocuments(ctx, h.cfg.Services.VectorURL, bot.ID, job.Collection, job.EmbeddingModel,
+		ids, texts, embeddings, metadata); err != nil {
+		return fmt.Errorf("write re-embedded chunks: %w", err)
+	}
+	return nil
+}
+
+// GetReembedStatus reports the latest re-embedding job of a bot (admin only); active tells whether it is running
+// and still saving progress, on any replica
+func (h *Handler) GetReembedStatus(c *fiber.Ctx) error {
+	botID := c.Params("bot_id")
+	job, err := h.reembedRepo.Latest(botID)
+	if err != nil {
+		return apierror.Send(c, fiber.StatusInternalServerError, apierror.CodeInternal, "failed to load re-embedding jobs")
+	}
+	if job == nil {
+		return apierror.Send(c, fiber.StatusNotFound, apierror.CodeNotFound, "the bot has not been re-embedded")
+	}
+	return c.JSON(fiber.Map{
+		"job":    job,
+		"active": reembedActive(job),
+	})
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"backend/clients"
+	"backend/models"
+	"context"
+	"io"
+	"net/http"
+)
+
+// ServiceClient is what the handlers call the downstream services and messengers through. *clients.Client
+// implements it; tests can pass NewHandler a fake instead of running the services.
+type ServiceClient interface {
+	// Document parser
+	ParseDocument(ctx context.Context, url, filename string, reader io.Reader, opts models.ParseOptions) (*models.ParseResponse, error)
+
+	// AI service
+	SplitDocument(ctx context.Context, aiURL string, text string, chunkSize, overlap int) (*models.SplitDocumentResponse, error)
+	RewriteQuery(ctx context.Context, aiURL, query string) (string, error)
+	CreateEmbeddings(ctx context.Context, aiURL, model string, usePrefix *bool, texts []string) ([][]float32, error)
+	CreateQueryEmbeddings(ctx context.Context, aiURL, model string, usePrefix *bool, texts []string) ([][]float32, error)
+	AdvancedSearch(ctx context.Context, aiURL, botID, query string, vectorResults []models.Document, topK int, maxContextChars int) (*models.AdvancedSearchResponse, error)
+	StreamGeneration(ctx context.Context, aiURL string, req models.GenerateRequest) (*http.Response, error)
+
+	// Vector DB service
+	AddVectorDocuments(ctx context.Context, vectorURL, clientID, model string, texts []string, embeddings [][]float32, metadata []map[string]string) error
+	SearchVectorDocuments(ctx context.Context, vectorURL, clientID, model string, queryEmbedding []float32, limit int, fields []string, uploaded models.UploadRange) ([]models.Document, error)
+	ListVectorDocuments(ctx context.Context, vectorURL, clientID string, limit int) ([]models.Document, error)
+	ListVectorDocumentsPage(ctx context.Context, vectorURL, clientID string, limit int, cursor string) ([]models.Document, string, error)
+	GetVectorStats(ctx context.Context, vectorURL, clientID string) (int, error)
+	EnsureVectorCollection(ctx context.Context, vectorURL, botID string) error
+	DeleteVectorDocuments(ctx context.Context, vectorURL, clientID string) error
+	DeleteVectorFileDocuments(ctx context.Context, vectorURL, clientID, fileName string, keepVersion int) error
+	CreateStagingCollection(ctx context.Context, vectorURL, botID string, dimension int) (string, error)
+	AddStagingDocuments(ctx context.Context, vectorURL, botID, collection, model string, ids, texts []string, embeddings [][]float32, metadata []map[string]string) error
+	PromoteStagingCollection(ctx context.Context, vectorURL, botID, collection, model string) error
+	DropStagingCollection(ctx context.Context, vectorURL, botID, collection string) error
+
+	// Messengers
+	SendTelegramMessage(ctx context.Context, apiURL, token string, chatID int64, text string) error
+	SetTelegramWebhook(ctx context.Context, apiURL, token, webhookURL, secretToken string) error
+	DeleteTelegramWebhook(ctx context.Context, apiURL, token string) error
+	PostSlackMessage(ctx context.Context, apiURL, token, channel, threadTS, text string) error
+	PostSlackResponse(ctx context.Context, responseURL, text string) error
+}
+
+var _ ServiceClient = (*clients.Client)(nil)
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// serverRestartingMessage ends chat streams still open when the shutdown grace period runs out
+const serverRestartingMessage = "server restarting, the answer was cut short; please retry"
+
+// streamTracker keeps track of the chat streams in flight, so shutdown can let them finish
+type streamTracker struct {
+	mu       sync.Mutex
+	wg       sync.WaitGroup
+	open     int
+	draining bool
+	stop     chan struct{} // Closed when the streams still open must end now
+	stopOnce sync.Once
+}
+
+func newStreamTracker() *streamTracker {
+	return &streamTracker{stop: make(chan struct{})}
+}
+
+// begin registers a new stream; it returns false once shutdown has begun
+func (t *streamTracker) begin() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.draining {
+		return false
+	}
+	t.open++
+	t.wg.Add(1)
+	return true
+}
+
+// end is called when a stream begun with begin has been written out
+func (t *streamTracker) end() {
+	t.mu.Lock()
+	t.open--
+	t.mu.Unlock()
+	t.wg.Done()
+}
+
+// stopping is closed when open streams must end
+func (t *streamTracker) stopping() <-chan struct{} {
+	return t.stop
+}
+
+// DrainStreams prepares chat streaming for shutdown: new chat streams are refused with 503, open ones get
+// grace to finish their answers, and those still running after it end with an error frame saying the
+// server is restarting, followed by the usual done frame. It returns once every stream has ended or ctx expires.
+func (h *Handler) DrainStreams(ctx context.Context, grace time.Duration) {
+	t := h.streams
+	t.mu.Lock()
+	t.draining = true
+	open := t.open
+	t.mu.Unlock()
+	if open == 0 {
+		return
+	}
+	log.Printf("Waiting up to %s for %d chat stream(s) to finish...", grace, open)
+
+	finished := make(chan struct{})
+	go func() {
+		t.wg.Wait()
+		close(finished)
+	}()
+	timer := time.NewTimer(grace)
+	defer timer.Stop()
+	select {
+	case <-finished:
+		return
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+
+	t.mu.Lock()
+	log.Printf("Ending %d chat stream(s) still open", t.open)
+	t.mu.Unlock()
+	t.stopOnce.Do(func() { close(t.stop) })
+	select {
+	case <-finished:
+	case <-ctx.Done():
+		log.Printf("Shutdown timed out with chat streams still open")
+	}
+}
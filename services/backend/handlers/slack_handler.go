@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"backend/apierror"
+	"backend/database"
+	"backend/utils"
+	"backend/validation"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// slackMessageLimit keeps each reply well under Slack's 40k character truncation and readable in clients
+const slackMessageLimit = 4000
+
+// slackMaxRequestAge rejects signed requests older than this to prevent replays
+const slackMaxRequestAge = 5 * time.Minute
+
+// slackMentionPattern matches user mentions like <@U123ABC> in message text
+var slackMentionPattern = regexp.MustCompile(`<@[A-Z0-9]+(\|[^>]*)?>`)
+
+// SlackCredentials are stored encrypted per bot
+type SlackCredentials struct {
+	BotToken      string `json:"bot_token"`
+	SigningSecret string `json:"signing_secret"`
+}
+
+// ConfigureSlackRequest represents a request to connect a Slack app
+type ConfigureSlackRequest struct {
+	BotToken      string `json:"bot_token" validate:"required,max=255"`
+	SigningSecret string `json:"signing_secret" validate:"required,max=255"`
+}
+
+// slackEnvelope is the subset of a Slack Events API request the bridge handles
+type slackEnvelope struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Event     struct {
+		Type        string `json:"type"`
+		Subtype     string `json:"subtype"`
+		BotID       string `json:"bot_id"`
+		User        string `json:"user"`
+		Text        string `json:"text"`
+		Channel     string `json:"channel"`
+		ChannelType string `json:"channel_type"`
+		TS          string `json:"ts"`
+		ThreadTS    string `json:"thread_ts"`
+	} `json:"event"`
+}
+
+// ConfigureSlack stores the Slack bot token and signing secret (encrypted) for the bot (owner only).
+// The returned request URL has to be set in the Slack app for Event Subscriptions and slash commands.
+func (h *Handler) ConfigureSlack(c *fiber.Ctx) error {
+	if err := h.requireIntegrations(); err != nil {
+		return err
+	}
+	bot, err := h.ownedBot(c)
+	if err != nil {
+		return err
+	}
+
+	req := new(ConfigureSlackRequest)
+	if err := validation.ParseBody(c, req); err != nil {
+		return err
+	}
+
+	creds := SlackCredentials{
+		BotToken:      strings.TrimSpace(req.BotToken),
+		SigningSecret: strings.TrimSpace(req.SigningSecret),
+	}
+	if err := h.saveIntegration(bot.ID, database.ProviderSlack, creds); err != nil {
+		log.Printf("[ConfigureSlack] Failed to save integration for bot %s: %v", bot.ID, err)
+		return apierror.Send(c, fiber.StatusInternalServerError, apierror.CodeInternal, "failed to save integration")
+	}
+
+	resp := fiber.Map{
+		"success":      true,
+		"webhook_path": "/api/v1/integrations/slack/" + bot.ID,
+	}
+	if h.cfg.Integrations.PublicBaseURL != "" {
+		resp["request_url"] = h.cfg.Integrations.PublicBaseURL + "/api/v1/integrations/slack/" + bot.ID
+	}
+	return c.JSON(resp)
+}
+
+// DeleteSlack disconnects the Slack app (owner only)
+func (h *Handler) DeleteSlack(c *fiber.Ctx) error {
+	if err := h.requireIntegrations(); err != nil {
+		return err
+	}
+	bot, err := h.ownedBot(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.integrationRepo.Delete(bot.ID, database.ProviderSlack); err != nil {
+		return apierror.Send(c, fiber.StatusNotFound, apierror.CodeNotFound, "integration not found")
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+	})
+}
+
+// SlackWebhook receives signed Slack Events API callbacks and slash commands for a bot.
+// Requests are acknowledged immediately (Slack expects a reply within 3s) and answered asynchronously.
+func (h *Handler) SlackWebhook(c *fiber.Ctx) error {
+	if err := h.requireIntegrations(); err != nil {
+		return err
+	}
+
+	botID := normalizeBotID(c.Params("bot_id"))
+	var creds SlackCredentials
+	if err := h.loadIntegration(botID, database.ProviderSlack, &creds); err != nil {
+		return apierror.Send(c, fiber.StatusNotFound, apierror.CodeNotFound, "integration not found")
+	}
+	if !verifySlackSignature(creds.SigningSecret, c.Get("X-Slack-Request-Timestamp"), c.Get("X-Slack-Signature"), c.Body(), time.Now()) {
+		return apierror.Send(c, fiber.StatusUnauthorized, apierror.CodeUnauthorized, "invalid Slack signature")
+	}
+
+	// Slash commands are form encoded, Events API callbacks are JSON
+	if c.Is("application/x-www-form-urlencoded") {
+		return h.handleSlackCommand(c, botID)
+	}
+
+	var envelope slackEnvelope
+	if err := json.Unmarshal(c.Body(), &envelope); err != nil {
+		return apierror.Send(c, fiber.StatusBadRequest, apierror.CodeInvalidBody, "invalid request body")
+	}
+
+	switch envelope.Type {
+	case "url_verification":
+		return c.JSON(fiber.Map{"challenge": envelope.Challenge})
+	case "event_callback":
+	default:
+		return c.SendStatus(fiber.StatusOK)
+	}
+
+	// Retries are sent when an earlier delivery was not acknowledged in time; the answer is already on its way
+	if c.Get("X-Slack-Retry-Num") != "" {
+		return c.SendStatus(fiber.StatusOK)
+	}
+
+	event := envelope.Event
+	// Ignore the bot's own and other bots' messages to avoid reply loops
+	if event.BotID != "" || event.Subtype != "" {
+		return c.SendStatus(fiber.StatusOK)
+	}
+	// Channel messages that mention the bot also arrive as app_mention, so only direct messages are handled here
+	if event.Type != "app_mention" && !(event.Type == "message" && event.ChannelType == "im") {
+		return c.SendStatus(fiber.StatusOK)
+	}
+
+	text := strings.TrimSpace(slackMentionPattern.ReplaceAllString(event.Text, ""))
+	if text == "" {
+		return c.SendStatus(fiber.StatusOK)
+	}
+
+	bot, err := h.botRepo.GetByID(botID)
+	if err != nil {
+		return c.SendStatus(fiber.StatusOK)
+	}
+
+	threadTS := event.ThreadTS
+	if threadTS == "" && event.Type == "app_mention" {
+		threadTS = event.TS
+	}
+	go h.replySlack(bot, text, func(part string) error {
+		return h.client.PostSlackMessage(context.Background(), h.cfg.Integrations.SlackAPIURL, creds.BotToken, event.Channel, threadTS, part)
+	})
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// handleSlackCommand acknowledges a slash command and posts the answer to its response_url
+func (h *Handler) handleSlackCommand(c *fiber.Ctx, botID string) error {
+	text := strings.TrimSpace(c.FormValue("text"))
+	responseURL := c.FormValue("response_url")
+	if text == "" || responseURL == "" {
+		return c.JSON(fiber.Map{
+			"response_type": "ephemeral",
+			"text":          "Please add a question, e.g. `" + c.FormValue("command") + " how do I get started?`",
+		})
+	}
+
+	bot, err := h.botRepo.GetByID(botID)
+	if err != nil {
+		return apierror.Send(c, fiber.StatusNotFound, apierror.CodeBotNotFound, "bot not found")
+	}
+
+	go h.replySlack(bot, text, func(part string) error {
+		return h.client.PostSlackResponse(context.Background(), responseURL, part)
+	})
+	return c.JSON(fiber.Map{
+		"response_type": "ephemeral",
+		"text":          "🤔 Thinking...",
+	})
+}
+
+// replySlack answers a Slack message, splitting long answers into several messages
+func (h *Handler) replySlack(bot *database.Bot, text string, post func(part string) error) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.HTTPClient.Timeout)
+	defer cancel()
+
+	answer, err := h.answerForBot(ctx, bot, text, database.ProviderSlack)
+	if err != nil {
+		log.Printf("[SlackWebhook] Failed to answer for bot %s: %v", bot.ID, err)
+		answer = "Sorry, I couldn't answer right now. Please try again later."
+	}
+	if answer == "" {
+		answer = "Sorry, I don't have an answer to that."
+	}
+
+	for _, part := range utils.SplitMessage(answer, slackMessageLimit) {
+		if err := post(part); err != nil {
+			log.Printf("[SlackWebhook] Failed to post message for bot %s: %v", bot.ID, err)
+			return
+		}
+	}
+}
+
+// verifySlackSignature checks the v0 request signature: HMAC-SHA256 of "v0:timestamp:body" with the signing secret
+func verifySlackSignature(secret, timestamp, signature string, body []byte, now time.Time) bool {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	age := now.Sub(time.Unix(ts, 0))
+	if age > slackMaxRequestAge || age < -slackMaxRequestAge {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + timestamp + ":"))
+	mac.Write(body)
+	expected := "v0=" + hex.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"backend/apierror"
+	"backend/utils"
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Chat stream formats, selected with the stream_format query parameter
+const (
+	// streamFormatEvents (default): every frame is a JSON object with a "type" of
+	// "sources", "token", "error" or "done"; "done" always ends the stream
+	streamFormatEvents = "events"
+	// streamFormatLegacy: an untyped documents frame, AI service frames passed through as is,
+	// then "[DONE]"; kept for clients written against the original stream
+	streamFormatLegacy = "legacy"
+)
+
+// Chat stream frame types
+const (
+	frameSources = "sources"
+	frameToken   = "token"
+	frameError   = "error"
+	frameDone    = "done"
+)
+
+// legacyStream reports whether the request asked for the legacy stream format
+func legacyStream(c *fiber.Ctx) (bool, error) {
+	switch format := c.Query("stream_format"); format {
+	case "", streamFormatEvents:
+		return false, nil
+	case streamFormatLegacy:
+		return true, nil
+	default:
+		return false, apierror.New(fiber.StatusBadRequest, apierror.CodeValidationFailed,
+			fmt.Sprintf("unsupported stream_format %q (use %q or %q)", format, streamFormatEvents, streamFormatLegacy))
+	}
+}
+
+// maxFrameBytes bounds one line of the AI service stream; bufio.Scanner's 64KB default
+// would cut the stream short on a long frame
+const maxFrameBytes = 1 << 20
+
+// newFrameScanner reads the AI service stream line by line
+func newFrameScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxFrameBytes)
+	return scanner
+}
+
+// streamFrame is a frame of the AI service generation stream
+type streamFrame struct {
+	Type  string `json:"type"`
+	Token string `json:"token"`
+	Error string `json:"error"`
+}
+
+// parseStreamFrame decodes an AI service SSE line ("data: {...}")
+func parseStreamFrame(line string) (streamFrame, bool) {
+	var frame streamFrame
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &frame); err != nil {
+		return streamFrame{}, false
+	}
+	return frame, true
+}
+
+// chatStream writes chat SSE frames in the format the client asked for
+type chatStream struct {
+	w      *bufio.Writer
+	legacy bool
+	filter *utils.ArtifactFilter // Post-processes relayed tokens; nil = tokens are forwarded as is
+}
+
+// send writes one "data:" frame
+func (s chatStream) send(payload any) {
+	data, _ := json.Marshal(payload)
+	fmt.Fprintf(s.w, "data: %s\n\n", data)
+}
+
+// sources writes the documents frame built by documentsEvent
+func (s chatStream) sources(event map[string]any) {
+	if !s.legacy {
+		event["type"] = frameSources
+	}
+	s.send(event)
+}
+
+// token writes a piece of the answer
+func (s chatStream) token(token string) {
+	s.send(map[string]string{"type": frameToken, "token": token})
+}
+
+// error reports a failure; the legacy format used an untyped {"error": ...} frame
+func (s chatStream) error(message string) {
+	if s.legacy {
+		s.send(map[string]string{"error": message})
+		return
+	}
+	s.send(map[string]string{"type": frameError, "error": message})
+}
+
+// relay forwards a frame of the AI service stream and returns the answer text it carried.
+// The legacy format passes the line through verbatim; otherwise only token and error frames are re-encoded,
+// since the stream's own done frame is written by done. Filtered tokens are re-encoded in both formats.
+func (s chatStream) relay(line string, frame streamFrame) string {
+	if frame.Type == frameToken && s.filter != nil {
+		token := s.filter.Push(frame.Token)
+		if token != "" {
+			s.token(token)
+		}
+		return token
+	}
+
+	text := ""
+	switch frame.Type {
+	case frameToken:
+		text = frame.Token
+	case frameDone:
+		text = s.flush()
+	}
+	if s.legacy {
+		fmt.Fprintf(s.w, "%s\n\n", line)
+		return text
+	}
+	switch frame.Type {
+	case frameToken:
+		s.token(frame.Token)
+	case frameError:
+		s.error(frame.Error)
+	}
+	return text
+}
+
+// flush writes the answer text held back by the filter and returns it
+func (s chatStream) flush() string {
+	if s.filter == nil {
+		return ""
+	}
+	rest := s.filter.Flush()
+	if rest != "" {
+		s.token(rest)
+	}
+	return rest
+}
+
+// done ends the stream. The events format repeats the complete answer (if any) in the done frame,
+// so clients can store it without reassembling tokens; the legacy format is unchanged.
+func (s chatStream) done(answer string) {
+	if s.legacy {
+		fmt.Fprintf(s.w, "data: [DONE]\n\n")
+		return
+	}
+	frame := map[string]string{"type": frameDone}
+	if answer != "" {
+		frame["answer"] = answer
+	}
+	s.send(frame)
+}
+
+// frameFlusher decides when buffered frames are written to the client. With no interval every frame is
+// flushed at once; otherwise the first unflushed frame arms a timer and everything buffered until it fires
+// goes out in one write, or earlier once maxBytes are buffered. Fewer, larger writes cost less per stream
+// when many streams are open, at the price of up to interval of added latency per token.
+type frameFlusher struct {
+	w        *bufio.Writer
+	interval time.Duration
+	maxBytes int
+	timer    *time.Timer // Armed while frames are waiting; nil otherwise
+}
+
+// wrote is called after a frame was buffered; an error means the client is gone
+func (f *frameFlusher) wrote() error {
+	if f.interval <= 0 || (f.maxBytes > 0 && f.w.Buffered() >= f.maxBytes) {
+		return f.flush()
+	}
+	if f.timer == nil {
+		f.timer = time.NewTimer(f.interval)
+	}
+	return nil
+}
+
+// due fires when waiting frames must be flushed; it never fires while nothing is waiting
+func (f *frameFlusher) due() <-chan time.Time {
+	if f.timer == nil {
+		return nil
+	}
+	return f.timer.C
+}
+
+// flush writes everything buffered and disarms the timer
+func (f *frameFlusher) flush() error {
+	if f.timer != nil {
+		f.timer.Stop()
+		f.timer = nil
+	}
+	return f.w.Flush()
+}
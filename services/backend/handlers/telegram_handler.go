@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"backend/apierror"
+	"backend/database"
+	"backend/utils"
+	"backend/validation"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// telegramMessageLimit is Telegram's message length limit, counted in UTF-16 code units
+const telegramMessageLimit = 4096
+
+// telegramSecretHeader carries the secret_token registered with setWebhook
+const telegramSecretHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+// TelegramCredentials are stored encrypted per bot
+type TelegramCredentials struct {
+	BotToken    string `json:"bot_token"`
+	SecretToken string `json:"secret_token"`
+}
+
+// ConfigureTelegramRequest represents a request to connect a Telegram bot
+type ConfigureTelegramRequest struct {
+	BotToken string `json:"bot_token" validate:"required,max=255"`
+}
+
+// telegramUpdate is the subset of a Telegram Update the bridge handles
+type telegramUpdate struct {
+	UpdateID int64 `json:"update_id"`
+	Message  *struct {
+		Chat struct {
+			ID int64 `json:"id"`
+		} `json:"chat"`
+		Text string `json:"text"`
+	} `json:"message"`
+}
+
+// telegramWebhookURL is the public endpoint Telegram delivers updates of the bot to
+func (h *Handler) telegramWebhookURL(botID string) string {
+	return fmt.Sprintf("%s/api/v1/integrations/telegram/%s", h.cfg.Integrations.PublicBaseURL, botID)
+}
+
+// ConfigureTelegram stores the Telegram bot token (encrypted) and registers the webhook (owner only).
+// Without PUBLIC_BASE_URL the webhook is not registered and the secret token is returned for manual setup.
+func (h *Handler) ConfigureTelegram(c *fiber.Ctx) error {
+	if err := h.requireIntegrations(); err != nil {
+		return err
+	}
+	bot, err := h.ownedBot(c)
+	if err != nil {
+		return err
+	}
+
+	req := new(ConfigureTelegramRequest)
+	if err := validation.ParseBody(c, req); err != nil {
+		return err
+	}
+
+	secretBytes := make([]byte, 32)
+	if _, err := rand.Read(secretBytes); err != nil {
+		return apierror.Send(c, fiber.StatusInternalServerError, apierror.CodeInternal, "failed to generate secret token")
+	}
+	creds := TelegramCredentials{
+		BotToken:    strings.TrimSpace(req.BotToken),
+		SecretToken: hex.EncodeToString(secretBytes),
+	}
+
+	registered := false
+	if h.cfg.Integrations.PublicBaseURL != "" {
+		if err := h.client.SetTelegramWebhook(c.UserContext(), h.cfg.Integrations.TelegramAPIURL, creds.BotToken, h.telegramWebhookURL(bot.ID), creds.SecretToken); err != nil {
+			return apierror.Send(c, fiber.StatusBadGateway, apierror.CodeUpstreamFailed, fmt.Sprintf("failed to register Telegram webhook: %v", err))
+		}
+		registered = true
+	}
+
+	if err := h.saveIntegration(bot.ID, database.ProviderTelegram, creds); err != nil {
+		log.Printf("[ConfigureTelegram] Failed to save integration for bot %s: %v", bot.ID, err)
+		return apierror.Send(c, fiber.StatusInternalServerError, apierror.CodeInternal, "failed to save integration")
+	}
+
+	resp := fiber.Map{
+		"success":            true,
+		"webhook_path":       "/api/v1/integrations/telegram/" + bot.ID,
+		"webhook_registered": registered,
+	}
+	if !registered {
+		resp["secret_token"] = creds.SecretToken
+	}
+	return c.JSON(resp)
+}
+
+// DeleteTelegram disconnects the Telegram bot (owner only)
+func (h *Handler) DeleteTelegram(c *fiber.Ctx) error {
+	if err := h.requireIntegrations(); err != nil {
+		return err
+	}
+	bot, err := h.ownedBot(c)
+	if err != nil {
+		return err
+	}
+
+	var creds TelegramCredentials
+	if err := h.loadIntegration(bot.ID, database.ProviderTelegram, &creds); err == nil {
+		if err := h.client.DeleteTelegramWebhook(c.UserContext(), h.cfg.Integrations.TelegramAPIURL, creds.BotToken); err != nil {
+			log.Printf("[DeleteTelegram] Failed to delete webhook of bot %s: %v", bot.ID, err)
+		}
+	}
+
+	if err := h.integrationRepo.Delete(bot.ID, database.ProviderTelegram); err != nil {
+		return apierror.Send(c, fiber.StatusNotFound, apierror.CodeNotFound, "integration not found")
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+	})
+}
+
+// TelegramWebhook receives Telegram updates for a bot and answers text messages with the RAG pipeline.
+// The update is acknowledged immediately and the answer is sent asynchronously via sendMessage.
+func (h *Handler) TelegramWebhook(c *fiber.Ctx) error {
+	if err := h.requireIntegrations(); err != nil {
+		return err
+	}
+
+	botID := normalizeBotID(c.Params("bot_id"))
+	var creds TelegramCredentials
+	if err := h.loadIntegration(botID, database.ProviderTelegram, &creds); err != nil {
+		return apierror.Send(c, fiber.StatusNotFound, apierror.CodeNotFound, "integration not found")
+	}
+	if subtle.ConstantTimeCompare([]byte(c.Get(telegramSecretHeader)), []byte(creds.SecretToken)) != 1 {
+		return apierror.Send(c, fiber.StatusUnauthorized, apierror.CodeUnauthorized, "invalid secret token")
+	}
+
+	var update telegramUpdate
+	if err := c.BodyParser(&update); err != nil {
+		return apierror.Send(c, fiber.StatusBadRequest, apierror.CodeInvalidBody, "invalid request body")
+	}
+	if update.Message == nil || strings.TrimSpace(update.Message.Text) == "" {
+		return c.SendStatus(fiber.StatusOK)
+	}
+
+	bot, err := h.botRepo.GetByID(botID)
+	if err != nil {
+		return c.SendStatus(fiber.StatusOK)
+	}
+
+	go h.replyTelegram(bot, creds.BotToken, update.Message.Chat.ID, update.Message.Text)
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// replyTelegram answers a Telegram message, splitting long answers into several messages
+func (h *Handler) replyTelegram(bot *database.Bot, token string, chatID int64, text string) {
+	var answer string
+	if strings.HasPrefix(text, "/start") {
+		answer = bot.Description
+		if answer == "" {
+			answer = fmt.Sprintf("👋 Hi! I'm %s. Ask me anything.", bot.Name)
+		}
+	} else {
+		ctx, cancel := context.WithTimeout(context.Background(), h.cfg.HTTPClient.Timeout)
+		defer cancel()
+
+		var err error
+		answer, err = h.answerForBot(ctx, bot, text, database.ProviderTelegram)
+		if err != nil {
+			log.Printf("[TelegramWebhook] Failed to answer for bot %s: %v", bot.ID, err)
+			answer = "Sorry, I couldn't answer right now. Please try again later."
+		}
+		if answer == "" {
+			answer = "Sorry, I don't have an answer to that."
+		}
+	}
+
+	for _, part := range utils.SplitMessageUTF16(answer, telegramMessageLimit) {
+		if err := h.client.SendTelegramMessage(context.Background(), h.cfg.Integrations.TelegramAPIURL, token, chatID, part); err != nil {
+			log.Printf("[TelegramWebhook] Failed to send message for bot %s: %v", bot.ID, err)
+			return
+		}
+	}
+}
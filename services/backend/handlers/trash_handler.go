@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"backend/apierror"
+	"backend/auth"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ListTrash returns the current user's deleted bots that can still be restored
+func (h *Handler) ListTrash(c *fiber.Ctx) error {
+	userID, ok := auth.GetUserID(c)
+	if !ok {
+		return apierror.Send(c, fiber.StatusUnauthorized, apierror.CodeUnauthorized, "unauthorized")
+	}
+
+	bots, err := h.botRepo.ListDeleted(userID)
+	if err != nil {
+		return apierror.Send(c, fiber.StatusInternalServerError, apierror.CodeInternal, "failed to get deleted bots")
+	}
+
+	cutoff := time.Now().Add(-h.cfg.Trash.Retention)
+	items := make([]fiber.Map, 0, len(bots))
+	for _, bot := range bots {
+		if bot.DeletedAt.Time.Before(cutoff) {
+			continue
+		}
+		items = append(items, fiber.Map{
+			"bot":              bot,
+			"restorable_until": bot.DeletedAt.Time.Add(h.cfg.Trash.Retention),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"bots":           items,
+		"retention_days": int(h.cfg.Trash.Retention.Hours() / 24),
+	})
+}
+
+// RestoreBot takes a deleted bot out of the trash within the retention window (owner only)
+func (h *Handler) RestoreBot(c *fiber.Ctx) error {
+	userID, ok := auth.GetUserID(c)
+	if !ok {
+		return apierror.Send(c, fiber.StatusUnauthorized, apierror.CodeUnauthorized, "unauthorized")
+	}
+
+	botID := normalizeBotID(c.Params("id"))
+	bot, err := h.botRepo.GetDeleted(botID, userID)
+	if err != nil {
+		return apierror.Send(c, fiber.StatusNotFound, apierror.CodeBotNotFound, "bot not found in trash")
+	}
+	if time.Since(bot.DeletedAt.Time) > h.cfg.Trash.Retention {
+		return apierror.Send(c, fiber.StatusGone, apierror.CodeNotFound, "the recovery window for this bot has expired")
+	}
+
+	// Restored documents count against the storage quota again
+	documents, err := h.botRepo.GetDocuments(botID)
+	if err != nil {
+		return apierror.Send(c, fiber.StatusInternalServerError, apierror.CodeInternal, "failed to get documents")
+	}
+	var chunks int
+	var size int64
+	for _, d := range documents {
+		chunks += d.ChunksCount
+		size += d.FileSize
+	}
+	if err := h.checkQuota(userID, chunks, size); err != nil {
+		return err
+	}
+
+	if err := h.botRepo.Restore(botID, userID); err != nil {
+		return apierror.Send(c, fiber.StatusInternalServerError, apierror.CodeInternal, "failed to restore bot")
+	}
+	if err := h.client.EnsureVectorCollection(c.UserContext(), h.cfg.Services.VectorURL, botID); err != nil {
+		log.Printf("[RestoreBot] Failed to ensure vector collection for bot %s: %v", botID, err)
+	}
+
+	bot.DeletedAt.Valid = false
+	return c.JSON(fiber.Map{
+		"success": true,
+		"bot":     bot,
+	})
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"backend/apierror"
+	"backend/auth"
+	"backend/database"
+	"backend/netguard"
+	"backend/validation"
+	"backend/webhooks"
+	"fmt"
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type WebhookHandler struct {
+	botRepo      *database.BotRepository
+	webhookRepo  *database.WebhookRepository
+	allowPrivate bool // Accept URLs on loopback/private addresses (WEBHOOK_ALLOW_PRIVATE)
+}
+
+func NewWebhookHandler(botRepo *database.BotRepository, webhookRepo *database.WebhookRepository, allowPrivate bool) *WebhookHandler {
+	return &WebhookHandler{
+		botRepo:      botRepo,
+		webhookRepo:  webhookRepo,
+		allowPrivate: allowPrivate,
+	}
+}
+
+// CreateWebhookRequest represents a request to register a webhook
+type CreateWebhookRequest struct {
+	URL    string   `json:"url" validate:"required,http_url,max=2048"`
+	Events []string `json:"events" validate:"required,min=1,dive,oneof=chat.completed document.indexed"`
+}
+
+// CreateWebhookResponse includes the signing secret, which is only shown once
+type CreateWebhookResponse struct {
+	*database.Webhook
+	Secret string `json:"secret"`
+}
+
+// requireBotOwner resolves the bot id from the path and checks that the current user owns it
+func (h *WebhookHandler) requireBotOwner(c *fiber.Ctx) (string, error) {
+	userID, ok := auth.GetUserID(c)
+	if !ok {
+		return "", apierror.New(fiber.StatusUnauthorized, apierror.CodeUnauthorized, "unauthorized")
+	}
+
+	botID := normalizeBotID(c.Params("id"))
+	isOwner, err := h.botRepo.CheckOwnership(botID, userID)
+	if err != nil || !isOwner {
+		return "", apierror.New(fiber.StatusNotFound, apierror.CodeBotNotFound, "bot not found")
+	}
+	return botID, nil
+}
+
+// ListWebhooks returns the webhooks of a bot (owner only)
+func (h *WebhookHandler) ListWebhooks(c *fiber.Ctx) error {
+	botID, err := h.requireBotOwner(c)
+	if err != nil {
+		return err
+	}
+
+	hooks, err := h.webhookRepo.ListByBot(botID)
+	if err != nil {
+		return apierror.Send(c, fiber.StatusInternalServerError, apierror.CodeInternal, "failed to get webhooks")
+	}
+
+	return c.JSON(fiber.Map{
+		"webhooks":         hooks,
+		"supported_events": webhooks.SupportedEvents,
+	})
+}
+
+// CreateWebhook registers a webhook for a bot and returns its signing secret (owner only)
+func (h *WebhookHandler) CreateWebhook(c *fiber.Ctx) error {
+	botID, err := h.requireBotOwner(c)
+	if err != nil {
+		return err
+	}
+
+	req := new(CreateWebhookRequest)
+	if err := validation.ParseBody(c, req); err != nil {
+		return err
+	}
+	// Deliveries come from inside the platform's network, so internal services must not be reachable
+	// through a webhook. The dispatcher checks the dialed address again on every delivery.
+	if !h.allowPrivate {
+		if err := netguard.CheckPublicURL(c.UserContext(), req.URL); err != nil {
+			return apierror.Send(c, fiber.StatusBadRequest, apierror.CodeValidationFailed, fmt.Sprintf("webhook URL must point to a public address: %v", err))
+		}
+	}
+
+	secret, err := webhooks.GenerateSecret()
+	if err != nil {
+		return apierror.Send(c, fiber.StatusInternalServerError, apierror.CodeInternal, "failed to generate webhook secret")
+	}
+
+	hook := &database.Webhook{
+		BotID:    botID,
+		URL:      req.URL,
+		Secret:   secret,
+		Events:   req.Events,
+		IsActive: true,
+	}
+	if err := h.webhookRepo.Create(hook); err != nil {
+		return apierror.Send(c, fiber.StatusInternalServerError, apierror.CodeInternal, "failed to create webhook")
+	}
+
+	log.Printf("[CreateWebhook] Registered webhook %d for bot %s: %v", hook.ID, botID, hook.Events)
+	return c.Status(fiber.StatusCreated).JSON(CreateWebhookResponse{
+		Webhook: hook,
+		Secret:  secret,
+	})
+}
+
+// DeleteWebhook removes a webhook of a bot (owner only)
+func (h *WebhookHandler) DeleteWebhook(c *fiber.Ctx) error {
+	botID, err := h.requireBotOwner(c)
+	if err != nil {
+		return err
+	}
+
+	webhookID, err := c.ParamsInt("webhook_id")
+	if err != nil || webhookID <= 0 {
+		return apierror.Send(c, fiber.StatusBadRequest, apierror.CodeBadRequest, "invalid webhook id")
+	}
+
+	if err := h.webhookRepo.Delete(uint(webhookID), botID); err != nil {
+		return apierror.Send(c, fiber.StatusNotFound, apierror.CodeNotFound, "webhook not found")
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+	})
+}
@@ -0,0 +1,69 @@
+package logging
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Log formats (LOG_FORMAT)
+const (
+	FormatText = "text" // key=value lines
+	FormatJSON = "json" // One JSON object per line, for log platforms
+)
+
+// Setup installs the process-wide slog logger, writing to stderr at level (debug, info, warn or error)
+// in format. The standard log package is routed through it too, so the remaining log.Printf lines
+// come out in the same format, at info level.
+func Setup(level, format string) error {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("LOG_LEVEL must be debug, info, warn or error, got %q", level)
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl}
+	var handler slog.Handler
+	switch format {
+	case FormatText:
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	case FormatJSON:
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	default:
+		return fmt.Errorf("LOG_FORMAT must be %q or %q, got %q", FormatText, FormatJSON, format)
+	}
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// Middleware logs every request once it has been handled: 5xx responses at error level, 4xx at warn,
+// the rest at info. Errors are rendered by the app's ErrorHandler first, so the logged status is the one sent.
+func Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		if chainErr := c.Next(); chainErr != nil {
+			if err := c.App().ErrorHandler(c, chainErr); err != nil {
+				_ = c.SendStatus(fiber.StatusInternalServerError)
+			}
+		}
+
+		status := c.Response().StatusCode()
+		level := slog.LevelInfo
+		switch {
+		case status >= fiber.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= fiber.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+		slog.Log(c.UserContext(), level, "Request",
+			"method", c.Method(),
+			"path", c.Path(),
+			"status", status,
+			"latency_ms", time.Since(start).Milliseconds(),
+			"ip", c.IP(),
+		)
+		return nil
+	}
+}
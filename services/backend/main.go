@@ -1,29 +1,57 @@
 package main
 
 import (
+	"backend/apierror"
+	"backend/audit"
 	"backend/auth"
+	"backend/bodylimit"
 	"backend/clients"
 	"backend/config"
 	"backend/database"
 	"backend/handlers"
+	"backend/logging"
+	"backend/secrets"
+	"backend/utils"
+	"backend/webhooks"
 	"context"
 	"log"
+	"log/slog"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"runtime"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/limiter"
-	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/recover"
 )
 
 func main() {
+	logCfg := config.LoadLogging()
+	if err := logging.Setup(logCfg.Level, logCfg.Format); err != nil {
+		log.Fatalf("Invalid logging configuration: %v", err)
+	}
+
+	// "backend migrate" migrates the schema and exits: production runs it once per release instead of
+	// every replica migrating on startup (RUN_MIGRATIONS=false)
+	if len(os.Args) > 1 {
+		if os.Args[1] != "migrate" {
+			log.Fatalf("Unknown command %q (usage: backend [migrate])", os.Args[1])
+		}
+		db := connectDB()
+		defer db.Close()
+		if err := db.AutoMigrate(); err != nil {
+			log.Fatalf("Failed to run migrations: %v", err)
+		}
+		log.Println("✓ Database migrations completed")
+		return
+	}
+
 	// Load configuration
 	cfg, err := config.Load()
 	if err != nil {
@@ -33,28 +61,44 @@ func main() {
 	// Optimize for multi-core CPU
 	runtime.GOMAXPROCS(runtime.NumCPU())
 
-	// Connect to database
-	databaseURL := os.Getenv("DATABASE_URL")
-	if databaseURL == "" {
-		log.Fatal("DATABASE_URL environment variable is required")
-	}
-
-	db, err := database.NewDB(databaseURL)
-	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
-	}
+	db := connectDB()
 	defer db.Close()
-	log.Println("✓ Database connected")
 
-	// Run auto migrations
-	if err := db.AutoMigrate(); err != nil {
-		log.Fatalf("Failed to run migrations: %v", err)
+	if cfg.Database.RunMigrations {
+		if err := db.AutoMigrate(); err != nil {
+			log.Fatalf("Failed to run migrations: %v", err)
+		}
+		log.Println("✓ Database migrations completed")
+	} else {
+		log.Println("⏭️  RUN_MIGRATIONS=false, skipping database migrations")
 	}
-	log.Println("✓ Database migrations completed")
 
 	// Initialize repositories
 	userRepo := database.NewUserRepository(db)
 	botRepo := database.NewBotRepository(db)
+	webhookRepo := database.NewWebhookRepository(db)
+	integrationRepo := database.NewIntegrationRepository(db)
+	auditRepo := database.NewAuditRepository(db)
+	reembedRepo := database.NewReembedRepository(db)
+
+	// Re-embedding jobs run inside the backend process, so the ones still marked running were cut off by
+	// its restart; marking them failed lets POST /admin/reembed/:bot_id resume them
+	if n, err := reembedRepo.MarkInterrupted(); err != nil {
+		log.Printf("⚠️  %v", err)
+	} else if n > 0 {
+		log.Printf("⚠️  %d re-embedding job(s) were interrupted by a restart and can be resumed", n)
+	}
+
+	// Messenger integrations need an encryption key for stored credentials
+	var secretBox *secrets.Box
+	if cfg.Integrations.EncryptionKey != "" {
+		secretBox, err = secrets.NewBox(cfg.Integrations.EncryptionKey)
+		if err != nil {
+			log.Fatalf("Failed to initialize integrations encryption: %v", err)
+		}
+	} else {
+		log.Println("⚠️  INTEGRATIONS_ENCRYPTION_KEY is not set, messenger integrations are disabled")
+	}
 
 	// Initialize JWT service
 	jwtSecret := os.Getenv("JWT_SECRET")
@@ -62,11 +106,13 @@ func main() {
 		jwtSecret = auth.GenerateSecretKey()
 		log.Printf("⚠️  Generated JWT_SECRET: %s (save this for production!)", jwtSecret)
 	}
-	jwtService := auth.NewJWTService(jwtSecret, 24*time.Hour) // 24h token expiration
+	// Token versions are checked against the users table, so password changes revoke tokens on every replica
+	jwtService := auth.NewJWTService(jwtSecret, cfg.Auth.JWTExpiration, userRepo)
 
-	// Create HTTP client with connection pooling and optimized settings
+	// Create HTTP client with connection pooling and optimized settings.
+	// No client-wide Timeout: short calls get SERVICE_CALL_TIMEOUT per request, while streaming generation
+	// runs until the answer is complete, the client disconnects or the server shuts down.
 	httpClient := &http.Client{
-		Timeout: cfg.HTTPClient.Timeout,
 		Transport: &http.Transport{
 			MaxIdleConns:        200,
 			MaxIdleConnsPerHost: 100,
@@ -83,16 +129,33 @@ func main() {
 	}
 
 	// Initialize client and handlers
-	serviceClient := clients.NewClient(httpClient)
-	h := handlers.NewHandler(cfg, serviceClient)
-	authHandler := handlers.NewAuthHandler(userRepo, jwtService)
-	botHandler := handlers.NewBotHandler(botRepo)
+	serviceClient := clients.NewClient(httpClient, cfg.HTTPClient.ServiceTimeout, cfg.RAG.EmbeddingCacheSize)
+	dispatcher := webhooks.NewDispatcher(webhookRepo, webhooks.Config{
+		Workers:      cfg.Webhooks.Workers,
+		MaxAttempts:  cfg.Webhooks.MaxAttempts,
+		Timeout:      cfg.Webhooks.Timeout,
+		BaseBackoff:  cfg.Webhooks.RetryBackoff,
+		AllowPrivate: cfg.Webhooks.AllowPrivate,
+	})
+	dispatcher.Start()
+	auditLog := audit.NewLogger(auditRepo)
+	auditLog.Start()
+	h := handlers.NewHandler(cfg, serviceClient, botRepo, userRepo, dispatcher, integrationRepo, secretBox, auditLog, reembedRepo)
+	authHandler := handlers.NewAuthHandler(userRepo, jwtService, cfg.Auth.LoginMaxFailures, cfg.Auth.LoginLockout)
+	botHandler := handlers.NewBotHandler(botRepo, cfg.RAG.EmbeddingModels, auditLog)
+	adminHandler := handlers.NewAdminHandler(userRepo, botRepo, auditRepo)
+	webhookHandler := handlers.NewWebhookHandler(botRepo, webhookRepo, cfg.Webhooks.AllowPrivate)
+
+	// Best effort: the parser may still be starting, so this only warns
+	go checkParserFormats(serviceClient, cfg.Services.DocParserURL)
 
 	// Create Fiber app with optimizations for high load
 	app := fiber.New(fiber.Config{
 		AppName:                      "backend-gateway",
-		Prefork:                      false,            // Disabled in Docker
-		BodyLimit:                    50 * 1024 * 1024, // 50MB
+		DisableStartupMessage:        logCfg.Format == logging.FormatJSON, // The banner isn't a log line
+		ErrorHandler:                 apierror.ErrorHandler,
+		Prefork:                      false, // Disabled in Docker
+		BodyLimit:                    cfg.Upload.BodyLimit(),
 		ReadTimeout:                  cfg.HTTPClient.Timeout,
 		WriteTimeout:                 cfg.HTTPClient.Timeout,
 		IdleTimeout:                  120 * time.Second,
@@ -109,59 +172,111 @@ func main() {
 	app.Use(recover.New(recover.Config{
 		EnableStackTrace: true,
 	}))
-	app.Use(logger.New(logger.Config{
-		Format: "[${time}] ${status} - ${method} ${path} (${latency})\n",
-	}))
+	app.Use(logging.Middleware())
 
 	// Rate limiting for API protection
 	app.Use(limiter.New(limiter.Config{
+		// Messenger platforms deliver updates for all bots from a few shared IPs; those
+		// requests are authenticated by per-bot secrets instead of being limited by IP
+		Next: func(c *fiber.Ctx) bool {
+			return strings.HasPrefix(c.Path(), "/api/v1/integrations/")
+		},
 		Max:        100,
 		Expiration: 1 * time.Minute,
 		KeyGenerator: func(c *fiber.Ctx) string {
 			return c.IP()
 		},
 		LimitReached: func(c *fiber.Ctx) error {
-			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
-				"error": "rate limit exceeded",
-			})
+			return apierror.Send(c, fiber.StatusTooManyRequests, apierror.CodeRateLimited, "rate limit exceeded")
 		},
 	}))
 
 	app.Use(cors.New(cors.Config{
-		AllowOrigins:     "*",
-		AllowMethods:     "GET,POST,PUT,DELETE,OPTIONS",
-		AllowHeaders:     "Origin,Content-Type,Accept,Authorization",
+		AllowOrigins:     strings.Join(cfg.CORS.AllowOrigins, ","),
+		AllowMethods:     cfg.CORS.AllowMethods,
+		AllowHeaders:     cfg.CORS.AllowHeaders,
 		AllowCredentials: false,
 	}))
 
+	// The server-wide BodyLimit admits uploads; everything else takes small JSON bodies.
+	// Registered after CORS, so browsers can read the 413 instead of seeing a CORS failure.
+	app.Use(bodylimit.New(cfg.Server.JSONBodyLimit, isUploadRoute))
+
 	// Public routes (no authentication required)
 	app.Get("/health", h.Health)
 	app.Post("/api/v1/auth/register", authHandler.Register)
 	app.Post("/api/v1/auth/login", authHandler.Login)
-	app.Get("/api/v1/config/defaults", h.GetDefaults)
 
-	// Public bot routes (for chat access)
-	app.Get("/api/v1/bots/:id", botHandler.GetBot)
-	app.Post("/api/v1/chat/public/:bot_id", h.PublicRAGChat) // Public chat endpoint
+	// Public bot routes (for chat access); a token, if present, identifies the owner of private bots
+	optionalAuth := auth.OptionalMiddleware(jwtService)
+	// Global defaults for the create form; with ?bot_id= the token identifies the bot's owner
+	app.Get("/api/v1/config/defaults", optionalAuth, h.GetDefaults)
+	// Registered before /bots/:id, which would otherwise treat "trash" as a bot id
+	app.Get("/api/v1/bots/trash", auth.Middleware(jwtService), h.ListTrash)
+	app.Get("/api/v1/bots/:id", optionalAuth, botHandler.GetBot)
+	app.Get("/api/v1/bots/:id/widget-config", optionalAuth, botHandler.GetWidgetConfig)
+	app.Get("/api/v1/bots/:id/greeting", optionalAuth, botHandler.GetGreeting)
+	app.Get("/api/v1/bots/:id/documents/public", optionalAuth, botHandler.GetPublicDocuments)
+	app.Post("/api/v1/chat/public/:bot_id", optionalAuth, h.PublicRAGChat) // Public chat endpoint
+
+	// Messenger webhooks (verified by per-bot secrets, not JWT)
+	app.Post("/api/v1/integrations/telegram/:bot_id", h.TelegramWebhook)
+	app.Post("/api/v1/integrations/slack/:bot_id", h.SlackWebhook)
 
 	// Protected routes (require authentication)
 	protected := app.Group("/api/v1", auth.Middleware(jwtService))
 
 	// Auth
 	protected.Get("/auth/me", authHandler.Me)
+	protected.Get("/quota", h.GetQuota)
+	protected.Get("/me/summary", h.GetAccountSummary)
+	protected.Post("/auth/change-password", authHandler.ChangePassword)
 
 	// Bot management (owner only)
 	protected.Post("/bots", botHandler.CreateBot)
 	protected.Get("/bots", botHandler.GetMyBots)
 	protected.Put("/bots/:id", botHandler.UpdateBot)
 	protected.Delete("/bots/:id", botHandler.DeleteBot)
+	protected.Post("/bots/batch-delete", botHandler.BatchDeleteBots)
+	protected.Post("/bots/:id/restore", h.RestoreBot)
 	protected.Get("/bots/:id/documents", botHandler.GetBotDocuments)
+	protected.Get("/bots/:id/documents/search", botHandler.SearchBotDocuments)
+	protected.Get("/bots/:id/export", h.ExportBot)
+	protected.Post("/bots/import", h.ImportBot)
+
+	// Messenger integrations (owner only)
+	protected.Put("/bots/:id/integrations/telegram", h.ConfigureTelegram)
+	protected.Delete("/bots/:id/integrations/telegram", h.DeleteTelegram)
+	protected.Put("/bots/:id/integrations/slack", h.ConfigureSlack)
+	protected.Delete("/bots/:id/integrations/slack", h.DeleteSlack)
+
+	// Webhooks (owner only)
+	protected.Get("/bots/:id/webhooks", webhookHandler.ListWebhooks)
+	protected.Post("/bots/:id/webhooks", webhookHandler.CreateWebhook)
+	protected.Delete("/bots/:id/webhooks/:webhook_id", webhookHandler.DeleteWebhook)
 
 	// Document upload (owner only)
 	protected.Post("/bots/:id/documents/upload", h.UploadDocumentForBot)
+	protected.Post("/bots/:id/documents/preview", h.PreviewDocumentChunks)
+	protected.Post("/bots/:id/documents/crawl", h.CrawlDocuments)
+
+	// Diagnostics (owner only)
+	protected.Get("/diag/advanced-search/:bot_id", h.DiagAdvancedSearch)
+	protected.Post("/bots/:id/evaluate", h.EvaluateBot)
 
 	// RAG chat (owner or with bot_id)
 	protected.Post("/chat/rag", h.RAGChat) // Legacy support
+	protected.Post("/chat/multi", h.MultiBotChat)
+
+	// Admin routes (role from JWT claims)
+	admin := protected.Group("/admin", auth.AdminMiddleware())
+	admin.Get("/bots", adminHandler.ListBots)
+	admin.Get("/users", adminHandler.ListUsers)
+	admin.Put("/users/:id/quota", adminHandler.SetUserQuota)
+	admin.Post("/bots/:id/deactivate", adminHandler.DeactivateBot)
+	admin.Get("/audit", adminHandler.ListAudit)
+	admin.Post("/reembed/:bot_id", h.ReembedBot)
+	admin.Get("/reembed/:bot_id", h.GetReembedStatus)
 
 	// Graceful shutdown setup
 	quit := make(chan os.Signal, 1)
@@ -170,18 +285,75 @@ func main() {
 	go func() {
 		<-quit
 		log.Println("Gracefully shutting down server...")
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.Stream.DrainTimeout+30*time.Second)
 		defer cancel()
+		// Chat streams are drained first, while their connections are still served
+		h.DrainStreams(ctx, cfg.Stream.DrainTimeout)
 		if err := app.ShutdownWithContext(ctx); err != nil {
 			log.Printf("Server shutdown error: %v", err)
 		}
 	}()
 
 	// Start server
-	log.Printf("🚀 Backend gateway starting on port %s (CPUs: %d)", cfg.Server.Port, runtime.NumCPU())
+	slog.Info("Backend gateway starting",
+		"port", cfg.Server.Port,
+		"cpus", runtime.NumCPU(),
+		"cors_origins", strings.Join(cfg.CORS.AllowOrigins, ","),
+		"max_upload", utils.FormatBytes(cfg.Upload.MaxBytes),
+	)
 	if err := app.Listen(":" + cfg.Server.Port); err != nil {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 
+	// Let in-flight webhook deliveries finish and queued audit entries be written before exiting
+	stopCtx, stopCancel := context.WithTimeout(context.Background(), cfg.Webhooks.Timeout)
+	defer stopCancel()
+	dispatcher.Stop(stopCtx)
+	auditLog.Stop(stopCtx)
+
 	log.Println("Server stopped gracefully")
 }
+
+// isUploadRoute reports whether the request goes to a route whose body may be as large as an upload:
+// document upload and preview (multipart files) and bot import (bundles with chunks and embeddings)
+func isUploadRoute(c *fiber.Ctx) bool {
+	path := c.Path()
+	if path == "/api/v1/bots/import" {
+		return true
+	}
+	return strings.HasPrefix(path, "/api/v1/bots/") &&
+		(strings.HasSuffix(path, "/documents/upload") || strings.HasSuffix(path, "/documents/preview"))
+}
+
+// checkParserFormats warns when the gateway accepts upload extensions the document parser cannot handle
+func checkParserFormats(client *clients.Client, docParserURL string) {
+	formats, err := client.GetSupportedFormats(context.Background(), docParserURL)
+	if err != nil {
+		log.Printf("⚠️  Could not fetch document parser formats: %v", err)
+		return
+	}
+	supported := make(map[string]bool, len(formats))
+	for _, format := range formats {
+		supported[strings.ToLower(format)] = true
+	}
+	for _, ext := range utils.AllowedUploadExtensions() {
+		if !supported[ext] {
+			log.Printf("⚠️  Upload extension %s is accepted by the gateway but not supported by the document parser", ext)
+		}
+	}
+}
+
+// connectDB connects to DATABASE_URL or exits
+func connectDB() *database.DB {
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		log.Fatal("DATABASE_URL environment variable is required")
+	}
+
+	db, err := database.NewDB(databaseURL)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	log.Println("✓ Database connected")
+	return db
+}
@@ -1,17 +1,103 @@
 package models
 
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// JSON parse modes, sent to the document parser as the json_mode form field
+const (
+	JSONModePretty  = "pretty"  // Indented JSON (parser default)
+	JSONModeFlatten = "flatten" // One "key.path: value" line per leaf
+)
+
+// Upload chunk strategies, chosen with the chunk_strategy form field
+const (
+	ChunkStrategySemantic = "semantic" // Character-sized chunks from the AI service splitter (default)
+	ChunkStrategySections = "sections" // Markdown: one chunk per heading section, prefixed with its heading path
+)
+
+// ParseOptions are per-document parser settings chosen by the uploader
+type ParseOptions struct {
+	JSONMode  string // "" (parser default), JSONModePretty or JSONModeFlatten
+	CSVHeader bool   // Treat the first CSV row as column names ("Name: Chair | Price: 10" rows)
+
+	MarkdownSections bool // Also return markdown split by headings (ParseResponse.Sections)
+	DetectLanguage   bool // Also return the dominant language of the text (ParseResponse.Language)
+}
+
+// DocumentSection is a markdown section returned by the parser: its heading path ("Install > Docker") and body
+type DocumentSection struct {
+	Heading string `json:"heading,omitempty"` // Empty for text before the first heading
+	Text    string `json:"text"`
+}
+
 // ParseResponse represents the response from the document parser service
 type ParseResponse struct {
-	Text     string `json:"text"`
-	FileName string `json:"file_name"`
-	FileType string `json:"file_type"`
-	Size     int64  `json:"size"`
+	Text           string `json:"text"`
+	FileName       string `json:"file_name"`
+	FileType       string `json:"file_type"`
+	Size           int64  `json:"size"`
+	PagesParsed    int    `json:"pages_parsed,omitempty"` // Pages with extractable text (paged formats only)
+	PagesTotal     int    `json:"pages_total,omitempty"`
+	PagesTruncated int    `json:"pages_truncated,omitempty"` // Pages beyond the parser's MAX_PDF_PAGES, not parsed
+
+	Sections []DocumentSection `json:"sections,omitempty"` // Only when ParseOptions.MarkdownSections was set
+	Recovery *ArchiveRecovery  `json:"recovery,omitempty"` // Set when text was salvaged from a damaged DOCX or XLSX
+	Language string            `json:"language,omitempty"` // ISO 639-1 code; only with DetectLanguage, empty when undetermined
+}
+
+// ArchiveRecovery reports what the parser could read from a damaged ZIP-based document
+type ArchiveRecovery struct {
+	DirectoryLost  bool     `json:"directory_lost,omitempty"` // Entries were found by scanning, the archive index was unreadable
+	EntriesTotal   int      `json:"entries_total"`
+	DamagedEntries []string `json:"damaged_entries,omitempty"` // Read only partly or skipped
+}
+
+// SkippedPages returns how many of the parsed pages had no extractable text (0 for formats without pages)
+func (r *ParseResponse) SkippedPages() int {
+	if r.PagesExamined() <= r.PagesParsed {
+		return 0
+	}
+	return r.PagesExamined() - r.PagesParsed
+}
+
+// PagesExamined returns how many pages the parser went through, i.e. all but the truncated ones
+func (r *ParseResponse) PagesExamined() int {
+	return r.PagesTotal - r.PagesTruncated
+}
+
+// ExtractionWarning describes a partial extraction, or returns "" when every page had text
+// and the file was not damaged
+func (r *ParseResponse) ExtractionWarning() string {
+	var warnings []string
+	if r.PagesTruncated > 0 {
+		warnings = append(warnings, fmt.Sprintf("the document was truncated: only the first %d of %d pages were parsed", r.PagesExamined(), r.PagesTotal))
+	}
+	if skipped := r.SkippedPages(); skipped > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d of %d pages had no extractable text", skipped, r.PagesExamined()))
+	}
+	if rec := r.Recovery; rec != nil {
+		warning := "the file is damaged and its text may be incomplete"
+		switch {
+		case len(rec.DamagedEntries) > 0:
+			warning += fmt.Sprintf(": %d of %d parts could not be fully read (%s)",
+				len(rec.DamagedEntries), rec.EntriesTotal, strings.Join(rec.DamagedEntries, ", "))
+		case rec.DirectoryLost:
+			warning += fmt.Sprintf(": its archive index was unreadable, %d parts were recovered", rec.EntriesTotal)
+		}
+		warnings = append(warnings, warning)
+	}
+	return strings.Join(warnings, "; ")
 }
 
 // EmbeddingsRequest represents a request for text embeddings
 type EmbeddingsRequest struct {
-	Texts   []string `json:"texts"`
-	IsQuery bool     `json:"is_query"`
+	Texts     []string `json:"texts"`
+	IsQuery   bool     `json:"is_query"`
+	Model     string   `json:"model,omitempty"`      // Bot's embedding model; empty = the AI service default
+	UsePrefix *bool    `json:"use_prefix,omitempty"` // Query/passage instruction prefix; nil = by model name (e5)
 }
 
 // EmbeddingsResponse represents the response containing embeddings
@@ -26,12 +112,22 @@ type SplitDocumentRequest struct {
 	Overlap   int    `json:"overlap"`
 }
 
+// RewriteQueryRequest asks the AI service to rewrite a search query before it is embedded
+type RewriteQueryRequest struct {
+	Query string `json:"query"`
+}
+
+// RewriteQueryResponse carries the rewritten query
+type RewriteQueryResponse struct {
+	Query string `json:"query"`
+}
+
 // SplitDocumentResponse represents a response with semantic chunks
 type SplitDocumentResponse struct {
 	Chunks      []string `json:"chunks"`
 	NumChunks   int      `json:"num_chunks"`
-	TotalChars  int      `json:"total_chars"`
-	AvgChunkLen int      `json:"avg_chunk_size"`
+	TotalChars  int      `json:"total_chars"`    // Characters of the split text
+	AvgChunkLen int      `json:"avg_chunk_size"` // TotalChars / NumChunks
 }
 
 // GenerateRequest represents a request for text generation
@@ -56,26 +152,128 @@ type VectorAddRequest struct {
 	Texts      []string            `json:"texts"`
 	Embeddings [][]float32         `json:"embeddings"`
 	Metadata   []map[string]string `json:"metadata"`
+
+	EmbeddingModel string `json:"embedding_model,omitempty"`
+}
+
+// VectorStagingRequest creates a staging collection for re-embedded documents
+type VectorStagingRequest struct {
+	Dimension int `json:"dimension"`
+}
+
+// VectorStagingResponse represents a staging collection creation response
+type VectorStagingResponse struct {
+	Success bool              `json:"success"`
+	Data    VectorStagingData `json:"data"`
+	Error   string            `json:"error,omitempty"`
+}
+
+// VectorStagingData names the created staging collection
+type VectorStagingData struct {
+	Collection string `json:"collection"`
+}
+
+// VectorStagingDocumentsRequest adds re-embedded documents to a staging collection under their current ids
+type VectorStagingDocumentsRequest struct {
+	IDs        []string            `json:"ids"`
+	Texts      []string            `json:"texts"`
+	Embeddings [][]float32         `json:"embeddings"`
+	Metadata   []map[string]string `json:"metadata"`
+
+	EmbeddingModel string `json:"embedding_model,omitempty"`
+}
+
+// VectorPromoteRequest swaps a staging collection in as the bot's collection
+type VectorPromoteRequest struct {
+	EmbeddingModel string `json:"embedding_model,omitempty"`
 }
 
 // VectorSearchRequest represents a vector search request
 type VectorSearchRequest struct {
-	BotID          string    `json:"bot_id"`
-	QueryEmbedding []float32 `json:"query_embedding"`
-	Limit          int       `json:"limit"`
+	BotID          string     `json:"bot_id"`
+	QueryEmbedding []float32  `json:"query_embedding"`
+	Limit          int        `json:"limit"`
+	Fields         []string   `json:"fields,omitempty"` // Payload keys to return besides text; empty = all
+	EmbeddingModel string     `json:"embedding_model,omitempty"`
+	FallbackLimit  int        `json:"fallback_limit"` // Always 0: the backend runs its own fallback (RAG_FALLBACK_LIMIT)
+	UploadedAfter  *time.Time `json:"uploaded_after,omitempty"`
+	UploadedBefore *time.Time `json:"uploaded_before,omitempty"`
+}
+
+// UploadRange restricts retrieval to documents uploaded in [After, Before); a nil bound is open
+type UploadRange struct {
+	After  *time.Time
+	Before *time.Time
+}
+
+// IsZero reports whether the range has no bounds
+func (r UploadRange) IsZero() bool {
+	return r.After == nil && r.Before == nil
+}
+
+// Document is a retrieved chunk as the vector service returns it and the AI service reranks it:
+// the point id, text, similarity score (0 for listed chunks) and the other payload keys in Metadata.
+// Source is the chunk's page URL or file name.
+type Document struct {
+	ID           string            `json:"id"`
+	Text         string            `json:"text"`
+	Score        float64           `json:"score"`
+	Source       string            `json:"source,omitempty"`
+	Metadata     map[string]string `json:"metadata"`
+	FromFallback bool              `json:"from_fallback,omitempty"` // Listed in place of search results that found nothing
+	RerankScore  *float64          `json:"rerank_score,omitempty"`  // Cross-encoder score, set by the AI service
+}
+
+// AdvancedSearchResponse represents the reranked documents and the context built from them by the AI service
+type AdvancedSearchResponse struct {
+	Results           []Document `json:"results"`
+	CompressedContext string     `json:"compressed_context"`
+	NumResults        int        `json:"num_results"`
 }
 
 // VectorSearchResponse represents the response from vector search
 type VectorSearchResponse struct {
-	Success bool           `json:"success"`
-	Data    map[string]any `json:"data"`
-	Error   string         `json:"error"`
+	Success bool             `json:"success"`
+	Data    VectorSearchData `json:"data"`
+	Error   string           `json:"error"`
 }
 
+// VectorSearchData holds the found documents
+type VectorSearchData struct {
+	Documents []Document `json:"documents"`
+	Count     int        `json:"count"`
+}
+
+// VectorListResponse represents one page of documents listed from a bot collection
 type VectorListResponse struct {
-	Success   bool             `json:"success"`
-	Error     string           `json:"error,omitempty"`
-	Documents []map[string]any `json:"documents,omitempty"`
+	Success bool           `json:"success"`
+	Data    VectorListData `json:"data"`
+	Error   string         `json:"error,omitempty"`
+}
+
+// VectorListData holds a page of documents and the cursor of the next one (empty after the last page)
+type VectorListData struct {
+	Documents  []Document `json:"documents"`
+	Count      int        `json:"count"`
+	NextCursor string     `json:"next_cursor"`
+}
+
+// VectorStatsResponse represents chunk statistics for a bot collection
+type VectorStatsResponse struct {
+	Success        bool   `json:"success"`
+	Error          string `json:"error,omitempty"`
+	TotalDocuments int    `json:"total_documents"`
+}
+
+// ExportChunk represents an indexed chunk in a bot export bundle
+type ExportChunk struct {
+	Text     string            `json:"text"`
+	Metadata map[string]string `json:"metadata"`
+}
+
+// FormatsResponse represents the document parser's supported formats
+type FormatsResponse struct {
+	Formats []string `json:"formats"`
 }
 
 // UploadRequest represents a document upload request
@@ -85,23 +283,40 @@ type UploadRequest struct {
 
 // SearchRequest represents a document search request
 type SearchRequest struct {
-	ClientID string `json:"client_id" validate:"required"`
-	Query    string `json:"query" validate:"required"`
-	Limit    int    `json:"limit" validate:"omitempty,gte=1,lte=100"`
+	ClientID string `json:"client_id" validate:"required,max=255"`
+	Query    string `json:"query" validate:"required,max=10000"`
+	Limit    int    `json:"limit" validate:"omitempty,gte=1,lte=500"` // lte = config.MaxSearchLimit
 }
 
+// MaxNewTokensLimit is the largest max_new_tokens a chat request may ask the AI service for
+const MaxNewTokensLimit = 8192
+
 // RAGChatRequest represents a RAG chat request with model parameters
 type RAGChatRequest struct {
-	ClientID     string  `json:"client_id" validate:"required"`
-	Query        string  `json:"query" validate:"required"`
+	ClientID     string  `json:"client_id" validate:"required,max=255"`
+	Query        string  `json:"query" validate:"required,max=10000"`
 	Message      string  `json:"message"` // Alternative field name for query
-	Limit        int     `json:"limit" validate:"omitempty,gte=1,lte=100"`
+	Limit        int     `json:"limit" validate:"omitempty,gte=1,lte=500"`
 	Temperature  float64 `json:"temperature" validate:"omitempty,gte=0,lte=2"`
 	TopP         float64 `json:"top_p" validate:"omitempty,gte=0,lte=1"`
 	TopK         int     `json:"top_k" validate:"omitempty,gte=1,lte=200"`
-	MaxNewTokens int     `json:"max_new_tokens" validate:"omitempty,gte=1,lte=4096"`
+	MaxNewTokens int     `json:"max_new_tokens" validate:"omitempty,gte=1,lte=8192"` // lte = MaxNewTokensLimit
 	DoSample     bool    `json:"do_sample"`
 	SystemPrompt string  `json:"system_prompt" validate:"omitempty,max=2000"`
+	Highlight    bool    `json:"highlight"` // Return query keyword positions for each document
+	Citations    bool    `json:"citations"` // Ask the model to cite documents by id
+
+	// Answer only from documents uploaded in [uploaded_after, uploaded_before) (RFC3339); either bound may be omitted
+	UploadedAfter  *time.Time `json:"uploaded_after,omitempty"`
+	UploadedBefore *time.Time `json:"uploaded_before,omitempty"`
+
+	// PromptVars holds server-side values of system prompt template variables (see utils.RenderPrompt)
+	PromptVars map[string]string `json:"-"`
+}
+
+// UploadRange returns the upload date range retrieval is restricted to
+func (r *RAGChatRequest) UploadRange() UploadRange {
+	return UploadRange{After: r.UploadedAfter, Before: r.UploadedBefore}
 }
 
 // GenerationDefaults holds default generation parameters
@@ -0,0 +1,54 @@
+package netguard
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"syscall"
+)
+
+// IsPublic reports whether ip is a public unicast address: not loopback, private, link-local, multicast or unspecified
+func IsPublic(ip net.IP) bool {
+	return ip != nil && !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsUnspecified() && !ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast() && !ip.IsInterfaceLocalMulticast() && !ip.IsMulticast()
+}
+
+// PublicAddressesOnly is a net.Dialer Control function that refuses connections to non-public addresses.
+// It checks the address actually dialed, so DNS answers that change after a URL was checked are caught too.
+func PublicAddressesOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if !IsPublic(net.ParseIP(host)) {
+		return fmt.Errorf("refusing to connect to non-public address %s", host)
+	}
+	return nil
+}
+
+// CheckPublicURL resolves the host of an http(s) URL and returns an error unless all of its addresses are public
+func CheckPublicURL(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Hostname() == "" {
+		return fmt.Errorf("invalid URL %q", rawURL)
+	}
+	host := u.Hostname()
+	if ip := net.ParseIP(host); ip != nil {
+		if !IsPublic(ip) {
+			return fmt.Errorf("%s is not a public address", host)
+		}
+		return nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return fmt.Errorf("cannot resolve %s: %w", host, err)
+	}
+	for _, addr := range addrs {
+		if !IsPublic(addr.IP) {
+			return fmt.Errorf("%s resolves to non-public address %s", host, addr.IP)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+)
+
+// DefaultModelArtifacts are control tokens and prompt switches that models leak into their output
+var DefaultModelArtifacts = []string{"/no_think", "<|im_start|>", "<|im_end|>", "<|endoftext|>", "<|eot_id|>", "</s>"}
+
+const (
+	thinkOpen  = "<think>"
+	thinkClose = "</think>"
+)
+
+// ArtifactFilter removes model artifacts and <think>...</think> blocks from a streamed answer.
+// Tokens are pushed as they arrive; text that could be the start of an artifact split across tokens
+// is held back until the next token (or Flush) shows what it is. Leading whitespace of the answer is dropped,
+// so the blank lines that usually follow an empty think block don't reach the client.
+type ArtifactFilter struct {
+	artifacts []string
+	pending   string
+	inThink   bool
+	started   bool // Some text has been emitted
+}
+
+// NewArtifactFilter creates a filter for one answer. Think blocks are always removed; artifacts are removed verbatim.
+func NewArtifactFilter(artifacts []string) *ArtifactFilter {
+	patterns := []string{thinkOpen, thinkClose}
+	for _, artifact := range artifacts {
+		if artifact != "" {
+			patterns = append(patterns, artifact)
+		}
+	}
+	return &ArtifactFilter{artifacts: patterns}
+}
+
+// Push adds a token and returns the text that is safe to emit (possibly empty)
+func (f *ArtifactFilter) Push(token string) string {
+	buf := f.pending + token
+	var out strings.Builder
+	for {
+		if f.inThink {
+			end := strings.Index(buf, thinkClose)
+			if end < 0 {
+				// Everything inside the block is dropped; only a possible partial "</think>" is kept
+				f.pending = buf[len(buf)-partialSuffix(buf, []string{thinkClose}):]
+				return f.emit(out.String())
+			}
+			buf = buf[end+len(thinkClose):]
+			f.inThink = false
+			continue
+		}
+
+		pos, match := f.firstArtifact(buf)
+		if pos < 0 {
+			keep := partialSuffix(buf, f.artifacts)
+			out.WriteString(buf[:len(buf)-keep])
+			f.pending = buf[len(buf)-keep:]
+			return f.emit(out.String())
+		}
+		out.WriteString(buf[:pos])
+		buf = buf[pos+len(match):]
+		if match == thinkOpen {
+			f.inThink = true
+		}
+	}
+}
+
+// Flush returns the text held back at the end of the answer. An unterminated think block is dropped.
+func (f *ArtifactFilter) Flush() string {
+	rest := f.pending
+	f.pending = ""
+	if f.inThink {
+		return ""
+	}
+	return f.emit(rest)
+}
+
+// emit drops leading whitespace until the first visible text of the answer
+func (f *ArtifactFilter) emit(text string) string {
+	if !f.started {
+		text = strings.TrimLeftFunc(text, unicode.IsSpace)
+		f.started = text != ""
+	}
+	return text
+}
+
+// firstArtifact returns the position and value of the earliest artifact in s, or -1
+func (f *ArtifactFilter) firstArtifact(s string) (int, string) {
+	pos, match := -1, ""
+	for _, artifact := range f.artifacts {
+		if i := strings.Index(s, artifact); i >= 0 && (pos < 0 || i < pos || (i == pos && len(artifact) > len(match))) {
+			pos, match = i, artifact
+		}
+	}
+	return pos, match
+}
+
+// partialSuffix returns the length of the longest suffix of s that is a proper prefix of one of the patterns
+func partialSuffix(s string, patterns []string) int {
+	longest := 0
+	for _, pattern := range patterns {
+		for n := len(pattern) - 1; n > longest; n-- {
+			if strings.HasSuffix(s, pattern[:n]) {
+				longest = n
+				break
+			}
+		}
+	}
+	return longest
+}
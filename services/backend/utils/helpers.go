@@ -1,8 +1,12 @@
 package utils
 
 import (
+	"backend/models"
 	"fmt"
+	"sort"
 	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
 )
 
 // ChunkText splits text into chunks with overlap, optimized for semantic search
@@ -63,9 +67,39 @@ func ChunkText(text string, size, overlap int) []string {
 	return chunks
 }
 
+// ChunkSections turns markdown sections into chunks that start with their heading path, so each chunk
+// says which part of the document it comes from. A section that fits in size is one chunk, however short;
+// longer sections are split with ChunkText and every piece gets the heading.
+func ChunkSections(sections []models.DocumentSection, size, overlap int) []string {
+	var chunks []string
+	for _, section := range sections {
+		body := strings.TrimSpace(section.Text)
+		if body == "" {
+			continue
+		}
+		prefix := ""
+		if section.Heading != "" {
+			prefix = section.Heading + "\n\n"
+		}
+		if size <= 0 || len(prefix)+len(body) <= size {
+			chunks = append(chunks, prefix+body)
+			continue
+		}
+		// The heading takes part of every piece, but never more than half of it
+		bodySize := size - len(prefix)
+		if bodySize < size/2 {
+			bodySize = size / 2
+		}
+		for _, piece := range ChunkText(body, bodySize, overlap) {
+			chunks = append(chunks, prefix+piece)
+		}
+	}
+	return chunks
+}
+
 // ExtractRelevantTexts returns trimmed snippets for each document.
 // It attempts to center the snippet around query keywords so we don't always take the start of the doc.
-func ExtractRelevantTexts(docs []map[string]any, query string, maxChars int, window int) []string {
+func ExtractRelevantTexts(docs []models.Document, query string, maxChars int, window int) []string {
 	out := make([]string, 0, len(docs))
 
 	if maxChars <= 0 {
@@ -79,15 +113,7 @@ func ExtractRelevantTexts(docs []map[string]any, query string, maxChars int, win
 	keywords := filterKeywords(queryLower)
 
 	for _, d := range docs {
-		txtRaw, ok := d["text"]
-		if !ok {
-			continue
-		}
-		text, ok := txtRaw.(string)
-		if !ok {
-			continue
-		}
-		text = strings.TrimSpace(text)
+		text := strings.TrimSpace(d.Text)
 		if text == "" {
 			continue
 		}
@@ -153,7 +179,77 @@ func findFirstKeyword(text string, keywords []string) int {
 	return best
 }
 
-// BuildContext creates a formatted context string from documents
+// KeywordMatch marks an occurrence of a query keyword in a document.
+// Offsets are in characters (runes), end exclusive, so clients can slice the text directly.
+type KeywordMatch struct {
+	Start   int    `json:"start"`
+	End     int    `json:"end"`
+	Keyword string `json:"keyword"`
+}
+
+// FindKeywordMatches returns non-overlapping, case-insensitive occurrences of the query keywords in text,
+// ordered by position. Keywords are selected the same way as for snippet extraction.
+func FindKeywordMatches(text, query string) []KeywordMatch {
+	keywords := filterKeywords(strings.ToLower(query))
+	if len(keywords) == 0 || text == "" {
+		return nil
+	}
+	lowered := strings.ToLower(text)
+
+	type span struct{ start, end int }
+	var spans []span
+	for _, kw := range keywords {
+		for offset := 0; offset < len(lowered); {
+			idx := strings.Index(lowered[offset:], kw)
+			if idx == -1 {
+				break
+			}
+			start := offset + idx
+			spans = append(spans, span{start, start + len(kw)})
+			offset = start + len(kw)
+		}
+	}
+	if len(spans) == 0 {
+		return nil
+	}
+
+	// Earlier first; on ties the longer keyword wins
+	sort.Slice(spans, func(i, j int) bool {
+		if spans[i].start != spans[j].start {
+			return spans[i].start < spans[j].start
+		}
+		return spans[i].end > spans[j].end
+	})
+
+	matches := make([]KeywordMatch, 0, len(spans))
+	lastEnd, runePos, bytePos := -1, 0, 0
+	for _, sp := range spans {
+		if sp.start < lastEnd {
+			continue
+		}
+		// Convert byte offsets to rune offsets incrementally
+		runePos += utf8.RuneCountInString(lowered[bytePos:sp.start])
+		start := runePos
+		runePos += utf8.RuneCountInString(lowered[sp.start:sp.end])
+		bytePos = sp.end
+		matches = append(matches, KeywordMatch{
+			Start:   start,
+			End:     runePos,
+			Keyword: lowered[sp.start:sp.end],
+		})
+		lastEnd = sp.end
+	}
+	return matches
+}
+
+// CitationInstruction asks the model to reference context documents by the ids assigned in BuildContext
+const CitationInstruction = "When you use information from the context, cite the supporting documents by their ids in square brackets, e.g. [1] or [2][3]. Only cite ids that appear in the context."
+
+// NoContextInstruction keeps the model from guessing when retrieval found nothing relevant
+const NoContextInstruction = "The knowledge base has no relevant information for this question. Tell the user you don't have information on that instead of guessing, and do not make up an answer."
+
+// BuildContext creates a formatted context string from documents.
+// Each document is tagged with a stable 1-based id ("[1]", "[2]", ...) matching its position in docs.
 func BuildContext(docs []string) string {
 	if len(docs) == 0 {
 		return ""
@@ -161,7 +257,7 @@ func BuildContext(docs []string) string {
 
 	parts := make([]string, len(docs))
 	for i, d := range docs {
-		parts[i] = fmt.Sprintf("Document %d:\n%s", i+1, d)
+		parts[i] = fmt.Sprintf("Document [%d]:\n%s", i+1, d)
 	}
 
 	return strings.Join(parts, "\n\n")
@@ -178,6 +274,77 @@ func SanitizeInput(input string) string {
 	return input
 }
 
+// SplitMessage splits text into parts of at most maxRunes runes for messengers with a message size limit.
+// It prefers paragraph, then line, then word boundaries and only cuts words that are longer than a part.
+func SplitMessage(text string, maxRunes int) []string {
+	return splitMessage(text, maxRunes, func(rune) int { return 1 })
+}
+
+// SplitMessageUTF16 is SplitMessage for messengers that measure messages in UTF-16 code units,
+// like Telegram: characters outside the Basic Multilingual Plane, such as most emoji, count twice.
+func SplitMessageUTF16(text string, maxUnits int) []string {
+	return splitMessage(text, maxUnits, utf16.RuneLen)
+}
+
+// splitMessage splits text into parts whose runes add up to at most maxWidth by the width function
+func splitMessage(text string, maxWidth int, width func(rune) int) []string {
+	runes := []rune(strings.TrimSpace(text))
+	var parts []string
+	for {
+		fit, used := 0, 0
+		for fit < len(runes) && used+width(runes[fit]) <= maxWidth {
+			used += width(runes[fit])
+			fit++
+		}
+		if fit == len(runes) {
+			break
+		}
+		fit = max(fit, 1)
+		cut := lastBreak(runes[:fit], fit/2)
+		if part := strings.TrimSpace(string(runes[:cut])); part != "" {
+			parts = append(parts, part)
+		}
+		runes = []rune(strings.TrimLeft(string(runes[cut:]), " \n\t"))
+	}
+	if len(runes) > 0 {
+		parts = append(parts, string(runes))
+	}
+	return parts
+}
+
+// lastBreak returns the position after the last paragraph, line or word break in window,
+// ignoring breaks before minPos so parts do not get too short; len(window) if there is none
+func lastBreak(window []rune, minPos int) int {
+	for _, sep := range []string{"\n\n", "\n", " "} {
+		sepRunes := []rune(sep)
+		for i := len(window) - len(sepRunes); i >= minPos; i-- {
+			if string(window[i:i+len(sepRunes)]) == sep {
+				return i + len(sepRunes)
+			}
+		}
+	}
+	return len(window)
+}
+
+// FormatBytes renders a byte count in human-readable binary units (e.g. "50MB")
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	value := float64(n)
+	suffixes := []string{"KB", "MB", "GB", "TB"}
+	i := -1
+	for value >= unit && i < len(suffixes)-1 {
+		value /= unit
+		i++
+	}
+	if value == float64(int64(value)) {
+		return fmt.Sprintf("%d%s", int64(value), suffixes[i])
+	}
+	return fmt.Sprintf("%.1f%s", value, suffixes[i])
+}
+
 // ValidateClientID checks if client ID is valid
 func ValidateClientID(clientID string) error {
 	if clientID == "" {
@@ -208,3 +375,51 @@ func ValidateQuery(query string) error {
 
 	return nil
 }
+
+// charsPerToken is a conservative characters-per-token ratio for mixed Russian/English text.
+// Real tokenizers average 3-4 chars per token; underestimating the ratio keeps prompts inside the window.
+const charsPerToken = 3
+
+// contextDocOverheadTokens approximates the "Document [n]:" header and separators added by BuildContext
+const contextDocOverheadTokens = 6
+
+// EstimateTokens returns an approximate token count for text
+func EstimateTokens(text string) int {
+	runes := utf8.RuneCountInString(text)
+	return (runes + charsPerToken - 1) / charsPerToken
+}
+
+// TruncateToTokens cuts text so its estimated size does not exceed maxTokens (rune-safe)
+func TruncateToTokens(text string, maxTokens int) string {
+	if maxTokens <= 0 {
+		return ""
+	}
+	maxRunes := maxTokens * charsPerToken
+	if utf8.RuneCountInString(text) <= maxRunes {
+		return text
+	}
+	runes := []rune(text)
+	return string(runes[:maxRunes])
+}
+
+// FitToTokenBudget keeps documents in order (most relevant first) while they fit into the token budget.
+// If even the first document does not fit, it is truncated so some context is always kept.
+func FitToTokenBudget(docs []string, budget int) []string {
+	if budget <= 0 || len(docs) == 0 {
+		return docs
+	}
+	out := make([]string, 0, len(docs))
+	used := 0
+	for _, d := range docs {
+		cost := EstimateTokens(d) + contextDocOverheadTokens
+		if used+cost > budget {
+			if len(out) == 0 {
+				out = append(out, TruncateToTokens(d, budget-contextDocOverheadTokens))
+			}
+			break
+		}
+		out = append(out, d)
+		used += cost
+	}
+	return out
+}
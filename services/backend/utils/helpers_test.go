@@ -0,0 +1,25 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+func TestSplitMessageUTF16CountsEmojiTwice(t *testing.T) {
+	// 3000 emoji are 3000 runes but 6000 UTF-16 code units, over Telegram's 4096 limit
+	text := strings.Repeat("😀", 3000)
+
+	parts := SplitMessageUTF16(text, 4096)
+	if len(parts) != 2 {
+		t.Fatalf("got %d parts, want 2", len(parts))
+	}
+	for i, part := range parts {
+		if units := len(utf16.Encode([]rune(part))); units > 4096 {
+			t.Errorf("part %d has %d UTF-16 code units, want at most 4096", i, units)
+		}
+	}
+	if strings.Join(parts, "") != text {
+		t.Error("parts do not add up to the original text")
+	}
+}
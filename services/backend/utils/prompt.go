@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Variables available in system prompt templates
+const (
+	PromptVarBotName = "bot_name"
+	PromptVarDate    = "date"    // Current UTC date, YYYY-MM-DD
+	PromptVarLocale  = "locale"  // Preferred language of the user, e.g. "de-DE"; empty when unknown
+	PromptVarContext = "context" // Retrieved documents
+)
+
+// promptVarPattern matches "{{name}}" placeholders, spaces inside the braces allowed
+var promptVarPattern = regexp.MustCompile(`\{\{\s*([a-z_]+)\s*\}\}`)
+
+// localePattern is a BCP 47 language tag such as "en" or "pt-BR"
+var localePattern = regexp.MustCompile(`^[A-Za-z]{2,8}(-[A-Za-z0-9]{1,8})*$`)
+
+// RenderPrompt replaces the {{name}} placeholders of a system prompt template with vars[name].
+// Rendering is a single pass: inserted values (documents, user-controlled locale) are never rendered again,
+// and placeholders without a value are left untouched.
+func RenderPrompt(template string, vars map[string]string) string {
+	return promptVarPattern.ReplaceAllStringFunc(template, func(placeholder string) string {
+		name := promptVarPattern.FindStringSubmatch(placeholder)[1]
+		if value, ok := vars[name]; ok {
+			return value
+		}
+		return placeholder
+	})
+}
+
+// PromptUsesVariable reports whether a system prompt template has a {{name}} placeholder
+func PromptUsesVariable(template, name string) bool {
+	for _, match := range promptVarPattern.FindAllStringSubmatch(template, -1) {
+		if match[1] == name {
+			return true
+		}
+	}
+	return false
+}
+
+// PreferredLocale returns the first language tag of an Accept-Language header, or "" if it is missing or malformed
+func PreferredLocale(acceptLanguage string) string {
+	tag, _, _ := strings.Cut(acceptLanguage, ",")
+	tag, _, _ = strings.Cut(tag, ";")
+	tag = strings.TrimSpace(tag)
+	if len(tag) > 35 || !localePattern.MatchString(tag) {
+		return ""
+	}
+	return tag
+}
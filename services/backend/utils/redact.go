@@ -0,0 +1,140 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Built-in redaction rule names
+const (
+	RedactEmail      = "email"
+	RedactPhone      = "phone"
+	RedactCreditCard = "credit_card"
+	RedactCustom     = "custom"
+)
+
+// RedactionRule replaces matches of a pattern with a placeholder
+type RedactionRule struct {
+	Name        string
+	Pattern     *regexp.Regexp
+	Replacement string
+	Check       func(match string) bool // Optional extra check of a match, e.g. the Luhn checksum
+}
+
+// builtinRedactionRules are applied in this order: card numbers before phones, since both are digit runs
+var builtinRedactionRules = []RedactionRule{
+	{
+		Name:        RedactEmail,
+		Pattern:     regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`),
+		Replacement: "[EMAIL]",
+	},
+	{
+		Name:        RedactCreditCard,
+		Pattern:     regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`),
+		Replacement: "[CARD]",
+		Check:       luhnValid,
+	},
+	{
+		Name:        RedactPhone,
+		Pattern:     regexp.MustCompile(`\+?\d[\d\s().\-]{8,}\d`),
+		Replacement: "[PHONE]",
+		Check: func(match string) bool {
+			digits := countDigits(match)
+			return digits >= 10 && digits <= 15
+		},
+	},
+}
+
+// RedactionRuleNames returns the names of the built-in redaction rules
+func RedactionRuleNames() []string {
+	names := make([]string, 0, len(builtinRedactionRules))
+	for _, rule := range builtinRedactionRules {
+		names = append(names, rule.Name)
+	}
+	return names
+}
+
+// RedactionRules builds a ruleset from built-in rule names (all of them when names is empty)
+// and custom regular expressions, which are replaced with "[REDACTED]"
+func RedactionRules(names []string, customPatterns []string) ([]RedactionRule, error) {
+	selected := make(map[string]bool, len(names))
+	for _, name := range names {
+		selected[name] = true
+	}
+
+	rules := make([]RedactionRule, 0, len(builtinRedactionRules)+len(customPatterns))
+	for _, rule := range builtinRedactionRules {
+		if len(names) == 0 || selected[rule.Name] {
+			rules = append(rules, rule)
+		}
+	}
+	for _, pattern := range customPatterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %q: %w", pattern, err)
+		}
+		rules = append(rules, RedactionRule{Name: RedactCustom, Pattern: re, Replacement: "[REDACTED]"})
+	}
+	return rules, nil
+}
+
+// Redact replaces sensitive data matched by the rules and returns the redacted text
+// with the number of replacements per rule name (empty when nothing matched)
+func Redact(text string, rules []RedactionRule) (string, map[string]int) {
+	counts := make(map[string]int)
+	for _, rule := range rules {
+		text = rule.Pattern.ReplaceAllStringFunc(text, func(match string) string {
+			if rule.Check != nil && !rule.Check(match) {
+				return match
+			}
+			counts[rule.Name]++
+			return rule.Replacement
+		})
+	}
+	return text, counts
+}
+
+// FormatRedactionCounts formats redaction counts for logs, e.g. "email=2, phone=1"
+func FormatRedactionCounts(counts map[string]int) string {
+	parts := make([]string, 0, len(counts))
+	for name, count := range counts {
+		parts = append(parts, fmt.Sprintf("%s=%d", name, count))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ", ")
+}
+
+// countDigits returns the number of ASCII digits in s
+func countDigits(s string) int {
+	n := 0
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			n++
+		}
+	}
+	return n
+}
+
+// luhnValid reports whether the digits of s pass the Luhn checksum used by payment card numbers
+func luhnValid(s string) bool {
+	sum := 0
+	double := false
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// SniffLength is how many leading bytes http.DetectContentType inspects
+const SniffLength = 512
+
+// allowedUploadTypes maps every upload extension accepted by the gateway to the content types
+// http.DetectContentType may report for it. Entries ending in "/" match a whole family.
+// Keep in sync with the formats registered in document-parser-service (GET /formats).
+var allowedUploadTypes = map[string][]string{
+	".pdf":  {"application/pdf"},
+	".docx": {"application/zip"},
+	".odt":  {"application/zip"},
+	".epub": {"application/zip"},
+	".xlsx": {"application/zip"},
+	".rtf":  {"text/"},
+	".txt":  {"text/"},
+	".md":   {"text/"},
+	".csv":  {"text/"},
+	".json": {"text/"},
+	".html": {"text/"},
+	".htm":  {"text/"},
+}
+
+// AllowedUploadExtensions returns the accepted upload extensions in sorted order
+func AllowedUploadExtensions() []string {
+	exts := make([]string, 0, len(allowedUploadTypes))
+	for ext := range allowedUploadTypes {
+		exts = append(exts, ext)
+	}
+	sort.Strings(exts)
+	return exts
+}
+
+// UploadExtension returns the lower-cased extension of filename and whether uploads of it are accepted
+func UploadExtension(filename string) (string, bool) {
+	ext := strings.ToLower(filepath.Ext(filename))
+	_, ok := allowedUploadTypes[ext]
+	return ext, ok
+}
+
+// CheckUploadContent sniffs the leading bytes of a file and verifies they match the claimed extension
+func CheckUploadContent(ext string, head []byte) error {
+	expected, ok := allowedUploadTypes[ext]
+	if !ok {
+		return fmt.Errorf("unsupported file type %q", ext)
+	}
+
+	sniffed := http.DetectContentType(head)
+	mediaType := strings.TrimSpace(strings.SplitN(sniffed, ";", 2)[0])
+	for _, want := range expected {
+		if mediaType == want || (strings.HasSuffix(want, "/") && strings.HasPrefix(mediaType, want)) {
+			return nil
+		}
+	}
+	return fmt.Errorf("file content (%s) does not match extension %s", mediaType, ext)
+}
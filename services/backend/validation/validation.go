@@ -0,0 +1,146 @@
+package validation
+
+import (
+	"backend/apierror"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+// validate is shared across requests; validator caches struct metadata internally
+var validate = newValidator()
+
+// FieldError describes a single failed validation rule
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+// newValidator creates a validator that reports fields by their json (or form) names
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		for _, tag := range []string{"json", "form"} {
+			name := strings.SplitN(field.Tag.Get(tag), ",", 2)[0]
+			if name == "-" {
+				return ""
+			}
+			if name != "" {
+				return name
+			}
+		}
+		return field.Name
+	})
+	v.RegisterValidation("regexp", func(fl validator.FieldLevel) bool {
+		_, err := regexp.Compile(fl.Field().String())
+		return err == nil
+	})
+	return v
+}
+
+// ParseBody parses the request body into dst and validates it against its validate tags.
+// The returned error is an *apierror.Error ready to be returned from a handler.
+func ParseBody(c *fiber.Ctx, dst any) error {
+	if err := c.BodyParser(dst); err != nil {
+		return apierror.New(fiber.StatusBadRequest, apierror.CodeInvalidBody, "invalid request body")
+	}
+	return Struct(dst)
+}
+
+// Struct validates v against its validate tags, skipping the listed struct fields (e.g. "ClientID").
+// Failed rules are reported as field-level details of a VALIDATION_FAILED error.
+func Struct(v any, except ...string) error {
+	var err error
+	if len(except) > 0 {
+		err = validate.StructExcept(v, except...)
+	} else {
+		err = validate.Struct(v)
+	}
+	if err == nil {
+		return nil
+	}
+
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		return apierror.New(fiber.StatusBadRequest, apierror.CodeValidationFailed, err.Error())
+	}
+
+	fields := make([]FieldError, 0, len(validationErrs))
+	messages := make([]string, 0, len(validationErrs))
+	for _, fe := range validationErrs {
+		message := fieldMessage(fe)
+		fields = append(fields, FieldError{
+			Field:   fieldPath(fe),
+			Rule:    fe.Tag(),
+			Param:   fe.Param(),
+			Message: message,
+		})
+		messages = append(messages, message)
+	}
+
+	apiErr := apierror.New(fiber.StatusBadRequest, apierror.CodeValidationFailed, "validation failed: "+strings.Join(messages, "; "))
+	apiErr.Details = fields
+	return apiErr
+}
+
+// fieldPath returns the dotted json path of the field without the top-level struct name
+func fieldPath(fe validator.FieldError) string {
+	ns := fe.Namespace()
+	if i := strings.IndexByte(ns, '.'); i >= 0 {
+		return ns[i+1:]
+	}
+	return ns
+}
+
+// fieldMessage builds a human-readable message for a failed rule
+func fieldMessage(fe validator.FieldError) string {
+	field := fieldPath(fe)
+	isString := fe.Kind() == reflect.String
+	isList := fe.Kind() == reflect.Slice || fe.Kind() == reflect.Map
+
+	switch fe.Tag() {
+	case "required":
+		return fmt.Sprintf("%s is required", field)
+	case "email":
+		return fmt.Sprintf("%s must be a valid email address", field)
+	case "min":
+		if isString {
+			return fmt.Sprintf("%s must be at least %s characters", field, fe.Param())
+		}
+		if isList {
+			return fmt.Sprintf("%s must contain at least %s items", field, fe.Param())
+		}
+		return fmt.Sprintf("%s must be at least %s", field, fe.Param())
+	case "max":
+		if isString {
+			return fmt.Sprintf("%s must be at most %s characters", field, fe.Param())
+		}
+		if isList {
+			return fmt.Sprintf("%s must contain at most %s items", field, fe.Param())
+		}
+		return fmt.Sprintf("%s must be at most %s", field, fe.Param())
+	case "http_url":
+		return fmt.Sprintf("%s must be an http(s) URL", field)
+	case "oneof":
+		return fmt.Sprintf("%s must be one of: %s", field, strings.ReplaceAll(fe.Param(), " ", ", "))
+	case "gte":
+		return fmt.Sprintf("%s must be greater than or equal to %s", field, fe.Param())
+	case "lte":
+		return fmt.Sprintf("%s must be less than or equal to %s", field, fe.Param())
+	case "regexp":
+		return fmt.Sprintf("%s must be a valid regular expression", field)
+	case "hostname":
+		return fmt.Sprintf("%s must be a domain name like example.com", field)
+	case "hexcolor":
+		return fmt.Sprintf("%s must be a hex color like #2563eb", field)
+	default:
+		return fmt.Sprintf("%s failed the %q rule", field, fe.Tag())
+	}
+}
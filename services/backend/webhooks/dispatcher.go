@@ -0,0 +1,266 @@
+package webhooks
+
+import (
+	"backend/database"
+	"backend/netguard"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Event types delivered to webhooks
+const (
+	EventChatCompleted   = "chat.completed"
+	EventDocumentIndexed = "document.indexed"
+)
+
+// SupportedEvents lists event types that webhooks can subscribe to
+var SupportedEvents = []string{EventChatCompleted, EventDocumentIndexed}
+
+// Headers set on every delivery
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// queueSize bounds pending events and retries; events beyond it are dropped with a log line
+const queueSize = 1024
+
+// Event is the JSON payload POSTed to webhooks
+type Event struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	BotID     string    `json:"bot_id"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+// Config controls delivery behaviour
+type Config struct {
+	Workers      int
+	MaxAttempts  int
+	Timeout      time.Duration
+	BaseBackoff  time.Duration
+	AllowPrivate bool // Allow delivering to loopback/private addresses (off by default to prevent SSRF)
+}
+
+// job is either a fresh event to fan out (hook == nil) or a retry of one delivery
+type job struct {
+	event   Event
+	payload []byte
+	hook    *database.Webhook
+	attempt int
+}
+
+// Dispatcher delivers bot events to subscribed webhooks asynchronously with retry/backoff
+type Dispatcher struct {
+	repo     *database.WebhookRepository
+	client   *http.Client
+	cfg      Config
+	queue    chan job
+	done     chan struct{}
+	wg       sync.WaitGroup
+	stopOnce sync.Once
+}
+
+// NewDispatcher creates a dispatcher; call Start to launch workers.
+// Redirects are not followed: a webhook URL is checked when it is registered, and a redirect could point anywhere.
+func NewDispatcher(repo *database.WebhookRepository, cfg Config) *Dispatcher {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	if !cfg.AllowPrivate {
+		dialer.Control = netguard.PublicAddressesOnly
+	}
+	return &Dispatcher{
+		repo: repo,
+		client: &http.Client{
+			Timeout: cfg.Timeout,
+			Transport: &http.Transport{
+				Proxy:                 http.ProxyFromEnvironment,
+				DialContext:           dialer.DialContext,
+				TLSHandshakeTimeout:   10 * time.Second,
+				ResponseHeaderTimeout: cfg.Timeout,
+			},
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		cfg:   cfg,
+		queue: make(chan job, queueSize),
+		done:  make(chan struct{}),
+	}
+}
+
+// Start launches the delivery workers
+func (d *Dispatcher) Start() {
+	for i := 0; i < d.cfg.Workers; i++ {
+		d.wg.Add(1)
+		go d.worker()
+	}
+}
+
+// Stop stops accepting events and waits for in-flight deliveries until ctx expires.
+// Scheduled retries that have not fired yet are dropped.
+func (d *Dispatcher) Stop(ctx context.Context) {
+	d.stopOnce.Do(func() { close(d.done) })
+
+	finished := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-ctx.Done():
+		log.Printf("[webhooks] Stop timed out with deliveries in flight")
+	}
+}
+
+// Emit queues an event for all active webhooks of the bot subscribed to its type.
+// It never blocks the caller; a nil dispatcher ignores events.
+func (d *Dispatcher) Emit(botID, eventType string, data any) {
+	if d == nil {
+		return
+	}
+	event := Event{
+		ID:        uuid.New().String(),
+		Type:      eventType,
+		BotID:     botID,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("[webhooks] Failed to encode %s event for bot %s: %v", eventType, botID, err)
+		return
+	}
+	d.enqueue(job{event: event, payload: payload})
+}
+
+// enqueue adds a job without blocking
+func (d *Dispatcher) enqueue(j job) {
+	select {
+	case <-d.done:
+		log.Printf("[webhooks] Dispatcher stopped, dropping %s event %s", j.event.Type, j.event.ID)
+		return
+	default:
+	}
+	select {
+	case d.queue <- j:
+	default:
+		log.Printf("[webhooks] Queue full, dropping %s event %s", j.event.Type, j.event.ID)
+	}
+}
+
+func (d *Dispatcher) worker() {
+	defer d.wg.Done()
+	for {
+		select {
+		case <-d.done:
+			return
+		case j := <-d.queue:
+			d.process(j)
+		}
+	}
+}
+
+// process fans a fresh event out to its webhooks, or retries a single delivery
+func (d *Dispatcher) process(j job) {
+	if j.hook != nil {
+		d.attempt(j)
+		return
+	}
+
+	hooks, err := d.repo.ListForEvent(j.event.BotID, j.event.Type)
+	if err != nil {
+		log.Printf("[webhooks] Failed to load webhooks for bot %s: %v", j.event.BotID, err)
+		return
+	}
+	for i := range hooks {
+		d.attempt(job{event: j.event, payload: j.payload, hook: &hooks[i], attempt: 1})
+	}
+}
+
+// attempt delivers once and schedules a retry with exponential backoff on retryable failures
+func (d *Dispatcher) attempt(j job) {
+	retry, err := d.deliver(j)
+	if err == nil {
+		return
+	}
+	if !retry || j.attempt >= d.cfg.MaxAttempts {
+		log.Printf("[webhooks] Giving up on %s event %s to webhook %d after %d attempt(s): %v",
+			j.event.Type, j.event.ID, j.hook.ID, j.attempt, err)
+		return
+	}
+
+	backoff := d.cfg.BaseBackoff << (j.attempt - 1)
+	log.Printf("[webhooks] Delivery of %s event %s to webhook %d failed (attempt %d), retrying in %s: %v",
+		j.event.Type, j.event.ID, j.hook.ID, j.attempt, backoff, err)
+	next := j
+	next.attempt++
+	time.AfterFunc(backoff, func() { d.enqueue(next) })
+}
+
+// deliver POSTs the signed payload; the bool reports whether a failure is worth retrying
+func (d *Dispatcher) deliver(j job) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), d.cfg.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, j.hook.URL, bytes.NewReader(j.payload))
+	if err != nil {
+		return false, fmt.Errorf("create request: %w", err)
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, j.event.Type)
+	req.Header.Set(HeaderDelivery, j.event.ID)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(j.hook.Secret, timestamp, j.payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("execute request: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode >= 300 {
+		retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		return retry, fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return false, nil
+}
+
+// Sign computes the signature header value: "sha256=" + hex HMAC-SHA256 of "<timestamp>.<body>".
+// Receivers recompute it with the webhook secret and should reject stale timestamps.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// GenerateSecret returns a random signing secret for a new webhook
+func GenerateSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("generate secret: %w", err)
+	}
+	return "whsec_" + hex.EncodeToString(buf), nil
+}
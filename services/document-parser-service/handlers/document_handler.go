@@ -1,34 +1,65 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"io"
+	"log"
 	"mime/multipart"
+	"strconv"
+	"time"
 
 	"document-parser-service/parsers"
 	"github.com/gofiber/fiber/v2"
 )
 
 type DocumentHandler struct {
-	parser *parsers.DocumentParser
+	parser       *parsers.DocumentParser
+	parseTimeout time.Duration // 0 = без ограничения
 }
 
-func NewDocumentHandler() *DocumentHandler {
+// errParseTimeout - разбор документа не уложился в parseTimeout
+var errParseTimeout = errors.New("parse timed out")
+
+func NewDocumentHandler(options parsers.Options, parseTimeout time.Duration) *DocumentHandler {
 	return &DocumentHandler{
-		parser: parsers.NewDocumentParser(),
+		parser:       parsers.NewDocumentParser(options),
+		parseTimeout: parseTimeout,
 	}
 }
 
 type ParseResponse struct {
-	Text     string `json:"text"`
-	FileName string `json:"file_name"`
-	FileType string `json:"file_type"`
-	Size     int64  `json:"size"`
+	Text           string `json:"text"`
+	FileName       string `json:"file_name"`
+	FileType       string `json:"file_type"`
+	Size           int64  `json:"size"`
+	PagesParsed    int    `json:"pages_parsed,omitempty"`
+	PagesTotal     int    `json:"pages_total,omitempty"`
+	PagesTruncated int    `json:"pages_truncated,omitempty"` // Страницы за пределом MAX_PDF_PAGES, которые не разбирались
+
+	Sections []parsers.Section `json:"sections,omitempty"` // Только при markdown_sections=true
+	Recovery *parsers.Recovery `json:"recovery,omitempty"` // Документ повреждён, текст может быть неполным
+	Language string            `json:"language,omitempty"` // Только при detect_language=true
 }
 
 type ErrorResponse struct {
 	Error string `json:"error"`
 }
 
+type FormatsResponse struct {
+	Formats []string `json:"formats"`
+}
+
+// SupportedFormats возвращает расширения файлов, которые умеет разбирать сервис
+func (h *DocumentHandler) SupportedFormats() []string {
+	return h.parser.SupportedFormats()
+}
+
+// ListFormats отдает список поддерживаемых форматов (используется backend для сверки)
+func (h *DocumentHandler) ListFormats(c *fiber.Ctx) error {
+	return c.JSON(FormatsResponse{Formats: h.SupportedFormats()})
+}
+
 func (h *DocumentHandler) ParseDocument(c *fiber.Ctx) error {
 	file, err := c.FormFile("file")
 	if err != nil {
@@ -45,6 +76,22 @@ func (h *DocumentHandler) ParseDocument(c *fiber.Ctx) error {
 	}
 	defer src.Close()
 
+	opts := parsers.ParseOptions{JSONMode: c.FormValue("json_mode")}
+	if !parsers.ValidJSONMode(opts.JSONMode) {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error: fmt.Sprintf("неизвестный json_mode %q (используйте %q или %q)", opts.JSONMode, parsers.JSONModePretty, parsers.JSONModeFlatten),
+		})
+	}
+	for name, dst := range map[string]*bool{
+		"csv_header":        &opts.CSVHeader,
+		"markdown_sections": &opts.MarkdownSections,
+		"detect_language":   &opts.DetectLanguage,
+	} {
+		if err := formBool(c, name, dst); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{Error: err.Error()})
+		}
+	}
+
 	content, err := io.ReadAll(src)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
@@ -52,21 +99,94 @@ func (h *DocumentHandler) ParseDocument(c *fiber.Ctx) error {
 		})
 	}
 
-	text, err := h.parser.ParseFile(content, file.Filename)
-	if err != nil {
+	result, err := h.parse(content, file.Filename, opts)
+	switch {
+	case errors.Is(err, errParseTimeout):
+		log.Printf("⏱️ Parsing %s (%d bytes) exceeded %s", file.Filename, file.Size, h.parseTimeout)
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(ErrorResponse{
+			Error: fmt.Sprintf("разбор документа превысил лимит времени (%s)", h.parseTimeout),
+		})
+	case errors.Is(err, parsers.ErrTooManyPages):
+		log.Printf("📚 Rejected %s: %v", file.Filename, err)
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(ErrorResponse{
+			Error: err.Error(),
+		})
+	case errors.Is(err, parsers.ErrUnzippedTooLarge):
+		log.Printf("💣 Rejected %s: %v", file.Filename, err)
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(ErrorResponse{
+			Error: err.Error(),
+		})
+	case err != nil:
 		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
 			Error: err.Error(),
 		})
 	}
+	if r := result.Recovery; r != nil {
+		log.Printf("🩹 Recovered text from damaged %s: %d entries, damaged %v, directory lost: %t",
+			file.Filename, r.EntriesTotal, r.DamagedEntries, r.DirectoryLost)
+	}
 
 	return c.JSON(ParseResponse{
-		Text:     text,
-		FileName: file.Filename,
-		FileType: getFileType(file),
-		Size:     file.Size,
+		Text:           result.Text,
+		FileName:       file.Filename,
+		FileType:       getFileType(file),
+		Size:           file.Size,
+		PagesParsed:    result.PagesParsed,
+		PagesTotal:     result.PagesTotal,
+		PagesTruncated: result.PagesTruncated,
+		Sections:       result.Sections,
+		Recovery:       result.Recovery,
+		Language:       result.Language,
 	})
 }
 
+// parse runs ParseFile under parseTimeout. Parsers can't be interrupted, so a parse that times out
+// keeps running in the background until it finishes; the request is answered right away.
+// A parser panic is returned as an error instead of crashing the service.
+func (h *DocumentHandler) parse(content []byte, filename string, opts parsers.ParseOptions) (parsers.ParseResult, error) {
+	type outcome struct {
+		result parsers.ParseResult
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- outcome{err: fmt.Errorf("ошибка при парсинге файла %s: %v", filename, r)}
+			}
+		}()
+		result, err := h.parser.ParseFile(content, filename, opts)
+		done <- outcome{result, err}
+	}()
+
+	if h.parseTimeout <= 0 {
+		o := <-done
+		return o.result, o.err
+	}
+	timer := time.NewTimer(h.parseTimeout)
+	defer timer.Stop()
+	select {
+	case o := <-done:
+		return o.result, o.err
+	case <-timer.C:
+		return parsers.ParseResult{}, errParseTimeout
+	}
+}
+
+// formBool читает необязательное логическое поле формы name в dst (пустое поле dst не меняет)
+func formBool(c *fiber.Ctx, name string, dst *bool) error {
+	value := c.FormValue(name)
+	if value == "" {
+		return nil
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return fmt.Errorf("некорректный %s %q (используйте true или false)", name, value)
+	}
+	*dst = parsed
+	return nil
+}
+
 func getFileType(file *multipart.FileHeader) string {
 	contentType := file.Header.Get("Content-Type")
 	if contentType != "" {
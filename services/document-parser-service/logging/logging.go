@@ -0,0 +1,69 @@
+package logging
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Форматы логов (LOG_FORMAT)
+const (
+	FormatText = "text" // Строки key=value
+	FormatJSON = "json" // JSON-объект на строку - для систем сбора логов
+)
+
+// Setup устанавливает общий логгер slog: пишет в stderr на уровне level (debug, info, warn или error)
+// в формате format. Через него же идёт стандартный пакет log, так что строки log.Printf выходят
+// в том же формате, с уровнем info.
+func Setup(level, format string) error {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("LOG_LEVEL должен быть debug, info, warn или error, получено %q", level)
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl}
+	var handler slog.Handler
+	switch format {
+	case FormatText:
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	case FormatJSON:
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	default:
+		return fmt.Errorf("LOG_FORMAT должен быть %q или %q, получено %q", FormatText, FormatJSON, format)
+	}
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// Middleware пишет в лог каждый обработанный запрос: ответы 5xx - с уровнем error, 4xx - warn,
+// остальные - info. Ошибку сначала отрисовывает ErrorHandler приложения, так что в логе - отправленный статус.
+func Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		if chainErr := c.Next(); chainErr != nil {
+			if err := c.App().ErrorHandler(c, chainErr); err != nil {
+				_ = c.SendStatus(fiber.StatusInternalServerError)
+			}
+		}
+
+		status := c.Response().StatusCode()
+		level := slog.LevelInfo
+		switch {
+		case status >= fiber.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= fiber.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+		slog.Log(c.UserContext(), level, "Request",
+			"method", c.Method(),
+			"path", c.Path(),
+			"status", status,
+			"latency_ms", time.Since(start).Milliseconds(),
+			"ip", c.IP(),
+		)
+		return nil
+	}
+}
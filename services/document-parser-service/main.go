@@ -4,25 +4,34 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"log/slog"
 	"os"
 	"os/signal"
 	"runtime"
+	"strconv"
 	"syscall"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/limiter"
-	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/recover"
 
 	"document-parser-service/handlers"
+	"document-parser-service/logging"
+	"document-parser-service/parsers"
 )
 
 func main() {
 	// Optimize for multi-core
 	runtime.GOMAXPROCS(runtime.NumCPU())
 
+	// Same variables and defaults as the backend
+	logFormat := envOr("LOG_FORMAT", logging.FormatText)
+	if err := logging.Setup(envOr("LOG_LEVEL", "info"), logFormat); err != nil {
+		log.Fatalf("Invalid logging configuration: %v", err)
+	}
+
 	// Load configuration from environment
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -36,6 +45,53 @@ func main() {
 	bodyLimitInt := 52428800
 	fmt.Sscanf(bodyLimit, "%d", &bodyLimitInt)
 
+	// Best-effort table-aware PDF extraction (rows with " | " between cells)
+	pdfTableLayout := false
+	if value := os.Getenv("PDF_TABLE_LAYOUT"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			log.Fatalf("Invalid PDF_TABLE_LAYOUT value %q: %v", value, err)
+		}
+		pdfTableLayout = parsed
+	}
+
+	// Per-document parse deadline; keep it below the backend's SERVICE_CALL_TIMEOUT
+	parseTimeout := 60 * time.Second
+	if value := os.Getenv("PARSE_TIMEOUT"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed < 0 {
+			log.Fatalf("Invalid PARSE_TIMEOUT value %q", value)
+		}
+		parseTimeout = parsed
+	}
+
+	// Decompressed size limit of DOCX/ODT/EPUB/XLSX (zip bomb guard)
+	maxUnzippedBytes := int64(parsers.DefaultMaxUnzippedBytes)
+	if value := os.Getenv("PARSE_MAX_UNZIPPED_BYTES"); value != "" {
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || parsed <= 0 {
+			log.Fatalf("Invalid PARSE_MAX_UNZIPPED_BYTES value %q", value)
+		}
+		maxUnzippedBytes = parsed
+	}
+
+	// Page limit of PDF documents: longer ones are truncated or rejected
+	maxPDFPages := 2000
+	if value := os.Getenv("MAX_PDF_PAGES"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			log.Fatalf("Invalid MAX_PDF_PAGES value %q", value)
+		}
+		maxPDFPages = parsed
+	}
+	pdfPageLimitAction := parsers.PageLimitTruncate
+	if value := os.Getenv("PDF_PAGE_LIMIT_ACTION"); value != "" {
+		if value != parsers.PageLimitTruncate && value != parsers.PageLimitReject {
+			log.Fatalf("Invalid PDF_PAGE_LIMIT_ACTION value %q (use %q or %q)", value, parsers.PageLimitTruncate, parsers.PageLimitReject)
+		}
+		pdfPageLimitAction = value
+	}
+
 	corsOrigins := os.Getenv("CORS_ALLOW_ORIGINS")
 	if corsOrigins == "" {
 		corsOrigins = "*"
@@ -54,7 +110,7 @@ func main() {
 	app := fiber.New(fiber.Config{
 		AppName:                      "Document Parser Service",
 		ServerHeader:                 "Document-Parser",
-		DisableStartupMessage:        false,
+		DisableStartupMessage:        logFormat == logging.FormatJSON, // The banner isn't a log line
 		BodyLimit:                    bodyLimitInt,
 		Prefork:                      false, // Disabled for Docker
 		ReadTimeout:                  60 * time.Second,
@@ -68,9 +124,7 @@ func main() {
 	})
 
 	app.Use(recover.New())
-	app.Use(logger.New(logger.Config{
-		Format: "[${time}] ${status} - ${method} ${path} (${latency})\n",
-	}))
+	app.Use(logging.Middleware())
 
 	// Rate limiting
 	app.Use(limiter.New(limiter.Config{
@@ -87,7 +141,12 @@ func main() {
 		AllowHeaders: corsHeaders,
 	}))
 
-	handler := handlers.NewDocumentHandler()
+	handler := handlers.NewDocumentHandler(parsers.Options{
+		PDFTableLayout:     pdfTableLayout,
+		MaxUnzippedBytes:   maxUnzippedBytes,
+		MaxPDFPages:        maxPDFPages,
+		PDFPageLimitAction: pdfPageLimitAction,
+	}, parseTimeout)
 
 	app.Get("/", func(c *fiber.Ctx) error {
 		return c.JSON(fiber.Map{
@@ -99,14 +158,13 @@ func main() {
 
 	app.Get("/health", func(c *fiber.Ctx) error {
 		return c.JSON(fiber.Map{
-			"status":  "healthy",
-			"service": "document-parser",
-			"supported_formats": []string{
-				".txt", ".pdf", ".docx", ".json", ".csv", ".xlsx", ".xls", ".html", ".htm", ".md",
-			},
+			"status":            "healthy",
+			"service":           "document-parser",
+			"supported_formats": handler.SupportedFormats(),
 		})
 	})
 
+	app.Get("/formats", handler.ListFormats)
 	app.Post("/parse", handler.ParseDocument)
 
 	// Graceful shutdown
@@ -115,20 +173,34 @@ func main() {
 
 	go func() {
 		<-quit
-		log.Println("🛑 Gracefully shutting down Document Parser...")
+		slog.Info("Gracefully shutting down Document Parser")
 		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 		defer cancel()
 		if err := app.ShutdownWithContext(ctx); err != nil {
-			log.Printf("Shutdown error: %v", err)
+			slog.Error("Shutdown error", "error", err)
 		}
 	}()
 
-	log.Printf("🚀 Document Parser Service starting on port %s (CPUs: %d)", port, runtime.NumCPU())
-	log.Printf("   Body limit: %d bytes", bodyLimitInt)
-	log.Printf("   CORS origins: %s", corsOrigins)
+	slog.Info("Document Parser Service starting",
+		"port", port,
+		"cpus", runtime.NumCPU(),
+		"body_limit", bodyLimitInt,
+		"cors_origins", corsOrigins,
+		"pdf_table_layout", pdfTableLayout,
+		"parse_timeout", parseTimeout,
+		"max_unzipped_bytes", maxUnzippedBytes,
+	)
 	if err := app.Listen(fmt.Sprintf(":%s", port)); err != nil {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 
-	log.Println("Server stopped gracefully")
+	slog.Info("Server stopped gracefully")
+}
+
+// envOr returns the environment variable key, or fallback when it is unset or empty
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
 }
@@ -0,0 +1,236 @@
+package parsers
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// DefaultMaxUnzippedBytes - лимит распакованных байт одного документа, если в Options он не задан
+const DefaultMaxUnzippedBytes = 256 << 20
+
+// ErrUnzippedTooLarge - ZIP-документ (DOCX, ODT, EPUB, XLSX) распаковывается в больше байт, чем разрешено:
+// обычно это zip-бомба
+var ErrUnzippedTooLarge = errors.New("распакованный документ превышает допустимый размер")
+
+// Recovery - отчёт о разборе повреждённого ZIP-документа: что удалось прочитать
+type Recovery struct {
+	DirectoryLost  bool     `json:"directory_lost,omitempty"` // Центральный каталог не читается, записи найдены по локальным заголовкам
+	EntriesTotal   int      `json:"entries_total"`
+	DamagedEntries []string `json:"damaged_entries,omitempty"` // Прочитаны не полностью или пропущены
+}
+
+// zipArchive - ZIP-контейнер документа с общим на весь разбор лимитом распакованных байт.
+// Архив с повреждённым центральным каталогом открывается по локальным заголовкам записей.
+type zipArchive struct {
+	entries       []zipEntry
+	format        string
+	remaining     int64    // Сколько ещё байт можно распаковать
+	directoryLost bool     // Записи найдены сканированием, а не по центральному каталогу
+	damaged       []string // Записи, которые не удалось прочитать полностью
+}
+
+// zipEntry - файл архива
+type zipEntry struct {
+	name string
+	size uint64 // Заявленный распакованный размер (0, если неизвестен)
+	open func() (io.ReadCloser, error)
+}
+
+// openZip открывает ZIP-контейнер документа format
+func (p *DocumentParser) openZip(content []byte, format string) (*zipArchive, error) {
+	archive := &zipArchive{format: format, remaining: p.maxUnzippedBytes()}
+	zipReader, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		// Обрезанный или повреждённый в конце архив: ищем записи по их локальным заголовкам
+		archive.entries = scanZipEntries(content)
+		if len(archive.entries) == 0 {
+			return nil, fmt.Errorf("не удалось открыть %s как ZIP: %w", format, err)
+		}
+		archive.directoryLost = true
+		return archive, nil
+	}
+	for _, file := range zipReader.File {
+		archive.entries = append(archive.entries, zipEntry{name: file.Name, size: file.UncompressedSize64, open: file.Open})
+	}
+	return archive, nil
+}
+
+// maxUnzippedBytes возвращает лимит распакованных байт одного документа
+func (p *DocumentParser) maxUnzippedBytes() int64 {
+	if p.options.MaxUnzippedBytes > 0 {
+		return p.options.MaxUnzippedBytes
+	}
+	return DefaultMaxUnzippedBytes
+}
+
+// has сообщает, есть ли в архиве файл name
+func (a *zipArchive) has(name string) bool {
+	return a.file(name) != nil
+}
+
+func (a *zipArchive) file(name string) *zipEntry {
+	for i := range a.entries {
+		if a.entries[i].name == name {
+			return &a.entries[i]
+		}
+	}
+	return nil
+}
+
+// read распаковывает файл name, расходуя общий лимит архива
+func (a *zipArchive) read(name string) ([]byte, error) {
+	data, err := a.extract(name)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// salvage распаковывает файл name, как read, но у повреждённой записи возвращает то, что успело
+// распаковаться до ошибки. Ошибка - только если файла нет, он превышает лимит или не дал ни байта.
+func (a *zipArchive) salvage(name string) ([]byte, error) {
+	data, err := a.extract(name)
+	if err != nil && (len(data) == 0 || errors.Is(err, ErrUnzippedTooLarge)) {
+		return nil, err
+	}
+	return data, nil
+}
+
+// extract распаковывает файл name. При ошибке распаковки возвращает и прочитанное до неё,
+// а запись попадает в отчёт recovery.
+func (a *zipArchive) extract(name string) ([]byte, error) {
+	entry := a.file(name)
+	if entry == nil {
+		return nil, fmt.Errorf("не найден %s в %s файле", name, a.format)
+	}
+	if entry.size > uint64(a.remaining) {
+		return nil, fmt.Errorf("%s в %s файле: %w", name, a.format, ErrUnzippedTooLarge)
+	}
+
+	file, err := entry.open()
+	if err != nil {
+		a.damaged = append(a.damaged, name)
+		return nil, fmt.Errorf("не удалось открыть %s: %w", name, err)
+	}
+	defer file.Close()
+
+	// Заявленному в заголовке размеру не доверяем: читаем не больше остатка лимита
+	data, err := io.ReadAll(io.LimitReader(file, a.remaining+1))
+	if int64(len(data)) > a.remaining {
+		return nil, fmt.Errorf("%s в %s файле: %w", name, a.format, ErrUnzippedTooLarge)
+	}
+	a.remaining -= int64(len(data))
+	if err != nil {
+		a.damaged = append(a.damaged, name)
+		return data, fmt.Errorf("не удалось прочитать %s: %w", name, err)
+	}
+	return data, nil
+}
+
+// checkTotalSize проверяет заявленный распакованный размер всего архива - для библиотек,
+// которые распаковывают архив целиком сами (excelize)
+func (a *zipArchive) checkTotalSize() error {
+	var total uint64
+	for _, entry := range a.entries {
+		total += entry.size
+		if total > uint64(a.remaining) {
+			return fmt.Errorf("%s файл: %w", a.format, ErrUnzippedTooLarge)
+		}
+	}
+	return nil
+}
+
+// rebuild собирает из читаемых записей исправный ZIP (без сжатия) для библиотек, которые не открывают
+// повреждённые архивы. Повреждённые записи входят в него в том объёме, в каком удалось их распаковать,
+// нечитаемые пропускаются.
+func (a *zipArchive) rebuild() ([]byte, error) {
+	var buf bytes.Buffer
+	writer := zip.NewWriter(&buf)
+	for _, entry := range a.entries {
+		data, err := a.extract(entry.name)
+		if errors.Is(err, ErrUnzippedTooLarge) {
+			return nil, err
+		}
+		if err != nil && len(data) == 0 {
+			continue
+		}
+		w, err := writer.CreateHeader(&zip.FileHeader{Name: entry.name, Method: zip.Store})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// recovery возвращает отчёт о повреждениях архива или nil, если он прочитан без ошибок
+func (a *zipArchive) recovery() *Recovery {
+	if !a.directoryLost && len(a.damaged) == 0 {
+		return nil
+	}
+	return &Recovery{DirectoryLost: a.directoryLost, EntriesTotal: len(a.entries), DamagedEntries: a.damaged}
+}
+
+// zipLocalHeaderSize - размер фиксированной части локального заголовка записи ZIP
+const zipLocalHeaderSize = 30
+
+// scanZipEntries находит записи архива по сигнатурам локальных заголовков (PK\x03\x04) - так читаются
+// архивы, у которых обрезан или испорчен центральный каталог в конце файла.
+// Записи без сжатия с размером в дескрипторе данных (флаг 3) пропускаются: их конец не найти.
+func scanZipEntries(content []byte) []zipEntry {
+	signature := []byte("PK\x03\x04")
+	var entries []zipEntry
+	seen := make(map[string]bool)
+	for offset := 0; ; {
+		i := bytes.Index(content[offset:], signature)
+		if i < 0 || offset+i+zipLocalHeaderSize > len(content) {
+			break
+		}
+		header := content[offset+i:]
+		flags := binary.LittleEndian.Uint16(header[6:])
+		method := binary.LittleEndian.Uint16(header[8:])
+		compressedSize := int(binary.LittleEndian.Uint32(header[18:]))
+		size := binary.LittleEndian.Uint32(header[22:])
+		nameEnd := zipLocalHeaderSize + int(binary.LittleEndian.Uint16(header[26:]))
+		dataStart := nameEnd + int(binary.LittleEndian.Uint16(header[28:]))
+		if dataStart > len(header) {
+			break
+		}
+		name := string(header[zipLocalHeaderSize:nameEnd])
+		data := header[dataStart:]
+		sizeKnown := flags&0x8 == 0
+		if sizeKnown && compressedSize <= len(data) {
+			data = data[:compressedSize]
+		}
+		offset += i + dataStart
+		if sizeKnown {
+			offset += len(data)
+		} else {
+			size = 0
+		}
+
+		var open func() (io.ReadCloser, error)
+		switch {
+		case method == zip.Deflate:
+			open = func() (io.ReadCloser, error) { return flate.NewReader(bytes.NewReader(data)), nil }
+		case method == zip.Store && sizeKnown:
+			open = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(data)), nil }
+		}
+		if open == nil || name == "" || name[len(name)-1] == '/' || seen[name] {
+			continue
+		}
+		seen[name] = true
+		entries = append(entries, zipEntry{name: name, size: uint64(size), open: open})
+	}
+	return entries
+}
@@ -0,0 +1,78 @@
+package parsers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// csvHeaderCellSeparator разделяет пары "Столбец: значение" в строке CSV с заголовками
+const csvHeaderCellSeparator = " | "
+
+// parseCSVWithHeaders выводит каждую строку данных как "Столбец1: значение1 | Столбец2: значение2",
+// так что строка таблицы остаётся понятной и в отдельном чанке. Пустые ячейки не выводятся.
+// Если первая строка не похожа на заголовки (см. looksLikeCSVHeader), разбор идёт как в parseCSV.
+func (p *DocumentParser) parseCSVWithHeaders(content []byte) (string, error) {
+	reader := csv.NewReader(bytes.NewReader(content))
+	reader.FieldsPerRecord = -1 // Строки выгрузок часто короче или длиннее заголовка
+	records, err := reader.ReadAll()
+	if err != nil {
+		return "", fmt.Errorf("не удалось прочитать CSV: %w", err)
+	}
+	if len(records) < 2 || !looksLikeCSVHeader(records[0]) {
+		return p.parseCSV(content)
+	}
+
+	headers := make([]string, len(records[0]))
+	for i, name := range records[0] {
+		headers[i] = strings.TrimSpace(name)
+	}
+	headers[0] = strings.TrimPrefix(headers[0], "\ufeff") // BOM из выгрузок Excel
+
+	var text strings.Builder
+	for _, row := range records[1:] {
+		cells := make([]string, 0, len(row))
+		for i, value := range row {
+			value = strings.Join(strings.Fields(value), " ")
+			if value == "" {
+				continue
+			}
+			cells = append(cells, csvColumnName(headers, i)+": "+value)
+		}
+		if len(cells) == 0 {
+			continue
+		}
+		text.WriteString(strings.Join(cells, csvHeaderCellSeparator))
+		text.WriteString("\n")
+	}
+	return strings.TrimSpace(text.String()), nil
+}
+
+// looksLikeCSVHeader сообщает, похожа ли строка на заголовки: названия непустые, не повторяются
+// и не являются числами (строка из чисел - это уже данные)
+func looksLikeCSVHeader(row []string) bool {
+	seen := make(map[string]bool, len(row))
+	named := 0
+	for _, cell := range row {
+		name := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(cell, "\ufeff")))
+		if name == "" {
+			continue
+		}
+		if _, err := strconv.ParseFloat(name, 64); err == nil || seen[name] {
+			return false
+		}
+		seen[name] = true
+		named++
+	}
+	return named > 0
+}
+
+// csvColumnName возвращает название столбца i; у безымянных и лишних столбцов - "Столбец N"
+func csvColumnName(headers []string, i int) string {
+	if i < len(headers) && headers[i] != "" {
+		return headers[i]
+	}
+	return fmt.Sprintf("Столбец %d", i+1)
+}
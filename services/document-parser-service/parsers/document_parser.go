@@ -1,15 +1,17 @@
 package parsers
 
 import (
-	"archive/zip"
 	"bytes"
 	"encoding/csv"
 	"encoding/json"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/ledongthuc/pdf"
@@ -18,45 +20,135 @@ import (
 
 type DocumentParser struct {
 	supportedFormats map[string]ParserFunc
+	resultFormats    map[string]ResultParserFunc
+	options          Options
 }
 
+// Options - настройки разбора
+type Options struct {
+	// PDFTableLayout включает извлечение текста PDF по координатам (см. pageLayoutText):
+	// таблицы выводятся строками с разделителем " | " вместо перемешанного текста
+	PDFTableLayout bool
+	// MaxUnzippedBytes ограничивает распакованный размер ZIP-документов (DOCX, ODT, EPUB, XLSX);
+	// 0 - DefaultMaxUnzippedBytes
+	MaxUnzippedBytes int64
+	// MaxPDFPages - сколько страниц PDF разбирается (0 - без ограничения). Что делать с более длинным
+	// документом, решает PDFPageLimitAction
+	MaxPDFPages        int
+	PDFPageLimitAction string // PageLimitTruncate (по умолчанию) или PageLimitReject
+}
+
+// Действия при превышении MaxPDFPages
+const (
+	PageLimitTruncate = "truncate" // Разобрать первые MaxPDFPages страниц (ParseResult.PagesTruncated)
+	PageLimitReject   = "reject"   // Отклонить документ с ErrTooManyPages
+)
+
+// ErrTooManyPages - в PDF больше страниц, чем MaxPDFPages, а PDFPageLimitAction = PageLimitReject
+var ErrTooManyPages = errors.New("в документе слишком много страниц")
+
 type ParserFunc func(content []byte) (string, error)
 
-func NewDocumentParser() *DocumentParser {
+// ResultParserFunc разбирает форматы, которые сообщают о полноте извлечения:
+// сколько страниц дали текст (PDF) или что удалось прочитать из повреждённого архива (DOCX, XLSX)
+type ResultParserFunc func(content []byte) (ParseResult, error)
+
+// ParseResult - результат разбора документа.
+// Для постраничных форматов (PDF) PagesParsed < PagesTotal означает частичное извлечение
+// (например, страницы-сканы без текстового слоя); для остальных форматов оба поля равны 0.
+// Recovery заполняется, если текст извлечён из повреждённого DOCX или XLSX и может быть неполным.
+type ParseResult struct {
+	Text        string
+	PagesParsed int
+	PagesTotal  int
+	Sections    []Section // Разделы markdown по заголовкам, если запрошены MarkdownSections
+	Recovery    *Recovery
+	Language    string // Код ISO 639-1, если запрошен DetectLanguage и язык определён
+	// PagesTruncated - страницы за пределом MaxPDFPages, которые не разбирались (они входят в PagesTotal)
+	PagesTruncated int
+}
+
+func NewDocumentParser(options Options) *DocumentParser {
 	p := &DocumentParser{
 		supportedFormats: make(map[string]ParserFunc),
+		resultFormats:    make(map[string]ResultParserFunc),
+		options:          options,
 	}
 	p.supportedFormats[".txt"] = p.parseTXT
-	p.supportedFormats[".pdf"] = p.parsePDF
-	p.supportedFormats[".docx"] = p.parseDOCX
+	p.resultFormats[".pdf"] = p.parsePDF
+	p.resultFormats[".docx"] = p.parseDOCX
+	p.supportedFormats[".odt"] = p.parseODT
+	p.supportedFormats[".rtf"] = p.parseRTF
+	p.supportedFormats[".epub"] = p.parseEPUB
 	p.supportedFormats[".json"] = p.parseJSON
 	p.supportedFormats[".csv"] = p.parseCSV
-	p.supportedFormats[".xlsx"] = p.parseXLSX
-	p.supportedFormats[".xls"] = p.parseXLSX
+	p.resultFormats[".xlsx"] = p.parseXLSX // excelize не читает старый бинарный .xls
 	p.supportedFormats[".html"] = p.parseHTML
 	p.supportedFormats[".htm"] = p.parseHTML
 	p.supportedFormats[".md"] = p.parseMarkdown
 	return p
 }
 
-func (p *DocumentParser) ParseFile(content []byte, filename string) (string, error) {
+// ParseOptions - настройки разбора одного документа, которые задаёт запрос
+type ParseOptions struct {
+	JSONMode  string // JSONModePretty (по умолчанию) или JSONModeFlatten
+	CSVHeader bool   // Первая строка CSV - заголовки столбцов
+
+	MarkdownSections bool // Для .md вернуть и разделы по заголовкам (ParseResult.Sections)
+	DetectLanguage   bool // Определить основной язык текста (ParseResult.Language)
+}
+
+func (p *DocumentParser) ParseFile(content []byte, filename string, opts ParseOptions) (ParseResult, error) {
+	result, err := p.parseFile(content, filename, opts)
+	if err != nil {
+		return ParseResult{}, err
+	}
+	if opts.DetectLanguage {
+		result.Language = DetectLanguage(result.Text)
+	}
+	return result, nil
+}
+
+func (p *DocumentParser) parseFile(content []byte, filename string, opts ParseOptions) (ParseResult, error) {
 	ext := strings.ToLower(filepath.Ext(filename))
+	if resultFunc, ok := p.resultFormats[ext]; ok {
+		result, err := resultFunc(content)
+		if err != nil {
+			return ParseResult{}, fmt.Errorf("ошибка при парсинге файла %s: %w", filename, err)
+		}
+		return result, nil
+	}
 	parserFunc, ok := p.supportedFormats[ext]
 	if !ok {
-		return "", fmt.Errorf("формат %s не поддерживается", ext)
+		return ParseResult{}, fmt.Errorf("формат %s не поддерживается", ext)
+	}
+	if ext == ".json" && opts.JSONMode == JSONModeFlatten {
+		parserFunc = p.parseJSONFlat
+	}
+	if ext == ".csv" && opts.CSVHeader {
+		parserFunc = p.parseCSVWithHeaders
 	}
 	text, err := parserFunc(content)
 	if err != nil {
-		return "", fmt.Errorf("ошибка при парсинге файла %s: %w", filename, err)
+		return ParseResult{}, fmt.Errorf("ошибка при парсинге файла %s: %w", filename, err)
 	}
-	return text, nil
+	result := ParseResult{Text: text}
+	if ext == ".md" && opts.MarkdownSections {
+		result.Sections = splitMarkdownSections(text)
+	}
+	return result, nil
 }
 
-func (p *DocumentParser) getSupportedFormats() []string {
-	formats := make([]string, 0, len(p.supportedFormats))
+// SupportedFormats возвращает отсортированный список поддерживаемых расширений
+func (p *DocumentParser) SupportedFormats() []string {
+	formats := make([]string, 0, len(p.supportedFormats)+len(p.resultFormats))
 	for format := range p.supportedFormats {
 		formats = append(formats, format)
 	}
+	for format := range p.resultFormats {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
 	return formats
 }
 
@@ -64,64 +156,80 @@ func (p *DocumentParser) parseTXT(content []byte) (string, error) {
 	return string(content), nil
 }
 
-func (p *DocumentParser) parsePDF(content []byte) (string, error) {
+func (p *DocumentParser) parsePDF(content []byte) (ParseResult, error) {
 	reader := bytes.NewReader(content)
 	pdfReader, err := pdf.NewReader(reader, int64(len(content)))
 	if err != nil {
-		return "", fmt.Errorf("не удалось открыть PDF: %w", err)
+		return ParseResult{}, fmt.Errorf("не удалось открыть PDF: %w", err)
 	}
-	var text strings.Builder
 	numPages := pdfReader.NumPage()
-	for i := 1; i <= numPages; i++ {
+	limit := numPages
+	if maxPages := p.options.MaxPDFPages; maxPages > 0 && numPages > maxPages {
+		if p.options.PDFPageLimitAction == PageLimitReject {
+			return ParseResult{}, fmt.Errorf("%d страниц при лимите %d: %w", numPages, maxPages, ErrTooManyPages)
+		}
+		limit = maxPages
+	}
+	var text strings.Builder
+	parsed := 0
+	for i := 1; i <= limit; i++ {
 		page := pdfReader.Page(i)
 		if page.V.IsNull() {
 			continue
 		}
-		pageText, err := page.GetPlainText(nil)
-		if err != nil {
+		pageText, err := p.pdfPageText(page)
+		// Страницы-изображения и битые страницы пропускаем, но учитываем в статистике
+		if err != nil || strings.TrimSpace(pageText) == "" {
 			continue
 		}
+		parsed++
 		text.WriteString(pageText)
 		text.WriteString("\n\n")
 	}
-	return strings.TrimSpace(text.String()), nil
+	return ParseResult{
+		Text:           strings.TrimSpace(text.String()),
+		PagesParsed:    parsed,
+		PagesTotal:     numPages,
+		PagesTruncated: numPages - limit,
+	}, nil
 }
 
-func (p *DocumentParser) parseDOCX(content []byte) (string, error) {
-	// DOCX это ZIP архив с XML файлами
-	reader := bytes.NewReader(content)
-	zipReader, err := zip.NewReader(reader, int64(len(content)))
-	if err != nil {
-		return "", fmt.Errorf("не удалось открыть DOCX как ZIP: %w", err)
-	}
-
-	// Ищем word/document.xml
-	var documentXML *zip.File
-	for _, file := range zipReader.File {
-		if file.Name == "word/document.xml" {
-			documentXML = file
-			break
+// pdfPageText извлекает текст страницы PDF. В режиме PDFTableLayout сначала пробует раскладку
+// по координатам и возвращается к GetPlainText, если она не дала текста
+func (p *DocumentParser) pdfPageText(page pdf.Page) (string, error) {
+	if p.options.PDFTableLayout {
+		if text, err := pageLayoutText(page); err == nil && strings.TrimSpace(text) != "" {
+			return text, nil
 		}
 	}
+	return page.GetPlainText(nil)
+}
 
-	if documentXML == nil {
-		return "", fmt.Errorf("не найден word/document.xml в DOCX файле")
-	}
-
-	// Читаем XML
-	xmlFile, err := documentXML.Open()
+func (p *DocumentParser) parseDOCX(content []byte) (ParseResult, error) {
+	// DOCX это ZIP архив с XML файлами. Из повреждённого архива берём то,
+	// что успело распаковаться, а обрезанный XML читаем до первой ошибки
+	archive, err := p.openZip(content, "DOCX")
 	if err != nil {
-		return "", fmt.Errorf("не удалось открыть document.xml: %w", err)
+		return ParseResult{}, err
 	}
-	defer xmlFile.Close()
-
-	xmlData, err := io.ReadAll(xmlFile)
+	xmlData, err := archive.salvage("word/document.xml")
 	if err != nil {
-		return "", fmt.Errorf("не удалось прочитать document.xml: %w", err)
+		return ParseResult{}, err
 	}
 
 	// Парсим XML и извлекаем текст
-	return extractTextFromDocumentXML(xmlData)
+	text, err := extractTextFromDocumentXML(xmlData)
+	recovery := archive.recovery()
+	if err != nil && recovery != nil {
+		text, err = salvageDocumentXMLText(xmlData), nil
+		if text == "" {
+			return ParseResult{}, fmt.Errorf("DOCX повреждён, текст восстановить не удалось")
+		}
+	}
+	if err != nil {
+		return ParseResult{}, err
+	}
+	return ParseResult{Text: text, Recovery: recovery}, nil
 }
 
 // extractTextFromDocumentXML извлекает текст из word/document.xml
@@ -160,6 +268,104 @@ func extractTextFromDocumentXML(xmlData []byte) (string, error) {
 	return strings.TrimSpace(text.String()), nil
 }
 
+// salvageDocumentXMLText извлекает текст абзацев тела документа из обрезанного или испорченного
+// word/document.xml: те же w:body/w:p/w:r/w:t, что и extractTextFromDocumentXML, но до первой ошибки XML
+func salvageDocumentXMLText(xmlData []byte) string {
+	decoder := xml.NewDecoder(bytes.NewReader(xmlData))
+	var text strings.Builder
+	var path []string // Локальные имена открытых элементов
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			path = append(path, t.Name.Local)
+		case xml.EndElement:
+			if len(path) == 3 && path[1] == "body" && path[2] == "p" {
+				text.WriteString("\n")
+			}
+			if len(path) > 0 {
+				path = path[:len(path)-1]
+			}
+		case xml.CharData:
+			if len(path) == 5 && path[1] == "body" && path[2] == "p" && path[3] == "r" && path[4] == "t" {
+				text.Write(t)
+			}
+		}
+	}
+	return strings.TrimSpace(text.String())
+}
+
+func (p *DocumentParser) parseODT(content []byte) (string, error) {
+	// ODT, как и DOCX, - ZIP архив; текст документа лежит в content.xml
+	archive, err := p.openZip(content, "ODT")
+	if err != nil {
+		return "", err
+	}
+	xmlData, err := archive.read("content.xml")
+	if err != nil {
+		return "", err
+	}
+	return extractTextFromODFContent(xmlData)
+}
+
+// extractTextFromODFContent извлекает текст абзацев и заголовков из content.xml (OpenDocument).
+// Каждый text:p / text:h выводится отдельной строкой; text:s, text:tab и text:line-break
+// заменяются пробелами, табуляцией и переводом строки.
+func extractTextFromODFContent(xmlData []byte) (string, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(xmlData))
+	var text strings.Builder
+	depth := 0 // Вложенность text:p / text:h; текст вне абзацев - служебный
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("не удалось распарсить XML: %w", err)
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if t.Name.Space != odfTextNamespace {
+				continue
+			}
+			switch t.Name.Local {
+			case "p", "h":
+				depth++
+			case "s":
+				count := 1
+				for _, attr := range t.Attr {
+					if attr.Name.Local == "c" {
+						fmt.Sscanf(attr.Value, "%d", &count)
+					}
+				}
+				text.WriteString(strings.Repeat(" ", max(1, min(count, 100))))
+			case "tab":
+				text.WriteString("\t")
+			case "line-break":
+				text.WriteString("\n")
+			}
+		case xml.EndElement:
+			if t.Name.Space == odfTextNamespace && (t.Name.Local == "p" || t.Name.Local == "h") {
+				depth--
+				if depth == 0 {
+					text.WriteString("\n")
+				}
+			}
+		case xml.CharData:
+			if depth > 0 {
+				text.Write(t)
+			}
+		}
+	}
+	return strings.TrimSpace(text.String()), nil
+}
+
+// odfTextNamespace - пространство имён text: в OpenDocument
+const odfTextNamespace = "urn:oasis:names:tc:opendocument:xmlns:text:1.0"
+
 func (p *DocumentParser) parseJSON(content []byte) (string, error) {
 	var data interface{}
 	if err := json.Unmarshal(content, &data); err != nil {
@@ -186,28 +392,93 @@ func (p *DocumentParser) parseCSV(content []byte) (string, error) {
 	return strings.TrimSpace(text.String()), nil
 }
 
-func (p *DocumentParser) parseXLSX(content []byte) (string, error) {
-	reader := bytes.NewReader(content)
-	f, err := excelize.OpenReader(reader)
+func (p *DocumentParser) parseXLSX(content []byte) (ParseResult, error) {
+	// excelize распаковывает книгу сам: размер проверяется заранее, а лимит передаётся и ему
+	archive, err := p.openZip(content, "Excel")
 	if err != nil {
-		return "", fmt.Errorf("не удалось открыть Excel: %w", err)
+		return ParseResult{}, err
+	}
+	if err := archive.checkTotalSize(); err != nil {
+		return ParseResult{}, err
+	}
+	// Общая таблица строк держится в памяти (UnzipXMLSizeLimit = весь лимит): при выгрузке во временный файл
+	// excelize инициализирует её без блокировки, и параллельное чтение листов было бы гонкой
+	limit := p.maxUnzippedBytes()
+	openOptions := excelize.Options{UnzipSizeLimit: limit, UnzipXMLSizeLimit: limit}
+	var f *excelize.File
+	if !archive.directoryLost {
+		f, err = excelize.OpenReader(bytes.NewReader(content), openOptions)
+	}
+	// excelize не открывает книгу, если не читается хотя бы одна запись архива:
+	// пересобираем архив из читаемых записей, и повреждённые листы просто окажутся пустыми.
+	// Без служебных частей книги ([Content_Types].xml, xl/workbook.xml и их связей) это не поможет
+	if archive.directoryLost || err != nil {
+		rebuilt, rebuildErr := archive.rebuild()
+		if rebuildErr != nil {
+			return ParseResult{}, rebuildErr
+		}
+		if f, err = excelize.OpenReader(bytes.NewReader(rebuilt), openOptions); err != nil {
+			if archive.recovery() != nil {
+				return ParseResult{}, fmt.Errorf("Excel повреждён, книгу восстановить не удалось: %w", err)
+			}
+			return ParseResult{}, fmt.Errorf("не удалось открыть Excel: %w", err)
+		}
 	}
 	defer f.Close()
-	var text strings.Builder
 	sheets := f.GetSheetList()
-	for _, sheet := range sheets {
+	// Стили тоже читаются лениво без блокировки - загружаем их до запуска воркеров
+	f.GetStyle(0)
+
+	// Листы разбираются параллельно и собираются в исходном порядке
+	texts := make([]string, len(sheets))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range min(xlsxSheetWorkers, len(sheets)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				texts[i] = xlsxSheetText(f, sheets[i])
+			}
+		}()
+	}
+	for i := range sheets {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	var text strings.Builder
+	for i, sheet := range sheets {
 		text.WriteString(fmt.Sprintf("=== Лист: %s ===\n", sheet))
-		rows, err := f.GetRows(sheet)
+		text.WriteString(texts[i])
+		text.WriteString("\n")
+	}
+	return ParseResult{Text: strings.TrimSpace(text.String()), Recovery: archive.recovery()}, nil
+}
+
+// xlsxSheetWorkers - сколько листов книги разбирается одновременно
+const xlsxSheetWorkers = 4
+
+// xlsxSheetText читает лист построчно (потоковый Rows вместо GetRows, который собирает весь лист в памяти).
+// Нечитаемый лист даёт пустой текст.
+func xlsxSheetText(f *excelize.File, sheet string) string {
+	rows, err := f.Rows(sheet)
+	if err != nil {
+		return ""
+	}
+	defer rows.Close()
+
+	var text strings.Builder
+	for rows.Next() {
+		row, err := rows.Columns()
 		if err != nil {
-			continue
-		}
-		for _, row := range rows {
-			text.WriteString(strings.Join(row, ", "))
-			text.WriteString("\n")
+			break
 		}
+		text.WriteString(strings.Join(row, ", "))
 		text.WriteString("\n")
 	}
-	return strings.TrimSpace(text.String()), nil
+	return text.String()
 }
 
 func (p *DocumentParser) parseHTML(content []byte) (string, error) {
@@ -215,6 +486,11 @@ func (p *DocumentParser) parseHTML(content []byte) (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("не удалось распарсить HTML: %w", err)
 	}
+	return htmlText(doc), nil
+}
+
+// htmlText возвращает видимый текст страницы без пустых строк
+func htmlText(doc *goquery.Document) string {
 	doc.Find("script, style").Remove()
 	text := doc.Find("body").Text()
 	if text == "" {
@@ -228,7 +504,7 @@ func (p *DocumentParser) parseHTML(content []byte) (string, error) {
 			cleanedLines = append(cleanedLines, trimmed)
 		}
 	}
-	return strings.Join(cleanedLines, "\n"), nil
+	return strings.Join(cleanedLines, "\n")
 }
 
 func (p *DocumentParser) parseMarkdown(content []byte) (string, error) {
@@ -0,0 +1,160 @@
+package parsers
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// epubContainer - META-INF/container.xml: путь к OPF-пакету книги
+type epubContainer struct {
+	Rootfiles []struct {
+		FullPath string `xml:"full-path,attr"`
+	} `xml:"rootfiles>rootfile"`
+}
+
+// epubPackage - OPF-пакет: файлы книги (manifest) и порядок чтения (spine)
+type epubPackage struct {
+	Manifest []struct {
+		ID        string `xml:"id,attr"`
+		Href      string `xml:"href,attr"`
+		MediaType string `xml:"media-type,attr"`
+	} `xml:"manifest>item"`
+	Spine []struct {
+		IDRef string `xml:"idref,attr"`
+	} `xml:"spine>itemref"`
+}
+
+// epubChapterTypes - типы файлов spine, из которых извлекается текст
+var epubChapterTypes = map[string]bool{
+	"application/xhtml+xml": true,
+	"text/html":             true,
+}
+
+// epubBlockElements - элементы, после которых текст главы переносится на новую строку
+const epubBlockElements = "p, h1, h2, h3, h4, h5, h6, li, dt, dd, div, tr, br, blockquote, pre"
+
+// parseEPUB извлекает текст книги по главам в порядке spine.
+// Главы распаковываются и разбираются по одной, так что в памяти не держится вся книга в распакованном виде.
+// Каждая глава начинается с заголовка "=== Глава: ... ===" (если он есть) и отделяется пустой строкой,
+// чтобы чанки не смешивали соседние главы.
+func (p *DocumentParser) parseEPUB(content []byte) (string, error) {
+	archive, err := p.openZip(content, "EPUB")
+	if err != nil {
+		return "", err
+	}
+	if archive.has("META-INF/encryption.xml") {
+		return "", fmt.Errorf("EPUB защищён DRM, текст извлечь нельзя")
+	}
+
+	opfPath, err := epubPackagePath(archive)
+	if err != nil {
+		return "", err
+	}
+	opfData, err := archive.read(opfPath)
+	if err != nil {
+		return "", err
+	}
+	var pkg epubPackage
+	if err := xml.Unmarshal(opfData, &pkg); err != nil {
+		return "", fmt.Errorf("не удалось распарсить %s: %w", opfPath, err)
+	}
+
+	hrefs := make(map[string]string, len(pkg.Manifest))
+	for _, item := range pkg.Manifest {
+		if epubChapterTypes[item.MediaType] {
+			hrefs[item.ID] = item.Href
+		}
+	}
+
+	baseDir := path.Dir(opfPath)
+	var text strings.Builder
+	chapters := 0
+	for _, itemRef := range pkg.Spine {
+		href, ok := hrefs[itemRef.IDRef]
+		if !ok {
+			continue
+		}
+		name, err := epubItemPath(baseDir, href)
+		if err != nil {
+			continue
+		}
+		// Отсутствующие и битые главы пропускаются: остальная книга всё равно полезна.
+		// Превышение лимита распаковки прерывает разбор - это признак zip-бомбы
+		chapter, err := epubChapterText(archive, name)
+		if errors.Is(err, ErrUnzippedTooLarge) {
+			return "", err
+		}
+		if err != nil || chapter == "" {
+			continue
+		}
+		chapters++
+		text.WriteString(chapter)
+		text.WriteString("\n\n")
+	}
+	if chapters == 0 {
+		return "", fmt.Errorf("в EPUB не найдено глав с текстом")
+	}
+	return strings.TrimSpace(text.String()), nil
+}
+
+// epubPackagePath возвращает путь к OPF-пакету из META-INF/container.xml
+func epubPackagePath(archive *zipArchive) (string, error) {
+	data, err := archive.read("META-INF/container.xml")
+	if err != nil {
+		return "", err
+	}
+	var container epubContainer
+	if err := xml.Unmarshal(data, &container); err != nil {
+		return "", fmt.Errorf("не удалось распарсить META-INF/container.xml: %w", err)
+	}
+	for _, rootfile := range container.Rootfiles {
+		if rootfile.FullPath != "" {
+			return rootfile.FullPath, nil
+		}
+	}
+	return "", fmt.Errorf("в META-INF/container.xml не указан OPF-пакет")
+}
+
+// epubItemPath переводит href из manifest (относительный к OPF, URL-кодированный) в имя файла архива
+func epubItemPath(baseDir, href string) (string, error) {
+	ref, err := url.Parse(href)
+	if err != nil {
+		return "", err
+	}
+	return path.Join(baseDir, ref.Path), nil
+}
+
+// epubChapterText извлекает текст главы; заголовок берётся из первого h1-h3 или <title>
+func epubChapterText(archive *zipArchive, name string) (string, error) {
+	data, err := archive.read(name)
+	if err != nil {
+		return "", err
+	}
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("не удалось распарсить %s: %w", name, err)
+	}
+
+	title := strings.TrimSpace(doc.Find("h1, h2, h3").First().Text())
+	if title == "" {
+		title = strings.TrimSpace(doc.Find("title").First().Text())
+	}
+	doc.Find("head").Remove()
+	// XHTML книг часто записан без переводов строк между тегами: без них абзацы слиплись бы
+	doc.Find(epubBlockElements).AfterHtml("\n")
+	body := htmlText(doc)
+	if body == "" {
+		return "", nil
+	}
+	if title == "" {
+		return body, nil
+	}
+	return fmt.Sprintf("=== Глава: %s ===\n%s", strings.Join(strings.Fields(title), " "), body), nil
+}
@@ -0,0 +1,101 @@
+package parsers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Режимы разбора JSON (поле формы json_mode)
+const (
+	JSONModePretty  = "pretty"  // По умолчанию: форматированный JSON
+	JSONModeFlatten = "flatten" // Строки "путь.к.ключу: значение"
+)
+
+// ValidJSONMode сообщает, поддерживается ли режим json_mode ("" - режим по умолчанию)
+func ValidJSONMode(mode string) bool {
+	return mode == "" || mode == JSONModePretty || mode == JSONModeFlatten
+}
+
+// parseJSONFlat разворачивает JSON в строки "путь: значение" (user.address.city: Paris, items[0].price: 10).
+// Ключи идут в порядке документа; такие строки эмбеддятся и находятся поиском лучше, чем скобки и отступы.
+func (p *DocumentParser) parseJSONFlat(content []byte) (string, error) {
+	// json.Valid также ограничивает глубину вложенности, так что рекурсия ниже не переполнит стек
+	if !json.Valid(content) {
+		var data interface{}
+		err := json.Unmarshal(content, &data)
+		return "", fmt.Errorf("невалидный JSON: %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+	var text strings.Builder
+	if err := flattenJSONValue(decoder, "", &text); err != nil {
+		return "", fmt.Errorf("невалидный JSON: %w", err)
+	}
+	return strings.TrimSpace(text.String()), nil
+}
+
+// flattenJSONValue читает из decoder одно значение и пишет его листья строками "путь: значение"
+func flattenJSONValue(decoder *json.Decoder, path string, out *strings.Builder) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	switch value := token.(type) {
+	case json.Delim:
+		count := 0
+		for ; decoder.More(); count++ {
+			childPath := fmt.Sprintf("%s[%d]", path, count)
+			if value == '{' {
+				keyToken, err := decoder.Token()
+				if err != nil {
+					return err
+				}
+				key, _ := keyToken.(string)
+				childPath = joinJSONPath(path, key)
+			}
+			if err := flattenJSONValue(decoder, childPath, out); err != nil {
+				return err
+			}
+		}
+		if _, err := decoder.Token(); err != nil { // Закрывающая скобка
+			return err
+		}
+		if count == 0 {
+			empty := "[]"
+			if value == '{' {
+				empty = "{}"
+			}
+			writeJSONLine(out, path, empty)
+		}
+	case string:
+		// Перевод строки внутри значения оторвал бы его продолжение от пути
+		writeJSONLine(out, path, strings.Join(strings.Fields(value), " "))
+	case json.Number:
+		writeJSONLine(out, path, value.String())
+	case bool:
+		writeJSONLine(out, path, strconv.FormatBool(value))
+	case nil:
+		writeJSONLine(out, path, "null")
+	}
+	return nil
+}
+
+func joinJSONPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func writeJSONLine(out *strings.Builder, path, value string) {
+	if path != "" {
+		out.WriteString(path)
+		out.WriteString(": ")
+	}
+	out.WriteString(value)
+	out.WriteString("\n")
+}
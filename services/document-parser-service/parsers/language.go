@@ -0,0 +1,173 @@
+package parsers
+
+import (
+	"strings"
+	"unicode"
+)
+
+// languageSampleLetters - сколько букв текста учитывается при определении языка: длинный документ
+// не замедляет разбор, а язык начала документа почти всегда совпадает с основным
+const languageSampleLetters = 20000
+
+// languageMinLetters - меньше букв для уверенного определения языка недостаточно
+const languageMinLetters = 20
+
+// languageScripts - письменности, по которым язык определяется сразу (или уточняется отдельно)
+var languageScripts = []struct {
+	table *unicode.RangeTable
+	code  string
+}{
+	{unicode.Latin, "latin"},
+	{unicode.Cyrillic, "cyrillic"},
+	{unicode.Arabic, "arabic"},
+	{unicode.Han, "han"},
+	{unicode.Hiragana, "kana"},
+	{unicode.Katakana, "kana"},
+	{unicode.Greek, "el"},
+	{unicode.Hebrew, "he"},
+	{unicode.Hangul, "ko"},
+	{unicode.Thai, "th"},
+	{unicode.Devanagari, "hi"},
+	{unicode.Armenian, "hy"},
+	{unicode.Georgian, "ka"},
+}
+
+// latinStopwords - частые служебные слова языков на латинице
+var latinStopwords = map[string][]string{
+	"en": {"the", "and", "of", "to", "is", "in", "that", "for", "with", "you", "are", "this", "it", "on", "be"},
+	"de": {"der", "die", "und", "das", "ist", "nicht", "mit", "den", "ein", "eine", "auf", "für", "sich", "auch", "von"},
+	"fr": {"le", "la", "les", "et", "des", "est", "une", "pour", "dans", "que", "qui", "pas", "sur", "avec", "du"},
+	"es": {"el", "los", "las", "y", "es", "una", "para", "por", "con", "que", "del", "se", "como", "más", "pero"},
+	"it": {"il", "di", "che", "è", "per", "una", "non", "sono", "della", "gli", "con", "anche", "del", "nel", "alla"},
+	"pt": {"o", "os", "as", "e", "é", "um", "uma", "para", "com", "não", "que", "do", "da", "em", "mais"},
+	"nl": {"de", "het", "een", "en", "is", "van", "niet", "dat", "met", "voor", "zijn", "op", "ook", "naar", "je"},
+	"pl": {"i", "w", "nie", "się", "na", "jest", "to", "że", "do", "z", "jak", "co", "ale", "dla", "od"},
+}
+
+// latinMinStopwords - меньше совпадений со служебными словами могут оказаться случайными
+const latinMinStopwords = 3
+
+// latinStopwordIndex - обратный индекс latinStopwords: слово -> языки, в которых оно служебное
+var latinStopwordIndex = func() map[string][]string {
+	index := make(map[string][]string)
+	for lang, words := range latinStopwords {
+		for _, word := range words {
+			index[word] = append(index[word], lang)
+		}
+	}
+	return index
+}()
+
+// DetectLanguage возвращает код ISO 639-1 основного языка текста или "", если определить его не удалось.
+// Письменность определяет язык сразу (греческий, иврит, корейский...), а для латиницы, кириллицы,
+// арабского письма и иероглифов язык уточняется по служебным словам и характерным буквам.
+func DetectLanguage(text string) string {
+	counts := make(map[string]int)
+	letters := 0
+	for _, r := range text {
+		if letters >= languageSampleLetters {
+			break
+		}
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		for _, script := range languageScripts {
+			if unicode.Is(script.table, r) {
+				counts[script.code]++
+				break
+			}
+		}
+	}
+	if letters < languageMinLetters {
+		return ""
+	}
+
+	dominant, best := "", 0
+	for code, count := range counts {
+		if count > best || (count == best && code < dominant) {
+			dominant, best = code, count
+		}
+	}
+	switch dominant {
+	case "latin":
+		return detectLatinLanguage(text)
+	case "cyrillic":
+		return detectCyrillicLanguage(text)
+	case "arabic":
+		// Буквы персидского алфавита, которых нет в арабском
+		if strings.ContainsAny(text, "پچژگ") {
+			return "fa"
+		}
+		return "ar"
+	case "han", "kana":
+		// Японский текст пишется иероглифами вперемешку с каной
+		if counts["kana"]*10 >= counts["han"] {
+			return "ja"
+		}
+		return "zh"
+	}
+	return dominant
+}
+
+// detectLatinLanguage выбирает язык на латинице с наибольшим числом служебных слов
+func detectLatinLanguage(text string) string {
+	scores := make(map[string]int)
+	words := 0
+	for _, word := range strings.FieldsFunc(sampleText(text), func(r rune) bool { return !unicode.IsLetter(r) }) {
+		words++
+		for _, lang := range latinStopwordIndex[strings.ToLower(word)] {
+			scores[lang]++
+		}
+	}
+	lang, best := "", 0
+	for code, score := range scores {
+		if score > best || (score == best && code < lang) {
+			lang, best = code, score
+		}
+	}
+	// Служебные слова составляют заметную долю любого связного текста; иначе это список имён или кодов
+	if best < latinMinStopwords || best*20 < words {
+		return ""
+	}
+	return lang
+}
+
+// detectCyrillicLanguage отличает языки на кириллице по буквам, которых нет в русском.
+// Болгарский узнаётся по частым ъ и щ при полном отсутствии ы и э
+func detectCyrillicLanguage(text string) string {
+	sample := strings.ToLower(sampleText(text))
+	count := func(letters string) int {
+		n := 0
+		for _, r := range letters {
+			n += strings.Count(sample, string(r))
+		}
+		return n
+	}
+	letters := len([]rune(sample))
+	switch {
+	case count("јљњћџђ")*200 >= letters:
+		return "sr"
+	case count("әғқңөұүһ")*200 >= letters:
+		return "kk"
+	case count("ў")*200 >= letters:
+		return "be"
+	case count("іїєґ")*100 >= letters:
+		return "uk"
+	case count("ыэ") == 0 && count("ъщ")*100 >= letters:
+		return "bg"
+	}
+	return "ru"
+}
+
+// sampleText обрезает текст примерно до languageSampleLetters символов по границе руны
+func sampleText(text string) string {
+	runes := 0
+	for i := range text {
+		if runes == languageSampleLetters {
+			return text[:i]
+		}
+		runes++
+	}
+	return text
+}
@@ -0,0 +1,113 @@
+package parsers
+
+import (
+	"strings"
+)
+
+// markdownHeadingSeparator соединяет заголовки разных уровней в путь раздела ("Установка > Docker")
+const markdownHeadingSeparator = " > "
+
+// Section - раздел документа: путь заголовков и текст под ним
+type Section struct {
+	Heading string `json:"heading,omitempty"` // Пусто у текста до первого заголовка
+	Text    string `json:"text"`
+}
+
+// splitMarkdownSections делит markdown по ATX-заголовкам (# ... ######).
+// Heading раздела - путь от заголовка верхнего уровня, так что у "### Docker" внутри "## Установка"
+// он равен "Установка > Docker". Строки внутри блоков кода (``` и ~~~) заголовками не считаются.
+// Разделы без текста (например, "## Глава" сразу перед "### Подглава") пропускаются,
+// их заголовки остаются только в пути вложенных разделов.
+func splitMarkdownSections(text string) []Section {
+	var sections []Section
+	var headings [6]string // Текущий заголовок каждого уровня
+	var body strings.Builder
+	heading := ""
+	fence := "" // Открывающая последовательность текущего блока кода
+
+	flush := func() {
+		if content := strings.TrimSpace(body.String()); content != "" {
+			sections = append(sections, Section{Heading: heading, Text: content})
+		}
+		body.Reset()
+	}
+
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if marker := codeFenceMarker(trimmed); marker != "" {
+			switch {
+			case fence == "":
+				fence = marker
+			case strings.HasPrefix(marker, fence) && trimmed == marker:
+				fence = ""
+			}
+		} else if fence == "" {
+			if level, title, ok := markdownHeading(line); ok {
+				flush()
+				headings[level-1] = title
+				for i := level; i < len(headings); i++ {
+					headings[i] = ""
+				}
+				heading = joinHeadings(headings[:level])
+				continue
+			}
+		}
+		body.WriteString(line)
+		body.WriteString("\n")
+	}
+	flush()
+	return sections
+}
+
+// markdownHeading разбирает строку ATX-заголовка: "## Заголовок ##" -> 2, "Заголовок"
+func markdownHeading(line string) (int, string, bool) {
+	// Отступ в 4 пробела и больше - это блок кода
+	trimmed := strings.TrimLeft(line, " ")
+	if len(line)-len(trimmed) > 3 {
+		return 0, "", false
+	}
+	level := 0
+	for level < len(trimmed) && trimmed[level] == '#' {
+		level++
+	}
+	if level == 0 || level > 6 {
+		return 0, "", false
+	}
+	rest := trimmed[level:]
+	if rest != "" && rest[0] != ' ' && rest[0] != '\t' {
+		return 0, "", false // "#хэштег" - не заголовок
+	}
+	title := strings.TrimSpace(rest)
+	// Закрывающие # допустимы, если отделены пробелом
+	if closed := strings.TrimRight(title, "#"); closed == "" || strings.HasSuffix(closed, " ") {
+		title = strings.TrimSpace(closed)
+	}
+	if title == "" {
+		return 0, "", false
+	}
+	return level, title, true
+}
+
+// codeFenceMarker возвращает последовательность ``` или ~~~ в начале строки блока кода
+func codeFenceMarker(trimmed string) string {
+	for _, c := range []byte{'`', '~'} {
+		n := 0
+		for n < len(trimmed) && trimmed[n] == c {
+			n++
+		}
+		if n >= 3 {
+			return trimmed[:n]
+		}
+	}
+	return ""
+}
+
+func joinHeadings(headings []string) string {
+	var path []string
+	for _, h := range headings {
+		if h != "" {
+			path = append(path, h)
+		}
+	}
+	return strings.Join(path, markdownHeadingSeparator)
+}
@@ -0,0 +1,163 @@
+package parsers
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// Пороговые значения раскладки страницы в долях размера шрифта (em)
+const (
+	lineToleranceEm = 0.5 // Глифы, чьи Y отличаются меньше, стоят в одной строке
+	wordGapEm       = 0.15
+	columnGapEm     = 1.2 // Промежуток шире - граница ячейки таблицы
+	defaultFontSize = 10.0
+)
+
+// tableCellSeparator разделяет ячейки строк таблицы в извлечённом тексте
+const tableCellSeparator = " | "
+
+// errNoGlyphWidths - у шрифтов страницы нет ширин глифов (часто у CID-шрифтов), промежутки между
+// глифами не вычислить, и раскладка по координатам невозможна
+var errNoGlyphWidths = errors.New("glyph widths are unknown")
+
+// pdfLine - строка страницы: глифы с близкой координатой Y
+type pdfLine struct {
+	y      float64
+	glyphs []pdf.Text
+}
+
+// pageLayoutText извлекает текст страницы по координатам глифов.
+// Строки собираются слева направо; широкие промежутки между глифами делят строку на ячейки.
+// Две и более идущие подряд строки с несколькими ячейками считаются таблицей и выводятся
+// с разделителем " | ", так что строка прайс-листа остаётся одной строкой текста.
+// Остальные строки выводятся как обычный текст. Без ширин глифов возвращает errNoGlyphWidths.
+func pageLayoutText(page pdf.Page) (text string, err error) {
+	// Библиотека сообщает о битом содержимом страницы паникой
+	defer func() {
+		if r := recover(); r != nil {
+			text, err = "", fmt.Errorf("%v", r)
+		}
+	}()
+
+	glyphs := page.Content().Text
+	if !hasGlyphWidths(glyphs) {
+		return "", errNoGlyphWidths
+	}
+	lines := groupLines(glyphs)
+	rows := make([][]string, len(lines))
+	for i, line := range lines {
+		rows[i] = splitCells(line.glyphs)
+	}
+
+	var out strings.Builder
+	for i := 0; i < len(rows); {
+		// Таблица - серия из двух и более строк с несколькими ячейками
+		end := i
+		for end < len(rows) && len(rows[end]) > 1 {
+			end++
+		}
+		if end-i >= 2 {
+			for _, row := range rows[i:end] {
+				out.WriteString(strings.Join(row, tableCellSeparator))
+				out.WriteString("\n")
+			}
+			i = end
+			continue
+		}
+		if len(rows[i]) > 0 {
+			out.WriteString(strings.Join(rows[i], " "))
+			out.WriteString("\n")
+		}
+		i++
+	}
+	return out.String(), nil
+}
+
+// hasGlyphWidths сообщает, известна ли ширина большинства видимых глифов
+func hasGlyphWidths(glyphs []pdf.Text) bool {
+	visible, measured := 0, 0
+	for _, g := range glyphs {
+		if strings.TrimSpace(g.S) == "" {
+			continue
+		}
+		visible++
+		if g.W > 0 {
+			measured++
+		}
+	}
+	return visible > 0 && measured*2 >= visible
+}
+
+// groupLines собирает глифы в строки сверху вниз, глифы каждой строки - слева направо
+func groupLines(glyphs []pdf.Text) []pdfLine {
+	sorted := make([]pdf.Text, 0, len(glyphs))
+	for _, g := range glyphs {
+		if g.S != "" {
+			sorted = append(sorted, g)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Y != sorted[j].Y {
+			return sorted[i].Y > sorted[j].Y
+		}
+		return sorted[i].X < sorted[j].X
+	})
+
+	var lines []pdfLine
+	for _, g := range sorted {
+		if n := len(lines); n > 0 && math.Abs(lines[n-1].y-g.Y) <= lineToleranceEm*fontSize(g) {
+			lines[n-1].glyphs = append(lines[n-1].glyphs, g)
+			continue
+		}
+		lines = append(lines, pdfLine{y: g.Y, glyphs: []pdf.Text{g}})
+	}
+	for _, line := range lines {
+		sort.SliceStable(line.glyphs, func(i, j int) bool { return line.glyphs[i].X < line.glyphs[j].X })
+	}
+	return lines
+}
+
+// splitCells склеивает глифы строки в слова и делит их на ячейки по широким промежуткам.
+// Пробелы входят в промежуток, поэтому таблицы, выровненные пробелами, тоже делятся на ячейки.
+func splitCells(glyphs []pdf.Text) []string {
+	var cells []string
+	var cell strings.Builder
+	end := 0.0 // Правый край предыдущего видимого глифа
+	space := false
+	for _, g := range glyphs {
+		if strings.TrimSpace(g.S) == "" {
+			space = true
+			continue
+		}
+		if cell.Len() > 0 {
+			gap := g.X - end
+			switch {
+			case gap > columnGapEm*fontSize(g):
+				cells = append(cells, cell.String())
+				cell.Reset()
+			case gap > wordGapEm*fontSize(g) || space:
+				cell.WriteByte(' ')
+			}
+		}
+		cell.WriteString(g.S)
+		end = g.X + g.W
+		space = false
+	}
+	if cell.Len() > 0 {
+		cells = append(cells, cell.String())
+	}
+	return cells
+}
+
+// fontSize возвращает размер шрифта глифа; у отражённых матриц он отрицательный, у некоторых PDF - нулевой
+func fontSize(g pdf.Text) float64 {
+	if size := math.Abs(g.FontSize); size > 0 {
+		return size
+	}
+	return defaultFontSize
+}
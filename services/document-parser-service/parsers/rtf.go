@@ -0,0 +1,227 @@
+package parsers
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// rtfSkippedDestinations - группы RTF, которые не содержат текста документа
+// (таблицы шрифтов и стилей, метаданные, картинки, встроенные объекты)
+var rtfSkippedDestinations = map[string]bool{
+	"fonttbl": true, "colortbl": true, "stylesheet": true, "info": true, "pict": true, "object": true,
+	"listtable": true, "listoverridetable": true, "rsidtbl": true, "generator": true, "filetbl": true,
+	"revtbl": true, "themedata": true, "colorschememapping": true, "latentstyles": true, "datastore": true,
+	"xmlnstbl": true, "header": true, "headerl": true, "headerr": true, "headerf": true,
+	"footer": true, "footerl": true, "footerr": true, "footerf": true, "fldinst": true,
+}
+
+// rtfSymbols - управляющие слова, которые выводят текст
+var rtfSymbols = map[string]string{
+	"par": "\n", "line": "\n", "sect": "\n", "page": "\n", "row": "\n",
+	"tab": "\t", "cell": "\t",
+	"emdash": "—", "endash": "–", "bullet": "•",
+	"lquote": "‘", "rquote": "’", "ldblquote": "“", "rdblquote": "”",
+	"emspace": " ", "enspace": " ", "qmspace": " ",
+}
+
+// rtfCodepages - кодировки \ansicpgN для байтов \'hh
+var rtfCodepages = map[int]*charmap.Charmap{
+	866:   charmap.CodePage866,
+	1250:  charmap.Windows1250,
+	1251:  charmap.Windows1251,
+	1252:  charmap.Windows1252,
+	1253:  charmap.Windows1253,
+	1254:  charmap.Windows1254,
+	1255:  charmap.Windows1255,
+	1256:  charmap.Windows1256,
+	1257:  charmap.Windows1257,
+	1258:  charmap.Windows1258,
+	10000: charmap.Macintosh,
+}
+
+// rtfGroup - состояние группы {...}
+type rtfGroup struct {
+	skip bool // Группа не выводится (см. rtfSkippedDestinations и \*)
+	uc   int  // Сколько символов замены следует за \uN
+}
+
+// rtfReader извлекает текст из RTF: управляющие слова отбрасываются, служебные группы пропускаются
+type rtfReader struct {
+	data     []byte
+	pos      int
+	out      strings.Builder
+	group    rtfGroup
+	stack    []rtfGroup
+	codepage *charmap.Charmap
+	skipNext int // Оставшиеся символы замены после \uN
+}
+
+func (p *DocumentParser) parseRTF(content []byte) (string, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(content, " \t\r\n\ufeff"), []byte(`{\rtf`)) {
+		return "", fmt.Errorf("файл не является RTF документом")
+	}
+	r := &rtfReader{data: content, group: rtfGroup{uc: 1}, codepage: charmap.Windows1252}
+	if err := r.read(); err != nil {
+		return "", err
+	}
+	return cleanRTFText(r.out.String()), nil
+}
+
+func (r *rtfReader) read() error {
+	for r.pos < len(r.data) {
+		c := r.data[r.pos]
+		r.pos++
+		switch c {
+		case '{':
+			r.stack = append(r.stack, r.group)
+		case '}':
+			if len(r.stack) == 0 {
+				return fmt.Errorf("повреждённый RTF: лишняя закрывающая скобка")
+			}
+			r.group = r.stack[len(r.stack)-1]
+			r.stack = r.stack[:len(r.stack)-1]
+			r.skipNext = 0
+		case '\\':
+			r.control()
+		case '\r', '\n':
+			// Переводы строк в исходнике RTF не относятся к тексту
+		default:
+			r.text(c)
+		}
+	}
+	if len(r.stack) > 0 {
+		return fmt.Errorf("повреждённый RTF: не закрыто групп: %d", len(r.stack))
+	}
+	return nil
+}
+
+// control разбирает управляющее слово или символ после обратной косой черты
+func (r *rtfReader) control() {
+	if r.pos >= len(r.data) {
+		return
+	}
+	c := r.data[r.pos]
+	if !isASCIILetter(c) {
+		r.pos++
+		switch c {
+		case '\\', '{', '}':
+			r.text(c)
+		case '\'':
+			r.hexByte()
+		case '~':
+			r.write(" ")
+		case '_':
+			r.write("-")
+		case '*':
+			r.group.skip = true
+		case '\r', '\n':
+			r.write("\n")
+		}
+		return
+	}
+
+	start := r.pos
+	for r.pos < len(r.data) && isASCIILetter(r.data[r.pos]) {
+		r.pos++
+	}
+	word := string(r.data[start:r.pos])
+	numStart := r.pos
+	if r.pos < len(r.data) && r.data[r.pos] == '-' {
+		r.pos++
+	}
+	for r.pos < len(r.data) && r.data[r.pos] >= '0' && r.data[r.pos] <= '9' {
+		r.pos++
+	}
+	param, hasParam := 0, r.pos > numStart
+	if hasParam {
+		param, _ = strconv.Atoi(string(r.data[numStart:r.pos]))
+	}
+	// Один пробел после управляющего слова - разделитель, а не текст
+	if r.pos < len(r.data) && r.data[r.pos] == ' ' {
+		r.pos++
+	}
+
+	switch {
+	case rtfSkippedDestinations[word]:
+		r.group.skip = true
+	case word == "u" && hasParam:
+		if param < 0 {
+			param += 65536
+		}
+		r.write(string(rune(param)))
+		r.skipNext = r.group.uc
+	case word == "uc" && hasParam:
+		r.group.uc = param
+	case word == "ansicpg" && hasParam:
+		if cm, ok := rtfCodepages[param]; ok {
+			r.codepage = cm
+		}
+	default:
+		if symbol, ok := rtfSymbols[word]; ok {
+			r.write(symbol)
+		}
+	}
+}
+
+// hexByte разбирает \'hh - символ в кодировке документа
+func (r *rtfReader) hexByte() {
+	if r.pos+2 > len(r.data) {
+		r.pos = len(r.data)
+		return
+	}
+	b, err := strconv.ParseUint(string(r.data[r.pos:r.pos+2]), 16, 8)
+	r.pos += 2
+	if err != nil {
+		return
+	}
+	r.text(byte(b))
+}
+
+// text выводит байт текста, декодируя символы вне ASCII по кодовой странице документа
+func (r *rtfReader) text(c byte) {
+	if c < 0x80 {
+		r.write(string(rune(c)))
+		return
+	}
+	r.write(string(r.codepage.DecodeByte(c)))
+}
+
+// write добавляет текст, если группа выводится и символы замены \uN уже пропущены
+func (r *rtfReader) write(s string) {
+	if r.group.skip {
+		return
+	}
+	if r.skipNext > 0 {
+		r.skipNext--
+		return
+	}
+	r.out.WriteString(s)
+}
+
+func isASCIILetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// cleanRTFText убирает пробелы по краям строк и схлопывает серии пустых строк
+func cleanRTFText(text string) string {
+	lines := strings.Split(text, "\n")
+	var cleaned []string
+	blank := false
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			if !blank && len(cleaned) > 0 {
+				cleaned = append(cleaned, "")
+			}
+			blank = true
+			continue
+		}
+		cleaned = append(cleaned, line)
+		blank = false
+	}
+	return strings.TrimSpace(strings.Join(cleaned, "\n"))
+}
@@ -7,7 +7,7 @@ import json
 from typing import TYPE_CHECKING
 
 from app.models.schemas import AskRequest
-from app.services.rag_service import rag_service
+from app.services.rag_service import rag_service, UnknownEmbeddingModelError
 from app.config.settings import settings
 
 if TYPE_CHECKING:
@@ -22,7 +22,8 @@ SERVICE_INFO = {
     "version": "2.0.0",
     "model": settings.gguf_model_path or "NOT CONFIGURED",
     "embedding_model": settings.embedding_model_name or "NOT CONFIGURED",
-    "capabilities": ["llm_generation", "embeddings"]
+    "embedding_models_allowed": settings.embedding_models_allowed,
+    "capabilities": ["llm_generation", "embeddings", "query_rewrite"]
 }
 
 
@@ -131,23 +132,46 @@ def embeddings_endpoint(payload: dict = Body(...)):
     """Создание эмбеддингов для списка текстов."""
     texts = payload.get("texts")
     is_query = payload.get("is_query", False)  # Для e5 моделей: query vs passage
+    model_name = payload.get("model") or None  # Модель бота; по умолчанию EMBEDDING_MODEL_NAME
+    use_prefix = payload.get("use_prefix")  # Префиксы query/passage; None - по имени модели (e5)
     
     if not isinstance(texts, list) or not texts:
         raise HTTPException(status_code=400, detail="texts is required and must be a non-empty list")
+    if use_prefix is not None and not isinstance(use_prefix, bool):
+        raise HTTPException(status_code=400, detail="use_prefix must be a boolean")
     try:
-        vectors = rag_service.create_embeddings(texts, is_query=is_query)
+        vectors = rag_service.create_embeddings(texts, is_query=is_query, model_name=model_name, use_prefix=use_prefix)
         return {"embeddings": vectors}
+    except UnknownEmbeddingModelError as e:
+        raise HTTPException(status_code=400, detail=str(e))
     except Exception as e:
         raise HTTPException(status_code=500, detail=f"Ошибка при создании embeddings: {str(e)}")
 
 
+@router.post("/rewrite-query")
+def rewrite_query_endpoint(payload: dict = Body(...)):
+    """
+    Переписывание запроса перед поиском: исправление опечаток, раскрытие сокращений и коротких запросов.
+    Backend вызывает его до эмбеддинга запроса, если у бота включён rewrite_query.
+    """
+    query = payload.get("query")
+    if not isinstance(query, str) or not query.strip():
+        raise HTTPException(status_code=400, detail="query is required")
+
+    try:
+        rewritten = model_service.rewrite_query(query.strip())
+        return {"query": rewritten, "original": query}
+    except Exception as e:
+        raise HTTPException(status_code=500, detail=f"Ошибка переписывания запроса: {str(e)}")
+
+
 @router.post("/advanced-search")
 def advanced_search_endpoint(payload: dict = Body(...)):
     """
     🚀 Продвинутый универсальный поиск
     
     Использует:
-    - Hybrid Search (Vector + BM25)
+    - Dense retrieval results from Qdrant (BM25 is no longer used)
     - Cross-Encoder Reranking
     """
     bot_id = payload.get("bot_id")
@@ -35,6 +35,10 @@ class Settings(BaseSettings):
     # Embeddings для RAG
     embedding_model_name: str | None = os.getenv("EMBEDDING_MODEL_NAME")
     embedding_cache_folder: str | None = os.getenv("EMBEDDING_CACHE_FOLDER")
+    # Дополнительные модели, которые боты могут выбрать вместо EMBEDDING_MODEL_NAME (через запятую)
+    embedding_models_allowed: list[str] = [
+        name.strip() for name in os.getenv("EMBEDDING_MODELS_ALLOWED", "").split(",") if name.strip()
+    ]
     
     # Reranker для точного переранжирования
     reranker_model_name: str = os.getenv("RERANKER_MODEL_NAME", "cross-encoder/ms-marco-MiniLM-L-6-v2")
@@ -2,6 +2,7 @@
 Сервис для работы с GGUF моделями через llama.cpp
 Оптимизировано для CPU - в 10-20 раз быстрее PyTorch
 """
+import re
 import threading
 from pathlib import Path
 from typing import Iterator, Optional, Any, List, Dict
@@ -15,6 +16,13 @@ except ImportError:
 
 from app.config.settings import settings
 
+# Инструкция переписывания запроса перед поиском (см. rewrite_query)
+QUERY_REWRITE_PROMPT = (
+    "Rewrite the user's search query for document retrieval: fix spelling mistakes, expand abbreviations "
+    "and make very short queries more specific. Keep the language and the meaning of the query. "
+    "Reply with the rewritten query only, on one line, without explanations."
+)
+
 
 class ModelServiceGGUF:
     """Сервис для работы с GGUF моделями (CPU оптимизированный)"""
@@ -113,6 +121,39 @@ class ModelServiceGGUF:
         
         return output['choices'][0]['text'].strip()
     
+    def rewrite_query(self, query: str, max_new_tokens: int = 64) -> str:
+        """
+        Переписывает поисковый запрос перед эмбеддингом: исправляет опечатки и раскрывает короткие запросы.
+        Генерация жадная, без системного промпта ответов. Если модель вернула пустую строку
+        или текст намного длиннее запроса, возвращается исходный запрос.
+        """
+        model = self.load_model()
+
+        prompt = (
+            f"<|im_start|>system\n{QUERY_REWRITE_PROMPT}<|im_end|>\n"
+            f"<|im_start|>user\n{query}<|im_end|>\n"
+            "<|im_start|>assistant\n"
+        )
+        output = model(
+            prompt,
+            stream=False,
+            stop=self._stop_sequences,
+            max_tokens=max_new_tokens,
+            temperature=0.0,
+            top_p=1.0,
+            top_k=-1,
+        )
+        text = output['choices'][0]['text']
+        # Блок рассуждений (в том числе не закрытый из-за лимита токенов) не относится к запросу
+        text = re.sub(r"<think>.*?(</think>|$)", "", text, flags=re.DOTALL)
+        lines = [line.strip() for line in text.splitlines() if line.strip()]
+        if not lines:
+            return query
+        rewritten = lines[0].strip("\"'`«» ")
+        if not rewritten or len(rewritten) > max(4 * len(query), 200):
+            return query
+        return rewritten
+
     def generate_response_stream(
         self,
         messages: List[Dict[str, str]],
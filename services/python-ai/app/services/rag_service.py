@@ -23,6 +23,12 @@ def clean_pdf_artifacts(text: str) -> str:
     return text.strip()
 
 
+class UnknownEmbeddingModelError(ValueError):
+    """Модель не входит в EMBEDDING_MODEL_NAME / EMBEDDING_MODELS_ALLOWED"""
+
+    def __init__(self, model_name: str):
+        super().__init__(f"embedding model {model_name!r} is not allowed")
+
 
 class RAGService:
     """
@@ -33,25 +39,34 @@ class RAGService:
     """
     
     def __init__(self):
-        self._embedding_model = None
+        self._embedding_models: Dict[str, SentenceTransformer] = {}
         self._reranker_model = None
         self._lock = threading.Lock()
     
-    def load_embedding_model(self) -> SentenceTransformer:
-        """Загрузить модель для embeddings (singleton)"""
+    def resolve_embedding_model(self, model_name: Optional[str] = None) -> str:
+        """Имя модели для embeddings: EMBEDDING_MODEL_NAME по умолчанию или одна из EMBEDDING_MODELS_ALLOWED"""
+        if not settings.embedding_model_name:
+            raise ValueError("EMBEDDING_MODEL_NAME is not configured")
+        if not model_name or model_name == settings.embedding_model_name:
+            return settings.embedding_model_name
+        if model_name not in settings.embedding_models_allowed:
+            raise UnknownEmbeddingModelError(model_name)
+        return model_name
+
+    def load_embedding_model(self, model_name: Optional[str] = None) -> SentenceTransformer:
+        """Загрузить модель для embeddings (одна копия на модель)"""
+        name = self.resolve_embedding_model(model_name)
         with self._lock:
-            if self._embedding_model is None:
-                if not settings.embedding_model_name:
-                    raise ValueError("EMBEDDING_MODEL_NAME is not configured")
+            if name not in self._embedding_models:
                 if not settings.embedding_cache_folder:
                     raise ValueError("EMBEDDING_CACHE_FOLDER is not configured")
                 
-                self._embedding_model = SentenceTransformer(
-                    settings.embedding_model_name,
+                self._embedding_models[name] = SentenceTransformer(
+                    name,
                     cache_folder=settings.embedding_cache_folder
                 )
-                print(f"✅ Embedding model loaded: {settings.embedding_model_name}")
-            return self._embedding_model
+                print(f"✅ Embedding model loaded: {name}")
+            return self._embedding_models[name]
     
     def load_reranker_model(self) -> Optional[CrossEncoder]:
         """Загрузить Cross-Encoder reranker (singleton)"""
@@ -66,19 +81,29 @@ class RAGService:
                     return None
             return self._reranker_model
     
-    def create_embeddings(self, texts: List[str], is_query: bool = False) -> List[List[float]]:
+    def create_embeddings(
+        self,
+        texts: List[str],
+        is_query: bool = False,
+        model_name: Optional[str] = None,
+        use_prefix: Optional[bool] = None,
+    ) -> List[List[float]]:
         """
         Создать векторные представления для текстов
         
         Args:
             texts: Список текстов
             is_query: True если это запрос (для некоторых моделей добавляется префикс)
+            model_name: Модель бота; None - EMBEDDING_MODEL_NAME
+            use_prefix: Добавлять префиксы "query: "/"passage: "; None - только для e5 моделей
         """
-        embedding_model = self.load_embedding_model()
+        model_name = self.resolve_embedding_model(model_name)
+        embedding_model = self.load_embedding_model(model_name)
         
-        # Для multilingual-e5 моделей добавляем префикс
-        model_name = settings.embedding_model_name or ""
-        if "e5" in model_name.lower():
+        # Для multilingual-e5 моделей добавляем префикс, если бот не задал это явно
+        if use_prefix is None:
+            use_prefix = "e5" in model_name.lower()
+        if use_prefix:
             if is_query:
                 texts = [f"query: {text}" for text in texts]
             else:
@@ -253,8 +278,9 @@ class RAGService:
             for i, doc in enumerate(reranked[:top_k]):
                 score = doc.get('rerank_score', 0)
                 text_preview = doc.get('text', '')[:100].replace('\n', ' ')
-                file_name = doc.get('file_name', 'unknown')
-                chunk_idx = doc.get('chunk_index', '?')
+                metadata = doc.get('metadata') or {}
+                file_name = metadata.get('file_name', 'unknown')
+                chunk_idx = metadata.get('chunk_index', '?')
                 print(f"   #{i+1}: score={score:7.4f} | {file_name}[{chunk_idx}] | \"{text_preview}...\"")
             
             return reranked[:top_k]
@@ -2,7 +2,9 @@ package handlers
 
 import (
 	"context"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
 	"time"
 
 	"vector-db-service/models"
@@ -11,14 +13,32 @@ import (
 	"github.com/gofiber/fiber/v2"
 )
 
+// MaxSearchLimit is the largest search limit accepted; larger requests are rejected rather than truncated.
+// The backend validates its limits against the same bound.
+const MaxSearchLimit = 500
+
+// defaultFallbackLimit is how many chunks a search that finds nothing returns unless the request sets fallback_limit
+const defaultFallbackLimit = 256
+
 type VectorDBHandler struct {
-	qdrant *services.QdrantService
+	qdrant       *services.QdrantService
+	defaultLimit int // Search limit used when a request has none (RAG_MAX_RESULTS, shared with the backend)
 }
 
-func NewVectorDBHandler(qdrant *services.QdrantService) *VectorDBHandler {
+func NewVectorDBHandler(qdrant *services.QdrantService, defaultLimit int) *VectorDBHandler {
 	return &VectorDBHandler{
-		qdrant: qdrant,
+		qdrant:       qdrant,
+		defaultLimit: defaultLimit,
+	}
+}
+
+// errorStatus maps service errors to HTTP statuses: mixing embedding models is a conflict, anything else a server error
+func errorStatus(err error) int {
+	var mismatch *services.ModelMismatchError
+	if errors.As(err, &mismatch) {
+		return fiber.StatusConflict
 	}
+	return fiber.StatusInternalServerError
 }
 
 func (h *VectorDBHandler) EnsureCollection(c *fiber.Ctx) error {
@@ -37,7 +57,7 @@ func (h *VectorDBHandler) EnsureCollection(c *fiber.Ctx) error {
 	}
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
-	if err := h.qdrant.EnsureCollection(ctx, req.BotID); err != nil {
+	if err := h.qdrant.EnsureCollection(ctx, req.BotID, 0); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(models.Response{
 			Success: false,
 			Error:   err.Error(),
@@ -65,9 +85,9 @@ func (h *VectorDBHandler) AddDocuments(c *fiber.Ctx) error {
 	}
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
-	docIDs, err := h.qdrant.AddDocuments(ctx, req.BotID, req.Texts, req.Embeddings, req.Metadata)
+	docIDs, err := h.qdrant.AddDocuments(ctx, req.BotID, req.EmbeddingModel, req.Texts, req.Embeddings, req.Metadata, req.Wait)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(models.Response{
+		return c.Status(errorStatus(err)).JSON(models.Response{
 			Success: false,
 			Error:   err.Error(),
 		})
@@ -75,9 +95,9 @@ func (h *VectorDBHandler) AddDocuments(c *fiber.Ctx) error {
 	return c.JSON(models.Response{
 		Success: true,
 		Message: "Documents added",
-		Data: fiber.Map{
-			"doc_ids": docIDs,
-			"count":   len(docIDs),
+		Data: models.AddResponse{
+			DocIDs: docIDs,
+			Count:  len(docIDs),
 		},
 	})
 }
@@ -91,9 +111,7 @@ func (h *VectorDBHandler) SearchDocuments(c *fiber.Ctx) error {
 		})
 	}
 
-	// Debug logging
-	log.Printf("[VectorDB Search] bot_id: %q, limit: %d, embedding_len: %d",
-		req.BotID, req.Limit, len(req.QueryEmbedding))
+	slog.Debug("Search", "bot_id", req.BotID, "limit", req.Limit, "embedding_len", len(req.QueryEmbedding))
 
 	if len(req.QueryEmbedding) == 0 {
 		return c.Status(fiber.StatusBadRequest).JSON(models.Response{
@@ -102,39 +120,227 @@ func (h *VectorDBHandler) SearchDocuments(c *fiber.Ctx) error {
 		})
 	}
 
+	limit := req.Limit
+	if limit <= 0 {
+		limit = h.defaultLimit
+	}
+	if limit > MaxSearchLimit {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{
+			Success: false,
+			Error:   fmt.Sprintf("limit %d exceeds the maximum of %d", limit, MaxSearchLimit),
+		})
+	}
+	uploaded := services.UploadRange{After: req.UploadedAfter, Before: req.UploadedBefore}
+	if uploaded.After != nil && uploaded.Before != nil && !uploaded.After.Before(*uploaded.Before) {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{
+			Success: false,
+			Error:   "uploaded_after must be before uploaded_before",
+		})
+	}
+	fallbackLimit := defaultFallbackLimit
+	if req.FallbackLimit != nil {
+		fallbackLimit = *req.FallbackLimit
+	}
+	if fallbackLimit < 0 || fallbackLimit > MaxSearchLimit {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{
+			Success: false,
+			Error:   fmt.Sprintf("fallback_limit must be between 0 and %d", MaxSearchLimit),
+		})
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
 	// Use vector similarity search; fallback to full scan if empty
-	limit := req.Limit
-	if limit <= 0 {
-		limit = 20
-	}
-	results, err := h.qdrant.SearchDocuments(ctx, req.BotID, req.QueryEmbedding, uint64(limit))
+	results, err := h.qdrant.SearchDocuments(ctx, req.BotID, req.EmbeddingModel, req.QueryEmbedding, uint64(limit), req.Fields, uploaded)
 	if err != nil {
-		log.Printf("[VectorDB Search] Error: %v", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(models.Response{
+		slog.Error("Search failed", "bot_id", req.BotID, "error", err)
+		return c.Status(errorStatus(err)).JSON(models.Response{
 			Success: false,
 			Error:   err.Error(),
 		})
 	}
-	if len(results) == 0 {
-		all, fallbackErr := h.qdrant.GetAllDocuments(ctx, req.BotID)
+	// Listed documents ignore the upload range, so a date-restricted search gets no fallback
+	if len(results) == 0 && fallbackLimit > 0 && uploaded.IsZero() {
+		all, _, fallbackErr := h.qdrant.ListDocuments(ctx, req.BotID, fallbackLimit, "")
 		if fallbackErr == nil {
+			services.ProjectFields(all, req.Fields)
+			// Not relevance hits: flagged so callers don't rank them like search results
+			for i := range all {
+				all[i].FromFallback = true
+			}
 			results = all
-			log.Printf("[VectorDB Search] Fallback to full collection, got %d docs", len(results))
+			slog.Debug("Search found nothing, falling back to the first documents", "bot_id", req.BotID, "documents", len(results))
 		}
 	}
-	log.Printf("[VectorDB Search] Found %d results for bot_id: %q (vector search)", len(results), req.BotID)
+	slog.Debug("Search results", "bot_id", req.BotID, "results", len(results))
 	return c.JSON(models.Response{
 		Success: true,
-		Data: fiber.Map{
-			"documents": results,
-			"count":     len(results),
+		Data: models.SearchResponse{
+			Documents: results,
+			Count:     len(results),
 		},
 	})
 }
 
+// MigrateCollection replaces a bot's collection with one built from re-embedded documents, e.g. for an
+// embedding model with another dimension
+func (h *VectorDBHandler) MigrateCollection(c *fiber.Ctx) error {
+	botID := c.Params("bot_id")
+	if botID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{
+			Success: false,
+			Error:   "bot_id is required",
+		})
+	}
+	var req models.MigrateCollectionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{
+			Success: false,
+			Error:   "Invalid request body",
+		})
+	}
+	if len(req.Texts) != len(req.Embeddings) || len(req.Texts) != len(req.Metadata) {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{
+			Success: false,
+			Error:   "texts, embeddings and metadata must have the same length",
+		})
+	}
+	// Rebuilds the whole collection, so it gets as long as the rest of a large upload
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	result, err := h.qdrant.MigrateCollection(ctx, botID, req.EmbeddingModel, req.Dimension, req.Texts, req.Embeddings, req.Metadata)
+	if err != nil {
+		status := fiber.StatusInternalServerError
+		if errors.Is(err, services.ErrInvalidDimension) {
+			status = fiber.StatusBadRequest
+		}
+		return c.Status(status).JSON(models.Response{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	return c.JSON(models.Response{
+		Success: true,
+		Message: "Collection migrated",
+		Data:    result,
+	})
+}
+
+// stagingErrorStatus maps staging errors to HTTP statuses: a bad collection name or dimension is a client error
+func stagingErrorStatus(err error) int {
+	if errors.Is(err, services.ErrInvalidStagingCollection) || errors.Is(err, services.ErrInvalidDimension) {
+		return fiber.StatusBadRequest
+	}
+	return fiber.StatusInternalServerError
+}
+
+// CreateStagingCollection creates an empty collection that re-embedded documents of a bot are written to
+// in batches before it replaces the bot's collection
+func (h *VectorDBHandler) CreateStagingCollection(c *fiber.Ctx) error {
+	botID := c.Params("bot_id")
+	var req models.CreateStagingRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{
+			Success: false,
+			Error:   "Invalid request body",
+		})
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	collection, err := h.qdrant.CreateStagingCollection(ctx, botID, req.Dimension)
+	if err != nil {
+		return c.Status(stagingErrorStatus(err)).JSON(models.Response{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	return c.JSON(models.Response{
+		Success: true,
+		Message: "Staging collection created",
+		Data:    models.StagingResponse{Collection: collection},
+	})
+}
+
+// AddStagingDocuments writes a batch of re-embedded documents to a staging collection of the bot
+func (h *VectorDBHandler) AddStagingDocuments(c *fiber.Ctx) error {
+	botID, collection := c.Params("bot_id"), c.Params("collection")
+	var req models.StagingDocumentsRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{
+			Success: false,
+			Error:   "Invalid request body",
+		})
+	}
+	if len(req.IDs) != len(req.Texts) || len(req.Texts) != len(req.Embeddings) || len(req.Texts) != len(req.Metadata) {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{
+			Success: false,
+			Error:   "ids, texts, embeddings and metadata must have the same length",
+		})
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+	if err := h.qdrant.AddStagingDocuments(ctx, botID, collection, req.EmbeddingModel, req.IDs, req.Texts, req.Embeddings, req.Metadata); err != nil {
+		return c.Status(stagingErrorStatus(err)).JSON(models.Response{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	return c.JSON(models.Response{
+		Success: true,
+		Message: "Documents added",
+		Data: models.AddResponse{
+			DocIDs: req.IDs,
+			Count:  len(req.IDs),
+		},
+	})
+}
+
+// PromoteStagingCollection swaps a staging collection in as the bot's collection
+func (h *VectorDBHandler) PromoteStagingCollection(c *fiber.Ctx) error {
+	botID, collection := c.Params("bot_id"), c.Params("collection")
+	var req models.PromoteStagingRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.Response{
+				Success: false,
+				Error:   "Invalid request body",
+			})
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	result, err := h.qdrant.PromoteStagingCollection(ctx, botID, collection, req.EmbeddingModel)
+	if err != nil {
+		return c.Status(stagingErrorStatus(err)).JSON(models.Response{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	return c.JSON(models.Response{
+		Success: true,
+		Message: "Collection migrated",
+		Data:    result,
+	})
+}
+
+// DropStagingCollection deletes a staging collection of the bot that won't be promoted
+func (h *VectorDBHandler) DropStagingCollection(c *fiber.Ctx) error {
+	botID, collection := c.Params("bot_id"), c.Params("collection")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := h.qdrant.DropStagingCollection(ctx, botID, collection); err != nil {
+		return c.Status(stagingErrorStatus(err)).JSON(models.Response{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	return c.JSON(models.Response{
+		Success: true,
+		Message: "Staging collection deleted",
+	})
+}
+
 func (h *VectorDBHandler) DeleteDocuments(c *fiber.Ctx) error {
 	botID := c.Params("bot_id")
 	if botID == "" {
@@ -145,6 +351,19 @@ func (h *VectorDBHandler) DeleteDocuments(c *fiber.Ctx) error {
 	}
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
+	// ?file_name= deletes one file's points instead of the collection; ?keep_version= spares that version of it
+	if fileName := c.Query("file_name"); fileName != "" {
+		if err := h.qdrant.DeleteFileDocuments(ctx, botID, fileName, c.Query("keep_version")); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(models.Response{
+				Success: false,
+				Error:   err.Error(),
+			})
+		}
+		return c.JSON(models.Response{
+			Success: true,
+			Message: "File documents deleted",
+		})
+	}
 	if err := h.qdrant.DeleteDocuments(ctx, botID); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(models.Response{
 			Success: false,
@@ -181,6 +400,40 @@ func (h *VectorDBHandler) GetStats(c *fiber.Ctx) error {
 	})
 }
 
+// GetStatsByFile returns chunk and character counts per indexed file of a bot
+func (h *VectorDBHandler) GetStatsByFile(c *fiber.Ctx) error {
+	botID := c.Params("bot_id")
+	if botID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(models.Response{
+			Success: false,
+			Error:   "bot_id is required",
+		})
+	}
+	// Scrolls the whole collection, so large bots get more time than single-page calls
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+	files, err := h.qdrant.GetStatsByFile(ctx, botID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.Response{
+			Success: false,
+			Error:   err.Error(),
+		})
+	}
+	totalChunks := 0
+	for _, f := range files {
+		totalChunks += f.Chunks
+	}
+	return c.JSON(models.Response{
+		Success: true,
+		Data: models.FileStatsResponse{
+			BotID:       botID,
+			Files:       files,
+			TotalFiles:  len(files),
+			TotalChunks: totalChunks,
+		},
+	})
+}
+
 func (h *VectorDBHandler) ListDocuments(c *fiber.Ctx) error {
 	botID := c.Params("bot_id")
 	if botID == "" {
@@ -192,18 +445,24 @@ func (h *VectorDBHandler) ListDocuments(c *fiber.Ctx) error {
 	limit := c.QueryInt("limit", 10)
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
-	documents, err := h.qdrant.ListDocuments(ctx, botID, limit)
+	documents, nextCursor, err := h.qdrant.ListDocuments(ctx, botID, limit, c.Query("cursor"))
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(models.Response{
+		status := fiber.StatusInternalServerError
+		if errors.Is(err, services.ErrInvalidCursor) {
+			status = fiber.StatusBadRequest
+		}
+		return c.Status(status).JSON(models.Response{
 			Success: false,
 			Error:   err.Error(),
 		})
 	}
+	// next_cursor is passed back as ?cursor= to get the next page; it is empty after the last page
 	return c.JSON(models.Response{
 		Success: true,
-		Data: fiber.Map{
-			"documents": documents,
-			"count":     len(documents),
+		Data: models.ListResponse{
+			Documents:  documents,
+			Count:      len(documents),
+			NextCursor: nextCursor,
 		},
 	})
 }
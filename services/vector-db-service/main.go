@@ -4,19 +4,21 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"log/slog"
 	"os"
 	"os/signal"
 	"runtime"
+	"strconv"
 	"syscall"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/limiter"
-	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/recover"
 
 	"vector-db-service/handlers"
+	"vector-db-service/logging"
 	"vector-db-service/services"
 )
 
@@ -24,6 +26,12 @@ func main() {
 	// Optimize for multi-core
 	runtime.GOMAXPROCS(runtime.NumCPU())
 
+	// Same variables and defaults as the backend
+	logFormat := envOr("LOG_FORMAT", logging.FormatText)
+	if err := logging.Setup(envOr("LOG_LEVEL", "info"), logFormat); err != nil {
+		log.Fatalf("Invalid logging configuration: %v", err)
+	}
+
 	// Load configuration from environment
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -55,18 +63,45 @@ func main() {
 		corsHeaders = "Origin, Content-Type, Accept"
 	}
 
+	startupTimeout := 60 * time.Second
+	if value := os.Getenv("QDRANT_STARTUP_TIMEOUT"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			log.Fatalf("Invalid QDRANT_STARTUP_TIMEOUT %q: %v", value, err)
+		}
+		startupTimeout = parsed
+	}
+
+	// Same variable and default as the backend, so both services agree on the search limit
+	searchLimit := 100
+	if value := os.Getenv("RAG_MAX_RESULTS"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 || parsed > handlers.MaxSearchLimit {
+			log.Fatalf("Invalid RAG_MAX_RESULTS %q: must be between 1 and %d", value, handlers.MaxSearchLimit)
+		}
+		searchLimit = parsed
+	}
+
 	qdrantService, err := services.NewQdrantService(qdrantHost, qdrantPort)
 	if err != nil {
 		log.Fatalf("Failed to connect to Qdrant: %v", err)
 	}
 	defer qdrantService.Close()
 
+	// The gRPC connection is lazy: wait until Qdrant actually answers instead of starting up "healthy"
+	readyCtx, readyCancel := context.WithTimeout(context.Background(), startupTimeout)
+	qdrantVersion, err := qdrantService.WaitReady(readyCtx, 2*time.Second)
+	readyCancel()
+	if err != nil {
+		log.Fatalf("❌ Qdrant at %s:%s is unreachable after %s: %v", qdrantHost, qdrantPort, startupTimeout, err)
+	}
+
 	app := fiber.New(fiber.Config{
 		AppName:               "Vector DB Service",
 		ServerHeader:          "Vector-DB",
-		DisableStartupMessage: false,
-		BodyLimit:             50 * 1024 * 1024, // 50MB
-		Prefork:               false,            // Disabled for Docker
+		DisableStartupMessage: logFormat == logging.FormatJSON, // The banner isn't a log line
+		BodyLimit:             50 * 1024 * 1024,                // 50MB
+		Prefork:               false,                           // Disabled for Docker
 		ReadTimeout:           60 * time.Second,
 		WriteTimeout:          60 * time.Second,
 		IdleTimeout:           120 * time.Second,
@@ -76,9 +111,7 @@ func main() {
 	})
 
 	app.Use(recover.New())
-	app.Use(logger.New(logger.Config{
-		Format: "[${time}] ${status} - ${method} ${path} (${latency})\n",
-	}))
+	app.Use(logging.Middleware())
 
 	// Rate limiting
 	app.Use(limiter.New(limiter.Config{
@@ -95,7 +128,7 @@ func main() {
 		AllowHeaders: corsHeaders,
 	}))
 
-	handler := handlers.NewVectorDBHandler(qdrantService)
+	handler := handlers.NewVectorDBHandler(qdrantService, searchLimit)
 
 	app.Get("/", func(c *fiber.Ctx) error {
 		return c.JSON(fiber.Map{
@@ -105,20 +138,41 @@ func main() {
 		})
 	})
 
+	// Health reflects actual Qdrant reachability, so a broken Qdrant fails the container healthcheck
 	app.Get("/health", func(c *fiber.Ctx) error {
+		ctx, cancel := context.WithTimeout(c.UserContext(), 3*time.Second)
+		defer cancel()
+		version, err := qdrantService.HealthCheck(ctx)
+		if err != nil {
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+				"status":      "unhealthy",
+				"service":     "vector-db",
+				"qdrant_host": qdrantHost,
+				"qdrant_port": qdrantPort,
+				"error":       err.Error(),
+			})
+		}
 		return c.JSON(fiber.Map{
-			"status":      "healthy",
-			"service":     "vector-db",
-			"qdrant_host": qdrantHost,
-			"qdrant_port": qdrantPort,
+			"status":         "healthy",
+			"service":        "vector-db",
+			"qdrant_host":    qdrantHost,
+			"qdrant_port":    qdrantPort,
+			"qdrant_version": version,
 		})
 	})
 
 	app.Post("/collections/ensure", handler.EnsureCollection)
+	app.Post("/collections/migrate/:bot_id", handler.MigrateCollection)
+	// Batched re-embedding: create a staging collection, fill it, then swap it in (or drop it)
+	app.Post("/collections/staging/:bot_id", handler.CreateStagingCollection)
+	app.Post("/collections/staging/:bot_id/:collection/documents", handler.AddStagingDocuments)
+	app.Post("/collections/staging/:bot_id/:collection/promote", handler.PromoteStagingCollection)
+	app.Delete("/collections/staging/:bot_id/:collection", handler.DropStagingCollection)
 	app.Post("/documents/add", handler.AddDocuments)
 	app.Post("/documents/search", handler.SearchDocuments)
 	app.Delete("/documents/delete/:bot_id", handler.DeleteDocuments)
 	app.Get("/documents/stats/:bot_id", handler.GetStats)
+	app.Get("/documents/stats/:bot_id/by-file", handler.GetStatsByFile)
 	app.Get("/documents/list/:bot_id", handler.ListDocuments)
 
 	// Graceful shutdown
@@ -127,20 +181,32 @@ func main() {
 
 	go func() {
 		<-quit
-		log.Println("🛑 Gracefully shutting down Vector DB Service...")
+		slog.Info("Gracefully shutting down Vector DB Service")
 		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 		defer cancel()
 		if err := app.ShutdownWithContext(ctx); err != nil {
-			log.Printf("Shutdown error: %v", err)
+			slog.Error("Shutdown error", "error", err)
 		}
 	}()
 
-	log.Printf("🚀 Vector DB Service starting on port %s (CPUs: %d)", port, runtime.NumCPU())
-	log.Printf("📊 Connected to Qdrant at %s:%s", qdrantHost, qdrantPort)
-	log.Printf("   CORS origins: %s", corsOrigins)
+	slog.Info("Vector DB Service starting",
+		"port", port,
+		"cpus", runtime.NumCPU(),
+		"qdrant_version", qdrantVersion,
+		"qdrant", qdrantHost+":"+qdrantPort,
+		"cors_origins", corsOrigins,
+	)
 	if err := app.Listen(fmt.Sprintf(":%s", port)); err != nil {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 
-	log.Println("Server stopped gracefully")
+	slog.Info("Server stopped gracefully")
+}
+
+// envOr returns the environment variable key, or fallback when it is unset or empty
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
 }
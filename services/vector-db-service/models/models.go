@@ -1,16 +1,61 @@
 package models
 
+import "time"
+
 type AddDocumentsRequest struct {
 	BotID      string              `json:"bot_id"` // Changed from client_id to bot_id
 	Texts      []string            `json:"texts"`
 	Embeddings [][]float32         `json:"embeddings"`
 	Metadata   []map[string]string `json:"metadata"`
+	Wait       *bool               `json:"wait,omitempty"` // Return only once points are searchable; nil = QDRANT_UPSERT_WAIT
+	// Model the embeddings were created with ("" = AI service default); a collection holds one model only
+	EmbeddingModel string `json:"embedding_model,omitempty"`
 }
 
 type SearchRequest struct {
 	BotID          string    `json:"bot_id"` // Changed from client_id to bot_id
 	QueryEmbedding []float32 `json:"query_embedding"`
 	Limit          int       `json:"limit"`
+	Fields         []string  `json:"fields,omitempty"`          // Payload keys to return besides text; empty = all
+	EmbeddingModel string    `json:"embedding_model,omitempty"` // Must match the collection's model
+	FallbackLimit  *int      `json:"fallback_limit,omitempty"`  // Chunks listed when nothing is found; 0 = none, unset = 256
+	// Only points uploaded in [uploaded_after, uploaded_before) are searched (RFC3339); either bound may be omitted
+	UploadedAfter  *time.Time `json:"uploaded_after,omitempty"`
+	UploadedBefore *time.Time `json:"uploaded_before,omitempty"`
+}
+
+// MigrateCollectionRequest carries all documents of a bot re-embedded with the new model
+type MigrateCollectionRequest struct {
+	Texts          []string            `json:"texts"`
+	Embeddings     [][]float32         `json:"embeddings"`
+	Metadata       []map[string]string `json:"metadata"`
+	EmbeddingModel string              `json:"embedding_model,omitempty"`
+	Dimension      uint64              `json:"dimension,omitempty"` // Vector size of the new collection; 0 = that of the embeddings
+}
+
+// CreateStagingRequest creates an empty staging collection for re-embedded documents
+type CreateStagingRequest struct {
+	Dimension uint64 `json:"dimension"` // Vector size of the re-embedded documents
+}
+
+// StagingResponse is the data of a staging collection creation response
+type StagingResponse struct {
+	Collection string `json:"collection"` // Name to pass to the other staging endpoints
+}
+
+// StagingDocumentsRequest carries a batch of re-embedded documents for a staging collection; IDs are the ids
+// of the documents in the bot's current collection
+type StagingDocumentsRequest struct {
+	IDs            []string            `json:"ids"`
+	Texts          []string            `json:"texts"`
+	Embeddings     [][]float32         `json:"embeddings"`
+	Metadata       []map[string]string `json:"metadata"`
+	EmbeddingModel string              `json:"embedding_model,omitempty"`
+}
+
+// PromoteStagingRequest swaps a staging collection in as the bot's collection
+type PromoteStagingRequest struct {
+	EmbeddingModel string `json:"embedding_model,omitempty"` // Model the staged documents were embedded with
 }
 
 type EnsureCollectionRequest struct {
@@ -24,6 +69,53 @@ type Response struct {
 	Error   string      `json:"error,omitempty"`
 }
 
+// Document is a chunk returned by search and list: the point id, its text, its similarity score (0 for listed
+// chunks) and the other payload keys in Metadata. Source is where the chunk came from: its "source" payload
+// (page URL or file name) or, for chunks indexed before sources were stored, its file name.
+type Document struct {
+	ID           string            `json:"id"`
+	Text         string            `json:"text"`
+	Score        float32           `json:"score"`
+	Source       string            `json:"source,omitempty"`
+	Metadata     map[string]string `json:"metadata"`
+	FromFallback bool              `json:"from_fallback,omitempty"` // Listed in place of search results that found nothing
+}
+
+// AddResponse is the data of an /documents/add response: ids of the points written
+type AddResponse struct {
+	DocIDs []string `json:"doc_ids"`
+	Count  int      `json:"count"`
+}
+
+// SearchResponse is the data of a /documents/search response; when nothing matched, Documents holds
+// the first documents of the collection flagged FromFallback
+type SearchResponse struct {
+	Documents []Document `json:"documents"`
+	Count     int        `json:"count"`
+}
+
+// ListResponse is the data of a /documents/list page; NextCursor is empty after the last page
+type ListResponse struct {
+	Documents  []Document `json:"documents"`
+	Count      int        `json:"count"`
+	NextCursor string     `json:"next_cursor"`
+}
+
+// FileStats is the number of chunks and characters indexed from one file
+type FileStats struct {
+	FileName string `json:"file_name"`
+	Chunks   int    `json:"chunks"`
+	Chars    int    `json:"chars"`
+}
+
+// FileStatsResponse is the data of a /documents/stats/:bot_id/by-file response
+type FileStatsResponse struct {
+	BotID       string      `json:"bot_id"`
+	Files       []FileStats `json:"files"`
+	TotalFiles  int         `json:"total_files"`
+	TotalChunks int         `json:"total_chunks"`
+}
+
 type StatsResponse struct {
 	Success        bool   `json:"success"`
 	BotID          string `json:"bot_id"` // Changed from client_id
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	qdrant "github.com/qdrant/go-client/qdrant"
+)
+
+// embeddingModelKey is the payload key holding the embedding model a point was indexed with.
+// "" is the AI service default model, which is also what points indexed before models were tracked have.
+const embeddingModelKey = "embedding_model"
+
+// ModelMismatchError is returned when vectors of one embedding model are added to or searched in
+// a collection indexed with another: their similarities would be meaningless
+type ModelMismatchError struct {
+	Indexed   string
+	Requested string
+}
+
+func (e *ModelMismatchError) Error() string {
+	return fmt.Sprintf("collection is indexed with embedding model %s, not %s", modelName(e.Indexed), modelName(e.Requested))
+}
+
+func modelName(model string) string {
+	if model == "" {
+		return "default"
+	}
+	return fmt.Sprintf("%q", model)
+}
+
+// collectionModel returns the embedding model of a collection's points; ok is false while the collection is empty.
+// A known model is cached until the collection is deleted, since all its points share it.
+func (s *QdrantService) collectionModel(ctx context.Context, collectionName string) (string, bool, error) {
+	if model, ok := s.models.Load(collectionName); ok {
+		return model.(string), true, nil
+	}
+	limit := uint32(1)
+	scrollResult, err := s.pointsClient.Scroll(ctx, &qdrant.ScrollPoints{
+		CollectionName: collectionName,
+		Limit:          &limit,
+		WithPayload: &qdrant.WithPayloadSelector{
+			SelectorOptions: &qdrant.WithPayloadSelector_Include{
+				Include: &qdrant.PayloadIncludeSelector{Fields: []string{embeddingModelKey}},
+			},
+		},
+	})
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read collection embedding model: %w", err)
+	}
+	if len(scrollResult.Result) == 0 {
+		return "", false, nil
+	}
+	model := scrollResult.Result[0].Payload[embeddingModelKey].GetStringValue()
+	s.models.Store(collectionName, model)
+	return model, true, nil
+}
+
+// checkModel returns a *ModelMismatchError if the collection already holds vectors of another embedding model
+func (s *QdrantService) checkModel(ctx context.Context, collectionName, model string) error {
+	indexed, ok, err := s.collectionModel(ctx, collectionName)
+	if err != nil {
+		return err
+	}
+	if ok && indexed != model {
+		return &ModelMismatchError{Indexed: indexed, Requested: model}
+	}
+	return nil
+}
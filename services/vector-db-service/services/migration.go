@@ -0,0 +1,218 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	qdrant "github.com/qdrant/go-client/qdrant"
+)
+
+// ErrInvalidDimension is returned by MigrateCollection for embeddings that don't share one dimension
+var ErrInvalidDimension = errors.New("invalid dimension")
+
+// MigrationResult describes a collection rebuilt by MigrateCollection
+type MigrationResult struct {
+	Collection string `json:"collection"`         // New collection behind the bot's alias
+	Replaced   string `json:"replaced,omitempty"` // Collection that was dropped; empty if the bot had none
+	Dimension  uint64 `json:"dimension"`          // Vector size of the new collection
+	Points     int    `json:"points"`             // Points written to the new collection
+	Model      string `json:"embedding_model"`    // Embedding model of the new points ("" = AI service default)
+}
+
+// MigrateCollection rebuilds a bot's collection from re-embedded documents, e.g. after switching to an embedding
+// model with another dimension. The documents are written to a new collection, which then takes over the bot's
+// collection name as a Qdrant alias and the old collection is dropped. Searches keep using the old vectors until
+// the swap, so writes to the bot should be paused while the backend re-embeds and migrates.
+//
+// Swapping an alias is atomic. Bots whose collection predates migrations have a real collection under that name,
+// which must be deleted before the alias can be created; during that moment the bot looks empty.
+func (s *QdrantService) MigrateCollection(ctx context.Context, botID, model string, dimension uint64, texts []string, embeddings [][]float32, metadata []map[string]string) (*MigrationResult, error) {
+	for i, embedding := range embeddings {
+		if dimension == 0 {
+			dimension = uint64(len(embedding))
+		}
+		if uint64(len(embedding)) != dimension {
+			return nil, fmt.Errorf("%w: embedding %d has %d dimensions, expected %d", ErrInvalidDimension, i, len(embedding), dimension)
+		}
+	}
+	if dimension == 0 {
+		return nil, fmt.Errorf("%w: dimension is required when no embeddings are given", ErrInvalidDimension)
+	}
+
+	collectionName, err := s.CreateStagingCollection(ctx, botID, dimension)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.upsertPoints(ctx, collectionName, botID, model, nil, texts, embeddings, metadata, true); err != nil {
+		s.dropCollection(collectionName)
+		return nil, err
+	}
+	result, err := s.PromoteStagingCollection(ctx, botID, collectionName, model)
+	if err != nil {
+		s.dropCollection(collectionName)
+		return nil, err
+	}
+	return result, nil
+}
+
+// ErrInvalidStagingCollection is returned for a staging collection name that doesn't belong to the bot
+var ErrInvalidStagingCollection = errors.New("invalid staging collection")
+
+// CreateStagingCollection creates an empty collection that PromoteStagingCollection later swaps in as the bot's
+// collection. It lets a re-embedding be written in batches over several requests, and resumed after a failure.
+func (s *QdrantService) CreateStagingCollection(ctx context.Context, botID string, dimension uint64) (string, error) {
+	if dimension == 0 {
+		return "", fmt.Errorf("%w: dimension is required", ErrInvalidDimension)
+	}
+	collectionName := fmt.Sprintf("%s_%d", s.getCollectionName(botID), time.Now().UnixNano())
+	if err := s.createCollection(ctx, collectionName, dimension); err != nil {
+		return "", err
+	}
+	return collectionName, nil
+}
+
+// AddStagingDocuments writes re-embedded documents to a staging collection of the bot. The documents keep their
+// ids, so a batch written again after a failure replaces its points instead of duplicating them.
+func (s *QdrantService) AddStagingDocuments(ctx context.Context, botID, collectionName, model string, ids, texts []string, embeddings [][]float32, metadata []map[string]string) error {
+	if err := s.checkStagingCollection(botID, collectionName); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if _, err := uuid.Parse(id); err != nil {
+			return fmt.Errorf("%w: document id %q is not a UUID", ErrInvalidStagingCollection, id)
+		}
+	}
+	_, err := s.upsertPoints(ctx, collectionName, botID, model, ids, texts, embeddings, metadata, true)
+	return err
+}
+
+// PromoteStagingCollection makes a staging collection the bot's collection, dropping the one it replaces.
+// A failed swap leaves the staging collection in place, so promoting can be retried.
+func (s *QdrantService) PromoteStagingCollection(ctx context.Context, botID, collectionName, model string) (*MigrationResult, error) {
+	if err := s.checkStagingCollection(botID, collectionName); err != nil {
+		return nil, err
+	}
+	info, err := s.collectionsClient.Get(ctx, &qdrant.GetCollectionInfoRequest{CollectionName: collectionName})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get collection info: %w", err)
+	}
+	points := int(info.GetResult().GetPointsCount())
+	dimension := info.GetResult().GetConfig().GetParams().GetVectorsConfig().GetParams().GetSize()
+
+	alias := s.getCollectionName(botID)
+	replaced, err := s.swapAlias(ctx, alias, collectionName)
+	if err != nil {
+		return nil, err
+	}
+	if points > 0 {
+		s.models.Store(alias, model)
+	} else {
+		s.models.Delete(alias)
+	}
+	slog.Info("Collection migrated", "bot_id", botID, "points", points, "dimension", dimension, "collection", collectionName, "replaced", replaced)
+
+	return &MigrationResult{
+		Collection: collectionName,
+		Replaced:   replaced,
+		Dimension:  dimension,
+		Points:     points,
+		Model:      model,
+	}, nil
+}
+
+// DropStagingCollection deletes a staging collection of the bot that won't be promoted
+func (s *QdrantService) DropStagingCollection(ctx context.Context, botID, collectionName string) error {
+	if err := s.checkStagingCollection(botID, collectionName); err != nil {
+		return err
+	}
+	if _, err := s.collectionsClient.Delete(ctx, &qdrant.DeleteCollection{CollectionName: collectionName}); err != nil {
+		return fmt.Errorf("failed to delete collection %s: %w", collectionName, err)
+	}
+	return nil
+}
+
+// checkStagingCollection checks that collectionName is a staging collection name of the bot ("<alias>_<nanos>"),
+// so a request can't write to or drop another bot's collection
+func (s *QdrantService) checkStagingCollection(botID, collectionName string) error {
+	suffix, ok := strings.CutPrefix(collectionName, s.getCollectionName(botID)+"_")
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrInvalidStagingCollection, collectionName)
+	}
+	if _, err := strconv.ParseUint(suffix, 10, 64); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidStagingCollection, collectionName)
+	}
+	return nil
+}
+
+// swapAlias points alias at collectionName and drops the collection it replaced, which it returns
+func (s *QdrantService) swapAlias(ctx context.Context, alias, collectionName string) (string, error) {
+	previous, err := s.aliasTarget(ctx, alias)
+	if err != nil {
+		return "", err
+	}
+	actions := []*qdrant.AliasOperations{createAlias(alias, collectionName)}
+	if previous != "" {
+		// Deleting and re-creating the alias in one request switches searches over atomically
+		actions = append([]*qdrant.AliasOperations{{
+			Action: &qdrant.AliasOperations_DeleteAlias{DeleteAlias: &qdrant.DeleteAlias{AliasName: alias}},
+		}}, actions...)
+	} else {
+		// A collection can't share its name with an alias: a pre-alias collection is deleted first
+		exists, err := s.collectionsClient.CollectionExists(ctx, &qdrant.CollectionExistsRequest{CollectionName: alias})
+		if err != nil {
+			return "", fmt.Errorf("failed to check collection existence: %w", err)
+		}
+		if exists.GetResult().GetExists() {
+			if _, err := s.collectionsClient.Delete(ctx, &qdrant.DeleteCollection{CollectionName: alias}); err != nil {
+				return "", fmt.Errorf("failed to delete collection %s: %w", alias, err)
+			}
+			previous = alias
+		}
+	}
+	if _, err := s.collectionsClient.UpdateAliases(ctx, &qdrant.ChangeAliases{Actions: actions}); err != nil {
+		return "", fmt.Errorf("failed to switch alias %s to %s: %w", alias, collectionName, err)
+	}
+	if previous != "" && previous != alias {
+		if _, err := s.collectionsClient.Delete(ctx, &qdrant.DeleteCollection{CollectionName: previous}); err != nil {
+			// The bot already uses the new collection; the old one is only wasted space
+			slog.Warn("Failed to delete replaced collection", "collection", previous, "error", err)
+		}
+	}
+	return previous, nil
+}
+
+// createAlias is the alias action pointing alias at collectionName
+func createAlias(alias, collectionName string) *qdrant.AliasOperations {
+	return &qdrant.AliasOperations{
+		Action: &qdrant.AliasOperations_CreateAlias{CreateAlias: &qdrant.CreateAlias{CollectionName: collectionName, AliasName: alias}},
+	}
+}
+
+// aliasTarget returns the collection an alias points to, or "" if name is not an alias
+func (s *QdrantService) aliasTarget(ctx context.Context, name string) (string, error) {
+	aliases, err := s.collectionsClient.ListAliases(ctx, &qdrant.ListAliasesRequest{})
+	if err != nil {
+		return "", fmt.Errorf("failed to list aliases: %w", err)
+	}
+	for _, alias := range aliases.GetAliases() {
+		if alias.GetAliasName() == name {
+			return alias.GetCollectionName(), nil
+		}
+	}
+	return "", nil
+}
+
+// dropCollection removes a collection left behind by a failed migration
+func (s *QdrantService) dropCollection(collectionName string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := s.collectionsClient.Delete(ctx, &qdrant.DeleteCollection{CollectionName: collectionName}); err != nil {
+		slog.Warn("Failed to delete unfinished collection", "collection", collectionName, "error", err)
+	}
+}
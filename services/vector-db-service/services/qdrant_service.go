@@ -2,71 +2,128 @@ package services
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
+	"regexp"
+	"sort"
 	"strconv"
+	"sync"
 	"time"
+	"unicode/utf8"
+
+	"vector-db-service/models"
 
 	"github.com/google/uuid"
 	qdrant "github.com/qdrant/go-client/qdrant"
+	"golang.org/x/sync/errgroup"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/keepalive"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 // ...существующий код...
 
-// GetAllDocuments возвращает все документы коллекции для botID
-func (s *QdrantService) GetAllDocuments(ctx context.Context, botID string) ([]map[string]interface{}, error) {
-	collectionName := s.getCollectionName(botID)
-	exists, err := s.collectionsClient.CollectionExists(ctx, &qdrant.CollectionExistsRequest{
-		CollectionName: collectionName,
-	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to check collection: %w", err)
-	}
-	if exists.GetResult() == nil || !exists.GetResult().GetExists() {
-		return []map[string]interface{}{}, nil
-	}
-	// Scroll all points (no limit)
-	var results []map[string]interface{}
-	var nextPage *qdrant.PointId = nil
+// GetAllDocuments возвращает все документы коллекции для botID, читая её страницами по pageSize
+func (s *QdrantService) GetAllDocuments(ctx context.Context, botID string, pageSize int) ([]models.Document, error) {
+	var results []models.Document
+	cursor := ""
 	for {
-		scrollResult, err := s.pointsClient.Scroll(ctx, &qdrant.ScrollPoints{
-			CollectionName: collectionName,
-			WithPayload: &qdrant.WithPayloadSelector{
-				SelectorOptions: &qdrant.WithPayloadSelector_Enable{Enable: true},
-			},
-			Offset: nextPage,
-		})
+		page, next, err := s.ListDocuments(ctx, botID, pageSize, cursor)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scroll: %w", err)
+			return nil, err
 		}
-		for _, point := range scrollResult.Result {
-			result := map[string]interface{}{
-				"id": formatPointID(point.Id),
-			}
-			if point.Payload != nil {
-				for key, value := range point.Payload {
-					result[key] = value.GetStringValue()
-				}
-			}
-			results = append(results, result)
-		}
-		if scrollResult.NextPageOffset == nil {
-			break
+		results = append(results, page...)
+		if next == "" {
+			return results, nil
 		}
-		nextPage = scrollResult.NextPageOffset
+		cursor = next
 	}
-	return results, nil
 }
 
 type QdrantService struct {
 	conn               *grpc.ClientConn
+	qdrantClient       qdrant.QdrantClient
 	collectionsClient  qdrant.CollectionsClient
 	pointsClient       qdrant.PointsClient
 	embeddingDimension uint64
 	scoreThreshold     float32
+	collection         collectionParams
+	upsertWait         bool     // Default for AddDocuments: wait until upserted points are indexed
+	collectionPrefix   string   // QDRANT_COLLECTION_PREFIX: namespace of this deployment's collections ("" = none)
+	models             sync.Map // Collection name -> embedding model of its points (see collectionModel)
+}
+
+// collectionPrefixPattern keeps QDRANT_COLLECTION_PREFIX a valid part of a collection name
+var collectionPrefixPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]{0,63}$`)
+
+// collectionParams tunes indexing and storage of new collections; nil fields keep the Qdrant defaults
+type collectionParams struct {
+	hnswM             *uint64 // QDRANT_HNSW_M: edges per node; higher = better recall, more memory
+	hnswEfConstruct   *uint64 // QDRANT_HNSW_EF_CONSTRUCT: neighbours considered while building; higher = better recall, slower indexing
+	hnswOnDisk        *bool   // QDRANT_HNSW_ON_DISK: keep the HNSW graph on disk
+	onDiskVectors     *bool   // QDRANT_ON_DISK_VECTORS: serve vectors from disk (memmap) instead of RAM
+	onDiskPayload     *bool   // QDRANT_ON_DISK_PAYLOAD: keep payloads (chunk text) on disk
+	indexingThreshold *uint64 // QDRANT_INDEXING_THRESHOLD: segment size in KB above which the HNSW index is built
+	memmapThreshold   *uint64 // QDRANT_MEMMAP_THRESHOLD: segment size in KB above which vectors are memmapped
+}
+
+// loadCollectionParams reads collection tuning from the environment
+func loadCollectionParams() (collectionParams, error) {
+	var p collectionParams
+	var err error
+	if p.hnswM, err = envUint64("QDRANT_HNSW_M"); err != nil {
+		return p, err
+	}
+	if p.hnswEfConstruct, err = envUint64("QDRANT_HNSW_EF_CONSTRUCT"); err != nil {
+		return p, err
+	}
+	if p.hnswOnDisk, err = envBool("QDRANT_HNSW_ON_DISK"); err != nil {
+		return p, err
+	}
+	if p.onDiskVectors, err = envBool("QDRANT_ON_DISK_VECTORS"); err != nil {
+		return p, err
+	}
+	if p.onDiskPayload, err = envBool("QDRANT_ON_DISK_PAYLOAD"); err != nil {
+		return p, err
+	}
+	if p.indexingThreshold, err = envUint64("QDRANT_INDEXING_THRESHOLD"); err != nil {
+		return p, err
+	}
+	if p.memmapThreshold, err = envUint64("QDRANT_MEMMAP_THRESHOLD"); err != nil {
+		return p, err
+	}
+	return p, nil
+}
+
+// envUint64 returns nil when the variable is unset
+func envUint64(name string) (*uint64, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return nil, nil
+	}
+	parsed, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s %q: %w", name, value, err)
+	}
+	return &parsed, nil
+}
+
+// envBool returns nil when the variable is unset
+func envBool(name string) (*bool, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return nil, nil
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s %q: %w", name, value, err)
+	}
+	return &parsed, nil
 }
 
 func NewQdrantService(host, port string) (*QdrantService, error) {
@@ -88,10 +145,46 @@ func NewQdrantService(host, port string) (*QdrantService, error) {
 		}
 	}
 
+	collection, err := loadCollectionParams()
+	if err != nil {
+		return nil, err
+	}
+
+	// Waiting makes uploads slower (each batch returns only after Qdrant applied it) but documents are
+	// searchable as soon as the upload finishes; without it a chat right after an upload may miss them
+	upsertWait := true
+	if wait, err := envBool("QDRANT_UPSERT_WAIT"); err != nil {
+		return nil, err
+	} else if wait != nil {
+		upsertWait = *wait
+	}
+
+	// Environments sharing one Qdrant cluster (staging, prod) keep their collections apart with a prefix
+	collectionPrefix := os.Getenv("QDRANT_COLLECTION_PREFIX")
+	if collectionPrefix != "" && !collectionPrefixPattern.MatchString(collectionPrefix) {
+		return nil, fmt.Errorf("invalid QDRANT_COLLECTION_PREFIX %q: use up to 64 letters, digits, '_' or '-'", collectionPrefix)
+	}
+	if collectionPrefix != "" {
+		slog.Info("Collections are namespaced", "pattern", collectionPrefix+"_bot_<id>")
+	}
+
+	// Plaintext by default for local Docker; managed Qdrant (Qdrant Cloud) needs TLS and an API key
+	useTLS := false
+	if tlsStr := os.Getenv("QDRANT_USE_TLS"); tlsStr != "" {
+		parsed, err := strconv.ParseBool(tlsStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid QDRANT_USE_TLS %q: %w", tlsStr, err)
+		}
+		useTLS = parsed
+	}
+	transport := insecure.NewCredentials()
+	if useTLS {
+		transport = credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12, ServerName: host})
+	}
+
 	// Optimized gRPC connection with keepalive and connection pooling
-	conn, err := grpc.Dial(
-		addr,
-		grpc.WithInsecure(),
+	dialOptions := []grpc.DialOption{
+		grpc.WithTransportCredentials(transport),
 		grpc.WithKeepaliveParams(keepalive.ClientParameters{
 			Time:                30 * time.Second,
 			Timeout:             10 * time.Second,
@@ -101,20 +194,75 @@ func NewQdrantService(host, port string) (*QdrantService, error) {
 			grpc.MaxCallRecvMsgSize(100*1024*1024), // 100MB
 			grpc.MaxCallSendMsgSize(100*1024*1024),
 		),
-	)
+	}
+	if apiKey := os.Getenv("QDRANT_API_KEY"); apiKey != "" {
+		if !useTLS {
+			slog.Warn("QDRANT_API_KEY is set without QDRANT_USE_TLS: the key is sent in plaintext")
+		}
+		dialOptions = append(dialOptions, grpc.WithPerRPCCredentials(apiKeyCredentials{key: apiKey, requireTLS: useTLS}))
+	}
+
+	conn, err := grpc.Dial(addr, dialOptions...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Qdrant client: %w", err)
 	}
 
 	return &QdrantService{
 		conn:               conn,
+		qdrantClient:       qdrant.NewQdrantClient(conn),
 		collectionsClient:  qdrant.NewCollectionsClient(conn),
 		pointsClient:       qdrant.NewPointsClient(conn),
 		embeddingDimension: embeddingDim,
 		scoreThreshold:     scoreThreshold,
+		collection:         collection,
+		upsertWait:         upsertWait,
+		collectionPrefix:   collectionPrefix,
 	}, nil
 }
 
+// apiKeyCredentials sends the Qdrant API key in the "api-key" metadata of every call
+type apiKeyCredentials struct {
+	key        string
+	requireTLS bool
+}
+
+func (c apiKeyCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"api-key": c.key}, nil
+}
+
+func (c apiKeyCredentials) RequireTransportSecurity() bool {
+	return c.requireTLS
+}
+
+// HealthCheck calls the Qdrant health RPC and returns the server version.
+// grpc.Dial is lazy, so this is the first call that actually needs a connection.
+func (s *QdrantService) HealthCheck(ctx context.Context) (string, error) {
+	reply, err := s.qdrantClient.HealthCheck(ctx, &qdrant.HealthCheckRequest{})
+	if err != nil {
+		return "", fmt.Errorf("qdrant health check failed: %w", err)
+	}
+	return reply.GetVersion(), nil
+}
+
+// WaitReady retries the health check every interval until Qdrant answers or ctx is done
+func (s *QdrantService) WaitReady(ctx context.Context, interval time.Duration) (string, error) {
+	for attempt := 1; ; attempt++ {
+		checkCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		version, err := s.HealthCheck(checkCtx)
+		cancel()
+		if err == nil {
+			return version, nil
+		}
+		slog.Info("Qdrant is not ready", "attempt", attempt, "error", err)
+
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("qdrant not reachable after %d attempts: %w", attempt, err)
+		case <-time.After(interval):
+		}
+	}
+}
+
 // Close closes the gRPC connection
 func (s *QdrantService) Close() error {
 	if s.conn != nil {
@@ -139,12 +287,22 @@ func formatPointID(id *qdrant.PointId) string {
 	return strconv.FormatUint(id.GetNum(), 10)
 }
 
+// getCollectionName returns the collection (or alias, see MigrateCollection) of a bot: bot_<id>,
+// or <prefix>_bot_<id> with QDRANT_COLLECTION_PREFIX. Every method derives its collection from it.
 func (s *QdrantService) getCollectionName(botID string) string {
 	// Use bot_id instead of client_id for collection naming
+	if s.collectionPrefix != "" {
+		return fmt.Sprintf("%s_bot_%s", s.collectionPrefix, botID)
+	}
 	return fmt.Sprintf("bot_%s", botID)
 }
 
-func (s *QdrantService) EnsureCollection(ctx context.Context, botID string) error {
+// EnsureCollection creates the bot's collection if it doesn't exist yet, for vectors of the given
+// dimension (0 = QDRANT_COLLECTION_SIZE)
+func (s *QdrantService) EnsureCollection(ctx context.Context, botID string, dimension uint64) error {
+	if dimension == 0 {
+		dimension = s.embeddingDimension
+	}
 	collectionName := s.getCollectionName(botID)
 	exists, err := s.collectionsClient.CollectionExists(ctx, &qdrant.CollectionExistsRequest{
 		CollectionName: collectionName,
@@ -155,84 +313,167 @@ func (s *QdrantService) EnsureCollection(ctx context.Context, botID string) erro
 	if exists.GetResult() != nil && exists.GetResult().GetExists() {
 		return nil
 	}
-	_, err = s.collectionsClient.Create(ctx, &qdrant.CreateCollection{
+	return s.createCollection(ctx, collectionName, dimension)
+}
+
+// createCollection creates a collection for vectors of the given dimension with the configured index and storage params
+func (s *QdrantService) createCollection(ctx context.Context, collectionName string, dimension uint64) error {
+	create := &qdrant.CreateCollection{
 		CollectionName: collectionName,
 		VectorsConfig: &qdrant.VectorsConfig{
 			Config: &qdrant.VectorsConfig_Params{
 				Params: &qdrant.VectorParams{
-					Size:     s.embeddingDimension,
+					Size:     dimension,
 					Distance: qdrant.Distance_Cosine,
+					OnDisk:   s.collection.onDiskVectors,
 				},
 			},
 		},
-	})
-	if err != nil {
+		OnDiskPayload: s.collection.onDiskPayload,
+	}
+	p := s.collection
+	if p.hnswM != nil || p.hnswEfConstruct != nil || p.hnswOnDisk != nil {
+		create.HnswConfig = &qdrant.HnswConfigDiff{M: p.hnswM, EfConstruct: p.hnswEfConstruct, OnDisk: p.hnswOnDisk}
+	}
+	if p.indexingThreshold != nil || p.memmapThreshold != nil {
+		create.OptimizersConfig = &qdrant.OptimizersConfigDiff{IndexingThreshold: p.indexingThreshold, MemmapThreshold: p.memmapThreshold}
+	}
+	if _, err := s.collectionsClient.Create(ctx, create); err != nil {
 		return fmt.Errorf("failed to create collection: %w", err)
 	}
 	return nil
 }
 
-func (s *QdrantService) AddDocuments(ctx context.Context, botID string, texts []string, embeddings [][]float32, metadata []map[string]string) ([]string, error) {
-	if err := s.EnsureCollection(ctx, botID); err != nil {
+const (
+	upsertBatchSize   = 100 // Points per Upsert call
+	upsertConcurrency = 4   // Batches upserted at the same time
+)
+
+// AddDocuments upserts points and returns their ids in input order. wait overrides the
+// QDRANT_UPSERT_WAIT default (nil keeps it). A new collection is sized for the given embeddings;
+// embeddings of another model than the collection's are rejected with a *ModelMismatchError.
+func (s *QdrantService) AddDocuments(ctx context.Context, botID, model string, texts []string, embeddings [][]float32, metadata []map[string]string, wait *bool) ([]string, error) {
+	var dimension uint64
+	if len(embeddings) > 0 {
+		dimension = uint64(len(embeddings[0]))
+	}
+	if err := s.EnsureCollection(ctx, botID, dimension); err != nil {
 		return nil, err
 	}
 	collectionName := s.getCollectionName(botID)
+	if err := s.checkModel(ctx, collectionName, model); err != nil {
+		return nil, err
+	}
+	waitForIndex := s.upsertWait
+	if wait != nil {
+		waitForIndex = *wait
+	}
+	return s.upsertPoints(ctx, collectionName, botID, model, nil, texts, embeddings, metadata, waitForIndex)
+}
+
+// upsertPoints writes texts with their embeddings and metadata as points of a collection and returns the point ids
+// in input order. Points get new ids unless ids (UUIDs) are given, which makes writing the same points again idempotent.
+func (s *QdrantService) upsertPoints(ctx context.Context, collectionName, botID, model string, ids, texts []string, embeddings [][]float32, metadata []map[string]string, waitForIndex bool) ([]string, error) {
 	docIDs := make([]string, len(texts))
 	points := make([]*qdrant.PointStruct, len(texts))
 
-	// Process in parallel batches for better performance
-	const batchSize = 100
-	for i := 0; i < len(texts); i += batchSize {
-		end := i + batchSize
-		if end > len(texts) {
-			end = len(texts)
+	// Points (and their ids, in input order) are prepared up front; batches are then upserted concurrently
+	uploadDate := time.Now().UTC().Format(time.RFC3339)
+	for j := range texts {
+		docID := uuid.New().String()
+		if ids != nil {
+			docID = ids[j]
 		}
-
-		// Prepare points for this batch
-		for j := i; j < end; j++ {
-			docID := uuid.New().String()
-			docIDs[j] = docID
-			payload := map[string]*qdrant.Value{
-				"text": {
-					Kind: &qdrant.Value_StringValue{StringValue: texts[j]},
-				},
-				"bot_id": { // Changed from client_id to bot_id
-					Kind: &qdrant.Value_StringValue{StringValue: botID},
-				},
-				"upload_date": {
-					Kind: &qdrant.Value_StringValue{StringValue: time.Now().UTC().Format(time.RFC3339)},
-				},
-			}
-			for key, value := range metadata[j] {
-				payload[key] = &qdrant.Value{Kind: &qdrant.Value_StringValue{StringValue: value}}
-			}
-			points[j] = &qdrant.PointStruct{
-				Id: &qdrant.PointId{PointIdOptions: &qdrant.PointId_Uuid{Uuid: docID}},
-				Vectors: &qdrant.Vectors{
-					VectorsOptions: &qdrant.Vectors_Vector{
-						Vector: &qdrant.Vector{Data: embeddings[j]},
-					},
+		docIDs[j] = docID
+		payload := map[string]*qdrant.Value{
+			"text": {
+				Kind: &qdrant.Value_StringValue{StringValue: texts[j]},
+			},
+			"bot_id": { // Changed from client_id to bot_id
+				Kind: &qdrant.Value_StringValue{StringValue: botID},
+			},
+			"upload_date": {
+				Kind: &qdrant.Value_StringValue{StringValue: uploadDate},
+			},
+		}
+		for key, value := range metadata[j] {
+			payload[key] = &qdrant.Value{Kind: &qdrant.Value_StringValue{StringValue: value}}
+		}
+		payload[embeddingModelKey] = &qdrant.Value{Kind: &qdrant.Value_StringValue{StringValue: model}}
+		points[j] = &qdrant.PointStruct{
+			Id: &qdrant.PointId{PointIdOptions: &qdrant.PointId_Uuid{Uuid: docID}},
+			Vectors: &qdrant.Vectors{
+				VectorsOptions: &qdrant.Vectors_Vector{
+					Vector: &qdrant.Vector{Data: embeddings[j]},
 				},
-				Payload: payload,
-			}
+			},
+			Payload: payload,
 		}
+	}
 
-		// Upsert batch with context
-		batchCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
-		_, err := s.pointsClient.Upsert(batchCtx, &qdrant.UpsertPoints{
-			CollectionName: collectionName,
-			Points:         points[i:end],
-		})
-		cancel()
-		if err != nil {
-			return nil, fmt.Errorf("failed to upsert batch %d-%d: %w", i, end, err)
+	// The first failed batch cancels the ones still running; batches already written are not rolled back
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(upsertConcurrency)
+	for i := 0; i < len(points); i += upsertBatchSize {
+		start, end := i, i+upsertBatchSize
+		if end > len(points) {
+			end = len(points)
 		}
+		g.Go(func() error {
+			batchCtx, cancel := context.WithTimeout(gctx, 30*time.Second)
+			defer cancel()
+			_, err := s.pointsClient.Upsert(batchCtx, &qdrant.UpsertPoints{
+				CollectionName: collectionName,
+				Points:         points[start:end],
+				Wait:           &waitForIndex,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to upsert batch %d-%d: %w", start, end, err)
+			}
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
 	}
 
 	return docIDs, nil
 }
 
-func (s *QdrantService) SearchDocuments(ctx context.Context, botID string, queryEmbedding []float32, limit uint64) ([]map[string]interface{}, error) {
+// UploadRange restricts a search to points uploaded in [After, Before); a nil bound is open
+type UploadRange struct {
+	After  *time.Time
+	Before *time.Time
+}
+
+// IsZero reports whether the range has no bounds
+func (r UploadRange) IsZero() bool {
+	return r.After == nil && r.Before == nil
+}
+
+// filter returns a datetime range condition on the upload_date payload, or nil for an unbounded range
+func (r UploadRange) filter() *qdrant.Filter {
+	if r.IsZero() {
+		return nil
+	}
+	dates := &qdrant.DatetimeRange{}
+	if r.After != nil {
+		dates.Gte = timestamppb.New(*r.After)
+	}
+	if r.Before != nil {
+		dates.Lt = timestamppb.New(*r.Before)
+	}
+	return &qdrant.Filter{Must: []*qdrant.Condition{{
+		ConditionOneOf: &qdrant.Condition_Field{
+			Field: &qdrant.FieldCondition{Key: "upload_date", DatetimeRange: dates},
+		},
+	}}}
+}
+
+// SearchDocuments returns the closest points with their text and payload. With fields set, only those
+// payload keys (plus text) are fetched from Qdrant; uploaded limits the search to points uploaded in that range.
+// A query embedded with another model than the collection's is rejected with a *ModelMismatchError.
+func (s *QdrantService) SearchDocuments(ctx context.Context, botID, model string, queryEmbedding []float32, limit uint64, fields []string, uploaded UploadRange) ([]models.Document, error) {
 	collectionName := s.getCollectionName(botID)
 	exists, err := s.collectionsClient.CollectionExists(ctx, &qdrant.CollectionExistsRequest{
 		CollectionName: collectionName,
@@ -241,7 +482,10 @@ func (s *QdrantService) SearchDocuments(ctx context.Context, botID string, query
 		return nil, fmt.Errorf("failed to check collection: %w", err)
 	}
 	if exists.GetResult() == nil || !exists.GetResult().GetExists() {
-		return []map[string]interface{}{}, nil
+		return []models.Document{}, nil
+	}
+	if err := s.checkModel(ctx, collectionName, model); err != nil {
+		return nil, err
 	}
 	// Optimized search with optional score threshold
 	threshold := s.getScoreThreshold()
@@ -254,52 +498,118 @@ func (s *QdrantService) SearchDocuments(ctx context.Context, botID string, query
 		Vector:         queryEmbedding,
 		Limit:          limit,
 		ScoreThreshold: thresholdPtr,
-		WithPayload: &qdrant.WithPayloadSelector{
-			SelectorOptions: &qdrant.WithPayloadSelector_Enable{Enable: true},
-		},
+		Filter:         uploaded.filter(),
+		WithPayload:    payloadSelector(fields),
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to search: %w", err)
 	}
-	results := make([]map[string]interface{}, 0, len(searchResult.Result))
+	results := make([]models.Document, 0, len(searchResult.Result))
 	for i, point := range searchResult.Result {
-		result := map[string]interface{}{
-			"id":    formatPointID(point.Id),
-			"score": point.Score,
-		}
-		if point.Payload != nil {
-			if text, ok := point.Payload["text"]; ok {
-				textValue := text.GetStringValue()
-				result["text"] = textValue
-				// Log first 100 chars of each result with score
-				preview := textValue
-				if len(preview) > 100 {
-					preview = preview[:100]
-				}
-				log.Printf("[VectorDB] Result %d: score=%.4f, preview=%s...", i+1, point.Score, preview)
-			}
-			for key, value := range point.Payload {
-				if key != "text" && key != "bot_id" && key != "upload_date" {
-					result[key] = value.GetStringValue()
-				}
-			}
+		result := newDocument(point.Id, point.Score, point.Payload, searchHiddenKeys)
+		// Log first 100 chars of each result with score
+		preview := result.Text
+		if len(preview) > 100 {
+			preview = preview[:100]
 		}
+		slog.Debug("Search result", "rank", i+1, "score", point.Score, "preview", preview)
 		results = append(results, result)
 	}
 	return results, nil
 }
 
+// searchHiddenKeys are payload keys managed by the service itself, left out of search results
+var searchHiddenKeys = map[string]bool{"bot_id": true, "upload_date": true, embeddingModelKey: true}
+
+// newDocument converts a point into a Document; payload keys in hidden are left out of its metadata
+func newDocument(id *qdrant.PointId, score float32, payload map[string]*qdrant.Value, hidden map[string]bool) models.Document {
+	doc := models.Document{
+		ID:       formatPointID(id),
+		Text:     payload["text"].GetStringValue(),
+		Score:    score,
+		Metadata: make(map[string]string, len(payload)),
+	}
+	for key, value := range payload {
+		if key != "text" && !hidden[key] {
+			doc.Metadata[key] = value.GetStringValue()
+		}
+	}
+	doc.Source = documentSource(doc.Metadata)
+	return doc
+}
+
+// documentSource returns the "source" metadata of a chunk, or its file name for chunks indexed before
+// sources were stored
+func documentSource(metadata map[string]string) string {
+	if source := metadata["source"]; source != "" {
+		return source
+	}
+	return metadata["file_name"]
+}
+
+// DeleteDocuments deletes the bot's collection; for a migrated bot that is the collection behind its alias,
+// which takes the alias with it
 func (s *QdrantService) DeleteDocuments(ctx context.Context, botID string) error {
 	collectionName := s.getCollectionName(botID)
-	_, err := s.collectionsClient.Delete(ctx, &qdrant.DeleteCollection{
-		CollectionName: collectionName,
+	target, err := s.aliasTarget(ctx, collectionName)
+	if err != nil {
+		return err
+	}
+	if target == "" {
+		target = collectionName
+	}
+	_, err = s.collectionsClient.Delete(ctx, &qdrant.DeleteCollection{
+		CollectionName: target,
 	})
 	if err != nil {
 		return fmt.Errorf("failed to delete collection: %w", err)
 	}
+	s.models.Delete(collectionName)
+	return nil
+}
+
+// DeleteFileDocuments removes the points of one file of a bot. With keepVersion set, points whose "doc_version"
+// payload equals it are kept, so a re-uploaded file can replace its earlier versions after it is indexed.
+func (s *QdrantService) DeleteFileDocuments(ctx context.Context, botID, fileName, keepVersion string) error {
+	collectionName := s.getCollectionName(botID)
+	exists, err := s.collectionsClient.CollectionExists(ctx, &qdrant.CollectionExistsRequest{
+		CollectionName: collectionName,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to check collection: %w", err)
+	}
+	if exists.GetResult() == nil || !exists.GetResult().GetExists() {
+		return nil
+	}
+
+	filter := &qdrant.Filter{Must: []*qdrant.Condition{matchKeyword("file_name", fileName)}}
+	if keepVersion != "" {
+		filter.MustNot = []*qdrant.Condition{matchKeyword("doc_version", keepVersion)}
+	}
+	wait := true
+	_, err = s.pointsClient.Delete(ctx, &qdrant.DeletePoints{
+		CollectionName: collectionName,
+		Wait:           &wait,
+		Points:         &qdrant.PointsSelector{PointsSelectorOneOf: &qdrant.PointsSelector_Filter{Filter: filter}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete file points: %w", err)
+	}
 	return nil
 }
 
+// matchKeyword is a filter condition matching a string payload value exactly
+func matchKeyword(key, value string) *qdrant.Condition {
+	return &qdrant.Condition{
+		ConditionOneOf: &qdrant.Condition_Field{
+			Field: &qdrant.FieldCondition{
+				Key:   key,
+				Match: &qdrant.Match{MatchValue: &qdrant.Match_Keyword{Keyword: value}},
+			},
+		},
+	}
+}
+
 func (s *QdrantService) GetStats(ctx context.Context, botID string) (int, error) {
 	collectionName := s.getCollectionName(botID)
 	exists, err := s.collectionsClient.CollectionExists(ctx, &qdrant.CollectionExistsRequest{
@@ -323,7 +633,12 @@ func (s *QdrantService) GetStats(ctx context.Context, botID string) (int, error)
 	return int(info.GetResult().GetPointsCount()), nil
 }
 
-func (s *QdrantService) ListDocuments(ctx context.Context, botID string, limit int) ([]map[string]interface{}, error) {
+// statsPageSize is how many points are read per scroll call when aggregating stats
+const statsPageSize = 512
+
+// GetStatsByFile scrolls the collection and groups chunks by their file_name payload, sorted by file name.
+// Chunks without a file name are grouped under "".
+func (s *QdrantService) GetStatsByFile(ctx context.Context, botID string) ([]models.FileStats, error) {
 	collectionName := s.getCollectionName(botID)
 	exists, err := s.collectionsClient.CollectionExists(ctx, &qdrant.CollectionExistsRequest{
 		CollectionName: collectionName,
@@ -332,30 +647,134 @@ func (s *QdrantService) ListDocuments(ctx context.Context, botID string, limit i
 		return nil, fmt.Errorf("failed to check collection: %w", err)
 	}
 	if exists.GetResult() == nil || !exists.GetResult().GetExists() {
-		return []map[string]interface{}{}, nil
+		return []models.FileStats{}, nil
+	}
+
+	byFile := map[string]*models.FileStats{}
+	limit := uint32(statsPageSize)
+	var offset *qdrant.PointId
+	for {
+		scrollResult, err := s.pointsClient.Scroll(ctx, &qdrant.ScrollPoints{
+			CollectionName: collectionName,
+			Limit:          &limit,
+			Offset:         offset,
+			WithPayload:    payloadSelector([]string{"file_name"}),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to scroll: %w", err)
+		}
+		for _, point := range scrollResult.Result {
+			name := point.Payload["file_name"].GetStringValue()
+			stats, ok := byFile[name]
+			if !ok {
+				stats = &models.FileStats{FileName: name}
+				byFile[name] = stats
+			}
+			stats.Chunks++
+			stats.Chars += utf8.RuneCountInString(point.Payload["text"].GetStringValue())
+		}
+		if scrollResult.NextPageOffset == nil {
+			break
+		}
+		offset = scrollResult.NextPageOffset
+	}
+
+	files := make([]models.FileStats, 0, len(byFile))
+	for _, stats := range byFile {
+		files = append(files, *stats)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].FileName < files[j].FileName })
+	return files, nil
+}
+
+// ErrInvalidCursor is returned by ListDocuments for a cursor that is not a point id
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ListDocuments returns one page of up to limit documents starting at cursor ("" for the first page)
+// and the cursor of the next page, which is "" after the last page
+func (s *QdrantService) ListDocuments(ctx context.Context, botID string, limit int, cursor string) ([]models.Document, string, error) {
+	offset, err := parseCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	collectionName := s.getCollectionName(botID)
+	exists, err := s.collectionsClient.CollectionExists(ctx, &qdrant.CollectionExistsRequest{
+		CollectionName: collectionName,
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to check collection: %w", err)
+	}
+	if exists.GetResult() == nil || !exists.GetResult().GetExists() {
+		return []models.Document{}, "", nil
+	}
+	if limit <= 0 {
+		limit = 10
 	}
 	limitPtr := uint32(limit)
 	scrollResult, err := s.pointsClient.Scroll(ctx, &qdrant.ScrollPoints{
 		CollectionName: collectionName,
 		Limit:          &limitPtr,
+		Offset:         offset,
 		WithPayload: &qdrant.WithPayloadSelector{
 			SelectorOptions: &qdrant.WithPayloadSelector_Enable{Enable: true},
 		},
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to scroll: %w", err)
+		return nil, "", fmt.Errorf("failed to scroll: %w", err)
 	}
-	results := make([]map[string]interface{}, 0, len(scrollResult.Result))
+	results := make([]models.Document, 0, len(scrollResult.Result))
 	for _, point := range scrollResult.Result {
-		result := map[string]interface{}{
-			"id": formatPointID(point.Id),
+		// Listed points have no relevance: score 0
+		results = append(results, newDocument(point.Id, 0, point.Payload, nil))
+	}
+	return results, formatPointID(scrollResult.NextPageOffset), nil
+}
+
+// payloadSelector requests the whole payload, or only text and the given fields
+func payloadSelector(fields []string) *qdrant.WithPayloadSelector {
+	if len(fields) == 0 {
+		return &qdrant.WithPayloadSelector{
+			SelectorOptions: &qdrant.WithPayloadSelector_Enable{Enable: true},
 		}
-		if point.Payload != nil {
-			for key, value := range point.Payload {
-				result[key] = value.GetStringValue()
+	}
+	include := append([]string{"text"}, fields...)
+	return &qdrant.WithPayloadSelector{
+		SelectorOptions: &qdrant.WithPayloadSelector_Include{
+			Include: &qdrant.PayloadIncludeSelector{Fields: include},
+		},
+	}
+}
+
+// ProjectFields drops metadata keys of listed documents that are not in fields,
+// matching what SearchDocuments returns for the same fields
+func ProjectFields(docs []models.Document, fields []string) {
+	if len(fields) == 0 {
+		return
+	}
+	keep := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		keep[field] = true
+	}
+	for i := range docs {
+		for key := range docs[i].Metadata {
+			if !keep[key] {
+				delete(docs[i].Metadata, key)
 			}
 		}
-		results = append(results, result)
+		docs[i].Source = documentSource(docs[i].Metadata)
 	}
-	return results, nil
+}
+
+// parseCursor converts a cursor returned by ListDocuments back into a point id (nil for "")
+func parseCursor(cursor string) (*qdrant.PointId, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	if id, err := uuid.Parse(cursor); err == nil {
+		return &qdrant.PointId{PointIdOptions: &qdrant.PointId_Uuid{Uuid: id.String()}}, nil
+	}
+	if num, err := strconv.ParseUint(cursor, 10, 64); err == nil {
+		return &qdrant.PointId{PointIdOptions: &qdrant.PointId_Num{Num: num}}, nil
+	}
+	return nil, fmt.Errorf("%w %q", ErrInvalidCursor, cursor)
 }